@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	openai "github.com/openai/openai-go/v2"
+	"github.com/openai/openai-go/v2/option"
+)
+
+// ===================== Application Log =====================
+//
+// Log operacional em JSONL (uma linha por operação), separado do histórico de
+// conversas: não registra prompts nem respostas.
+
+type logEntry struct {
+	Time             string      `json:"time"`
+	Level            string      `json:"level"`
+	Mode             string      `json:"mode"`
+	Args             []string    `json:"args,omitempty"`
+	Profile          string      `json:"profile,omitempty"`
+	Persona          string      `json:"persona,omitempty"`
+	Model            string      `json:"model,omitempty"`
+	DurationMS       int64       `json:"duration_ms"`
+	PromptTokens     int64       `json:"prompt_tokens,omitempty"`
+	CompletionTokens int64       `json:"completion_tokens,omitempty"`
+	TotalTokens      int64       `json:"total_tokens,omitempty"`
+	CachedTokens     int64       `json:"cached_tokens,omitempty"`    // parte do prompt servida do cache
+	ReasoningTokens  int64       `json:"reasoning_tokens,omitempty"` // parte da saída gasta em raciocínio
+	FinishReason     string      `json:"finish_reason,omitempty"`    // da última resposta: stop, length, content_filter...
+	Retries          int         `json:"retries"`
+	RetryErrors      []string    `json:"retry_errors,omitempty"` // só em debug
+	Error            string      `json:"error,omitempty"`
+	RequestID        string      `json:"request_id,omitempty"` // x-request-id da última resposta da API
+	Status           int         `json:"status,omitempty"`
+	Endpoint         string      `json:"endpoint,omitempty"`
+	RateLimits       *rateLimits `json:"ratelimits,omitempty"` // x-ratelimit-* da última resposta que os trouxe
+
+	started time.Time
+	mu      sync.Mutex // o fan-out de --profiles anota uso em paralelo
+}
+
+// logLevels: off < error < info < debug. Default: info.
+var logLevels = map[string]int{"off": 0, "error": 1, "info": 2, "debug": 3}
+
+type appLogger struct {
+	level   int
+	profile string
+	persona string
+	command string // subcomando em execução ("" no modo principal)
+}
+
+var appLog = &appLogger{level: logLevels["info"]}
+
+// showRequestID (--show-request-id) imprime o x-request-id também nas chamadas
+// que dão certo; nos erros ele sempre aparece.
+var showRequestID bool
+
+// showUsage (--show-usage) imprime no stderr o uso de tokens e o
+// finish_reason de cada operação.
+var showUsage bool
+
+func stateDir() string {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, "gptcli")
+	}
+	usr, err := user.Current()
+	if err != nil {
+		return "."
+	}
+	return filepath.Join(usr.HomeDir, ".local", "state", "gptcli")
+}
+
+func appLogPath() string { return filepath.Join(stateDir(), "log.jsonl") }
+
+func initAppLog(level, profile, persona string) {
+	if lvl, ok := logLevels[strings.ToLower(strings.TrimSpace(level))]; ok {
+		appLog.level = lvl
+	}
+	appLog.profile, appLog.persona = profile, persona
+}
+
+type logEntryKey struct{}
+
+// logOp executa fn registrando duração, uso de tokens, retries e erro. A
+// operação vai no contexto passado a fn: pedidos simultâneos (web, fan-out)
+// anotam cada um na sua.
+func logOp(ctx context.Context, mode, model string, fn func(ctx context.Context) error) error {
+	e := &logEntry{Mode: mode, Model: model, started: time.Now()}
+	done := promStats.begin()
+	err := fn(context.WithValue(ctx, logEntryKey{}, e))
+	done()
+	appLog.write(e, err)
+	promStats.observeOp(e, err)
+	if err != nil && e.Status != 0 {
+		return fmt.Errorf("%w (%s)", err, e.requestInfo())
+	}
+	if err == nil && showRequestID && e.RequestID != "" {
+		fmt.Fprintf(os.Stderr, "(%s)\n", e.requestInfo())
+	}
+	if err == nil && showUsage && e.TotalTokens > 0 {
+		fmt.Fprintf(os.Stderr, "(%s)\n", e.usageInfo())
+	}
+	return err
+}
+
+// opEntry devolve a operação em andamento no contexto, ou nil fora de um logOp.
+func opEntry(ctx context.Context) *logEntry {
+	e, _ := ctx.Value(logEntryKey{}).(*logEntry)
+	return e
+}
+
+// usageInfo resume tokens e finish_reason da operação.
+func (e *logEntry) usageInfo() string {
+	in := fmt.Sprintf("%d entrada", e.PromptTokens)
+	if e.CachedTokens > 0 {
+		in += fmt.Sprintf(" (%d do cache)", e.CachedTokens)
+	}
+	out := fmt.Sprintf("%d saída", e.CompletionTokens)
+	if e.ReasoningTokens > 0 {
+		out += fmt.Sprintf(" (%d de raciocínio)", e.ReasoningTokens)
+	}
+	info := fmt.Sprintf("uso: %s + %s = %d tokens", in, out, e.TotalTokens)
+	if e.FinishReason != "" {
+		info += "; fim: " + e.FinishReason
+	}
+	return info
+}
+
+// requestInfo resume a última chamada para citar num chamado de suporte.
+func (e *logEntry) requestInfo() string {
+	parts := []string{"request-id: " + chooseNonEmpty(e.RequestID, "(nenhum)"), fmt.Sprintf("HTTP %d", e.Status)}
+	if e.Model != "" {
+		parts = append(parts, "modelo "+e.Model)
+	}
+	return strings.Join(append(parts, e.Endpoint), ", ")
+}
+
+// noteResponse é um middleware do cliente da OpenAI: espaça a requisição
+// conforme os limites do modelo (rates) e guarda o x-request-id, o status, o
+// endpoint e os limites de cada resposta na operação em andamento.
+func noteResponse(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+	e := opEntry(req.Context())
+	model := ""
+	if e != nil {
+		model = e.Model
+	}
+	key := req.URL.Host + " " + model
+	if model != "" {
+		if err := rates.pace(req.Context(), key, model); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := next(req)
+	if resp == nil {
+		return resp, err
+	}
+	info := upstreamInfo(req, resp)
+	if info.RateLimits != nil && model != "" {
+		rates.record(key, model, info.RateLimits)
+	}
+	noteUpstream(req.Context(), info)
+	return resp, err
+}
+
+// upstreamResponse é o que a operação guarda de uma resposta da API. O daemon,
+// que faz a chamada no lugar do cliente, o repassa no stream.
+type upstreamResponse struct {
+	RequestID  string      `json:"request_id,omitempty"`
+	Status     int         `json:"status"`
+	Endpoint   string      `json:"endpoint"`
+	RateLimits *rateLimits `json:"ratelimits,omitempty"`
+}
+
+func upstreamInfo(req *http.Request, resp *http.Response) *upstreamResponse {
+	return &upstreamResponse{
+		RequestID:  resp.Header.Get("x-request-id"),
+		Status:     resp.StatusCode,
+		Endpoint:   req.Method + " " + req.URL.Scheme + "://" + req.URL.Host + req.URL.Path,
+		RateLimits: parseRateLimits(resp.Header),
+	}
+}
+
+// noteUpstream guarda a resposta na operação em andamento.
+func noteUpstream(ctx context.Context, r *upstreamResponse) {
+	if e := opEntry(ctx); e != nil {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		e.RequestID, e.Status, e.Endpoint = r.RequestID, r.Status, r.Endpoint
+		if r.RateLimits != nil {
+			e.RateLimits = r.RateLimits
+		}
+	}
+}
+
+type usageKey struct{}
+
+// withUsageTotals faz noteUsage também somar o consumo em u, para quem precisa
+// do total de uma chamada específica.
+func withUsageTotals(ctx context.Context, u *openai.CompletionUsage) context.Context {
+	return context.WithValue(ctx, usageKey{}, u)
+}
+
+func noteUsage(ctx context.Context, u openai.CompletionUsage) {
+	if t, ok := ctx.Value(usageKey{}).(*openai.CompletionUsage); ok {
+		t.PromptTokens += u.PromptTokens
+		t.CompletionTokens += u.CompletionTokens
+		t.TotalTokens += u.TotalTokens
+	}
+	if e := opEntry(ctx); e != nil {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		e.PromptTokens += u.PromptTokens
+		e.CompletionTokens += u.CompletionTokens
+		e.TotalTokens += u.TotalTokens
+		e.CachedTokens += u.PromptTokensDetails.CachedTokens
+		e.ReasoningTokens += u.CompletionTokensDetails.ReasoningTokens
+	}
+}
+
+// noteFinish guarda o finish_reason da última resposta na operação em andamento.
+func noteFinish(ctx context.Context, reason string) {
+	if e := opEntry(ctx); e != nil {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		e.FinishReason = reason
+	}
+}
+
+func noteRetry(ctx context.Context, err error) {
+	if e := opEntry(ctx); e != nil {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		e.Retries++
+		if appLog.level >= logLevels["debug"] {
+			e.RetryErrors = append(e.RetryErrors, err.Error())
+		}
+	}
+}
+
+func (l *appLogger) write(e *logEntry, err error) {
+	if l.level == 0 || (err == nil && l.level < logLevels["info"]) {
+		return
+	}
+	e.Time = e.started.Format(time.RFC3339)
+	e.DurationMS = time.Since(e.started).Milliseconds()
+	e.Level = "info"
+	if err != nil {
+		e.Level = "error"
+		e.Error = err.Error()
+	}
+	e.Args = logArgs(l.command, os.Args[1:])
+	e.Profile, e.Persona = l.profile, l.persona
+
+	b, jerr := json.Marshal(e)
+	if jerr != nil {
+		return
+	}
+	ensureDir(stateDir())
+	f, ferr := os.OpenFile(appLogPath(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if ferr != nil {
+		return
+	}
+	defer f.Close()
+	_, _ = f.Write(append(b, '\n'))
+}
+
+// logArgs reduz a linha de comando ao subcomando e aos nomes das flags: o
+// prompt posicional e os valores (--system, --var, --api-key...) ficam fora.
+func logArgs(command string, args []string) []string {
+	var out []string
+	if command != "" && len(args) > 0 && args[0] == command {
+		out = append(out, command)
+		args = args[1:]
+	}
+	for _, a := range args {
+		if a == "--" {
+			break // o resto é posicional
+		}
+		if len(a) < 2 || a[0] != '-' {
+			continue
+		}
+		name, _, _ := strings.Cut(a, "=")
+		out = append(out, name)
+	}
+	return out
+}
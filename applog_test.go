@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+
+	openai "github.com/openai/openai-go/v2"
+)
+
+func TestLogArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		args    []string
+		want    []string
+	}{
+		{
+			name: "prompt e valores ficam fora",
+			args: []string{"--system", "segredo", "--var", "nome=Ana", "--model=gpt-5", "explique", "isto"},
+			want: []string{"--system", "--var", "--model"},
+		},
+		{
+			name: "api-key",
+			args: []string{"--api-key=sk-123", "-api-key", "sk-456", "oi"},
+			want: []string{"--api-key", "-api-key"},
+		},
+		{
+			name:    "subcomando",
+			command: "serve",
+			args:    []string{"serve", "--listen", "127.0.0.1:8080", "--auth=u.yaml"},
+			want:    []string{"serve", "--listen", "--auth"},
+		},
+		{
+			name: "depois de -- tudo é posicional",
+			args: []string{"--repl", "--", "--não-é-flag"},
+			want: []string{"--repl"},
+		},
+		{
+			name: "prompt que parece subcomando",
+			args: []string{"serve", "um café"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := logArgs(tt.command, tt.args); !slices.Equal(got, tt.want) {
+				t.Errorf("logArgs = %q, queria %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLogOpKeepsConcurrentEntriesApart(t *testing.T) {
+	level := appLog.level
+	appLog.level = logLevels["off"]
+	defer func() { appLog.level = level }()
+
+	var wg sync.WaitGroup
+	for i := 1; i <= 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			id := strconv.Itoa(i)
+			err := logOp(context.Background(), "web", "", func(ctx context.Context) error {
+				req := httptest.NewRequestWithContext(ctx, http.MethodPost, "https://api.example/v1/chat/completions", nil)
+				_, err := noteResponse(req, func(*http.Request) (*http.Response, error) {
+					h := http.Header{"X-Request-Id": {id}}
+					return &http.Response{StatusCode: 200, Header: h, Body: http.NoBody}, nil
+				})
+				noteUsage(ctx, openai.CompletionUsage{TotalTokens: int64(i)})
+				noteFinish(ctx, "stop")
+				e := opEntry(ctx)
+				if e.RequestID != id || e.TotalTokens != int64(i) || e.FinishReason != "stop" {
+					t.Errorf("operação %d anotou request-id %q e %d tokens", i, e.RequestID, e.TotalTokens)
+				}
+				return err
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if e := opEntry(context.Background()); e != nil {
+		t.Errorf("operação fora de logOp: %+v", e)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// ===================== Placeholders perguntados =====================
+//
+// {{ ask "ticket_id" }} num template ou no próprio prompt vira o valor de
+// --var ticket_id=...; sem --var, o gptcli pergunta no terminal antes de
+// enviar, e o mesmo nome só é perguntado uma vez. Sem terminal (CI, cron) a
+// execução falha dizendo qual --var falta, em vez de mandar o placeholder.
+
+// askPattern acha {{ ask "nome" }} num prompt comum, que não passa pelo
+// text/template (o texto pode ter chaves duplas por outros motivos).
+var askPattern = regexp.MustCompile(`\{\{-?\s*ask\s+"([^"]+)"\s*-?\}\}`)
+
+// askVar devolve vars[name] ou pergunta o valor em /dev/tty e o guarda em vars.
+func askVar(name string, vars map[string]string) (string, error) {
+	if v, ok := vars[name]; ok {
+		return v, nil
+	}
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return "", fmt.Errorf("{{ ask %q }}: sem terminal para perguntar; defina com --var %s=valor", name, name)
+	}
+	defer tty.Close()
+	fmt.Fprintf(tty, "%s: ", name)
+	line, err := bufio.NewReader(tty).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	if err == io.EOF && line == "" {
+		return "", fmt.Errorf("{{ ask %q }}: entrada encerrada sem resposta", name)
+	}
+	v := strings.TrimRight(line, "\r\n")
+	vars[name] = v
+	return v, nil
+}
+
+// expandAsks preenche os {{ ask }} de um prompt comum com --var ou perguntando.
+func expandAsks(prompt string, vars []string) (string, error) {
+	if !strings.Contains(prompt, "{{") {
+		return prompt, nil
+	}
+	values, err := parseVars(vars)
+	if err != nil {
+		return "", err
+	}
+	var askErr error
+	out := askPattern.ReplaceAllStringFunc(prompt, func(m string) string {
+		if askErr != nil {
+			return m
+		}
+		v, err := askVar(askPattern.FindStringSubmatch(m)[1], values)
+		askErr = err
+		return v
+	})
+	return out, askErr
+}
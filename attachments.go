@@ -0,0 +1,183 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	openai "github.com/openai/openai-go/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// ===================== Anexos do turno =====================
+//
+// Cada anexo (saída de /sh, página do /web...) fica no turno separado do texto
+// digitado, com o hash e o tamanho do que foi enviado. A mensagem da API é
+// montada a partir dele, então uma sessão gravada e relida manda exatamente o
+// mesmo conteúdo, o transcript mostra o que foi anexado, e quem precisa de
+// espaço na janela descarta primeiro os anexos pesados dos turnos antigos.
+// Imagens (--attach) guardam a data URL e vão como partes image_url.
+
+// imageTokens é o custo aproximado de uma imagem em detalhe alto (1024x1024).
+const imageTokens = 765
+
+type Attachment struct {
+	Kind    string `yaml:"kind,omitempty"`    // command | url | file | image | stdin
+	Source  string `yaml:"source"`            // "$ go test ./...", a URL, o caminho
+	Label   string `yaml:"label,omitempty"`   // cabeçalho do bloco enviado ao modelo
+	SHA256  string `yaml:"sha256,omitempty"`  // do conteúdo enviado
+	Bytes   int    `yaml:"bytes,omitempty"`   // tamanho do conteúdo enviado
+	Content string `yaml:"content,omitempty"` // vazio nos anexos descartados
+	Dropped bool   `yaml:"dropped,omitempty"` // removido para liberar a janela de contexto
+}
+
+// UnmarshalYAML aceita também o formato antigo, só com a origem ("$ ls"); o
+// conteúdo desses anexos já está no texto do turno.
+func (a *Attachment) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind == yaml.ScalarNode {
+		*a = Attachment{Source: n.Value}
+		return nil
+	}
+	type plain Attachment
+	return n.Decode((*plain)(a))
+}
+
+// newAttachment corta o conteúdo em maxAttachment e calcula o hash do que vai
+// de fato para o modelo.
+func newAttachment(kind, source, label, content string) Attachment {
+	if len(content) > maxAttachment {
+		content = content[:maxAttachment] + "\n[conteúdo truncado]"
+	}
+	a := Attachment{Kind: kind, Source: source, Label: label}
+	a.setContent(content)
+	return a
+}
+
+func (a *Attachment) setContent(content string) {
+	sum := sha256.Sum256([]byte(content))
+	a.Content, a.SHA256, a.Bytes = content, hex.EncodeToString(sum[:]), len(content)
+}
+
+// newImageAttachment lê uma imagem (arquivo, URL ou "-" para o stdin) para
+// ir ao modelo com visão junto com o texto do turno.
+func newImageAttachment(src string) (Attachment, error) {
+	url, err := imageDataURL(src)
+	if err != nil {
+		return Attachment{}, err
+	}
+	source := src
+	if src == "-" {
+		source = "stdin"
+	}
+	a := Attachment{Kind: "image", Source: source}
+	a.setContent(url)
+	return a, nil
+}
+
+// shortHash é o começo do sha256, o bastante para conferir à mão.
+func (a Attachment) shortHash() string {
+	if len(a.SHA256) < 12 {
+		return a.SHA256
+	}
+	return a.SHA256[:12]
+}
+
+// describe resume o anexo numa linha: origem, hash e tamanho.
+func (a Attachment) describe() string {
+	parts := []string{a.Source}
+	if a.SHA256 != "" {
+		parts = append(parts, "sha256:"+a.shortHash(), humanBytes(int64(a.Bytes)))
+	}
+	if a.Dropped {
+		parts = append(parts, "removido do contexto")
+	}
+	return strings.Join(parts, " · ")
+}
+
+// block é o trecho enviado ao modelo no lugar do anexo.
+func (a Attachment) block() string {
+	switch {
+	case a.Dropped:
+		return fmt.Sprintf("[anexo removido para liberar contexto: %s · sha256:%s · %s]\n\n", a.Source, a.shortHash(), humanBytes(int64(a.Bytes)))
+	case a.Content == "":
+		return "" // formato antigo: já está no texto do turno
+	case a.Kind == "image":
+		return fmt.Sprintf("[imagem anexada: %s]\n\n", a.Source) // a imagem vai numa parte própria
+	}
+	return fmt.Sprintf("%s:\n```\n%s\n```\n\n", chooseNonEmpty(a.Label, a.Source), strings.TrimRight(a.Content, "\n"))
+}
+
+// apiContent é o texto do turno como vai para a API: anexos antes do texto.
+func (t Turn) apiContent() string {
+	if len(t.Attachments) == 0 {
+		return t.Content
+	}
+	var b strings.Builder
+	for _, a := range t.Attachments {
+		b.WriteString(a.block())
+	}
+	b.WriteString(t.Content)
+	return b.String()
+}
+
+// images devolve as URLs das imagens do turno ainda no contexto.
+func (t Turn) images() []string {
+	var urls []string
+	for _, a := range t.Attachments {
+		if a.Kind == "image" && !a.Dropped && a.Content != "" {
+			urls = append(urls, a.Content)
+		}
+	}
+	return urls
+}
+
+// tokenEstimate conta o texto, os anexos e as imagens do turno.
+func (t Turn) tokenEstimate() int {
+	return estimateTokens(t.apiContent()) + 4 + imageTokens*len(t.images())
+}
+
+// userParts monta a mensagem do usuário com as imagens depois do texto.
+func userParts(text string, images []string) []openai.ChatCompletionContentPartUnionParam {
+	parts := []openai.ChatCompletionContentPartUnionParam{openai.TextContentPart(text)}
+	for _, url := range images {
+		parts = append(parts, openai.ImageContentPart(openai.ChatCompletionContentPartImageImageURLParam{URL: url}))
+	}
+	return parts
+}
+
+// dropHeavyAttachments descarta anexos, do maior para o menor e só fora da
+// última troca e dos turnos fixados, até a sessão caber em limit tokens.
+// Devolve quantos foram descartados.
+func (s *Session) dropHeavyAttachments(limit int) int {
+	type ref struct{ turn, att int }
+	var refs []ref
+	last := len(s.Turns) - 1
+	for last > 0 && s.Turns[last].Role != "user" {
+		last--
+	}
+	for i := 0; i < last; i++ {
+		if s.Turns[i].Pinned {
+			continue
+		}
+		for j, a := range s.Turns[i].Attachments {
+			if !a.Dropped && a.Content != "" {
+				refs = append(refs, ref{i, j})
+			}
+		}
+	}
+	sort.SliceStable(refs, func(x, y int) bool {
+		return s.Turns[refs[x].turn].Attachments[refs[x].att].Bytes > s.Turns[refs[y].turn].Attachments[refs[y].att].Bytes
+	})
+	dropped := 0
+	for _, r := range refs {
+		if s.estimatedTokens() <= limit {
+			break
+		}
+		a := &s.Turns[r.turn].Attachments[r.att]
+		a.Content, a.Dropped = "", true
+		dropped++
+	}
+	return dropped
+}
@@ -0,0 +1,486 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ===================== Audit =====================
+//
+// Trilha de auditoria dos modos servidor (`gptcli serve` e `gptcli daemon`):
+// uma linha JSON por pedido com quem, quando, profile, modelo, o prompt (só
+// o hash, por padrão), tokens, custo estimado e status. Diferente do log da
+// aplicação, o arquivo só cresce: é rotacionado por tamanho e, com uma chave,
+// cada linha leva o HMAC da anterior, e `gptcli audit verify` aponta a
+// primeira linha alterada, removida ou fora de ordem.
+
+// AuditConfig é a seção `audit` do config.
+type AuditConfig struct {
+	Enabled    bool   `yaml:"enabled,omitempty"`      // liga a auditoria no serve e no daemon (ou --audit)
+	Path       string `yaml:"path,omitempty"`         // default: audit.jsonl no diretório de estado
+	Prompts    string `yaml:"prompts,omitempty"`      // hash|full|off (default hash)
+	MaxSizeMB  int    `yaml:"max_size_mb,omitempty"`  // rotaciona ao passar disso (default 100)
+	Keep       int    `yaml:"keep,omitempty"`         // arquivos rotacionados mantidos (0 = todos)
+	HMACKeyEnv string `yaml:"hmac_key_env,omitempty"` // variável de ambiente com a chave do encadeamento
+}
+
+type auditEntry struct {
+	Seq              int64           `json:"seq"`
+	Time             string          `json:"time"`
+	Mode             string          `json:"mode"` // serve|daemon
+	User             string          `json:"user"`
+	Remote           string          `json:"remote,omitempty"`
+	Profile          string          `json:"profile,omitempty"`
+	Model            string          `json:"model,omitempty"`
+	Stream           bool            `json:"stream,omitempty"`
+	Rules            []string        `json:"rules,omitempty"` // regras do --rules aplicadas ao pedido
+	PromptSHA256     string          `json:"prompt_sha256,omitempty"`
+	Prompt           json.RawMessage `json:"prompt,omitempty"` // as mensagens, com prompts: full
+	PromptTokens     int64           `json:"prompt_tokens"`
+	CompletionTokens int64           `json:"completion_tokens"`
+	CostUSD          *float64        `json:"cost_usd,omitempty"` // ausente se o preço do modelo é desconhecido
+	Status           int             `json:"status"`
+	Error            string          `json:"error,omitempty"`
+	DurationMS       int64           `json:"duration_ms"`
+	Prev             string          `json:"prev,omitempty"` // HMAC da linha anterior
+
+	started time.Time
+}
+
+type auditLogger struct {
+	mu      sync.Mutex
+	cfg     AuditConfig
+	path    string
+	maxSize int64
+	key     []byte
+	f       *os.File
+	size    int64
+	seq     int64
+	last    string // HMAC da última linha
+}
+
+// auditLog é nil quando a auditoria está desligada.
+var auditLog *auditLogger
+
+const auditPrefix = "audit-" // arquivos rotacionados: audit-20261016-153000.000000.jsonl
+
+func auditPath(cfg AuditConfig) string {
+	return chooseNonEmpty(cfg.Path, filepath.Join(stateDir(), "audit.jsonl"))
+}
+
+// auditKey lê a chave do HMAC; sem hmac_key_env, não há encadeamento.
+func auditKey(cfg AuditConfig) ([]byte, error) {
+	if cfg.HMACKeyEnv == "" {
+		return nil, nil
+	}
+	key := os.Getenv(cfg.HMACKeyEnv)
+	if key == "" {
+		return nil, fmt.Errorf("audit: hmac_key_env=%s, mas a variável está vazia", cfg.HMACKeyEnv)
+	}
+	return []byte(key), nil
+}
+
+// openAudit liga a auditoria e retoma a sequência e o encadeamento do
+// arquivo existente.
+func openAudit(cfg AuditConfig) error {
+	switch cfg.Prompts {
+	case "":
+		cfg.Prompts = "hash"
+	case "hash", "full", "off":
+	default:
+		return fmt.Errorf("audit.prompts inválido: %q (use hash, full ou off)", cfg.Prompts)
+	}
+	if ephemeral {
+		return errors.New("audit: a auditoria grava em disco e não combina com --ephemeral")
+	}
+	key, err := auditKey(cfg)
+	if err != nil {
+		return err
+	}
+	l := &auditLogger{cfg: cfg, path: auditPath(cfg), maxSize: int64(max(cfg.MaxSizeMB, 0)) << 20, key: key}
+	if l.maxSize == 0 {
+		l.maxSize = 100 << 20
+	}
+	files, err := auditFiles(l.path)
+	if err != nil {
+		return err
+	}
+	for i := len(files) - 1; i >= 0; i-- {
+		e, mac, err := lastAuditLine(files[i])
+		if err != nil {
+			return err
+		}
+		if e != nil {
+			l.seq, l.last = e.Seq, mac
+			break
+		}
+	}
+	if err := l.open(); err != nil {
+		return err
+	}
+	auditLog = l
+	return nil
+}
+
+func (l *auditLogger) open() error {
+	if err := ensureFileDirectory(l.path); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("audit: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	l.f, l.size = f, info.Size()
+	return nil
+}
+
+// auditFiles lista os arquivos da trilha em ordem: os rotacionados (o nome
+// tem a data) e o atual por último.
+func auditFiles(path string) ([]string, error) {
+	dir, base := filepath.Dir(path), filepath.Base(path)
+	ext := filepath.Ext(base)
+	rotated, err := filepath.Glob(filepath.Join(dir, auditPrefix+"*"+ext))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(rotated)
+	files := make([]string, 0, len(rotated)+1)
+	for _, f := range rotated {
+		if f != path {
+			files = append(files, f)
+		}
+	}
+	if _, err := os.Stat(path); err == nil {
+		files = append(files, path)
+	}
+	return files, nil
+}
+
+func lastAuditLine(path string) (*auditEntry, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, "", err
+	}
+	defer f.Close()
+	var last []byte
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			last = line
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	if last == nil {
+		return nil, "", nil
+	}
+	e, _, mac, err := splitAuditLine(last)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: última linha: %w", path, err)
+	}
+	return e, mac, nil
+}
+
+// record grava a entrada; com chave, o HMAC cobre a linha inteira, inclusive
+// prev, e fica no fim dela.
+func (l *auditLogger) record(e *auditEntry) {
+	if l == nil {
+		return
+	}
+	e.Time = e.started.Format(time.RFC3339Nano)
+	e.DurationMS = time.Since(e.started).Milliseconds()
+	if info, ok := lookupModel(e.Model); ok && e.PromptTokens+e.CompletionTokens > 0 {
+		cost := (float64(e.PromptTokens)*info.inPerMillion + float64(e.CompletionTokens)*info.outPerMillion) / 1e6
+		e.CostUSD = &cost
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e.Seq = l.seq + 1
+	e.Prev = l.last
+	body, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	mac := ""
+	line := body
+	if l.key != nil {
+		mac = hex.EncodeToString(hmacSHA256(l.key, string(body)))
+		line = append(body[:len(body)-1:len(body)-1], `,"hmac":"`+mac+`"}`...)
+	}
+	line = append(line, '\n')
+	if l.size > 0 && l.size+int64(len(line)) > l.maxSize {
+		if err := l.rotate(); err != nil {
+			fmt.Fprintln(os.Stderr, "aviso: audit: rotação falhou:", err)
+		}
+	}
+	if _, err := l.f.Write(line); err != nil {
+		fmt.Fprintln(os.Stderr, "erro: audit: entrada não gravada:", err)
+		return
+	}
+	// a trilha precisa sobreviver a uma queda logo depois da resposta
+	_ = l.f.Sync()
+	l.size += int64(len(line))
+	l.seq, l.last = e.Seq, mac
+}
+
+func (l *auditLogger) rotate() error {
+	if err := l.f.Close(); err != nil {
+		return err
+	}
+	ext := filepath.Ext(l.path)
+	dest := filepath.Join(filepath.Dir(l.path), auditPrefix+time.Now().Format("20060102-150405.000000")+ext)
+	if err := os.Rename(l.path, dest); err != nil {
+		_ = l.open()
+		return err
+	}
+	if err := l.open(); err != nil {
+		return err
+	}
+	if l.cfg.Keep > 0 {
+		files, _ := auditFiles(l.path)
+		rotated := files[:len(files)-1]
+		for len(rotated) > l.cfg.Keep {
+			_ = os.Remove(rotated[0])
+			rotated = rotated[1:]
+		}
+	}
+	return nil
+}
+
+// auditPrompt prepara as mensagens conforme audit.prompts. O hash é das
+// mensagens normalizadas (chaves em ordem, sem espaços), igual para o mesmo
+// conteúdo venha ele do serve ou do daemon.
+func (l *auditLogger) auditPrompt(e *auditEntry, messages any) {
+	if l == nil || messages == nil || l.cfg.Prompts == "off" {
+		return
+	}
+	b, err := json.Marshal(messages)
+	if err != nil {
+		return
+	}
+	if l.cfg.Prompts == "full" {
+		e.Prompt = b
+		return
+	}
+	sum := sha256.Sum256(b)
+	e.PromptSHA256 = hex.EncodeToString(sum[:])
+}
+
+// auditMessages extrai as mensagens dos parâmetros crus de um chat.
+func auditMessages(params []byte) any {
+	dec := json.NewDecoder(bytes.NewReader(params))
+	dec.UseNumber()
+	var p map[string]any
+	if dec.Decode(&p) != nil {
+		return nil
+	}
+	return p["messages"]
+}
+
+// auditStatus é o status HTTP de um pedido do daemon, como o serve o
+// responderia.
+func auditStatus(err error) int {
+	if err == nil {
+		return http.StatusOK
+	}
+	status, _, _, _ := upstreamErrorFields(err)
+	return status
+}
+
+func osUserName() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return fmt.Sprint(os.Getuid())
+}
+
+// auditWriter guarda o status e a mensagem de erro da resposta do serve.
+type auditWriter struct {
+	http.ResponseWriter
+	status int
+	err    string
+}
+
+func (w *auditWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *auditWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *auditWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+func (w *auditWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// ---------- gptcli audit verify ----------
+
+// splitAuditLine separa a entrada, o corpo coberto pelo HMAC e o HMAC.
+func splitAuditLine(line []byte) (*auditEntry, []byte, string, error) {
+	line = bytes.TrimSpace(line)
+	var e struct {
+		auditEntry
+		HMAC string `json:"hmac"`
+	}
+	if err := json.Unmarshal(line, &e); err != nil {
+		return nil, nil, "", fmt.Errorf("json inválido: %w", err)
+	}
+	body := line
+	if e.HMAC != "" {
+		suffix := `,"hmac":"` + e.HMAC + `"}`
+		if !bytes.HasSuffix(line, []byte(suffix)) {
+			return nil, nil, "", errors.New("hmac fora do lugar")
+		}
+		body = append(line[:len(line)-len(suffix):len(line)-len(suffix)], '}')
+	}
+	return &e.auditEntry, body, e.HMAC, nil
+}
+
+func auditCmd(args []string) error {
+	if len(args) == 0 || args[0] != "verify" {
+		fmt.Fprintln(os.Stderr, "uso: gptcli audit verify [--path audit.jsonl]")
+		os.Exit(2)
+	}
+	fs := flag.NewFlagSet("audit verify", flag.ExitOnError)
+	path := fs.String("path", "", "arquivo atual da trilha (default: audit.path do config)")
+	_ = fs.Parse(args[1:])
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	key, err := auditKey(cfg.Audit)
+	if err != nil {
+		return err
+	}
+	files, err := auditFiles(chooseNonEmpty(*path, auditPath(cfg.Audit)))
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return errors.New("nenhum arquivo de auditoria encontrado")
+	}
+	n, first, err := verifyAudit(files, key)
+	if err != nil {
+		return err
+	}
+	what := "sequência contínua (sem hmac_key_env, o conteúdo não é conferido)"
+	if key != nil {
+		what = "sequência e encadeamento HMAC íntegros"
+	}
+	fmt.Printf("ok: %d entradas (seq %d a %d) em %d arquivos; %s\n", n, first, first+int64(n)-1, len(files), what)
+	return nil
+}
+
+// verifyAudit confere a sequência e, com a chave, o HMAC de cada linha e o
+// elo com a anterior. A primeira linha pode continuar uma trilha cujo começo
+// foi apagado pela rotação (keep).
+func verifyAudit(files []string, key []byte) (int, int64, error) {
+	var n int
+	var first, seq int64
+	last := ""
+	for _, path := range files {
+		f, err := os.Open(path)
+		if err != nil {
+			return n, first, err
+		}
+		r := bufio.NewReader(f)
+		for lineNo := 1; ; lineNo++ {
+			line, rerr := r.ReadBytes('\n')
+			if len(bytes.TrimSpace(line)) > 0 {
+				if err := checkAuditLine(line, key, n == 0, &seq, &last); err != nil {
+					f.Close()
+					return n, first, fmt.Errorf("%s:%d: %w", path, lineNo, err)
+				}
+				if n == 0 {
+					first = seq
+				}
+				n++
+			}
+			if rerr == io.EOF {
+				break
+			}
+			if rerr != nil {
+				f.Close()
+				return n, first, rerr
+			}
+		}
+		f.Close()
+	}
+	if n == 0 {
+		return 0, 0, errors.New("trilha de auditoria vazia")
+	}
+	return n, first, nil
+}
+
+func checkAuditLine(line, key []byte, first bool, seq *int64, last *string) error {
+	e, body, mac, err := splitAuditLine(line)
+	if err != nil {
+		return err
+	}
+	if !first && e.Seq != *seq+1 {
+		return fmt.Errorf("seq %d depois de %d: entradas removidas ou fora de ordem", e.Seq, *seq)
+	}
+	if key != nil {
+		if mac == "" {
+			return errors.New("entrada sem hmac")
+		}
+		if !first && e.Prev != *last {
+			return errors.New("prev não bate com o hmac da entrada anterior")
+		}
+		want := hex.EncodeToString(hmacSHA256(key, string(body)))
+		if !hmac.Equal([]byte(mac), []byte(want)) {
+			return errors.New("hmac não confere: entrada alterada (ou chave errada)")
+		}
+	}
+	*seq, *last = e.Seq, mac
+	return nil
+}
+
+// auditSummary descreve a configuração no início do servidor.
+func (l *auditLogger) summary() string {
+	if l == nil {
+		return ""
+	}
+	parts := []string{"auditoria em " + l.path, "prompts=" + l.cfg.Prompts}
+	if l.key != nil {
+		parts = append(parts, "hmac")
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeAudit grava n entradas numa trilha nova em dir e devolve o logger.
+func writeAudit(t *testing.T, dir string, cfg AuditConfig, n int) *auditLogger {
+	t.Helper()
+	cfg.Path = filepath.Join(dir, "audit.jsonl")
+	if err := openAudit(cfg); err != nil {
+		t.Fatal(err)
+	}
+	l := auditLog
+	t.Cleanup(func() {
+		l.f.Close()
+		auditLog = nil
+	})
+	for i := 0; i < n; i++ {
+		l.record(&auditEntry{Mode: "serve", User: "ana", Model: "gpt-5-mini", Status: 200, started: time.Now()})
+	}
+	return l
+}
+
+func auditLines(t *testing.T, path string) [][]byte {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bytes.SplitAfter(bytes.TrimSuffix(b, []byte("\n")), []byte("\n"))
+}
+
+func TestVerifyAudit(t *testing.T) {
+	const keyEnv = "GPTCLI_TEST_AUDIT_KEY"
+	t.Setenv(keyEnv, "segredo")
+	tests := []struct {
+		name    string
+		tamper  func(lines [][]byte) [][]byte
+		wantErr string
+	}{
+		{
+			name:   "íntegra",
+			tamper: func(lines [][]byte) [][]byte { return lines },
+		},
+		{
+			name: "linha alterada",
+			tamper: func(lines [][]byte) [][]byte {
+				lines[1] = bytes.Replace(lines[1], []byte(`"user":"ana"`), []byte(`"user":"bia"`), 1)
+				return lines
+			},
+			wantErr: ":2: hmac não confere",
+		},
+		{
+			name:    "linha removida",
+			tamper:  func(lines [][]byte) [][]byte { return append(lines[:1:1], lines[2:]...) },
+			wantErr: ":2: seq 3 depois de 1",
+		},
+		{
+			name:    "linhas trocadas",
+			tamper:  func(lines [][]byte) [][]byte { lines[1], lines[2] = lines[2], lines[1]; return lines },
+			wantErr: ":2: seq 3 depois de 1",
+		},
+		{
+			name: "hmac removido",
+			tamper: func(lines [][]byte) [][]byte {
+				e, body, _, err := splitAuditLine(lines[2])
+				if err != nil || e.Seq != 3 {
+					t.Fatalf("linha 3: %v", err)
+				}
+				lines[2] = append(body, '\n')
+				return lines
+			},
+			wantErr: ":3: entrada sem hmac",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			l := writeAudit(t, dir, AuditConfig{HMACKeyEnv: keyEnv}, 4)
+			lines := tt.tamper(auditLines(t, l.path))
+			if err := os.WriteFile(l.path, bytes.Join(lines, nil), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			n, first, err := verifyAudit([]string{l.path}, []byte("segredo"))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("erro = %v, queria %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || n != 4 || first != 1 {
+				t.Fatalf("verifyAudit = %d, %d, %v; queria 4, 1, nil", n, first, err)
+			}
+		})
+	}
+}
+
+func TestVerifyAuditWrongKey(t *testing.T) {
+	t.Setenv("GPTCLI_TEST_AUDIT_KEY", "segredo")
+	l := writeAudit(t, t.TempDir(), AuditConfig{HMACKeyEnv: "GPTCLI_TEST_AUDIT_KEY"}, 2)
+	if _, _, err := verifyAudit([]string{l.path}, []byte("outra")); err == nil || !strings.Contains(err.Error(), "hmac não confere") {
+		t.Fatalf("erro = %v, queria hmac não confere", err)
+	}
+}
+
+func TestAuditRotation(t *testing.T) {
+	t.Setenv("GPTCLI_TEST_AUDIT_KEY", "segredo")
+	dir := t.TempDir()
+	l := writeAudit(t, dir, AuditConfig{HMACKeyEnv: "GPTCLI_TEST_AUDIT_KEY", Keep: 2}, 1)
+	l.maxSize = l.size + 1 // cada entrada nova passa do limite e rotaciona
+	for i := 0; i < 4; i++ {
+		time.Sleep(time.Millisecond) // nomes dos rotacionados têm microssegundos
+		l.record(&auditEntry{Mode: "daemon", User: "ana", Status: 200, started: time.Now()})
+	}
+	files, err := auditFiles(l.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 3 || files[2] != l.path {
+		t.Fatalf("arquivos = %v; queria 2 rotacionados (keep) e o atual", files)
+	}
+	// o começo da trilha foi apagado pela rotação; o resto continua encadeado
+	n, first, err := verifyAudit(files, []byte("segredo"))
+	if err != nil || n != 3 || first != 3 {
+		t.Fatalf("verifyAudit = %d, %d, %v; queria 3, 3, nil", n, first, err)
+	}
+
+	// reabrir retoma a sequência e o encadeamento do arquivo existente
+	l.f.Close()
+	if err := openAudit(AuditConfig{Path: l.path, HMACKeyEnv: "GPTCLI_TEST_AUDIT_KEY"}); err != nil {
+		t.Fatal(err)
+	}
+	reopened := auditLog
+	defer reopened.f.Close()
+	reopened.record(&auditEntry{Mode: "serve", User: "ana", Status: 200, started: time.Now()})
+	files, _ = auditFiles(l.path)
+	if n, _, err := verifyAudit(files, []byte("segredo")); err != nil || n != 4 {
+		t.Fatalf("depois de reabrir: %d entradas, %v", n, err)
+	}
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// ===================== Batch =====================
+//
+// `gptcli batch run prompts.jsonl` responde uma lista de prompts, um por vez.
+// Cada resposta concluída vai para um checkpoint (JSONL de id => saída) assim
+// que chega; se o lote for interrompido (Ctrl+C, queda de rede, o circuit
+// breaker), `--resume` pula os ids que já estão lá.
+
+const batchUsage = `uso: gptcli batch <run|submit|status|fetch> [flags] [args]
+  run <entrada>       responde cada prompt agora e grava os resultados em JSONL (id, output/error)
+  submit <entrada>    envia a entrada à Batch API da OpenAI (até 24h, metade do preço)
+  status [id]         estado de um lote da Batch API (sem id: os lotes recentes)
+  fetch <id>          baixa os resultados de um lote concluído no mesmo JSONL do run
+
+A entrada é JSONL ({"id": "...", "prompt": "...", "system": "..."}) ou texto
+com um prompt por linha (o id é o número da linha).`
+
+type batchItem struct {
+	ID     string `json:"id"`
+	Prompt string `json:"prompt"`
+	System string `json:"system,omitempty"`
+}
+
+type batchResult struct {
+	ID     string `json:"id"`
+	Output string `json:"output,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+func batchCmd(args []string) error {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, batchUsage)
+		os.Exit(2)
+	}
+	switch args[0] {
+	case "run":
+		return batchRun(args[1:])
+	case "submit":
+		return batchSubmit(args[1:])
+	case "status":
+		return batchStatus(args[1:])
+	case "fetch":
+		return batchFetch(args[1:])
+	default:
+		fmt.Fprintln(os.Stderr, batchUsage)
+		os.Exit(2)
+	}
+	return nil
+}
+
+func batchRun(args []string) error {
+	fs := flag.NewFlagSet("batch run", flag.ExitOnError)
+	flags := commonFlags(fs)
+	out := fs.String("out", "", "arquivo de resultados JSONL (default: stdout)")
+	checkpoint := fs.String("checkpoint", "", "checkpoint das respostas concluídas (default: <entrada>.checkpoint.jsonl)")
+	resume := fs.Bool("resume", false, "retoma um lote interrompido, pulando os ids que já estão no checkpoint")
+	maxFailures := breakerFlag(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, batchUsage)
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	items, err := readBatchInput(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	ckPath := *checkpoint
+	if ckPath == "" {
+		ckPath = fs.Arg(0) + ".checkpoint.jsonl"
+		if fs.Arg(0) == "-" {
+			ckPath = "gptcli-batch.checkpoint.jsonl"
+		}
+	}
+	if flags.Ephemeral || ephemeralFromEnv() {
+		ckPath = "" // sem checkpoint: o lote não pode ser retomado
+	}
+	done := map[string]string{}
+	if ckPath != "" {
+		if _, err := os.Stat(ckPath); err == nil && !*resume {
+			return fmt.Errorf("%s já existe: use --resume para continuar o lote ou apague o arquivo", ckPath)
+		}
+		if *resume {
+			if done, err = loadBatchCheckpoint(ckPath); err != nil {
+				return err
+			}
+		}
+	}
+
+	cfg, _ := loadConfig()
+	st, err := resolveSettings(cfg, flags)
+	if err != nil {
+		return err
+	}
+	initAppLog(st.logLevel, st.profName, st.personaName)
+	configureTools(st)
+	client, err := buildClient(st)
+	if err != nil {
+		return err
+	}
+
+	var ck *os.File
+	if ckPath != "" {
+		if ck, err = os.OpenFile(ckPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600); err != nil {
+			return err
+		}
+		defer ck.Close()
+	}
+
+	ctx, stop := signal.NotifyContext(withRetryConfig(context.Background(), st.retry), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	brk := newBreaker(*maxFailures)
+	failed := map[string]string{}
+	skipped, tripped := 0, false
+	for i, it := range items {
+		if _, ok := done[it.ID]; ok {
+			skipped++
+			continue
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		fmt.Fprintf(os.Stderr, "[%d/%d] %s\n", i+1, len(items), it.ID)
+		sess := &Session{Format: st.format, Examples: st.persona.exampleTurns(), Tools: st.tools}
+		sess.addSystem(chooseNonEmpty(it.System, st.system))
+		sess.addUser(it.Prompt)
+		var answer string
+		err := logOp(ctx, "batch", st.model, func(ctx context.Context) error {
+			return withRetries(ctx, 4, func() error {
+				var err error
+				answer, err = streamChat(ctx, client, sess, st.model, st.temp, st.maxTokens, func(string) {})
+				return err
+			})
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			failed[it.ID] = err.Error()
+			fmt.Fprintf(os.Stderr, "aviso: %s: %v\n", it.ID, err)
+			if tripped = brk.record(err); tripped {
+				break
+			}
+			continue
+		}
+		brk.record(nil)
+		done[it.ID] = answer
+		if ck != nil {
+			b, _ := json.Marshal(batchResult{ID: it.ID, Output: answer})
+			if _, err := ck.Write(append(b, '\n')); err != nil {
+				return fmt.Errorf("checkpoint: %w", err)
+			}
+		}
+	}
+	flushTelemetry()
+
+	if err := writeBatchResults(*out, items, done, failed); err != nil {
+		return err
+	}
+	completed := 0
+	for _, it := range items {
+		if _, ok := done[it.ID]; ok {
+			completed++
+		}
+	}
+	pending := len(items) - completed - len(failed)
+	fmt.Fprintf(os.Stderr, "(%d concluído(s), %d com erro, %d pendente(s)", completed, len(failed), pending)
+	if skipped > 0 {
+		fmt.Fprintf(os.Stderr, "; %d já estavam no checkpoint", skipped)
+	}
+	fmt.Fprintln(os.Stderr, ")")
+	switch {
+	case pending > 0 || len(failed) > 0:
+		if tripped {
+			brk.report(completed+len(failed), len(items))
+		} else if ctx.Err() != nil {
+			fmt.Fprintln(os.Stderr, "lote interrompido")
+		}
+		if ckPath != "" {
+			fmt.Fprintln(os.Stderr, "para retomar, rode o mesmo comando com --resume")
+		}
+		os.Exit(1)
+	case ckPath != "":
+		_ = os.Remove(ckPath) // lote completo: o checkpoint não serve mais
+	}
+	return nil
+}
+
+// readBatchInput lê JSONL ou, se a primeira linha não for um objeto, texto
+// com um prompt por linha.
+func readBatchInput(path string) ([]batchItem, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64<<10), 16<<20)
+	var items []batchItem
+	seen := map[string]bool{}
+	jsonl := false
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		if len(items) == 0 {
+			jsonl = strings.HasPrefix(line, "{")
+		}
+		it := batchItem{ID: strconv.Itoa(n), Prompt: line}
+		if jsonl {
+			it = batchItem{}
+			if err := json.Unmarshal([]byte(line), &it); err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", path, n, err)
+			}
+			if it.ID == "" {
+				it.ID = strconv.Itoa(n)
+			}
+			if strings.TrimSpace(it.Prompt) == "" {
+				return nil, fmt.Errorf("%s:%d: prompt vazio", path, n)
+			}
+		}
+		if seen[it.ID] {
+			return nil, fmt.Errorf("%s:%d: id repetido %q", path, n, it.ID)
+		}
+		seen[it.ID] = true
+		items = append(items, it)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, errors.New("entrada sem prompts")
+	}
+	return items, nil
+}
+
+func loadBatchCheckpoint(path string) (map[string]string, error) {
+	done := map[string]string{}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return done, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64<<10), 16<<20)
+	for sc.Scan() {
+		var r batchResult
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+			continue // última linha cortada por uma interrupção: o item é refeito
+		}
+		done[r.ID] = r.Output
+	}
+	return done, sc.Err()
+}
+
+// writeBatchResults grava os resultados na ordem da entrada.
+func writeBatchResults(path string, items []batchItem, done, failed map[string]string) error {
+	w := os.Stdout
+	if path != "" {
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	for _, it := range items {
+		r := batchResult{ID: it.ID}
+		if out, ok := done[it.ID]; ok {
+			r.Output = out
+		} else if msg, ok := failed[it.ID]; ok {
+			r.Error = msg
+		} else {
+			continue
+		}
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,360 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	openai "github.com/openai/openai-go/v2"
+	"github.com/openai/openai-go/v2/shared"
+)
+
+// ===================== Batch API =====================
+//
+// `gptcli batch submit` manda a mesma entrada do `batch run` para a Batch API:
+// sobe um JSONL de pedidos a /v1/chat/completions e cria o lote, que o
+// provedor processa em até 24h pela metade do preço. `status` acompanha o
+// lote (--wait espera terminar) e `fetch` baixa as respostas no formato do
+// `batch run` (id, output/error).
+
+// batchRequest é uma linha do JSONL de entrada da Batch API.
+type batchRequest struct {
+	CustomID string          `json:"custom_id"`
+	Method   string          `json:"method"`
+	URL      string          `json:"url"`
+	Body     json.RawMessage `json:"body"`
+}
+
+// batchResponse é uma linha dos arquivos de saída e de erros.
+type batchResponse struct {
+	CustomID string `json:"custom_id"`
+	Response *struct {
+		StatusCode int                   `json:"status_code"`
+		RequestID  string                `json:"request_id"`
+		Body       openai.ChatCompletion `json:"body"`
+	} `json:"response"`
+	Error *struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func batchSubmit(args []string) error {
+	fs := flag.NewFlagSet("batch submit", flag.ExitOnError)
+	flags := commonFlags(fs)
+	wait := fs.Bool("wait", false, "espera o lote terminar e baixa os resultados")
+	out := fs.String("out", "", "com --wait: arquivo de resultados JSONL (default: stdout)")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "uso: gptcli batch submit [flags] <entrada.jsonl|entrada.txt|->")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	items, err := readBatchInput(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	st, client, err := resolveClient(flags)
+	if err != nil {
+		return err
+	}
+	if len(st.tools) > 0 {
+		fmt.Fprintln(os.Stderr, "aviso: a Batch API não executa ferramentas; --tool é ignorado")
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	for _, it := range items {
+		sess := &Session{Format: st.format, Examples: st.persona.exampleTurns()}
+		sess.addSystem(chooseNonEmpty(it.System, st.system))
+		sess.addUser(it.Prompt)
+		params := openai.ChatCompletionNewParams{
+			Model:    shared.ChatModel(st.model),
+			Messages: sess.messagesForAPI(strings.ToLower(sess.Format) == "json"),
+		}
+		if st.temp >= 0 {
+			params.Temperature = openai.Float(st.temp)
+		}
+		if st.maxTokens > 0 {
+			params.MaxTokens = openai.Int(st.maxTokens)
+		}
+		body, err := json.Marshal(params)
+		if err != nil {
+			return err
+		}
+		if err := enc.Encode(batchRequest{CustomID: it.ID, Method: "POST", URL: "/v1/chat/completions", Body: body}); err != nil {
+			return err
+		}
+	}
+
+	name := filepath.Base(fs.Arg(0))
+	if fs.Arg(0) == "-" {
+		name = "stdin"
+	}
+	ctx := context.Background()
+	var batch *openai.Batch
+	err = logOp(ctx, "batch-submit", st.model, func(ctx context.Context) error {
+		file, err := client.Files.New(ctx, openai.FileNewParams{
+			File:    openai.File(&buf, strings.TrimSuffix(name, filepath.Ext(name))+".jsonl", "application/jsonl"),
+			Purpose: openai.FilePurposeBatch,
+		})
+		if err != nil {
+			return fmt.Errorf("upload da entrada: %w", err)
+		}
+		batch, err = client.Batches.New(ctx, openai.BatchNewParams{
+			CompletionWindow: openai.BatchNewParamsCompletionWindow24h,
+			Endpoint:         openai.BatchNewParamsEndpointV1ChatCompletions,
+			InputFileID:      file.ID,
+			Metadata:         shared.Metadata{"gptcli_input": truncate(name, 500), "gptcli_model": st.model},
+		})
+		return err
+	})
+	flushTelemetry()
+	if err != nil {
+		return err
+	}
+	fmt.Println(batch.ID)
+	fmt.Fprintf(os.Stderr, "(lote criado: %d pedido(s) para %s, status %s)\n", len(items), st.model, batch.Status)
+	if !*wait {
+		fmt.Fprintf(os.Stderr, "acompanhe com: gptcli batch status --wait %s\n", batch.ID)
+		return nil
+	}
+	if batch, err = waitBatch(client, batch.ID, 30*time.Second); err != nil {
+		return err
+	}
+	return fetchBatch(client, batch, *out, false)
+}
+
+func batchStatus(args []string) error {
+	fs := flag.NewFlagSet("batch status", flag.ExitOnError)
+	flags := commonFlags(fs)
+	wait := fs.Bool("wait", false, "espera o lote terminar (completed, failed, expired ou cancelled)")
+	interval := fs.Duration("interval", 30*time.Second, "com --wait: intervalo entre as consultas")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "uso: gptcli batch status [--wait] [id]")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	_, client, err := resolveClient(flags)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	if fs.NArg() == 0 {
+		page, err := client.Batches.List(ctx, openai.BatchListParams{Limit: openai.Int(20)})
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tSTATUS\tCONCLUÍDOS\tFALHAS\tENTRADA\tCRIADO")
+		for _, b := range page.Data {
+			fmt.Fprintf(tw, "%s\t%s\t%d/%d\t%d\t%s\t%s\n", b.ID, b.Status, b.RequestCounts.Completed, b.RequestCounts.Total,
+				b.RequestCounts.Failed, chooseNonEmpty(b.Metadata["gptcli_input"], "-"), time.Unix(b.CreatedAt, 0).Local().Format("2006-01-02 15:04"))
+		}
+		return tw.Flush()
+	}
+
+	id := fs.Arg(0)
+	var batch *openai.Batch
+	if *wait {
+		batch, err = waitBatch(client, id, *interval)
+	} else {
+		batch, err = client.Batches.Get(ctx, id)
+	}
+	if err != nil {
+		return err
+	}
+	printBatch(batch)
+	if batch.Status == openai.BatchStatusCompleted {
+		fmt.Fprintf(os.Stderr, "baixe com: gptcli batch fetch %s\n", batch.ID)
+	}
+	return nil
+}
+
+func batchFetch(args []string) error {
+	fs := flag.NewFlagSet("batch fetch", flag.ExitOnError)
+	flags := commonFlags(fs)
+	out := fs.String("out", "", "arquivo de resultados JSONL (default: stdout)")
+	raw := fs.Bool("raw", false, "grava as linhas como o provedor devolveu (com uso de tokens e request ids)")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "uso: gptcli batch fetch [--out arquivo] [--raw] <id>")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	_, client, err := resolveClient(flags)
+	if err != nil {
+		return err
+	}
+	batch, err := client.Batches.Get(context.Background(), fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	return fetchBatch(client, batch, *out, *raw)
+}
+
+func printBatch(b *openai.Batch) {
+	fmt.Printf("lote:      %s\n", b.ID)
+	fmt.Printf("status:    %s\n", b.Status)
+	if in := b.Metadata["gptcli_input"]; in != "" {
+		fmt.Printf("entrada:   %s (%s)\n", in, chooseNonEmpty(b.Metadata["gptcli_model"], "?"))
+	}
+	c := b.RequestCounts
+	fmt.Printf("pedidos:   %d/%d concluído(s), %d falha(s)\n", c.Completed, c.Total, c.Failed)
+	fmt.Printf("criado:    %s\n", time.Unix(b.CreatedAt, 0).Local().Format("2006-01-02 15:04"))
+	if b.ExpiresAt > 0 && !batchDone(b.Status) {
+		fmt.Printf("expira:    %s\n", time.Unix(b.ExpiresAt, 0).Local().Format("2006-01-02 15:04"))
+	}
+	for _, e := range b.Errors.Data {
+		if e.Line > 0 {
+			fmt.Printf("erro:      linha %d: %s (%s)\n", e.Line, e.Message, e.Code)
+		} else {
+			fmt.Printf("erro:      %s (%s)\n", e.Message, e.Code)
+		}
+	}
+}
+
+func batchDone(s openai.BatchStatus) bool {
+	switch s {
+	case openai.BatchStatusCompleted, openai.BatchStatusFailed, openai.BatchStatusExpired, openai.BatchStatusCancelled:
+		return true
+	}
+	return false
+}
+
+// waitBatch consulta o lote até ele terminar; Ctrl+C só para de esperar.
+func waitBatch(client openai.Client, id string, interval time.Duration) (*openai.Batch, error) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	last := ""
+	for {
+		b, err := client.Batches.Get(ctx, id)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, fmt.Errorf("espera interrompida; o lote %s continua no provedor", id)
+			}
+			return nil, err
+		}
+		line := fmt.Sprintf("%s %d/%d", b.Status, b.RequestCounts.Completed, b.RequestCounts.Total)
+		if line != last {
+			fmt.Fprintf(os.Stderr, "[%s] %s\n", time.Now().Format("15:04:05"), line)
+			last = line
+		}
+		if batchDone(b.Status) {
+			return b, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("espera interrompida; o lote %s continua no provedor", id)
+		case <-time.After(interval):
+		}
+	}
+}
+
+// fetchBatch baixa os arquivos de saída e de erros do lote e os converte
+// para o JSONL do `batch run`.
+func fetchBatch(client openai.Client, b *openai.Batch, path string, raw bool) error {
+	if b.OutputFileID == "" && b.ErrorFileID == "" {
+		if !batchDone(b.Status) {
+			return fmt.Errorf("o lote %s ainda está em %s; espere com: gptcli batch status --wait %s", b.ID, b.Status, b.ID)
+		}
+		return fmt.Errorf("o lote %s terminou em %s sem resultados", b.ID, b.Status)
+	}
+	w := os.Stdout
+	if path != "" {
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	ctx := context.Background()
+	var ok, failed int
+	var usage openai.CompletionUsage
+	for _, fileID := range []string{b.OutputFileID, b.ErrorFileID} {
+		if fileID == "" {
+			continue
+		}
+		resp, err := client.Files.Content(ctx, fileID)
+		if err != nil {
+			return fmt.Errorf("download de %s: %w", fileID, err)
+		}
+		err = func() error {
+			defer resp.Body.Close()
+			if raw {
+				_, err := io.Copy(w, resp.Body)
+				return err
+			}
+			sc := bufio.NewScanner(resp.Body)
+			sc.Buffer(make([]byte, 0, 64<<10), 16<<20)
+			for sc.Scan() {
+				if len(bytes.TrimSpace(sc.Bytes())) == 0 {
+					continue
+				}
+				var line batchResponse
+				if err := json.Unmarshal(sc.Bytes(), &line); err != nil {
+					return fmt.Errorf("%s: linha inválida: %w", fileID, err)
+				}
+				r := batchResult{ID: line.CustomID}
+				switch {
+				case line.Error != nil:
+					r.Error = chooseNonEmpty(line.Error.Message, line.Error.Code)
+				case line.Response == nil:
+					r.Error = "resposta vazia"
+				case line.Response.StatusCode != 200:
+					r.Error = fmt.Sprintf("HTTP %d (request-id: %s)", line.Response.StatusCode, line.Response.RequestID)
+				case len(line.Response.Body.Choices) == 0:
+					r.Error = "resposta sem choices"
+				default:
+					r.Output = line.Response.Body.Choices[0].Message.Content
+					u := line.Response.Body.Usage
+					usage.PromptTokens += u.PromptTokens
+					usage.CompletionTokens += u.CompletionTokens
+					usage.TotalTokens += u.TotalTokens
+				}
+				if r.Error != "" {
+					failed++
+				} else {
+					ok++
+				}
+				if err := enc.Encode(r); err != nil {
+					return err
+				}
+			}
+			return sc.Err()
+		}()
+		if err != nil {
+			return err
+		}
+	}
+	if !raw {
+		fmt.Fprintf(os.Stderr, "(%d resposta(s), %d erro(s), %d tokens)\n", ok, failed, usage.TotalTokens)
+	}
+	if b.Status != openai.BatchStatusCompleted {
+		return errors.New("lote terminou em " + string(b.Status) + "; os resultados acima são parciais")
+	}
+	return nil
+}
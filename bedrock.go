@@ -0,0 +1,737 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/openai/openai-go/v2/option"
+)
+
+// ===================== AWS Bedrock =====================
+//
+// `provider: bedrock` no profile manda o chat para o Bedrock Runtime em vez
+// da API da OpenAI. O resto do gptcli continua falando chat/completions: um
+// middleware do cliente traduz cada pedido para a Converse API (converse ou
+// converse-stream), assina com SigV4 e devolve a resposta no formato da
+// OpenAI, inclusive o stream (SSE) e os tool calls. Só o chat é suportado;
+// imagens, áudio, embeddings etc. dão erro.
+//
+// Credenciais, na ordem: AWS_BEARER_TOKEN_BEDROCK (chave de API do Bedrock),
+// AWS_ACCESS_KEY_ID/AWS_SECRET_ACCESS_KEY (+ AWS_SESSION_TOKEN) e o perfil de
+// ~/.aws/credentials (aws_profile, AWS_PROFILE ou default).
+
+// BedrockConfig é a seção bedrock do profile.
+type BedrockConfig struct {
+	Region     string            `yaml:"region,omitempty"`      // default: AWS_REGION, AWS_DEFAULT_REGION ou o do ~/.aws/config
+	AWSProfile string            `yaml:"aws_profile,omitempty"` // perfil de ~/.aws/credentials; default AWS_PROFILE ou default
+	Models     map[string]string `yaml:"models,omitempty"`      // nome usado no gptcli => model ID ou ARN de inference profile
+}
+
+type awsCredentials struct {
+	accessKey, secretKey, sessionToken string
+	bearer                             string // AWS_BEARER_TOKEN_BEDROCK: dispensa o SigV4
+}
+
+// bedrockTarget é o destino resolvido por resolveSettings.
+type bedrockTarget struct {
+	endpoint string // https://bedrock-runtime.<região>.amazonaws.com, ou a base_url
+	region   string
+	creds    awsCredentials
+	models   map[string]string
+}
+
+func newBedrockTarget(cfg BedrockConfig, baseURL string) (*bedrockTarget, error) {
+	awsProfile := chooseNonEmpty(cfg.AWSProfile, os.Getenv("AWS_PROFILE"), "default")
+	region := chooseNonEmpty(cfg.Region, os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION"))
+	if region == "" {
+		sec := "profile " + awsProfile
+		if awsProfile == "default" {
+			sec = "default"
+		}
+		region = awsINI(chooseNonEmpty(os.Getenv("AWS_CONFIG_FILE"), filepath.Join(homeDir(), ".aws", "config")))[sec]["region"]
+	}
+	if region == "" {
+		return nil, errors.New("bedrock: defina bedrock.region no profile ou AWS_REGION")
+	}
+	b := &bedrockTarget{region: region, models: cfg.Models}
+	b.endpoint = "https://bedrock-runtime." + region + ".amazonaws.com"
+	if baseURL != "" { // endpoint de VPC, proxy corporativo...
+		u, err := url.Parse(baseURL)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("base_url inválida %q", baseURL)
+		}
+		b.endpoint = u.Scheme + "://" + u.Host
+	}
+
+	switch {
+	case os.Getenv("AWS_BEARER_TOKEN_BEDROCK") != "":
+		b.creds.bearer = strings.TrimSpace(os.Getenv("AWS_BEARER_TOKEN_BEDROCK"))
+	case os.Getenv("AWS_ACCESS_KEY_ID") != "":
+		b.creds = awsCredentials{
+			accessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+			secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		}
+	default:
+		sec := awsINI(chooseNonEmpty(os.Getenv("AWS_SHARED_CREDENTIALS_FILE"), filepath.Join(homeDir(), ".aws", "credentials")))[awsProfile]
+		b.creds = awsCredentials{
+			accessKey:    sec["aws_access_key_id"],
+			secretKey:    sec["aws_secret_access_key"],
+			sessionToken: sec["aws_session_token"],
+		}
+	}
+	if b.creds.bearer == "" && (b.creds.accessKey == "" || b.creds.secretKey == "") {
+		return nil, fmt.Errorf("bedrock: sem credenciais AWS (defina AWS_ACCESS_KEY_ID/AWS_SECRET_ACCESS_KEY, o perfil %q em ~/.aws/credentials ou AWS_BEARER_TOKEN_BEDROCK)", awsProfile)
+	}
+	return b, nil
+}
+
+func homeDir() string {
+	h, _ := os.UserHomeDir()
+	return h
+}
+
+// awsINI lê um arquivo no formato de ~/.aws/credentials: seção => chave => valor.
+func awsINI(path string) map[string]map[string]string {
+	out := map[string]map[string]string{}
+	f, err := os.Open(path)
+	if err != nil {
+		return out
+	}
+	defer f.Close()
+	var cur map[string]string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		ln := strings.TrimSpace(sc.Text())
+		switch {
+		case ln == "" || strings.HasPrefix(ln, "#") || strings.HasPrefix(ln, ";"):
+		case strings.HasPrefix(ln, "[") && strings.HasSuffix(ln, "]"):
+			name := strings.TrimSpace(ln[1 : len(ln)-1])
+			if out[name] == nil {
+				out[name] = map[string]string{}
+			}
+			cur = out[name]
+		case cur != nil:
+			if k, v, ok := strings.Cut(ln, "="); ok {
+				cur[strings.TrimSpace(k)] = strings.TrimSpace(v)
+			}
+		}
+	}
+	return out
+}
+
+// modelID aplica bedrock.models; nomes fora do mapa vão como vieram.
+func (b *bedrockTarget) modelID(model string) (string, error) {
+	if id, ok := b.models[model]; ok {
+		return id, nil
+	}
+	if strings.HasPrefix(model, "gpt-") {
+		return "", fmt.Errorf("bedrock: %q não é um modelo do Bedrock; defina model no profile (ex: anthropic.claude-sonnet-4-20250514-v1:0) ou um apelido em bedrock.models", model)
+	}
+	return model, nil
+}
+
+func (b *bedrockTarget) options() []option.RequestOption {
+	return []option.RequestOption{
+		option.WithBaseURL(b.endpoint + "/"),
+		option.WithMiddleware(b.middleware),
+	}
+}
+
+func (b *bedrockTarget) middleware(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+	if req.Method != http.MethodPost || !strings.HasSuffix(req.URL.Path, "/chat/completions") {
+		return nil, fmt.Errorf("bedrock: %s não é suportado (só chat)", strings.TrimPrefix(req.URL.Path, "/"))
+	}
+	if req.Body == nil {
+		return nil, errors.New("bedrock: pedido sem corpo")
+	}
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	var in chatRequest
+	if err := json.Unmarshal(body, &in); err != nil {
+		return nil, fmt.Errorf("bedrock: %w", err)
+	}
+	modelID, err := b.modelID(in.Model)
+	if err != nil {
+		return nil, err
+	}
+	conv, err := in.toConverse()
+	if err != nil {
+		return nil, err
+	}
+	payload, err := json.Marshal(conv)
+	if err != nil {
+		return nil, err
+	}
+	action := "converse"
+	if in.Stream {
+		action = "converse-stream"
+	}
+	u, _ := url.Parse(b.endpoint)
+	u.Path = "/model/" + modelID + "/" + action
+	u.RawPath = "/model/" + awsEscape(modelID) + "/" + action
+	req.URL, req.Host = u, ""
+	req.Body = io.NopCloser(bytes.NewReader(payload))
+	req.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(payload)), nil }
+	req.ContentLength = int64(len(payload))
+	req.Header.Del("Authorization")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if in.Stream {
+		req.Header.Set("Accept", "application/vnd.amazon.eventstream")
+	}
+	if b.creds.bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+b.creds.bearer)
+	} else {
+		signV4(req, payload, b.creds, b.region, "bedrock", time.Now())
+	}
+
+	resp, err := next(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		return bedrockErrorResponse(resp), nil
+	}
+	if in.Stream {
+		return bedrockStreamResponse(resp, in.Model), nil
+	}
+	return bedrockConverseResponse(resp, in.Model)
+}
+
+// ---------- pedido: chat/completions => Converse ----------
+
+type chatRequest struct {
+	Model               string          `json:"model"`
+	Messages            []chatMessage   `json:"messages"`
+	MaxTokens           int64           `json:"max_tokens"`
+	MaxCompletionTokens int64           `json:"max_completion_tokens"`
+	Temperature         *float64        `json:"temperature"`
+	TopP                *float64        `json:"top_p"`
+	Stop                json.RawMessage `json:"stop"` // texto ou lista
+	Stream              bool            `json:"stream"`
+	Tools               []struct {
+		Function struct {
+			Name        string          `json:"name"`
+			Description string          `json:"description"`
+			Parameters  json.RawMessage `json:"parameters"`
+		} `json:"function"`
+	} `json:"tools"`
+}
+
+type chatMessage struct {
+	Role       string          `json:"role"`
+	Content    json.RawMessage `json:"content"` // texto ou lista de partes
+	ToolCallID string          `json:"tool_call_id"`
+	ToolCalls  []struct {
+		ID       string `json:"id"`
+		Function struct {
+			Name      string `json:"name"`
+			Arguments string `json:"arguments"`
+		} `json:"function"`
+	} `json:"tool_calls"`
+}
+
+type converseMessage struct {
+	Role    string           `json:"role"`
+	Content []map[string]any `json:"content"`
+}
+
+// parts converte o content da OpenAI em blocos da Converse (texto e imagens
+// em data URL).
+func (m chatMessage) parts() ([]map[string]any, error) {
+	if len(m.Content) == 0 || string(m.Content) == "null" {
+		return nil, nil
+	}
+	var text string
+	if json.Unmarshal(m.Content, &text) == nil {
+		if strings.TrimSpace(text) == "" {
+			return nil, nil // a Converse recusa blocos de texto vazios
+		}
+		return []map[string]any{{"text": text}}, nil
+	}
+	var list []struct {
+		Type     string `json:"type"`
+		Text     string `json:"text"`
+		ImageURL struct {
+			URL string `json:"url"`
+		} `json:"image_url"`
+	}
+	if err := json.Unmarshal(m.Content, &list); err != nil {
+		return nil, fmt.Errorf("bedrock: content inesperado: %w", err)
+	}
+	var out []map[string]any
+	for _, p := range list {
+		switch p.Type {
+		case "text":
+			if strings.TrimSpace(p.Text) != "" {
+				out = append(out, map[string]any{"text": p.Text})
+			}
+		case "image_url":
+			meta, data, ok := strings.Cut(strings.TrimPrefix(p.ImageURL.URL, "data:"), ",")
+			if !ok || !strings.HasPrefix(p.ImageURL.URL, "data:") || !strings.HasSuffix(meta, ";base64") {
+				return nil, errors.New("bedrock: imagens só de arquivos locais (URLs http não são aceitas)")
+			}
+			format := strings.TrimPrefix(strings.TrimSuffix(meta, ";base64"), "image/")
+			if format == "jpg" {
+				format = "jpeg"
+			}
+			out = append(out, map[string]any{"image": map[string]any{"format": format, "source": map[string]any{"bytes": data}}})
+		default:
+			return nil, fmt.Errorf("bedrock: parte %q não suportada", p.Type)
+		}
+	}
+	return out, nil
+}
+
+func (in chatRequest) toConverse() (map[string]any, error) {
+	var system []map[string]any
+	var msgs []converseMessage
+	add := func(role string, blocks []map[string]any) {
+		if len(blocks) == 0 {
+			return
+		}
+		// a Converse exige papéis alternados: mensagens seguidas se juntam
+		if n := len(msgs); n > 0 && msgs[n-1].Role == role {
+			msgs[n-1].Content = append(msgs[n-1].Content, blocks...)
+			return
+		}
+		msgs = append(msgs, converseMessage{Role: role, Content: blocks})
+	}
+	for _, m := range in.Messages {
+		blocks, err := m.parts()
+		if err != nil {
+			return nil, err
+		}
+		switch m.Role {
+		case "system", "developer":
+			system = append(system, blocks...)
+		case "user":
+			add("user", blocks)
+		case "assistant":
+			for _, c := range m.ToolCalls {
+				var input any = map[string]any{}
+				if strings.TrimSpace(c.Function.Arguments) != "" {
+					if err := json.Unmarshal([]byte(c.Function.Arguments), &input); err != nil {
+						return nil, fmt.Errorf("bedrock: argumentos de %s: %w", c.Function.Name, err)
+					}
+				}
+				blocks = append(blocks, map[string]any{"toolUse": map[string]any{"toolUseId": c.ID, "name": c.Function.Name, "input": input}})
+			}
+			add("assistant", blocks)
+		case "tool":
+			var text string
+			_ = json.Unmarshal(m.Content, &text)
+			add("user", []map[string]any{{"toolResult": map[string]any{
+				"toolUseId": m.ToolCallID,
+				"content":   []map[string]any{{"text": chooseNonEmpty(text, "(vazio)")}},
+			}}})
+		}
+	}
+	out := map[string]any{"messages": msgs}
+	if len(system) > 0 {
+		out["system"] = system
+	}
+	inf := map[string]any{}
+	if n := max(in.MaxCompletionTokens, in.MaxTokens); n > 0 {
+		inf["maxTokens"] = n
+	}
+	if in.Temperature != nil {
+		inf["temperature"] = *in.Temperature
+	}
+	if in.TopP != nil {
+		inf["topP"] = *in.TopP
+	}
+	if len(in.Stop) > 0 {
+		var one string
+		var many []string
+		if json.Unmarshal(in.Stop, &one) == nil && one != "" {
+			inf["stopSequences"] = []string{one}
+		} else if json.Unmarshal(in.Stop, &many) == nil && len(many) > 0 {
+			inf["stopSequences"] = many
+		}
+	}
+	if len(inf) > 0 {
+		out["inferenceConfig"] = inf
+	}
+	if len(in.Tools) > 0 {
+		var tools []map[string]any
+		for _, t := range in.Tools {
+			schema := t.Function.Parameters
+			if len(schema) == 0 {
+				schema = json.RawMessage(`{"type":"object","properties":{}}`)
+			}
+			spec := map[string]any{"name": t.Function.Name, "inputSchema": map[string]any{"json": schema}}
+			if t.Function.Description != "" {
+				spec["description"] = t.Function.Description
+			}
+			tools = append(tools, map[string]any{"toolSpec": spec})
+		}
+		out["toolConfig"] = map[string]any{"tools": tools}
+	}
+	return out, nil
+}
+
+// ---------- resposta: Converse => chat/completions ----------
+
+var bedrockStopReasons = map[string]string{
+	"end_turn":             "stop",
+	"stop_sequence":        "stop",
+	"max_tokens":           "length",
+	"tool_use":             "tool_calls",
+	"content_filtered":     "content_filter",
+	"guardrail_intervened": "content_filter",
+}
+
+func bedrockFinishReason(stop string) string {
+	return chooseNonEmpty(bedrockStopReasons[stop], "stop")
+}
+
+type bedrockUsage struct {
+	InputTokens  int64 `json:"inputTokens"`
+	OutputTokens int64 `json:"outputTokens"`
+}
+
+func (u bedrockUsage) chat() map[string]any {
+	return map[string]any{"prompt_tokens": u.InputTokens, "completion_tokens": u.OutputTokens, "total_tokens": u.InputTokens + u.OutputTokens}
+}
+
+func bedrockCompletionID() string { return fmt.Sprintf("chatcmpl-bedrock-%d", time.Now().UnixNano()) }
+
+// rewrapJSON troca o corpo da resposta por um JSON novo.
+func rewrapJSON(resp *http.Response, v any) *http.Response {
+	data, _ := json.Marshal(v)
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	resp.ContentLength = int64(len(data))
+	resp.Header = resp.Header.Clone()
+	resp.Header.Set("Content-Type", "application/json")
+	resp.Header.Del("Content-Length")
+	return resp
+}
+
+// bedrockErrorResponse põe o erro da AWS no formato de erro da OpenAI, para
+// as mensagens e o retry do SDK funcionarem como sempre.
+func bedrockErrorResponse(resp *http.Response) *http.Response {
+	defer resp.Body.Close()
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	var e struct {
+		Message  string `json:"message"`
+		Message2 string `json:"Message"`
+	}
+	_ = json.Unmarshal(raw, &e)
+	kind, _, _ := strings.Cut(resp.Header.Get("X-Amzn-Errortype"), ":")
+	msg := chooseNonEmpty(e.Message, e.Message2, strings.TrimSpace(string(raw)), resp.Status)
+	return rewrapJSON(resp, map[string]any{"error": map[string]any{"message": "bedrock: " + msg, "type": kind, "code": kind}})
+}
+
+func bedrockConverseResponse(resp *http.Response, model string) (*http.Response, error) {
+	defer resp.Body.Close()
+	var out struct {
+		Output struct {
+			Message struct {
+				Content []struct {
+					Text    string `json:"text"`
+					ToolUse *struct {
+						ToolUseID string          `json:"toolUseId"`
+						Name      string          `json:"name"`
+						Input     json.RawMessage `json:"input"`
+					} `json:"toolUse"`
+				} `json:"content"`
+			} `json:"message"`
+		} `json:"output"`
+		StopReason string       `json:"stopReason"`
+		Usage      bedrockUsage `json:"usage"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("bedrock: resposta inválida: %w", err)
+	}
+	var text strings.Builder
+	var calls []map[string]any
+	for _, c := range out.Output.Message.Content {
+		text.WriteString(c.Text)
+		if c.ToolUse != nil {
+			calls = append(calls, map[string]any{
+				"id": c.ToolUse.ToolUseID, "type": "function",
+				"function": map[string]any{"name": c.ToolUse.Name, "arguments": string(c.ToolUse.Input)},
+			})
+		}
+	}
+	msg := map[string]any{"role": "assistant", "content": text.String()}
+	if len(calls) > 0 {
+		msg["tool_calls"] = calls
+	}
+	return rewrapJSON(resp, map[string]any{
+		"id": bedrockCompletionID(), "object": "chat.completion", "created": time.Now().Unix(), "model": model,
+		"choices": []any{map[string]any{"index": 0, "message": msg, "finish_reason": bedrockFinishReason(out.StopReason)}},
+		"usage":   out.Usage.chat(),
+	}), nil
+}
+
+// bedrockStreamResponse converte o event stream do converse-stream em SSE
+// de chat.completion.chunk enquanto ele chega.
+func bedrockStreamResponse(resp *http.Response, model string) *http.Response {
+	pr, pw := io.Pipe()
+	src := resp.Body
+	go func() {
+		defer src.Close()
+		pw.CloseWithError(translateEventStream(src, pw, model))
+	}()
+	resp.Body = pr
+	resp.ContentLength = -1
+	resp.Header = resp.Header.Clone()
+	resp.Header.Set("Content-Type", "text/event-stream")
+	resp.Header.Del("Content-Length")
+	return resp
+}
+
+func translateEventStream(r io.Reader, w io.Writer, model string) error {
+	id, created := bedrockCompletionID(), time.Now().Unix()
+	emit := func(v map[string]any) error {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "data: %s\n\n", data)
+		return err
+	}
+	chunk := func(delta map[string]any, finish any) error {
+		return emit(map[string]any{
+			"id": id, "object": "chat.completion.chunk", "created": created, "model": model,
+			"choices": []any{map[string]any{"index": 0, "delta": delta, "finish_reason": finish}},
+		})
+	}
+	toolIndex := map[int]int{} // contentBlockIndex => índice do tool call
+	br := bufio.NewReader(r)
+	for {
+		headers, payload, err := readEventMessage(br)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if headers[":message-type"] != "event" {
+			var e struct {
+				Message string `json:"message"`
+			}
+			_ = json.Unmarshal(payload, &e)
+			kind := chooseNonEmpty(headers[":exception-type"], headers[":error-code"], "erro")
+			return emit(map[string]any{"error": map[string]any{"message": "bedrock: " + chooseNonEmpty(e.Message, headers[":error-message"], kind), "type": kind}})
+		}
+		var ev struct {
+			ContentBlockIndex int `json:"contentBlockIndex"`
+			Start             struct {
+				ToolUse *struct {
+					ToolUseID string `json:"toolUseId"`
+					Name      string `json:"name"`
+				} `json:"toolUse"`
+			} `json:"start"`
+			Delta struct {
+				Text    string `json:"text"`
+				ToolUse *struct {
+					Input string `json:"input"`
+				} `json:"toolUse"`
+			} `json:"delta"`
+			StopReason string       `json:"stopReason"`
+			Usage      bedrockUsage `json:"usage"`
+		}
+		if err := json.Unmarshal(payload, &ev); err != nil {
+			return fmt.Errorf("bedrock: evento inválido: %w", err)
+		}
+		switch headers[":event-type"] {
+		case "messageStart":
+			err = chunk(map[string]any{"role": "assistant", "content": ""}, nil)
+		case "contentBlockStart":
+			if t := ev.Start.ToolUse; t != nil {
+				idx := len(toolIndex)
+				toolIndex[ev.ContentBlockIndex] = idx
+				err = chunk(map[string]any{"tool_calls": []any{map[string]any{
+					"index": idx, "id": t.ToolUseID, "type": "function",
+					"function": map[string]any{"name": t.Name, "arguments": ""},
+				}}}, nil)
+			}
+		case "contentBlockDelta":
+			if t := ev.Delta.ToolUse; t != nil {
+				err = chunk(map[string]any{"tool_calls": []any{map[string]any{
+					"index": toolIndex[ev.ContentBlockIndex], "function": map[string]any{"arguments": t.Input},
+				}}}, nil)
+			} else if ev.Delta.Text != "" {
+				err = chunk(map[string]any{"content": ev.Delta.Text}, nil)
+			}
+		case "messageStop":
+			err = chunk(map[string]any{}, bedrockFinishReason(ev.StopReason))
+		case "metadata":
+			err = emit(map[string]any{
+				"id": id, "object": "chat.completion.chunk", "created": created, "model": model,
+				"choices": []any{}, "usage": ev.Usage.chat(),
+			})
+		}
+		if err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "data: [DONE]\n\n")
+	return err
+}
+
+// readEventMessage lê uma mensagem do formato application/vnd.amazon.eventstream:
+// prelúdio (tamanho total, tamanho dos cabeçalhos, CRC), cabeçalhos, payload
+// e o CRC da mensagem. Só os cabeçalhos de texto são devolvidos.
+func readEventMessage(r io.Reader) (map[string]string, []byte, error) {
+	var prelude [12]byte
+	if _, err := io.ReadFull(r, prelude[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			err = errors.New("bedrock: event stream cortado")
+		}
+		return nil, nil, err
+	}
+	total := binary.BigEndian.Uint32(prelude[0:4])
+	hlen := binary.BigEndian.Uint32(prelude[4:8])
+	if crc32.ChecksumIEEE(prelude[:8]) != binary.BigEndian.Uint32(prelude[8:12]) {
+		return nil, nil, errors.New("bedrock: CRC inválido no event stream")
+	}
+	if total < 16+hlen || total > 16<<20 {
+		return nil, nil, fmt.Errorf("bedrock: mensagem de event stream com tamanho inválido (%d)", total)
+	}
+	rest := make([]byte, total-12)
+	if _, err := io.ReadFull(r, rest); err != nil {
+		return nil, nil, errors.New("bedrock: event stream cortado")
+	}
+	body := rest[:len(rest)-4]
+	crc := crc32.Update(crc32.ChecksumIEEE(prelude[:]), crc32.IEEETable, body)
+	if crc != binary.BigEndian.Uint32(rest[len(rest)-4:]) {
+		return nil, nil, errors.New("bedrock: CRC inválido no event stream")
+	}
+	headers := map[string]string{}
+	h := body[:hlen]
+	for len(h) > 0 {
+		n := int(h[0])
+		if len(h) < 2+n {
+			return nil, nil, errors.New("bedrock: cabeçalho inválido no event stream")
+		}
+		name, typ := string(h[1:1+n]), h[1+n]
+		h = h[2+n:]
+		size := 0
+		switch typ {
+		case 0, 1: // bool
+		case 2:
+			size = 1
+		case 3:
+			size = 2
+		case 4:
+			size = 4
+		case 5, 8:
+			size = 8
+		case 9:
+			size = 16
+		case 6, 7: // bytes, string: 2 bytes de tamanho
+			if len(h) < 2 {
+				return nil, nil, errors.New("bedrock: cabeçalho inválido no event stream")
+			}
+			size = 2 + int(binary.BigEndian.Uint16(h))
+		default:
+			return nil, nil, fmt.Errorf("bedrock: tipo de cabeçalho desconhecido %d", typ)
+		}
+		if len(h) < size {
+			return nil, nil, errors.New("bedrock: cabeçalho inválido no event stream")
+		}
+		if typ == 7 {
+			headers[name] = string(h[2:size])
+		}
+		h = h[size:]
+	}
+	return headers, body[hlen:], nil
+}
+
+// ---------- SigV4 ----------
+
+// signV4 assina o pedido (host, x-amz-date e o token de sessão) com AWS
+// Signature Version 4.
+func signV4(req *http.Request, payload []byte, creds awsCredentials, region, service string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	day := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.sessionToken)
+	}
+	headers := map[string]string{"host": req.URL.Host, "x-amz-date": amzDate}
+	if creds.sessionToken != "" {
+		headers["x-amz-security-token"] = creds.sessionToken
+	}
+	names := sortedKeys(headers)
+	var canonHeaders strings.Builder
+	for _, k := range names {
+		canonHeaders.WriteString(k + ":" + strings.TrimSpace(headers[k]) + "\n")
+	}
+	signed := strings.Join(names, ";")
+
+	// fora do S3, o caminho entra codificado duas vezes
+	segs := strings.Split(req.URL.EscapedPath(), "/")
+	for i, s := range segs {
+		segs[i] = awsEscape(s)
+	}
+	canonURI := chooseNonEmpty(strings.Join(segs, "/"), "/")
+
+	query := req.URL.Query()
+	var pairs []string
+	for _, k := range sortedKeys(query) {
+		vs := append([]string(nil), query[k]...)
+		sort.Strings(vs)
+		for _, v := range vs {
+			pairs = append(pairs, awsEscape(k)+"="+awsEscape(v))
+		}
+	}
+
+	canonical := strings.Join([]string{req.Method, canonURI, strings.Join(pairs, "&"), canonHeaders.String(), signed, sha256Hex(payload)}, "\n")
+	scope := day + "/" + region + "/" + service + "/aws4_request"
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonical))
+	key := []byte("AWS4" + creds.secretKey)
+	for _, part := range []string{day, region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	sig := hex.EncodeToString(hmacSHA256(key, toSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", creds.accessKey, scope, signed, sig))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	m := hmac.New(sha256.New, key)
+	m.Write([]byte(data))
+	return m.Sum(nil)
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// awsEscape codifica tudo fora de A-Z a-z 0-9 - _ . ~, como a AWS espera.
+func awsEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
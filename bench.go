@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	openai "github.com/openai/openai-go/v2"
+)
+
+// ===================== Bench =====================
+//
+// `gptcli bench --n 10` manda o mesmo prompt N vezes, em sequência e com
+// stream, e mede o tempo até o primeiro token, a latência total e a vazão
+// de geração, com percentis. Serve para comparar provedores, proxies e
+// regiões com as mesmas flags do chat (--provider, --base-url, --proxy).
+// Nenhuma tentativa é repetida: um retry escondido distorceria a latência.
+
+// benchPrompt é o prompt padrão: curto de mandar e com uma resposta longa o
+// bastante para a vazão significar alguma coisa.
+const benchPrompt = "Escreva os números de 1 a 60 por extenso, um por linha, sem mais nada."
+
+type benchRun struct {
+	TTFT   time.Duration
+	Total  time.Duration
+	Tokens int64 // tokens de saída (estimados se o provedor não informar uso)
+	Err    error
+}
+
+// tokensPerSec é a vazão depois do primeiro token, sem a espera inicial.
+func (r benchRun) tokensPerSec() float64 {
+	gen := r.Total - r.TTFT
+	if r.Tokens <= 1 || gen <= 0 {
+		return 0
+	}
+	return float64(r.Tokens-1) / gen.Seconds()
+}
+
+// benchStats resume uma métrica sobre as execuções que deram certo.
+type benchStats struct {
+	P50  float64 `json:"p50"`
+	P90  float64 `json:"p90"`
+	P99  float64 `json:"p99"`
+	Min  float64 `json:"min"`
+	Max  float64 `json:"max"`
+	Mean float64 `json:"mean"`
+}
+
+type benchReport struct {
+	Model        string     `json:"model"`
+	BaseURL      string     `json:"base_url,omitempty"`
+	Runs         int        `json:"runs"`
+	Errors       int        `json:"errors"`
+	TTFTMs       benchStats `json:"ttft_ms"`
+	TotalMs      benchStats `json:"total_ms"`
+	TokensPerSec benchStats `json:"tokens_per_sec"`
+	OutputTokens benchStats `json:"output_tokens"`
+}
+
+func benchCmd(args []string) error {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	flags := commonFlags(fs)
+	n := fs.Int("n", 10, "execuções medidas")
+	warmup := fs.Int("warmup", 1, "execuções iniciais descartadas (conexão TLS, cache do provedor)")
+	promptFile := fs.String("prompt-file", "", "arquivo com o prompt (- = stdin); sem ele, usa os argumentos ou um prompt padrão")
+	asJSON := fs.Bool("json", false, "imprime o relatório em JSON")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, `uso: gptcli bench [--n 10] [--warmup 1] [--prompt-file p.txt | "prompt"] [--json] [flags]`)
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if *n < 1 || *warmup < 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	prompt, err := benchPromptText(*promptFile, fs.Args())
+	if err != nil {
+		return err
+	}
+
+	cfg, _ := loadConfig()
+	st, err := resolveSettings(cfg, flags)
+	if err != nil {
+		return err
+	}
+	initAppLog(st.logLevel, st.profName, st.personaName)
+	// sem retry no SDK nem no gptcli: cada número é de um pedido só
+	st.retry.Attempts = 1
+	client, err := buildClient(st)
+	if err != nil {
+		return err
+	}
+
+	ctx := withRetryConfig(context.Background(), st.retry)
+	fmt.Fprintf(os.Stderr, "bench: %s • %s • %d execuções", st.model, chooseNonEmpty(st.baseURL, "api.openai.com"), *n)
+	if *warmup > 0 {
+		fmt.Fprintf(os.Stderr, " (+%d de aquecimento)", *warmup)
+	}
+	fmt.Fprintln(os.Stderr)
+	for i := 1; i <= *warmup; i++ {
+		if r := benchOnce(ctx, client, st, prompt); r.Err != nil {
+			fmt.Fprintf(os.Stderr, "aquecimento %d: erro: %v\n", i, r.Err)
+		}
+	}
+	var runs []benchRun
+	for i := 1; i <= *n; i++ {
+		r := benchOnce(ctx, client, st, prompt)
+		if r.Err != nil {
+			fmt.Fprintf(os.Stderr, "%d/%d: erro: %v\n", i, *n, r.Err)
+		} else {
+			fmt.Fprintf(os.Stderr, "%d/%d: primeiro token %s • total %s • %d tokens • %.1f tokens/s\n",
+				i, *n, r.TTFT.Round(time.Millisecond), r.Total.Round(time.Millisecond), r.Tokens, r.tokensPerSec())
+		}
+		runs = append(runs, r)
+	}
+	flushTelemetry()
+
+	report := newBenchReport(st, runs)
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			return err
+		}
+	} else if report.Errors < report.Runs {
+		printBenchReport(os.Stdout, report)
+	}
+	if report.Errors == report.Runs {
+		return fmt.Errorf("todas as %d execuções falharam", report.Runs)
+	}
+	return nil
+}
+
+func benchPromptText(file string, args []string) (string, error) {
+	var prompt string
+	switch {
+	case file == "-":
+		b, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return "", err
+		}
+		prompt = string(b)
+	case file != "":
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return "", err
+		}
+		prompt = string(b)
+	case len(args) > 0:
+		prompt = strings.Join(args, " ")
+	default:
+		return benchPrompt, nil
+	}
+	if strings.TrimSpace(prompt) == "" {
+		return "", errors.New("prompt vazio")
+	}
+	return prompt, nil
+}
+
+// benchOnce faz um turno isolado e mede o primeiro token e o total.
+func benchOnce(ctx context.Context, client openai.Client, st *settings, prompt string) (r benchRun) {
+	sess := &Session{}
+	sess.addSystem(st.system)
+	sess.addUser(prompt)
+	var usage openai.CompletionUsage
+	ctx = withUsageTotals(ctx, &usage)
+	var out string
+	r.Err = logOp(ctx, "bench", st.model, func(ctx context.Context) error {
+		started := time.Now()
+		var err error
+		out, err = streamChat(ctx, client, sess, st.model, st.temp, st.maxTokens, func(string) {
+			if r.TTFT == 0 {
+				r.TTFT = time.Since(started)
+			}
+		})
+		r.Total = time.Since(started)
+		return err
+	})
+	r.Tokens = usage.CompletionTokens
+	if r.Tokens == 0 {
+		r.Tokens = int64(estimateTokens(out))
+	}
+	if r.Err == nil && r.TTFT == 0 {
+		r.Err = errors.New("resposta vazia")
+	}
+	return r
+}
+
+func newBenchReport(st *settings, runs []benchRun) benchReport {
+	rep := benchReport{Model: st.model, BaseURL: st.baseURL, Runs: len(runs)}
+	var ttft, total, rate, tokens []float64
+	for _, r := range runs {
+		if r.Err != nil {
+			rep.Errors++
+			continue
+		}
+		ttft = append(ttft, float64(r.TTFT.Milliseconds()))
+		total = append(total, float64(r.Total.Milliseconds()))
+		rate = append(rate, r.tokensPerSec())
+		tokens = append(tokens, float64(r.Tokens))
+	}
+	rep.TTFTMs, rep.TotalMs = newBenchStats(ttft), newBenchStats(total)
+	rep.TokensPerSec, rep.OutputTokens = newBenchStats(rate), newBenchStats(tokens)
+	return rep
+}
+
+func newBenchStats(values []float64) benchStats {
+	if len(values) == 0 {
+		return benchStats{}
+	}
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+	sum := 0.0
+	for _, v := range sorted {
+		sum += v
+	}
+	round := func(v float64) float64 { return math.Round(v*10) / 10 }
+	return benchStats{
+		P50: round(percentile(sorted, 50)), P90: round(percentile(sorted, 90)), P99: round(percentile(sorted, 99)),
+		Min: round(sorted[0]), Max: round(sorted[len(sorted)-1]), Mean: round(sum / float64(len(sorted))),
+	}
+}
+
+// percentile usa o método nearest-rank sobre valores já ordenados.
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[min(max(rank, 1), len(sorted))-1]
+}
+
+func printBenchReport(w io.Writer, rep benchReport) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "\tp50\tp90\tp99\tmín\tmáx\tmédia\t")
+	for _, row := range []struct {
+		name string
+		s    benchStats
+		unit string
+	}{
+		{"primeiro token", rep.TTFTMs, "ms"},
+		{"total", rep.TotalMs, "ms"},
+		{"tokens/s", rep.TokensPerSec, ""},
+		{"tokens de saída", rep.OutputTokens, ""},
+	} {
+		s := row.s
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t\n", row.name,
+			benchValue(s.P50, row.unit), benchValue(s.P90, row.unit), benchValue(s.P99, row.unit),
+			benchValue(s.Min, row.unit), benchValue(s.Max, row.unit), benchValue(s.Mean, row.unit))
+	}
+	_ = tw.Flush()
+	if rep.Errors > 0 {
+		fmt.Fprintf(w, "%d de %d execuções falharam (fora das estatísticas)\n", rep.Errors, rep.Runs)
+	}
+}
+
+func benchValue(v float64, unit string) string {
+	if unit == "ms" {
+		return fmt.Sprintf("%.0fms", v)
+	}
+	return fmt.Sprintf("%.1f", v)
+}
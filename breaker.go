@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	openai "github.com/openai/openai-go/v2"
+)
+
+// ===================== Circuit breaker =====================
+//
+// Modos que processam vários itens (eval, flush) param depois de N falhas
+// seguidas que atingiriam qualquer item: chave recusada, cota esgotada, rede
+// fora do ar. Sem isso, cada item restante gastaria as próprias retentativas
+// para falhar do mesmo jeito. Erros de um item só (um 400 por causa do
+// prompt) não contam e zeram a sequência.
+
+// defaultMaxFailures é o padrão de --max-failures.
+const defaultMaxFailures = 3
+
+type breaker struct {
+	threshold int // 0 desliga
+	streak    int
+	last      error
+}
+
+// breakerFlag registra --max-failures num subcomando de lote.
+func breakerFlag(fs *flag.FlagSet) *int {
+	return fs.Int("max-failures", defaultMaxFailures, "interrompe o lote após N falhas seguidas de chave, cota ou rede (0 = nunca)")
+}
+
+func newBreaker(threshold int) *breaker { return &breaker{threshold: threshold} }
+
+// record anota o resultado de um item e diz se o lote deve parar.
+func (b *breaker) record(err error) bool {
+	if err == nil || !isSystemicError(err) {
+		b.streak, b.last = 0, nil
+		return false
+	}
+	b.streak++
+	b.last = err
+	return b.threshold > 0 && b.streak >= b.threshold
+}
+
+// report imprime no stderr o resumo de um lote interrompido.
+func (b *breaker) report(done, total int) {
+	fmt.Fprintf(os.Stderr, "\nlote interrompido após %d falha(s) seguida(s): %s\n", b.streak, systemicReason(b.last))
+	fmt.Fprintf(os.Stderr, "(%d de %d processado(s), %d pulado(s))\n", done, total, total-done)
+}
+
+// isSystemicError diz se o erro atingiria qualquer chamada, não só esta.
+func isSystemicError(err error) bool {
+	var apiErr *openai.Error
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case 401, 403, 429:
+			return true
+		}
+		return false
+	}
+	return isNetworkError(err)
+}
+
+// isFatalAPIError marca os erros que não adianta repetir: chave recusada e cota esgotada.
+func isFatalAPIError(err error) bool {
+	var apiErr *openai.Error
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.StatusCode == 401 || apiErr.StatusCode == 403 || apiErr.Code == "insufficient_quota"
+}
+
+func systemicReason(err error) string {
+	var apiErr *openai.Error
+	if errors.As(err, &apiErr) {
+		switch {
+		case apiErr.StatusCode == 401:
+			return "chave inválida (HTTP 401)"
+		case apiErr.StatusCode == 403:
+			return "acesso negado (HTTP 403)"
+		case apiErr.Code == "insufficient_quota":
+			return "cota esgotada (HTTP 429)"
+		default:
+			return "limite de requisições (HTTP 429)"
+		}
+	}
+	if err == nil {
+		return ""
+	}
+	return "sem conexão: " + err.Error()
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/openai/openai-go/v2/option"
+)
+
+// ===================== Capacidades dos modelos =====================
+//
+// Nem todo modelo aceita todo parâmetro: os de raciocínio (o1, o3, gpt-5)
+// recusam temperature e pedem max_completion_tokens, alguns não leem
+// imagens e os mais antigos não têm json_schema. Em vez de deixar a API
+// recusar o pedido, sanitizeChat ajusta o corpo do chat/completions conforme
+// a tabela e avisa no stderr (uma vez por modelo e parâmetro). Modelos fora
+// da tabela (outros provedores, servidores locais) passam intactos.
+
+type modelCaps struct {
+	temperature         bool // aceita temperature/top_p
+	maxCompletionTokens bool // usa max_completion_tokens no lugar de max_tokens
+	vision              bool // aceita partes image_url
+	jsonSchema          bool // aceita response_format json_schema
+	jsonObject          bool // aceita response_format json_object
+}
+
+// knownCaps é buscado pelo prefixo mais longo, como knownModels.
+var knownCaps = map[string]modelCaps{
+	"gpt-5":         {false, true, true, true, true},
+	"gpt-5-chat":    {true, false, true, true, true},
+	"gpt-4.1":       {true, false, true, true, true},
+	"gpt-4o":        {true, false, true, true, true},
+	"gpt-4-turbo":   {true, false, true, false, true},
+	"gpt-4":         {true, false, false, false, false},
+	"gpt-3.5-turbo": {true, false, false, false, true},
+	"o1":            {false, true, true, true, true},
+	"o1-mini":       {false, true, false, false, false},
+	"o1-preview":    {false, true, false, false, false},
+	"o3":            {false, true, true, true, true},
+	"o3-mini":       {false, true, false, true, true},
+	"o4-mini":       {false, true, true, true, true},
+}
+
+func lookupCaps(model string) (modelCaps, bool) {
+	best := ""
+	for name := range knownCaps {
+		if strings.HasPrefix(model, name) && len(name) > len(best) {
+			best = name
+		}
+	}
+	caps, ok := knownCaps[best]
+	return caps, ok
+}
+
+var capsNoted sync.Map
+
+// capsNote avisa no stderr uma vez por modelo e assunto.
+func capsNote(model, key, format string, args ...any) {
+	if _, seen := capsNoted.LoadOrStore(model+"\x00"+key, true); seen {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "nota: "+format+"\n", args...)
+}
+
+// sanitizeMiddleware aplica sanitizeChat a cada chamada de chat. Com
+// tempExplicit falso a temperature não veio do usuário e é removida sem nota.
+func sanitizeMiddleware(tempExplicit bool) option.Middleware {
+	return func(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+		if req.Method == http.MethodPost && strings.HasSuffix(req.URL.Path, "/chat/completions") {
+			if err := rewriteJSONBody(req, func(m map[string]any) bool { return sanitizeChat(m, tempExplicit) }); err != nil {
+				return nil, err
+			}
+		}
+		return next(req)
+	}
+}
+
+// sanitizeChat remove ou traduz os parâmetros que o modelo não aceita.
+func sanitizeChat(m map[string]any, tempExplicit bool) bool {
+	model, _ := m["model"].(string)
+	caps, ok := lookupCaps(model)
+	if !ok {
+		return false
+	}
+	changed := false
+	if !caps.temperature {
+		for _, k := range []string{"temperature", "top_p"} {
+			if _, ok := m[k]; ok {
+				delete(m, k)
+				changed = true
+				if !tempExplicit {
+					continue
+				}
+				capsNote(model, k, "%s não aceita %s; parâmetro removido", model, k)
+			}
+		}
+	}
+	if v, ok := m["max_tokens"]; ok && caps.maxCompletionTokens {
+		delete(m, "max_tokens")
+		if _, ok := m["max_completion_tokens"]; !ok {
+			m["max_completion_tokens"] = v
+		}
+		changed = true
+		capsNote(model, "max_tokens", "%s usa max_completion_tokens; max_tokens traduzido", model)
+	}
+	if !caps.vision {
+		dropped := 0
+		msgs, _ := m["messages"].([]any)
+		for _, raw := range msgs {
+			msg, _ := raw.(map[string]any)
+			parts, ok := msg["content"].([]any)
+			if !ok {
+				continue
+			}
+			kept := parts[:0]
+			for _, p := range parts {
+				if part, _ := p.(map[string]any); part["type"] == "image_url" {
+					dropped++
+					continue
+				}
+				kept = append(kept, p)
+			}
+			msg["content"] = kept
+		}
+		if dropped > 0 {
+			changed = true
+			capsNote(model, "vision", "%s não lê imagens; %d imagem(ns) removida(s) do pedido", model, dropped)
+		}
+	}
+	if rf, _ := m["response_format"].(map[string]any); rf != nil {
+		switch {
+		case rf["type"] == "json_schema" && !caps.jsonSchema && caps.jsonObject:
+			m["response_format"] = map[string]any{"type": "json_object"}
+			changed = true
+			capsNote(model, "json_schema", "%s não aceita json_schema; usando json_object (o schema ainda é validado na resposta)", model)
+		case rf["type"] == "json_schema" && !caps.jsonSchema, rf["type"] == "json_object" && !caps.jsonObject:
+			delete(m, "response_format")
+			changed = true
+			capsNote(model, "response_format", "%s não aceita response_format %s; parâmetro removido", model, rf["type"])
+		}
+	}
+	return changed
+}
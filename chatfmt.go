@@ -0,0 +1,327 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// ===================== Formatos slack e discord =====================
+//
+// --format slack|discord converte o Markdown da resposta para o dialeto de
+// cada plataforma e a divide em mensagens que cabem no limite dela, para o
+// stdout ir direto para um webhook de chat. O stream ao vivo vai para o
+// stderr (como em --stream-to stderr) e o stdout recebe só o resultado
+// convertido, com as partes separadas por outputChunkDelim.
+//
+// --chunk N faz a mesma divisão em qualquer formato (menos json), para
+// destinos com limite de tamanho por mensagem; --chunk-delim troca o
+// separador.
+
+// chatMessageLimit é o tamanho máximo de cada parte, em caracteres.
+var chatMessageLimit = map[string]int{
+	"slack":   4000, // acima disso o Slack corta ou esconde o texto
+	"discord": 2000, // limite do campo content
+}
+
+// minChunk é o menor --chunk aceito: cada parte precisa caber a reabertura
+// de um bloco de código.
+const minChunk = 100
+
+// outputChunk e outputChunkDelim são definidos por configureOutput
+// (--chunk, --chunk-delim); 0 = limite do formato.
+var (
+	outputChunk      int
+	outputChunkDelim = "---"
+)
+
+func isChatFormat(format string) bool {
+	_, ok := chatMessageLimit[strings.ToLower(format)]
+	return ok
+}
+
+// renderFinal converte a resposta para o formato e a divide no --chunk ou no
+// limite da plataforma; sem nenhum dos dois, devolve o texto numa parte só.
+func renderFinal(text, format string) []string {
+	format = strings.ToLower(format)
+	switch format {
+	case "slack":
+		text = toSlack(text)
+	case "discord":
+		text = toDiscord(text)
+	}
+	return splitChunks(text, chooseInt(outputChunk, chatMessageLimit[format]))
+}
+
+// joinChunks junta as partes com o separador numa linha própria.
+func joinChunks(parts []string) string {
+	return strings.Join(parts, "\n"+outputChunkDelim+"\n")
+}
+
+var (
+	mdFenceRe     = regexp.MustCompile("^\\s*(```|~~~)")
+	mdHeadingRe   = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	mdBulletRe    = regexp.MustCompile(`^(\s*)[-*+]\s+(.*)$`)
+	mdQuoteRe     = regexp.MustCompile(`^(\s*>+\s?)(.*)$`)
+	mdRuleRe      = regexp.MustCompile(`^\s*(?:(?:-\s*){3,}|(?:\*\s*){3,}|(?:_\s*){3,})$`)
+	mdTableSepRe  = regexp.MustCompile(`^\s*\|?\s*:?-{3,}:?\s*(\|\s*:?-{3,}:?\s*)*\|?\s*$`)
+	mdInlineCode  = regexp.MustCompile("`[^`\n]+`")
+	mdImageRe     = regexp.MustCompile(`!\[([^\]]*)\]\(([^)\s]+)\)`)
+	mdLinkRe      = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	mdBoldRe      = regexp.MustCompile(`\*\*(.+?)\*\*|__(.+?)__`)
+	mdItalicRe    = regexp.MustCompile(`\*([^*\s](?:[^*]*[^*\s])?)\*`)
+	mdStrikeRe    = regexp.MustCompile(`~~(.+?)~~`)
+	slackEscaper  = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	boldSentinel  = "\x00"
+	slackFenceRe  = regexp.MustCompile("^(\\s*)(```|~~~).*$")
+	tableLeadRe   = regexp.MustCompile(`^\s*\|`)
+	discordHeadRe = regexp.MustCompile(`^#{4,6}$`)
+)
+
+// chatLines percorre as linhas separando blocos de código (intocados, fora a
+// linha de abertura) de texto, e embrulha tabelas Markdown num bloco de
+// código, já que nenhuma das plataformas as desenha.
+func chatLines(text string, fence func(string) string, line func(string) string) string {
+	lines := strings.Split(text, "\n")
+	var out []string
+	inFence := false
+	for i := 0; i < len(lines); i++ {
+		ln := lines[i]
+		if mdFenceRe.MatchString(ln) {
+			if !inFence {
+				ln = fence(ln)
+			}
+			inFence = !inFence
+			out = append(out, ln)
+			continue
+		}
+		if inFence {
+			out = append(out, ln)
+			continue
+		}
+		if tableLeadRe.MatchString(ln) && i+1 < len(lines) && mdTableSepRe.MatchString(lines[i+1]) {
+			out = append(out, "```")
+			for ; i < len(lines) && tableLeadRe.MatchString(lines[i]); i++ {
+				out = append(out, lines[i])
+			}
+			out = append(out, "```")
+			i--
+			continue
+		}
+		out = append(out, line(ln))
+	}
+	return strings.Join(out, "\n")
+}
+
+// outsideCode aplica conv aos trechos fora de `código inline`.
+func outsideCode(s string, conv, code func(string) string) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range mdInlineCode.FindAllStringIndex(s, -1) {
+		b.WriteString(conv(s[last:m[0]]))
+		b.WriteString(code(s[m[0]:m[1]]))
+		last = m[1]
+	}
+	b.WriteString(conv(s[last:]))
+	return b.String()
+}
+
+// toSlack converte para mrkdwn: *negrito*, _itálico_, ~riscado~, <url|texto>,
+// títulos em negrito, marcadores •, blocos de código sem linguagem e & < >
+// escapados.
+func toSlack(text string) string {
+	inline := func(s string) string {
+		return outsideCode(s, func(s string) string {
+			s = slackEscaper.Replace(s)
+			s = mdImageRe.ReplaceAllStringFunc(s, func(m string) string {
+				g := mdImageRe.FindStringSubmatch(m)
+				if g[1] == "" {
+					return "<" + g[2] + ">"
+				}
+				return "<" + g[2] + "|" + g[1] + ">"
+			})
+			s = mdLinkRe.ReplaceAllString(s, "<$2|$1>")
+			s = mdBoldRe.ReplaceAllString(s, boldSentinel+"$1$2"+boldSentinel)
+			s = mdItalicRe.ReplaceAllString(s, "_${1}_")
+			s = mdStrikeRe.ReplaceAllString(s, "~$1~")
+			return strings.ReplaceAll(s, boldSentinel, "*")
+		}, slackEscaper.Replace)
+	}
+	return chatLines(text,
+		func(fence string) string { return slackFenceRe.ReplaceAllString(fence, "$1$2") },
+		func(ln string) string {
+			if mdRuleRe.MatchString(ln) {
+				return "──────────"
+			}
+			if m := mdHeadingRe.FindStringSubmatch(ln); m != nil {
+				return "*" + strings.ReplaceAll(inline(m[2]), "*", "") + "*"
+			}
+			if m := mdQuoteRe.FindStringSubmatch(ln); m != nil {
+				return strings.ReplaceAll(m[1], " ", "") + " " + inline(m[2])
+			}
+			if m := mdBulletRe.FindStringSubmatch(ln); m != nil {
+				return m[1] + "• " + inline(m[2])
+			}
+			return inline(ln)
+		})
+}
+
+// toDiscord ajusta o Markdown ao do Discord: __x__ (sublinhado lá) vira
+// negrito, títulos além de ### viram negrito, imagens viram a URL (o Discord
+// mostra a prévia) e linhas horizontais somem.
+func toDiscord(text string) string {
+	inline := func(s string) string {
+		return outsideCode(s, func(s string) string {
+			s = mdImageRe.ReplaceAllString(s, "$2")
+			return mdBoldRe.ReplaceAllString(s, "**$1$2**")
+		}, func(code string) string { return code })
+	}
+	return chatLines(text,
+		func(fence string) string { return fence },
+		func(ln string) string {
+			if mdRuleRe.MatchString(ln) {
+				return ""
+			}
+			if m := mdHeadingRe.FindStringSubmatch(ln); m != nil && discordHeadRe.MatchString(m[1]) {
+				return "**" + inline(m[2]) + "**"
+			}
+			return inline(ln)
+		})
+}
+
+// ---------- divisão em partes ----------
+
+// splitChunks divide o texto em partes de até limit caracteres, de preferência
+// entre parágrafos. Um bloco de código dividido é fechado no fim de uma parte
+// e reaberto, com a mesma linguagem, no começo da seguinte.
+func splitChunks(text string, limit int) []string {
+	if limit <= 0 || utf8.RuneCountInString(text) <= limit {
+		return []string{text}
+	}
+	text = strings.TrimSpace(text)
+	var chunks []string
+	var cur strings.Builder
+	flush := func() {
+		if s := strings.TrimSpace(cur.String()); s != "" {
+			chunks = append(chunks, s)
+		}
+		cur.Reset()
+	}
+	add := func(piece string) {
+		if cur.Len() > 0 && utf8.RuneCountInString(cur.String())+2+utf8.RuneCountInString(piece) > limit {
+			flush()
+		}
+		if cur.Len() > 0 {
+			cur.WriteString("\n\n")
+		}
+		cur.WriteString(piece)
+	}
+	for _, block := range markdownBlocks(text) {
+		if utf8.RuneCountInString(block) <= limit {
+			add(block)
+			continue
+		}
+		for _, p := range splitBlock(block, limit) {
+			add(p)
+		}
+	}
+	flush()
+	return chunks
+}
+
+// markdownBlocks separa o texto em parágrafos (linhas em branco fora de
+// blocos de código).
+func markdownBlocks(text string) []string {
+	var blocks []string
+	var cur []string
+	inFence := false
+	for _, ln := range strings.Split(text, "\n") {
+		if mdFenceRe.MatchString(ln) {
+			inFence = !inFence
+		}
+		if !inFence && strings.TrimSpace(ln) == "" {
+			if len(cur) > 0 {
+				blocks = append(blocks, strings.Join(cur, "\n"))
+				cur = nil
+			}
+			continue
+		}
+		cur = append(cur, ln)
+	}
+	if len(cur) > 0 {
+		blocks = append(blocks, strings.Join(cur, "\n"))
+	}
+	return blocks
+}
+
+// fenceReserve é o espaço guardado em cada parte para reabrir e fechar um
+// bloco de código.
+const fenceReserve = 24
+
+// splitBlock divide um parágrafo grande linha a linha; uma linha maior que a
+// parte é dividida entre palavras.
+func splitBlock(block string, limit int) []string {
+	var out, cur []string
+	curLen := 0
+	opener := "" // abertura do bloco de código em andamento
+	push := func(p string, cont bool) {
+		closing := 0
+		if opener != "" {
+			closing = 4 // "\n```"
+		}
+		n := utf8.RuneCountInString(p) + 1
+		if cont && len(cur) > 0 && curLen+n+closing <= limit {
+			cur[len(cur)-1] += " " + p // mesma linha: volta a juntar com espaço
+			curLen += n
+			return
+		}
+		if len(cur) > 0 && curLen+n+closing > limit {
+			chunk := strings.Join(cur, "\n")
+			if opener != "" {
+				chunk += "\n" + strings.TrimSpace(opener)[:3]
+			}
+			out = append(out, chunk)
+			cur, curLen = nil, 0
+			if opener != "" {
+				cur, curLen = []string{opener}, utf8.RuneCountInString(opener)+1
+			}
+		}
+		cur = append(cur, p)
+		curLen += n
+	}
+	for _, ln := range strings.Split(block, "\n") {
+		if utf8.RuneCountInString(ln) <= limit-fenceReserve {
+			push(ln, false)
+		} else {
+			for i, w := range splitWords(ln, max(limit-fenceReserve, 1)) {
+				push(w, i > 0)
+			}
+		}
+		if mdFenceRe.MatchString(ln) {
+			if opener == "" {
+				opener = ln
+			} else {
+				opener = ""
+			}
+		}
+	}
+	if len(cur) > 0 {
+		out = append(out, strings.Join(cur, "\n"))
+	}
+	return out
+}
+
+// splitWords quebra uma linha nas palavras; uma palavra maior que n é
+// cortada em pedaços de n caracteres.
+func splitWords(line string, n int) []string {
+	var out []string
+	for _, w := range strings.Fields(line) {
+		r := []rune(w)
+		for len(r) > n {
+			out = append(out, string(r[:n]))
+			r = r[n:]
+		}
+		out = append(out, string(r))
+	}
+	return out
+}
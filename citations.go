@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// ===================== Citações =====================
+//
+// --citations list|json junta as URLs da resposta (links markdown e URLs
+// soltas, fora de blocos de código) e imprime uma lista numerada de fontes
+// depois do texto. Com json, a lista vai para o stderr num objeto JSON, e o
+// stdout fica só com a resposta.
+
+// citationsMode é definido por configureOutput: "", "list" ou "json".
+var citationsMode string
+
+// citationURL aceita um nível de parênteses, como nas URLs da Wikipedia.
+const citationURL = `https?://[^\s()<>\[\]]+(?:\([^\s()<>]*\)[^\s()<>\[\]]*)*`
+
+var (
+	citationRe   = regexp.MustCompile(`\[([^\]\n]*)\]\((` + citationURL + `)(?:\s+"[^"]*")?\)|(` + citationURL + `)`)
+	inlineCodeRe = regexp.MustCompile("`[^`\n]*`")
+)
+
+type citation struct {
+	N     int    `json:"n"`
+	URL   string `json:"url"`
+	Title string `json:"title,omitempty"`
+}
+
+func parseCitations(v string) (string, error) {
+	switch v {
+	case "", "off":
+		return "", nil
+	case "list", "json":
+		return v, nil
+	}
+	return "", fmt.Errorf("--citations inválido %q (use list, json ou off)", v)
+}
+
+// extractCitations devolve as URLs na ordem em que aparecem, sem repetir.
+func extractCitations(text string) []citation {
+	var cites []citation
+	index := map[string]int{}
+	for _, m := range citationRe.FindAllStringSubmatch(stripCode(text), -1) {
+		title, url := strings.TrimSpace(m[1]), m[2]
+		if url == "" {
+			url = strings.TrimRight(m[3], `.,;:!?'"*_`)
+		}
+		if isCitationNumber(title) || title == url {
+			title = "" // [1](url), [url](url)
+		}
+		if i, ok := index[url]; ok {
+			if cites[i].Title == "" {
+				cites[i].Title = title
+			}
+			continue
+		}
+		index[url] = len(cites)
+		cites = append(cites, citation{N: len(cites) + 1, URL: url, Title: title})
+	}
+	return cites
+}
+
+// stripCode remove blocos ``` e `código` inline: URLs de exemplo não são fontes.
+func stripCode(text string) string {
+	var b strings.Builder
+	inFence := false
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inFence = !inFence
+			continue
+		}
+		if !inFence {
+			b.WriteString(inlineCodeRe.ReplaceAllString(line, " "))
+			b.WriteByte('\n')
+		}
+	}
+	return b.String()
+}
+
+func isCitationNumber(s string) bool {
+	s = strings.Trim(s, "^[]")
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// printCitations imprime as fontes de text conforme citationsMode. Em
+// --format json a lista vai para o stderr, para não quebrar o JSON.
+func printCitations(text, format string) {
+	if citationsMode == "" {
+		return
+	}
+	cites := extractCitations(text)
+	if len(cites) == 0 {
+		return
+	}
+	if citationsMode == "json" {
+		data, _ := json.Marshal(map[string][]citation{"citations": cites})
+		fmt.Fprintln(os.Stderr, string(data))
+		return
+	}
+	var w io.Writer = os.Stdout
+	if strings.ToLower(format) == "json" {
+		w = os.Stderr
+	}
+	fmt.Fprintln(w, "\nFontes:")
+	for _, c := range cites {
+		if c.Title != "" {
+			fmt.Fprintf(w, "[%d] %s — %s\n", c.N, c.Title, c.URL)
+		} else {
+			fmt.Fprintf(w, "[%d] %s\n", c.N, c.URL)
+		}
+	}
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+
+	openai "github.com/openai/openai-go/v2"
+	"github.com/openai/openai-go/v2/shared"
+)
+
+// ===================== Compressão de contexto =====================
+//
+// --compress-context encolhe entradas grandes (o stdin do modo não interativo
+// e os anexos /sh e /web do REPL) antes de irem ao modelo, para caberem em
+// janelas menores. local é extrativo e não faz chamadas: divide o texto em
+// blocos, pontua cada um pelas palavras que mais se repetem no documento (e
+// pelas da pergunta, quando há) e mantém os melhores na ordem original. model
+// pede a um modelo barato que copie os trechos importantes.
+
+// compressFlag aceita --compress-context (local) ou --compress-context=modo.
+type compressFlag string
+
+func (f *compressFlag) String() string   { return string(*f) }
+func (f *compressFlag) IsBoolFlag() bool { return true }
+func (f *compressFlag) Set(v string) error {
+	switch {
+	case v == "true":
+		*f = "local"
+	case v == "false":
+		*f = ""
+	case v == "local", v == "model", strings.HasPrefix(v, "model:") && len(v) > len("model:"):
+		*f = compressFlag(v)
+	default:
+		return errors.New("use --compress-context, --compress-context=local ou --compress-context=model[:nome]")
+	}
+	return nil
+}
+
+const (
+	defaultCompressBudget = 16_000 // tokens, para modelos fora da tabela
+	compressBlockChars    = 1500   // blocos maiores são quebrados por linha
+	compressPieceTokens   = 20_000 // cada chamada do modo model
+)
+
+// compressBudget é quanto uma entrada pode ocupar: metade da janela do
+// modelo, deixando o resto para system, histórico e resposta.
+func compressBudget(model string) int {
+	if info, ok := lookupModel(model); ok && info.window > 0 {
+		return info.window / 2
+	}
+	return defaultCompressBudget
+}
+
+// compressInput devolve text comprimido para caber em budget tokens e avisa a
+// economia no stderr. Textos que já cabem passam intactos; se o modelo falhar,
+// cai para o modo local.
+func compressInput(ctx context.Context, client openai.Client, st *settings, label, text, query string, budget int) string {
+	before := estimateTokens(text)
+	if st.compress == "" || before <= budget {
+		return text
+	}
+	mode, out := "local", ""
+	if strings.HasPrefix(st.compress, "model") {
+		model := strings.TrimPrefix(strings.TrimPrefix(st.compress, "model"), ":")
+		if model == "" {
+			// como nos títulos: endpoints customizados raramente têm os modelos baratos da OpenAI
+			model = "gpt-5-nano"
+			if st.baseURL != "" {
+				model = st.model
+			}
+		}
+		var err error
+		out, err = compressWithModel(ctx, client, model, text, query, budget)
+		switch {
+		case err != nil:
+			fmt.Fprintf(os.Stderr, "aviso: compressão com %s falhou (%v); usando a local\n", model, err)
+		case estimateTokens(out) > budget:
+			fmt.Fprintf(os.Stderr, "aviso: %s devolveu mais do que cabe; usando a compressão local\n", model)
+		default:
+			mode = model
+		}
+	}
+	if mode == "local" {
+		out = compressLocal(text, query, budget)
+	}
+	after := estimateTokens(out)
+	fmt.Fprintf(os.Stderr, "(%s comprimido: ~%d → ~%d tokens, -%.0f%%, %s)\n",
+		label, before, after, 100*float64(before-after)/float64(before), mode)
+	return out
+}
+
+// ---------- local ----------
+
+var compressStopwords = map[string]bool{}
+
+func init() {
+	for _, w := range strings.Fields(`the and for that with this from are was were have has not but you your
+		can will all any its into than then there their they them what when which who how why about also more
+		que para com uma por não dos das nos nas como mais mas foi são ser tem pelo pela seu sua isso este esta
+		esse essa aos ele ela eles elas quando onde qual muito também sobre entre até`) {
+		compressStopwords[w] = true
+	}
+}
+
+func compressWords(s string) []string {
+	var out []string
+	for _, w := range strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	}) {
+		if len([]rune(w)) >= 3 && !compressStopwords[w] {
+			out = append(out, w)
+		}
+	}
+	return out
+}
+
+// compressBlocks divide o texto em parágrafos; os longos demais (código sem
+// linha em branco, logs) viram grupos de linhas.
+func compressBlocks(text string) []string {
+	var blocks []string
+	for _, para := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n\n") {
+		if strings.TrimSpace(para) == "" {
+			continue
+		}
+		if len(para) <= compressBlockChars {
+			blocks = append(blocks, para)
+			continue
+		}
+		var cur strings.Builder
+		for _, line := range strings.Split(para, "\n") {
+			if cur.Len() > 0 && cur.Len()+len(line) > compressBlockChars {
+				blocks = append(blocks, cur.String())
+				cur.Reset()
+			}
+			if cur.Len() > 0 {
+				cur.WriteByte('\n')
+			}
+			cur.WriteString(line)
+		}
+		if cur.Len() > 0 {
+			blocks = append(blocks, cur.String())
+		}
+	}
+	return blocks
+}
+
+// compressLocal mantém o primeiro e o último bloco (títulos e instruções
+// costumam estar neles) e os de maior pontuação até encher o orçamento.
+func compressLocal(text, query string, budget int) string {
+	blocks := compressBlocks(text)
+	if len(blocks) == 0 {
+		return text
+	}
+	freq := map[string]int{}
+	for _, b := range blocks {
+		for _, w := range compressWords(b) {
+			freq[w]++
+		}
+	}
+	asked := map[string]bool{}
+	for _, w := range compressWords(query) {
+		asked[w] = true
+	}
+	score := make([]float64, len(blocks))
+	for i, b := range blocks {
+		words := compressWords(b)
+		seen := map[string]bool{}
+		for _, w := range words {
+			if seen[w] {
+				continue
+			}
+			seen[w] = true
+			score[i] += math.Log1p(float64(freq[w]))
+			if asked[w] {
+				score[i] += 5
+			}
+		}
+		score[i] /= math.Sqrt(float64(len(words) + 1)) // blocos longos não ganham só pelo tamanho
+	}
+
+	order := make([]int, 0, len(blocks))
+	for i := 1; i < len(blocks)-1; i++ {
+		order = append(order, i)
+	}
+	sort.SliceStable(order, func(a, b int) bool { return score[order[a]] > score[order[b]] })
+	order = append([]int{0, len(blocks) - 1}, order...)
+
+	keep := make([]bool, len(blocks))
+	used := 0
+	for _, i := range order {
+		cost := estimateTokens(blocks[i]) + 2
+		if used+cost > budget {
+			continue
+		}
+		keep[i] = true
+		used += cost
+	}
+
+	var b strings.Builder
+	gap := false
+	for i, block := range blocks {
+		if !keep[i] {
+			gap = true
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteString("\n\n")
+		}
+		if gap && b.Len() > 0 {
+			b.WriteString("[...]\n\n")
+		}
+		gap = false
+		b.WriteString(block)
+	}
+	if gap {
+		b.WriteString("\n\n[...]")
+	}
+	return b.String()
+}
+
+// ---------- model ----------
+
+// compressWithModel manda o texto em pedaços de até compressPieceTokens,
+// cada um com a sua cota proporcional do orçamento.
+func compressWithModel(ctx context.Context, client openai.Client, model, text, query string, budget int) (string, error) {
+	var pieces []string
+	var cur strings.Builder
+	for _, block := range compressBlocks(text) {
+		if cur.Len() > 0 && estimateTokens(cur.String())+estimateTokens(block) > compressPieceTokens {
+			pieces = append(pieces, cur.String())
+			cur.Reset()
+		}
+		cur.WriteString(block + "\n\n")
+	}
+	if cur.Len() > 0 {
+		pieces = append(pieces, cur.String())
+	}
+
+	ratio := float64(budget) / float64(estimateTokens(text))
+	focus := ""
+	if query != "" {
+		focus = " Priorize o que ajuda a responder: " + truncate(query, 500)
+	}
+	var out []string
+	for _, piece := range pieces {
+		words := int(float64(estimateTokens(piece)) * ratio * 0.75) // ~0,75 palavra por token
+		params := openai.ChatCompletionNewParams{
+			Model: shared.ChatModel(model),
+			Messages: []openai.ChatCompletionMessageParamUnion{
+				openai.SystemMessage(fmt.Sprintf("Você recebe um trecho de um documento. Copie literalmente as partes mais "+
+					"importantes, na ordem original, somando no máximo %d palavras. Marque os cortes com [...]. "+
+					"Não resuma, não comente e não acrescente nada.%s", max(words, 50), focus)),
+				openai.UserMessage(piece),
+			},
+		}
+		err := withRetries(ctx, 3, func() error {
+			resp, err := client.Chat.Completions.New(ctx, params)
+			if err != nil {
+				return err
+			}
+			if len(resp.Choices) == 0 || strings.TrimSpace(resp.Choices[0].Message.Content) == "" {
+				return errors.New("resposta vazia")
+			}
+			noteUsage(ctx, resp.Usage)
+			out = append(out, strings.TrimSpace(resp.Choices[0].Message.Content))
+			return nil
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+	return strings.Join(out, "\n\n"), nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ===================== Config cifrado =====================
+//
+// O config pode ser config.yaml.age ou config.yaml.gpg (ou qualquer caminho
+// em GPTCLI_CONFIG): ele é decifrado em memória pelo age ou pelo gpg, cujo
+// agente cuida da passphrase, e nunca vai em texto puro para o disco. Assim
+// um time pode versionar o config compartilhado num repositório de dotfiles.
+
+var configCache struct {
+	mu   sync.Mutex
+	data map[string][]byte
+	errs map[string]error // falhas também ficam guardadas: o agente não pergunta de novo
+}
+
+// findConfigPath: GPTCLI_CONFIG > config.yaml > config.yaml.age > config.yaml.gpg.
+func findConfigPath() string {
+	if p := os.Getenv("GPTCLI_CONFIG"); p != "" {
+		return p
+	}
+	plain := filepath.Join(configDir(), "config.yaml")
+	for _, p := range []string{plain, plain + ".age", plain + ".gpg"} {
+		if _, err := os.Stat(p); err == nil {
+			return p
+		}
+	}
+	return plain
+}
+
+func isEncryptedConfig(path string) bool {
+	switch filepath.Ext(path) {
+	case ".age", ".gpg", ".asc":
+		return true
+	}
+	return false
+}
+
+// readConfigFile lê o config, decifrando .age/.gpg uma vez por processo.
+func readConfigFile(path string) ([]byte, error) {
+	if !isEncryptedConfig(path) {
+		return os.ReadFile(path)
+	}
+	configCache.mu.Lock()
+	defer configCache.mu.Unlock()
+	if b, ok := configCache.data[path]; ok {
+		return b, nil
+	}
+	if err, ok := configCache.errs[path]; ok {
+		return nil, err
+	}
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	var cmd *exec.Cmd
+	if filepath.Ext(path) == ".age" {
+		args := []string{"--decrypt"}
+		for _, id := range ageIdentities() {
+			args = append(args, "-i", id)
+		}
+		cmd = exec.Command("age", append(args, path)...)
+	} else {
+		cmd = exec.Command("gpg", "--quiet", "--decrypt", path)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			err = fmt.Errorf("%s: %s não encontrado no PATH", path, cmd.Args[0])
+		} else {
+			err = fmt.Errorf("%s: falha ao decifrar com %s: %v %s", path, cmd.Args[0], err, strings.TrimSpace(stderr.String()))
+		}
+		if configCache.errs == nil {
+			configCache.errs = map[string]error{}
+		}
+		configCache.errs[path] = err
+		return nil, err
+	}
+	if configCache.data == nil {
+		configCache.data = map[string][]byte{}
+	}
+	configCache.data[path] = out
+	return out, nil
+}
+
+// ageIdentities procura as chaves do age: GPTCLI_AGE_IDENTITY, os keys.txt
+// usuais e chaves SSH. Sem nenhuma, o age ainda decifra arquivos `age -p`.
+func ageIdentities() []string {
+	if id := os.Getenv("GPTCLI_AGE_IDENTITY"); id != "" {
+		return []string{id}
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil
+	}
+	var ids []string
+	for _, p := range []string{
+		filepath.Join(home, ".config", "age", "keys.txt"),
+		filepath.Join(home, ".config", "sops", "age", "keys.txt"),
+		filepath.Join(home, ".ssh", "id_ed25519"),
+		filepath.Join(home, ".ssh", "id_rsa"),
+	} {
+		if _, err := os.Stat(p); err == nil {
+			ids = append(ids, p)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ===================== Self-consistency =====================
+//
+// --self-consistency N amostra o mesmo prompt N vezes com temperatura > 0,
+// extrai a resposta final de cada amostra e devolve a mais votada.
+
+const consistencyInstruction = "Raciocine passo a passo e termine com uma linha no formato " +
+	"\"Resposta final: <resposta curta>\"."
+
+// consistencyParallel limita as amostras simultâneas para não estourar rate limit.
+const consistencyParallel = 4
+
+var finalAnswerRe = regexp.MustCompile(`(?im)^\W*(?:resposta final|final answer)\W*:\s*(.+?)\s*$`)
+
+type consistencyReport struct {
+	Answer    string         `json:"answer"`
+	Votes     int            `json:"votes"`
+	Samples   int            `json:"samples"`
+	Failed    int            `json:"failed,omitempty"`
+	Agreement float64        `json:"agreement"`
+	Tally     map[string]int `json:"tally"`
+}
+
+// finalAnswer pega a linha "Resposta final:" ou, na falta dela, a última linha.
+func finalAnswer(text string) string {
+	if m := finalAnswerRe.FindAllStringSubmatch(text, -1); len(m) > 0 {
+		return strings.TrimSpace(m[len(m)-1][1])
+	}
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+// voteKey normaliza a resposta para a contagem (caixa, espaços, pontuação final).
+func voteKey(s string) string {
+	s = strings.ToLower(strings.Join(strings.Fields(s), " "))
+	return strings.Trim(s, " .!*`\"'")
+}
+
+func selfConsistency(ctx context.Context, st *settings, sess *Session, prompt string, n int) error {
+	client, err := buildClient(st)
+	if err != nil {
+		return err
+	}
+	prompt, err = st.prof.Hooks.runPre(ctx, prompt, st.model)
+	if err != nil {
+		return err
+	}
+	temp := st.temp
+	if temp <= 0 {
+		temp = 0.8 // com temperatura 0 as amostras seriam iguais
+	}
+
+	sample := *sess
+	sample.Turns = append(append([]Turn(nil), sess.Turns...), Turn{Role: "user", Content: prompt})
+	sample.System = strings.TrimSpace(sess.System + "\n\n" + consistencyInstruction)
+	if strings.ToLower(sample.Format) == "json" {
+		sample.Format = "" // o JSON aqui é o relatório, não a resposta do modelo
+	}
+
+	texts := make([]string, n)
+	errs := make([]error, n)
+	sem := make(chan struct{}, consistencyParallel)
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			own := sample // ferramentas acrescentam turnos à sessão de cada amostra
+			own.Turns = append([]Turn(nil), sample.Turns...)
+			errs[i] = withRetries(ctx, 4, func() error {
+				var err error
+				texts[i], err = streamChat(ctx, client, &own, st.model, temp, st.maxTokens, func(string) {})
+				return err
+			})
+		}()
+	}
+	wg.Wait()
+
+	report := consistencyReport{Samples: n, Tally: map[string]int{}}
+	first := map[string]int{} // chave => índice da primeira amostra com ela
+	for i, t := range texts {
+		if errs[i] != nil {
+			report.Failed++
+			fmt.Fprintf(os.Stderr, "aviso: amostra %d falhou: %v\n", i+1, errs[i])
+			continue
+		}
+		key := voteKey(finalAnswer(t))
+		if key == "" {
+			continue
+		}
+		report.Tally[key]++
+		if _, ok := first[key]; !ok {
+			first[key] = i
+		}
+	}
+	if len(report.Tally) == 0 {
+		return fmt.Errorf("nenhuma das %d amostras produziu resposta", n)
+	}
+
+	keys := make([]string, 0, len(report.Tally))
+	for k := range report.Tally {
+		keys = append(keys, k)
+	}
+	// mais votos primeiro; empate vai para quem apareceu antes
+	sort.Slice(keys, func(a, b int) bool {
+		if report.Tally[keys[a]] != report.Tally[keys[b]] {
+			return report.Tally[keys[a]] > report.Tally[keys[b]]
+		}
+		return first[keys[a]] < first[keys[b]]
+	})
+	winner := first[keys[0]]
+	report.Answer = finalAnswer(texts[winner])
+	report.Votes = report.Tally[keys[0]]
+	report.Agreement = float64(report.Votes) / float64(n-report.Failed)
+
+	sess.addUser(prompt)
+	sess.addAssistant(texts[winner])
+
+	if strings.ToLower(sess.Format) == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+	fmt.Println(report.Answer)
+	fmt.Fprintf(os.Stderr, "\n(concordância %d/%d = %.0f%%", report.Votes, n-report.Failed, report.Agreement*100)
+	for _, k := range keys[1:] {
+		fmt.Fprintf(os.Stderr, " • %q: %d", truncate(k, 40), report.Tally[k])
+	}
+	fmt.Fprintln(os.Stderr, ")")
+	return nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// ===================== Context Providers =====================
+//
+// Um profile pode declarar comandos cuja saída acompanha cada prompt
+// (`context: [{cmd: "git status --short"}]`), para o modelo conhecer o
+// ambiente sem que o usuário precise colar nada. A saída é coletada de novo a
+// cada turno e vai numa mensagem de sistema; não fica gravada na sessão.
+
+type ContextProvider struct {
+	Cmd     string `yaml:"cmd"`
+	Label   string `yaml:"label,omitempty"`   // título no bloco; default: o próprio comando
+	Timeout string `yaml:"timeout,omitempty"` // default 5s
+}
+
+// maxContextOutput limita a saída de cada provider.
+const maxContextOutput = 8 << 10
+
+func (p ContextProvider) timeout() time.Duration {
+	if d, err := time.ParseDuration(p.Timeout); err == nil && d > 0 {
+		return d
+	}
+	return 5 * time.Second
+}
+
+func validateContextProviders(providers []ContextProvider) error {
+	for i, p := range providers {
+		if strings.TrimSpace(p.Cmd) == "" {
+			return fmt.Errorf("context[%d]: cmd vazio", i)
+		}
+		if p.Timeout != "" {
+			if d, err := time.ParseDuration(p.Timeout); err != nil || d <= 0 {
+				return fmt.Errorf("context[%d]: timeout inválido %q (ex: 5s)", i, p.Timeout)
+			}
+		}
+	}
+	return nil
+}
+
+func (p ContextProvider) run(ctx context.Context) string {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout())
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "sh", "-c", p.Cmd)
+	cmd.WaitDelay = time.Second
+	out, err := cmd.CombinedOutput()
+	text := strings.TrimSpace(string(out))
+	if len(text) > maxContextOutput {
+		text = text[:maxContextOutput] + "\n[saída truncada]"
+	}
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		text += fmt.Sprintf("\n[timeout após %s]", p.timeout())
+	case err != nil:
+		text += fmt.Sprintf("\n[%v]", err)
+	}
+	return strings.TrimSpace(text)
+}
+
+// gatherContext roda os providers e monta o bloco enviado ao modelo.
+func gatherContext(ctx context.Context, providers []ContextProvider) string {
+	if len(providers) == 0 {
+		return ""
+	}
+	ctx, span := startSpan(ctx, "gptcli.context", attr("gptcli.context.providers", len(providers)))
+	defer span.end(nil)
+	var b strings.Builder
+	b.WriteString("Contexto do ambiente do usuário (coletado automaticamente agora):\n")
+	for _, p := range providers {
+		fmt.Fprintf(&b, "\n### %s\n```\n%s\n```\n", chooseNonEmpty(p.Label, "$ "+p.Cmd), p.run(ctx))
+	}
+	return b.String()
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+
+	openai "github.com/openai/openai-go/v2"
+)
+
+// ===================== Auto-continue =====================
+//
+// Quando a resposta para por max_tokens (finish_reason "length"), com
+// --auto-continue o gptcli pede a continuação e junta os trechos; sem a flag,
+// avisa no stderr em vez de entregar a resposta cortada em silêncio.
+
+const continuePrompt = "Sua resposta foi cortada pelo limite de tokens. Continue exatamente de onde parou, " +
+	"sem repetir nada do que já escreveu e sem comentários sobre a continuação."
+
+// defaultAutoContinue é o número de continuações de --auto-continue sem valor.
+const defaultAutoContinue = 3
+
+// autoContinueFlag aceita tanto --auto-continue quanto --auto-continue=N.
+type autoContinueFlag int
+
+func (f *autoContinueFlag) String() string   { return strconv.Itoa(int(*f)) }
+func (f *autoContinueFlag) IsBoolFlag() bool { return true }
+func (f *autoContinueFlag) Set(v string) error {
+	switch v {
+	case "true":
+		*f = defaultAutoContinue
+		return nil
+	case "false":
+		*f = 0
+		return nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return fmt.Errorf("use --auto-continue ou --auto-continue=N (N >= 0)")
+	}
+	*f = autoContinueFlag(n)
+	return nil
+}
+
+// continueTruncated recebe o primeiro trecho cortado e faz até
+// sess.AutoContinue pedidos de continuação. Os turnos intermediários não
+// ficam na sessão: quem chamou grava a resposta inteira num turno só.
+func continueTruncated(ctx context.Context, client openai.Client, sess *Session,
+	model string, temp float64, maxTokens int64, onDelta func(string), text string) (string, error) {
+
+	base := len(sess.Turns)
+	defer func() { sess.Turns = sess.Turns[:base] }()
+	for i := 1; i <= sess.AutoContinue; i++ {
+		fmt.Fprintf(os.Stderr, "\n(resposta cortada pelo limite de tokens; continuando %d/%d)\n", i, sess.AutoContinue)
+		sess.Turns = append(sess.Turns[:base],
+			Turn{Role: "assistant", Content: text},
+			Turn{Role: "user", Content: continuePrompt})
+		c, err := streamCompletion(ctx, client, sess, model, temp, maxTokens, onDelta)
+		text += c.content
+		if err != nil {
+			return text, err
+		}
+		if c.finishReason != "length" {
+			return text, nil
+		}
+	}
+	if sess.AutoContinue == 0 {
+		fmt.Fprintln(os.Stderr, "\n(aviso: resposta cortada pelo limite de tokens; use --auto-continue ou aumente --max-tokens)")
+	} else {
+		fmt.Fprintf(os.Stderr, "\n(aviso: resposta ainda cortada após %d continuações)\n", sess.AutoContinue)
+	}
+	return text, nil
+}
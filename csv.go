@@ -0,0 +1,530 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// ===================== CSV =====================
+//
+// `gptcli csv dados.csv "qual região cresceu mais?"` lê o arquivo localmente e
+// manda ao modelo só o esquema (tipo, vazios, distintos, mínimo e máximo de
+// cada coluna) e uma amostra das linhas. Para os números exatos, o modelo
+// chama a ferramenta csv_query, que filtra, agrupa e agrega o arquivo inteiro
+// aqui mesmo; o conjunto de dados nunca vai inteiro para o prompt.
+
+const csvSystem = `Você analisa um arquivo CSV. Você recebe o esquema e uma amostra das linhas, não o
+arquivo inteiro: não tire totais, médias ou rankings da amostra. Para qualquer número sobre o
+arquivo, chame a ferramenta csv_query (pode chamar várias vezes) e baseie a resposta nos
+resultados dela. Responda no idioma da pergunta, citando os números obtidos.`
+
+const csvSystemNoCompute = `Você analisa um arquivo CSV. Você recebe o esquema e uma amostra das linhas, não o
+arquivo inteiro. Deixe claro quando uma conclusão vier só da amostra. Responda no idioma da pergunta.`
+
+const (
+	defaultCSVSample = 20
+	maxCSVQueryRows  = 50 // linhas devolvidas por csv_query
+	maxCSVExamples   = 3  // valores de exemplo por coluna no esquema
+)
+
+type csvTable struct {
+	name   string
+	header []string
+	rows   [][]string
+}
+
+// csvData é a tabela do `gptcli csv` em andamento, lida pela ferramenta csv_query.
+var csvData *csvTable
+
+// sniffDelimiter escolhe entre vírgula, ponto e vírgula e tab pela primeira linha.
+func sniffDelimiter(path, firstLine string) rune {
+	if strings.EqualFold(filepath.Ext(path), ".tsv") {
+		return '\t'
+	}
+	best, bestN := ',', 0
+	for _, d := range []rune{',', ';', '\t'} {
+		if n := strings.Count(firstLine, string(d)); n > bestN {
+			best, bestN = d, n
+		}
+	}
+	return best
+}
+
+func loadCSV(path string, delim rune) (*csvTable, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	text := strings.TrimPrefix(string(data), "\ufeff") // BOM de planilhas exportadas
+	if delim == 0 {
+		first, _, _ := strings.Cut(text, "\n")
+		delim = sniffDelimiter(path, first)
+	}
+	r := csv.NewReader(strings.NewReader(text))
+	r.Comma = delim
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	header, err := r.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("%s está vazio", path)
+		}
+		return nil, err
+	}
+	t := &csvTable{name: filepath.Base(path)}
+	for i, h := range header {
+		h = strings.TrimSpace(h)
+		if h == "" {
+			h = fmt.Sprintf("col%d", i+1)
+		}
+		t.header = append(t.header, h)
+	}
+	for {
+		rec, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		// linhas curtas ou longas são ajustadas ao cabeçalho
+		row := make([]string, len(t.header))
+		copy(row, rec)
+		t.rows = append(t.rows, row)
+	}
+	return t, nil
+}
+
+func (t *csvTable) column(name string) (int, error) {
+	for i, h := range t.header {
+		if strings.EqualFold(h, name) {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("coluna desconhecida %q (colunas: %s)", name, strings.Join(t.header, ", "))
+}
+
+// parseNumber aceita 1234.5, 1.234,5 e 12%; o resto não é número.
+func parseNumber(s string) (float64, bool) {
+	s = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(s), "%"))
+	if s == "" {
+		return 0, false
+	}
+	if strings.Contains(s, ",") {
+		if strings.Contains(s, ".") && strings.LastIndex(s, ",") > strings.LastIndex(s, ".") {
+			s = strings.ReplaceAll(s, ".", "") // 1.234,5
+		}
+		if strings.Count(s, ",") == 1 && !strings.Contains(s, ".") {
+			s = strings.Replace(s, ",", ".", 1)
+		} else {
+			s = strings.ReplaceAll(s, ",", "") // 1,234.5
+		}
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	return f, err == nil && !math.IsNaN(f) && !math.IsInf(f, 0)
+}
+
+var csvDateLayouts = []string{"2006-01-02", "2006-01-02 15:04:05", time.RFC3339, "02/01/2006", "2006/01/02"}
+
+func isDate(s string) bool {
+	for _, l := range csvDateLayouts {
+		if _, err := time.Parse(l, strings.TrimSpace(s)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// schema descreve cada coluna: tipo inferido, vazios, distintos e faixa.
+func (t *csvTable) schema() string {
+	var b strings.Builder
+	tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "coluna\ttipo\tvazios\tdistintos\tfaixa / exemplos")
+	for i, h := range t.header {
+		empty, nums, dates := 0, 0, 0
+		lo, hi := math.Inf(1), math.Inf(-1)
+		distinct := map[string]bool{}
+		var examples []string
+		for _, row := range t.rows {
+			v := strings.TrimSpace(row[i])
+			if v == "" {
+				empty++
+				continue
+			}
+			if !distinct[v] && len(examples) < maxCSVExamples {
+				examples = append(examples, truncate(v, 30))
+			}
+			distinct[v] = true
+			if f, ok := parseNumber(v); ok {
+				nums++
+				lo, hi = math.Min(lo, f), math.Max(hi, f)
+			} else if isDate(v) {
+				dates++
+			}
+		}
+		filled := len(t.rows) - empty
+		kind, detail := "texto", strings.Join(examples, " | ")
+		switch {
+		case filled == 0:
+			kind, detail = "vazia", ""
+		case nums == filled:
+			kind, detail = "número", fmt.Sprintf("%s a %s", formatNumber(lo), formatNumber(hi))
+		case dates == filled:
+			kind = "data"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%s\n", h, kind, empty, len(distinct), detail)
+	}
+	tw.Flush()
+	return b.String()
+}
+
+// sample devolve as primeiras linhas e outras espalhadas pelo arquivo, para a
+// amostra não mostrar só o começo (quase sempre ordenado por data ou id).
+func (t *csvTable) sample(n int) [][]string {
+	if n <= 0 || len(t.rows) <= n {
+		return t.rows
+	}
+	head := n / 4
+	out := append([][]string{}, t.rows[:head]...)
+	rest := n - head
+	step := float64(len(t.rows)-head) / float64(rest)
+	for i := 0; i < rest; i++ {
+		out = append(out, t.rows[head+int(float64(i)*step)])
+	}
+	return out
+}
+
+func (t *csvTable) prompt(question string, sampleRows int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Arquivo %s: %d linhas, %d colunas.\n\nEsquema:\n%s\n", t.name, len(t.rows), len(t.header), t.schema())
+	shown := t.sample(sampleRows)
+	fmt.Fprintf(&b, "Amostra (%d de %d linhas):\n", len(shown), len(t.rows))
+	w := csv.NewWriter(&b)
+	_ = w.Write(t.header)
+	_ = w.WriteAll(shown)
+	fmt.Fprintf(&b, "\nPergunta: %s", question)
+	return b.String()
+}
+
+func formatNumber(f float64) string {
+	if f == math.Trunc(f) && math.Abs(f) < 1e15 {
+		return strconv.FormatFloat(f, 'f', 0, 64)
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// ---------- csv_query ----------
+
+var csvFilterRe = regexp.MustCompile(`^\s*(.+?)\s*(>=|<=|!=|=|>|<|~)\s*(.*?)\s*$`)
+
+type csvFilter struct {
+	col   int
+	op    string
+	value string
+	num   float64
+	isNum bool
+}
+
+func (t *csvTable) parseFilter(s string) (csvFilter, error) {
+	m := csvFilterRe.FindStringSubmatch(s)
+	if m == nil {
+		return csvFilter{}, fmt.Errorf("filtro inválido %q (use coluna=valor, coluna>10, coluna~texto)", s)
+	}
+	col, err := t.column(m[1])
+	if err != nil {
+		return csvFilter{}, err
+	}
+	f := csvFilter{col: col, op: m[2], value: strings.Trim(m[3], `"'`)}
+	f.num, f.isNum = parseNumber(f.value)
+	return f, nil
+}
+
+func (f csvFilter) match(row []string) bool {
+	v := strings.TrimSpace(row[f.col])
+	switch f.op {
+	case "=":
+		return strings.EqualFold(v, f.value)
+	case "!=":
+		return !strings.EqualFold(v, f.value)
+	case "~":
+		return strings.Contains(strings.ToLower(v), strings.ToLower(f.value))
+	}
+	// comparação numérica quando os dois lados são números; senão, de texto (datas ISO ordenam certo)
+	cmp := strings.Compare(v, f.value)
+	if n, ok := parseNumber(v); ok && f.isNum {
+		cmp = 0
+		if n < f.num {
+			cmp = -1
+		} else if n > f.num {
+			cmp = 1
+		}
+	}
+	switch f.op {
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	}
+	return cmp <= 0
+}
+
+var csvAggregates = []string{"count", "sum", "avg", "min", "max"}
+
+// query filtra, agrupa e agrega a tabela inteira, devolvendo uma tabela em texto.
+func (t *csvTable) query(args map[string]any) (string, error) {
+	agg := chooseNonEmpty(stringArg(args, "aggregate"), "count")
+	if !containsString(csvAggregates, agg) {
+		return "", fmt.Errorf("aggregate inválido %q (use %s)", agg, strings.Join(csvAggregates, ", "))
+	}
+	valueCol := -1
+	if name := stringArg(args, "column"); name != "" {
+		c, err := t.column(name)
+		if err != nil {
+			return "", err
+		}
+		valueCol = c
+	} else if agg != "count" {
+		return "", fmt.Errorf("%s precisa de column", agg)
+	}
+	var groupCols []int
+	groupBy, _ := args["group_by"].([]any)
+	for _, g := range groupBy {
+		name, _ := g.(string)
+		c, err := t.column(name)
+		if err != nil {
+			return "", err
+		}
+		groupCols = append(groupCols, c)
+	}
+	var filters []csvFilter
+	rawFilters, _ := args["filters"].([]any)
+	for _, rf := range rawFilters {
+		s, _ := rf.(string)
+		f, err := t.parseFilter(s)
+		if err != nil {
+			return "", err
+		}
+		filters = append(filters, f)
+	}
+
+	type acc struct {
+		key         []string
+		rows, n     int
+		sum, lo, hi float64
+	}
+	groups := map[string]*acc{}
+	var order []string
+	matched, skipped := 0, 0
+rows:
+	for _, row := range t.rows {
+		for _, f := range filters {
+			if !f.match(row) {
+				continue rows
+			}
+		}
+		matched++
+		key := make([]string, len(groupCols))
+		for i, c := range groupCols {
+			key[i] = strings.TrimSpace(row[c])
+		}
+		k := strings.Join(key, "\x00")
+		a := groups[k]
+		if a == nil {
+			a = &acc{key: key, lo: math.Inf(1), hi: math.Inf(-1)}
+			groups[k] = a
+			order = append(order, k)
+		}
+		a.rows++
+		if valueCol >= 0 {
+			f, ok := parseNumber(row[valueCol])
+			if !ok {
+				skipped++
+				continue
+			}
+			a.n++
+			a.sum += f
+			a.lo, a.hi = math.Min(a.lo, f), math.Max(a.hi, f)
+		}
+	}
+
+	value := func(a *acc) (float64, bool) {
+		switch agg {
+		case "count":
+			if valueCol >= 0 {
+				return float64(a.n), true
+			}
+			return float64(a.rows), true
+		case "sum":
+			return a.sum, a.n > 0
+		case "avg":
+			return a.sum / float64(a.n), a.n > 0
+		case "min":
+			return a.lo, a.n > 0
+		}
+		return a.hi, a.n > 0
+	}
+	switch stringArg(args, "sort") {
+	case "asc", "desc":
+		desc := stringArg(args, "sort") == "desc"
+		sort.SliceStable(order, func(i, j int) bool {
+			vi, _ := value(groups[order[i]])
+			vj, _ := value(groups[order[j]])
+			if desc {
+				return vi > vj
+			}
+			return vi < vj
+		})
+	default:
+		sort.Strings(order)
+	}
+	limit := maxCSVQueryRows
+	if n, ok := args["limit"].(float64); ok && n > 0 && int(n) < limit {
+		limit = int(n)
+	}
+
+	var b strings.Builder
+	tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	label := agg
+	if valueCol >= 0 {
+		label += "(" + t.header[valueCol] + ")"
+	}
+	var head []string
+	for _, c := range groupCols {
+		head = append(head, t.header[c])
+	}
+	fmt.Fprintln(tw, strings.Join(append(head, label), "\t"))
+	for i, k := range order {
+		if i == limit {
+			break
+		}
+		a := groups[k]
+		cell := "(sem números)"
+		if v, ok := value(a); ok {
+			cell = formatNumber(math.Round(v*1e4) / 1e4)
+		}
+		fmt.Fprintln(tw, strings.Join(append(append([]string{}, a.key...), cell), "\t"))
+	}
+	tw.Flush()
+	fmt.Fprintf(&b, "(%d de %d linhas passaram nos filtros", matched, len(t.rows))
+	if len(order) > limit {
+		fmt.Fprintf(&b, "; %d de %d grupos mostrados", limit, len(order))
+	}
+	if skipped > 0 {
+		fmt.Fprintf(&b, "; %d valores não numéricos ignorados", skipped)
+	}
+	b.WriteString(")\n")
+	return b.String(), nil
+}
+
+var csvQuerySchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"filters": map[string]any{
+			"type":        "array",
+			"items":       map[string]any{"type": "string"},
+			"description": `condições combinadas com E: "regiao=Sul", "ano>=2023", "produto~café" (~ = contém)`,
+		},
+		"group_by":  map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": "colunas de agrupamento"},
+		"aggregate": map[string]any{"type": "string", "enum": csvAggregates},
+		"column":    map[string]any{"type": "string", "description": "coluna numérica agregada (dispensável em count)"},
+		"sort":      map[string]any{"type": "string", "enum": []string{"asc", "desc"}, "description": "ordena pelo valor agregado"},
+		"limit":     map[string]any{"type": "integer", "description": fmt.Sprintf("máximo de grupos (até %d)", maxCSVQueryRows)},
+	},
+	"required": []string{"aggregate"},
+}
+
+func runCSVQuery(ctx context.Context, args map[string]any) (string, error) {
+	if csvData == nil {
+		return "", errors.New("csv_query só funciona dentro do gptcli csv")
+	}
+	return csvData.query(args)
+}
+
+// ---------- subcomando ----------
+
+func csvCmd(args []string) error {
+	fs := flag.NewFlagSet("csv", flag.ExitOnError)
+	flags := commonFlags(fs)
+	sampleRows := fs.Int("sample", defaultCSVSample, "linhas de amostra enviadas ao modelo")
+	delim := fs.String("delimiter", "", `separador: ",", ";" ou "tab" (default: detecta)`)
+	noCompute := fs.Bool("no-compute", false, "não oferece a ferramenta csv_query; o modelo vê só esquema e amostra")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, `uso: gptcli csv [flags] <arquivo.csv|.tsv> "pergunta"`)
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() < 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	path, question := fs.Arg(0), strings.TrimSpace(strings.Join(fs.Args()[1:], " "))
+
+	var comma rune
+	switch *delim {
+	case "":
+	case "tab", `\t`:
+		comma = '\t'
+	default:
+		if len([]rune(*delim)) != 1 {
+			return fmt.Errorf("--delimiter inválido %q (use um caractere ou tab)", *delim)
+		}
+		comma = []rune(*delim)[0]
+	}
+	table, err := loadCSV(path, comma)
+	if err != nil {
+		return err
+	}
+	if len(table.rows) == 0 {
+		return fmt.Errorf("%s só tem o cabeçalho", path)
+	}
+
+	cfg, _ := loadConfig()
+	st, err := resolveSettings(cfg, flags)
+	if err != nil {
+		return err
+	}
+	initAppLog(st.logLevel, st.profName, st.personaName)
+	configureOutput(st)
+	configureTools(st)
+	client, err := buildClient(st)
+	if err != nil {
+		return err
+	}
+	ctx := withRetryConfig(context.Background(), st.retry)
+
+	sess := &Session{Tools: st.tools}
+	if *noCompute {
+		sess.addSystem(chooseNonEmpty(flags.System, csvSystemNoCompute))
+	} else {
+		csvData = table
+		sess.Tools = append(sess.Tools, "csv_query")
+		sess.addSystem(chooseNonEmpty(flags.System, csvSystem))
+	}
+	prompt := table.prompt(question, *sampleRows)
+	fmt.Fprintf(os.Stderr, "(%s: %d linhas, %d colunas; enviando esquema e %d linhas de amostra, ~%d tokens)\n",
+		table.name, len(table.rows), len(table.header), len(table.sample(*sampleRows)), estimateTokens(prompt))
+	sess.addUser(prompt)
+	err = logOp(ctx, "csv", st.model, func(ctx context.Context) error {
+		return withRetries(ctx, 4, func() error {
+			_, err := streamOnce(ctx, client, sess, st.model, st.temp, st.maxTokens)
+			return err
+		})
+	})
+	flushTelemetry()
+	return err
+}
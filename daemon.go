@@ -0,0 +1,481 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	openai "github.com/openai/openai-go/v2"
+	"github.com/openai/openai-go/v2/option"
+)
+
+// ===================== Daemon =====================
+//
+// `gptcli daemon` mantém clientes HTTP (e suas conexões TLS) vivos num processo
+// em background. As invocações normais, ao encontrarem o socket, enviam os
+// parâmetros já montados e recebem os chunks do stream de volta, linha a linha.
+
+type daemonRequest struct {
+	Op      string          `json:"op"` // chat|ping|stop
+	APIKey  string          `json:"api_key,omitempty"`
+	BaseURL string          `json:"base_url,omitempty"`
+	Proxy   string          `json:"proxy,omitempty"`
+	Profile string          `json:"profile,omitempty"` // só para as métricas
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type daemonFrame struct {
+	Upstream *upstreamResponse `json:"upstream,omitempty"` // antes dos chunks: request id, status e limites da API
+	Chunk    json.RawMessage   `json:"chunk,omitempty"`
+	Error    string            `json:"error,omitempty"`
+	Done     bool              `json:"done,omitempty"`
+	Info     *daemonInfo       `json:"info,omitempty"`
+}
+
+type daemonInfo struct {
+	PID      int    `json:"pid"`
+	Uptime   string `json:"uptime"`
+	Clients  int    `json:"clients"`
+	Requests int64  `json:"requests"`
+}
+
+func daemonSocketPath() string {
+	if p := os.Getenv("GPTCLI_SOCKET"); p != "" {
+		return p
+	}
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "gptcli.sock")
+	}
+	return filepath.Join(stateDir(), "daemon.sock")
+}
+
+// ---------- lado cliente ----------
+
+type daemonClient struct {
+	socket                 string
+	apiKey, baseURL, proxy string
+}
+
+// daemonTarget é definido em main quando há um socket do daemon disponível.
+var daemonTarget *daemonClient
+
+// daemonEnabled é falso com --no-daemon ou --ephemeral.
+var daemonEnabled bool
+
+// daemonFor devolve o daemon para as settings de st, ou nil quando a chamada
+// deve ser direta: o daemon monta clientes só com chave, base_url e proxy.
+func daemonFor(st *settings) *daemonClient {
+	if !daemonEnabled || st.gateway.needsOwnClient() {
+		return nil
+	}
+	return probeDaemon(st.apiKey, st.baseURL, st.proxy)
+}
+
+func probeDaemon(apiKey, baseURL, proxy string) *daemonClient {
+	path := daemonSocketPath()
+	st, err := os.Stat(path)
+	if err != nil || st.Mode()&os.ModeSocket == 0 {
+		return nil
+	}
+	return &daemonClient{socket: path, apiKey: apiKey, baseURL: baseURL, proxy: proxy}
+}
+
+func daemonRoundTrip(socket string, req daemonRequest) (net.Conn, *json.Decoder, error) {
+	conn, err := net.DialTimeout("unix", socket, 200*time.Millisecond)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	return conn, json.NewDecoder(bufio.NewReader(conn)), nil
+}
+
+func (d *daemonClient) chat(ctx context.Context, params openai.ChatCompletionNewParams) (chunkStream, error) {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	conn, dec, err := daemonRoundTrip(d.socket, daemonRequest{
+		Op: "chat", APIKey: d.apiKey, BaseURL: d.baseURL, Proxy: d.proxy, Profile: appLog.profile, Params: raw,
+	})
+	if err != nil {
+		return nil, err
+	}
+	s := &daemonStream{ctx: ctx, conn: conn, dec: dec, done: make(chan struct{})}
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-s.done:
+		}
+	}()
+	return s, nil
+}
+
+type daemonStream struct {
+	ctx    context.Context
+	conn   net.Conn
+	dec    *json.Decoder
+	cur    openai.ChatCompletionChunk
+	err    error
+	done   chan struct{}
+	closed bool
+}
+
+func (s *daemonStream) Next() bool {
+	if s.err != nil {
+		return false
+	}
+	var f daemonFrame
+	if err := s.dec.Decode(&f); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = errors.New("daemon: conexão encerrada antes do fim do stream")
+		}
+		s.err = err
+		return false
+	}
+	if f.Upstream != nil {
+		// o noteResponse do daemon não vê a operação daqui
+		noteUpstream(s.ctx, f.Upstream)
+		if f.Chunk == nil && f.Error == "" && !f.Done {
+			return s.Next()
+		}
+	}
+	switch {
+	case f.Error != "":
+		s.err = errors.New(f.Error)
+		return false
+	case f.Done:
+		return false
+	}
+	s.cur = openai.ChatCompletionChunk{}
+	if err := json.Unmarshal(f.Chunk, &s.cur); err != nil {
+		s.err = err
+		return false
+	}
+	return true
+}
+
+func (s *daemonStream) Current() openai.ChatCompletionChunk { return s.cur }
+func (s *daemonStream) Err() error                          { return s.err }
+
+func (s *daemonStream) Close() error {
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	close(s.done)
+	return s.conn.Close()
+}
+
+// ---------- lado servidor ----------
+
+type daemonServer struct {
+	started  time.Time
+	mu       sync.Mutex
+	clients  map[string]openai.Client // chave: api_key|base_url|proxy
+	requests int64
+	user     string // dono do processo; com o socket em 600, é também quem chama
+}
+
+func (d *daemonServer) client(apiKey, baseURL, proxy string) (openai.Client, error) {
+	key := apiKey + "|" + baseURL + "|" + proxy
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if c, ok := d.clients[key]; ok {
+		return c, nil
+	}
+	c, err := buildClient(&settings{apiKey: apiKey, baseURL: baseURL, proxy: proxy, transport: transportConfig})
+	if err != nil {
+		return openai.Client{}, err
+	}
+	d.clients[key] = c
+	return c, nil
+}
+
+func (d *daemonServer) info() *daemonInfo {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return &daemonInfo{
+		PID:      os.Getpid(),
+		Uptime:   time.Since(d.started).Round(time.Second).String(),
+		Clients:  len(d.clients),
+		Requests: d.requests,
+	}
+}
+
+func (d *daemonServer) handle(conn net.Conn, stop func()) {
+	defer conn.Close()
+	enc := json.NewEncoder(conn)
+	var req daemonRequest
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		_ = enc.Encode(daemonFrame{Error: "daemon: requisição inválida: " + err.Error()})
+		return
+	}
+	switch req.Op {
+	case "ping":
+		_ = enc.Encode(daemonFrame{Done: true, Info: d.info()})
+		return
+	case "stop":
+		_ = enc.Encode(daemonFrame{Done: true})
+		stop()
+		return
+	case "chat":
+	default:
+		_ = enc.Encode(daemonFrame{Error: "daemon: operação desconhecida: " + req.Op})
+		return
+	}
+
+	client, err := d.client(req.APIKey, req.BaseURL, req.Proxy)
+	if err != nil {
+		_ = enc.Encode(daemonFrame{Error: err.Error()})
+		return
+	}
+	d.mu.Lock()
+	d.requests++
+	d.mu.Unlock()
+	var usage promUsage
+	var model struct {
+		Model string `json:"model"`
+	}
+	_ = json.Unmarshal(req.Params, &model)
+	a := &auditEntry{Mode: "daemon", User: d.user, Profile: req.Profile, Model: model.Model, Stream: true}
+	auditLog.auditPrompt(a, auditMessages(req.Params))
+	done, started := promStats.begin(), time.Now()
+	a.started = started
+	_, span := startSpan(context.Background(), "gptcli.daemon", attr("gen_ai.request.model", model.Model), attr("gptcli.profile", req.Profile))
+	defer func() {
+		span.setAttr("gen_ai.usage.input_tokens", usage.prompt)
+		span.setAttr("gen_ai.usage.output_tokens", usage.completion)
+		span.end(err)
+		done()
+		promStats.observe(promKey{"daemon", model.Model, req.Profile}, time.Since(started), usage, 0, err)
+		a.PromptTokens, a.CompletionTokens, a.Status = usage.prompt, usage.completion, auditStatus(err)
+		if err != nil {
+			a.Error = err.Error()
+		}
+		auditLog.record(a)
+	}()
+
+	// se o cliente desconectar (ctrl+c), cancela a chamada upstream
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_, _ = io.Copy(io.Discard, conn)
+		cancel()
+	}()
+
+	var upstream *upstreamResponse
+	stream := client.Chat.Completions.NewStreaming(ctx, openai.ChatCompletionNewParams{},
+		option.WithRequestBody("application/json", []byte(req.Params)),
+		option.WithJSONSet("stream", true),
+		option.WithMiddleware(func(r *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+			resp, err := next(r)
+			if resp != nil {
+				upstream = upstreamInfo(r, resp)
+			}
+			return resp, err
+		}))
+	defer stream.Close()
+	if upstream != nil {
+		if err = enc.Encode(daemonFrame{Upstream: upstream}); err != nil {
+			return
+		}
+	}
+	for stream.Next() {
+		chunk := stream.Current()
+		if u := chunk.Usage; u.TotalTokens > 0 {
+			usage = promUsage{u.PromptTokens, u.CompletionTokens, u.PromptTokensDetails.CachedTokens, u.CompletionTokensDetails.ReasoningTokens}
+		}
+		if err = enc.Encode(daemonFrame{Chunk: json.RawMessage(chunk.RawJSON())}); err != nil {
+			return
+		}
+	}
+	if err = stream.Err(); err != nil {
+		_ = enc.Encode(daemonFrame{Error: err.Error()})
+		return
+	}
+	_ = enc.Encode(daemonFrame{Done: true})
+}
+
+// warm cria o cliente do profile padrão e faz uma chamada leve para abrir a
+// conexão TLS antes do primeiro prompt.
+func (d *daemonServer) warm() {
+	cfg, err := loadConfig()
+	if err != nil {
+		return
+	}
+	prof := cfg.Profiles[cfg.Default]
+	apiKey := chooseNonEmpty(os.Getenv("OPENAI_API_KEY"), cfg.APIKey)
+	if apiKey == "" {
+		return
+	}
+	client, err := d.client(apiKey, prof.BaseURL, prof.Proxy)
+	if err != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, _ = client.Models.List(ctx)
+}
+
+const daemonUsage = `uso: gptcli daemon [start|status|stop] [--socket caminho] [--metrics-listen endereço] [--audit]
+  start    roda o daemon em primeiro plano (default)
+  status   mostra se o daemon está rodando
+  stop     encerra o daemon
+`
+
+func daemonCmd(args []string) error {
+	action := "start"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		action, args = args[0], args[1:]
+	}
+	fs := flag.NewFlagSet("daemon", flag.ExitOnError)
+	socket := fs.String("socket", daemonSocketPath(), "caminho do socket Unix (ou GPTCLI_SOCKET)")
+	keepalive := fs.Duration("keepalive", -1, "repete o ping de conexão a cada intervalo (ex: 30s; 0 desliga). Default: keepalive do config")
+	metricsAddr := fs.String("metrics-listen", "", "expõe GET /metrics (Prometheus) neste endereço, ex: 127.0.0.1:9464")
+	audit := fs.Bool("audit", false, "grava a trilha de auditoria (ou audit.enabled no config)")
+	fs.Usage = func() { fmt.Fprint(os.Stderr, daemonUsage); fs.PrintDefaults() }
+	_ = fs.Parse(args)
+
+	switch action {
+	case "status":
+		info, err := pingDaemon(*socket)
+		if err != nil {
+			fmt.Println("daemon parado")
+			return nil
+		}
+		fmt.Printf("daemon rodando • pid=%d • uptime=%s • clientes=%d • requisições=%d • socket=%s\n",
+			info.PID, info.Uptime, info.Clients, info.Requests, *socket)
+		return nil
+	case "stop":
+		conn, dec, err := daemonRoundTrip(*socket, daemonRequest{Op: "stop"})
+		if err != nil {
+			return errors.New("daemon não está rodando")
+		}
+		defer conn.Close()
+		var f daemonFrame
+		_ = dec.Decode(&f)
+		fmt.Println("(daemon encerrado)")
+		return nil
+	case "start":
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		if err := cfg.Transport.validate(); err != nil {
+			return err
+		}
+		transportConfig = cfg.Transport
+		every := *keepalive
+		if every < 0 {
+			if every, err = keepaliveInterval(cfg.Keepalive); err != nil {
+				return err
+			}
+		}
+		if *audit || cfg.Audit.Enabled {
+			if err := openAudit(cfg.Audit); err != nil {
+				return err
+			}
+		}
+		return runDaemon(*socket, every, *metricsAddr)
+	default:
+		fs.Usage()
+		os.Exit(2)
+	}
+	return nil
+}
+
+func pingDaemon(socket string) (*daemonInfo, error) {
+	conn, dec, err := daemonRoundTrip(socket, daemonRequest{Op: "ping"})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var f daemonFrame
+	if err := dec.Decode(&f); err != nil {
+		return nil, err
+	}
+	if f.Info == nil {
+		return nil, errors.New("resposta inesperada do daemon")
+	}
+	return f.Info, nil
+}
+
+func runDaemon(socket string, keepalive time.Duration, metricsAddr string) error {
+	if _, err := pingDaemon(socket); err == nil {
+		return fmt.Errorf("daemon já está rodando em %s", socket)
+	}
+	_ = os.Remove(socket) // socket órfão de uma execução anterior
+	if err := ensureFileDirectory(socket); err != nil {
+		return err
+	}
+	// o socket já nasce 0600: entre o Listen e um chmod, outro usuário
+	// local poderia conectar e usar a chave
+	var ln net.Listener
+	err := withUmask(0o077, func() (err error) {
+		ln, err = net.Listen("unix", socket)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+
+	d := &daemonServer{started: time.Now(), clients: map[string]openai.Client{}, user: osUserName()}
+	go d.warm()
+	if keepalive > 0 {
+		go d.keepalive(ctx, keepalive)
+	}
+	go flushTelemetryEvery(ctx, serveTelemetryFlush)
+	defer flushTelemetry()
+	if metricsAddr != "" {
+		enableMetrics()
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /metrics", promStats.handler)
+		mln, err := net.Listen("tcp", metricsAddr)
+		if err != nil {
+			ln.Close()
+			return fmt.Errorf("--metrics-listen: %w", err)
+		}
+		go func() { _ = http.Serve(mln, mux) }()
+		fmt.Fprintf(os.Stderr, "métricas em http://%s/metrics\n", metricsAddr)
+	}
+	if auditLog != nil {
+		fmt.Fprintln(os.Stderr, auditLog.summary())
+	}
+	fmt.Fprintf(os.Stderr, "gptcli daemon • pid=%d • socket=%s\n", os.Getpid(), socket)
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			return err
+		}
+		go d.handle(conn, stop)
+	}
+	_ = os.Remove(socket)
+	return nil
+}
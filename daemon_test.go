@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	openai "github.com/openai/openai-go/v2"
+)
+
+func TestDaemonCarriesUpstreamResponse(t *testing.T) {
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("X-Request-Id", "req-123")
+		fmt.Fprint(w, "data: {\"id\":\"c\",\"object\":\"chat.completion.chunk\",\"model\":\"m\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"oi\"},\"finish_reason\":\"stop\"}]}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer up.Close()
+
+	socket := filepath.Join(t.TempDir(), "d.sock")
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skip("sem socket Unix:", err)
+	}
+	defer ln.Close()
+	d := &daemonServer{started: time.Now(), clients: map[string]openai.Client{}}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go d.handle(conn, func() {})
+		}
+	}()
+
+	level := appLog.level
+	appLog.level = logLevels["off"]
+	defer func() { appLog.level = level }()
+	client := &daemonClient{socket: socket, apiKey: "k", baseURL: up.URL}
+	err = logOp(context.Background(), "chat", "m", func(ctx context.Context) error {
+		stream, err := client.chat(ctx, openai.ChatCompletionNewParams{Model: "m"})
+		if err != nil {
+			return err
+		}
+		defer stream.Close()
+		var text string
+		for stream.Next() {
+			if c := stream.Current(); len(c.Choices) > 0 {
+				text += c.Choices[0].Delta.Content
+			}
+		}
+		if text != "oi" {
+			t.Errorf("texto = %q", text)
+		}
+		if e := opEntry(ctx); e.RequestID != "req-123" || e.Status != http.StatusOK {
+			t.Errorf("operação: request-id %q, status %d", e.RequestID, e.Status)
+		}
+		return stream.Err()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWithUmaskCreatesPrivateSocket(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sem umask")
+	}
+	socket := filepath.Join(t.TempDir(), "d.sock")
+	var ln net.Listener
+	err := withUmask(0o077, func() (err error) {
+		ln, err = net.Listen("unix", socket)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	fi, err := os.Stat(socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := fi.Mode().Perm(); perm&0o077 != 0 {
+		t.Fatalf("socket com permissão %o", perm)
+	}
+}
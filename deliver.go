@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ===================== Entrega da resposta =====================
+//
+// --deliver manda a resposta final para fora além do stdout, para jobs
+// agendados (cron, `gptcli flush`) que ninguém está olhando:
+//   webhook:https://...       POST com um JSON (prompt, resposta, modelo...);
+//                             com --format slack|discord, uma mensagem do
+//                             webhook da plataforma por parte
+//   mailto:a@x.com,b@y.com    e-mail pelo servidor da seção smtp do config
+// Pode ser repetido. Uma entrega que falha é um erro do comando; a resposta já
+// saiu no stdout.
+
+// SMTPConfig é o servidor usado pelas entregas mailto:.
+type SMTPConfig struct {
+	Host     string `yaml:"host,omitempty"`
+	Port     int    `yaml:"port,omitempty"`     // default 587 (STARTTLS); 465 usa TLS direto
+	Username string `yaml:"username,omitempty"` // sem usuário, envia sem AUTH
+	Password string `yaml:"password,omitempty"` // ou GPTCLI_SMTP_PASSWORD
+	From     string `yaml:"from,omitempty"`     // default: username
+}
+
+// deliveryTarget é um destino de --deliver já validado.
+type deliveryTarget struct {
+	raw     string
+	webhook string   // URL do webhook
+	to      []string // destinatários do mailto
+	subject string   // ?subject= do mailto
+}
+
+// delivery é o que vai para os destinos.
+type delivery struct {
+	Prompt  string    `json:"prompt"`
+	Answer  string    `json:"answer"`
+	Model   string    `json:"model"`
+	Session string    `json:"session,omitempty"`
+	Profile string    `json:"profile,omitempty"`
+	Parts   []string  `json:"parts,omitempty"` // com --chunk, a resposta dividida
+	Time    time.Time `json:"time"`
+}
+
+func parseDeliverTargets(specs []string, smtpCfg SMTPConfig) ([]deliveryTarget, error) {
+	var out []deliveryTarget
+	for _, spec := range specs {
+		spec = strings.TrimSpace(spec)
+		t := deliveryTarget{raw: spec}
+		switch {
+		case strings.HasPrefix(spec, "webhook:"):
+			u, err := url.Parse(strings.TrimPrefix(spec, "webhook:"))
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return nil, fmt.Errorf("--deliver %q: webhook precisa de uma URL http(s)", spec)
+			}
+			t.webhook = u.String()
+		case strings.HasPrefix(spec, "mailto:"):
+			u, err := url.Parse(spec)
+			if err != nil {
+				return nil, fmt.Errorf("--deliver %q: %v", spec, err)
+			}
+			list, err := mail.ParseAddressList(u.Opaque)
+			if err != nil {
+				return nil, fmt.Errorf("--deliver %q: endereço inválido: %v", spec, err)
+			}
+			for _, a := range list {
+				t.to = append(t.to, a.Address)
+			}
+			t.subject = u.Query().Get("subject")
+			if strings.TrimSpace(smtpCfg.Host) == "" {
+				return nil, fmt.Errorf("--deliver %q: defina a seção smtp (host, username...) no config.yaml", spec)
+			}
+		default:
+			return nil, fmt.Errorf("--deliver %q: use webhook:https://... ou mailto:endereço", spec)
+		}
+		out = append(out, t)
+	}
+	return out, nil
+}
+
+// deliverAnswer grava a nota do --save-note, cola a resposta no painel do
+// --tmux-pane e a entrega a todos os destinos do --deliver.
+func deliverAnswer(ctx context.Context, st *settings, sess *Session) error {
+	if st.note != "" {
+		if err := saveLastNote(st.note, st.notes, sess, st.model); err != nil {
+			return fmt.Errorf("--save-note: %w", err)
+		}
+	}
+	if st.tmux != nil {
+		if _, answer := lastExchange(sess); answer != "" {
+			if err := sendToTmux(st.tmux, answer); err != nil {
+				return fmt.Errorf("--tmux-pane: %w", err)
+			}
+		}
+	}
+	if len(st.deliver) == 0 {
+		return nil
+	}
+	prompt, answer := lastExchange(sess)
+	if answer == "" {
+		return errors.New("--deliver: não há resposta para entregar")
+	}
+	d := delivery{Prompt: prompt, Answer: answer, Model: st.model, Session: sess.Name, Profile: st.profName, Time: time.Now().UTC()}
+	if st.chunk > 0 {
+		d.Parts = renderFinal(answer, st.format)
+	}
+	var errs []error
+	for _, t := range st.deliver {
+		var err error
+		if t.webhook != "" {
+			err = postWebhook(ctx, st.proxy, t.webhook, st.format, d)
+		} else {
+			err = sendMail(ctx, st.smtp, t, d)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("entrega para %s falhou: %w", t.raw, err))
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "(resposta entregue em %s)\n", t.raw)
+	}
+	return errors.Join(errs...)
+}
+
+// postWebhook manda o JSON da entrega ou, nos formatos de chat, cada parte
+// da resposta convertida como mensagem ({"text"} no Slack, {"content"} no
+// Discord).
+func postWebhook(ctx context.Context, proxy, endpoint, format string, d delivery) error {
+	if !isChatFormat(format) {
+		return postJSON(ctx, proxy, endpoint, d)
+	}
+	field := "text"
+	if format == "discord" {
+		field = "content"
+	}
+	for _, part := range renderFinal(d.Answer, format) {
+		if err := postJSON(ctx, proxy, endpoint, map[string]string{field: part}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func postJSON(ctx context.Context, proxy, endpoint string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	hc, err := sharedHTTPClient(proxy)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "gptcli")
+	resp, err := hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+func sendMail(ctx context.Context, cfg SMTPConfig, t deliveryTarget, d delivery) error {
+	port := chooseInt(cfg.Port, 587)
+	addr := net.JoinHostPort(cfg.Host, strconv.Itoa(port))
+	from := chooseNonEmpty(cfg.From, cfg.Username)
+	if from == "" {
+		return errors.New("smtp: defina from (ou username) no config")
+	}
+	dialer := &net.Dialer{Timeout: durationOr(transportConfig.DialTimeout, 10*time.Second)}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	if port == 465 {
+		conn = tls.Client(conn, &tls.Config{ServerName: cfg.Host})
+	}
+	_ = conn.SetDeadline(time.Now().Add(time.Minute))
+	c, err := smtp.NewClient(conn, cfg.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok && port != 465 {
+		if err := c.StartTLS(&tls.Config{ServerName: cfg.Host}); err != nil {
+			return err
+		}
+	}
+	if cfg.Username != "" {
+		pass := chooseNonEmpty(cfg.Password, os.Getenv("GPTCLI_SMTP_PASSWORD"))
+		if err := c.Auth(smtp.PlainAuth("", cfg.Username, pass, cfg.Host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+	for _, rcpt := range t.to {
+		if err := c.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	subject := chooseNonEmpty(t.subject, "gptcli: "+truncate(strings.Join(strings.Fields(d.Prompt), " "), 60))
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(t.to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", d.Time.Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\nContent-Transfer-Encoding: 8bit\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(strings.ReplaceAll(d.Answer, "\r\n", "\n"), "\n", "\r\n"))
+	msg.WriteString("\r\n")
+	if _, err := io.WriteString(w, msg.String()); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ===================== Diff =====================
+//
+// `gptcli diff a.go b.go "o que muda no comportamento?"` calcula o diff
+// unificado localmente e manda só ele ao modelo, em vez dos dois arquivos
+// inteiros. Com --session a pergunta entra numa conversa que pode continuar
+// depois com `gptcli --session`.
+
+const diffSystem = `Você analisa diffs unificados entre duas versões de um arquivo. Foque nas
+diferenças de comportamento (entradas, saídas, erros, efeitos colaterais, desempenho),
+não em mudanças cosméticas, e cite os trechos do diff que justificam cada ponto.
+Se o diff não bastar para responder, diga o que falta em vez de supor.
+Responda no idioma da pergunta.`
+
+const defaultDiffQuestion = "Explique as diferenças de comportamento entre as duas versões."
+
+func diffCmd(args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	flags := commonFlags(fs)
+	fs.StringVar(&flags.Session, "session", "", "sessão nomeada: grava a pergunta e a resposta para continuar depois")
+	ctxLines := fs.Int("U", 3, "linhas de contexto em volta de cada mudança")
+	noDiff := fs.Bool("no-diff", false, "não imprime o diff antes da resposta")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, `uso: gptcli diff [flags] <arquivo_a|-> <arquivo_b|-> ["pergunta"]`)
+		fmt.Fprintln(os.Stderr, "     ex: git show HEAD~1:main.go | gptcli diff - main.go \"isso muda o retry?\"")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() < 2 || *ctxLines < 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	nameA, nameB := fs.Arg(0), fs.Arg(1)
+	if nameA == "-" && nameB == "-" {
+		return errors.New("só um dos lados pode vir do stdin")
+	}
+	a, err := readDiffInput(nameA)
+	if err != nil {
+		return err
+	}
+	b, err := readDiffInput(nameB)
+	if err != nil {
+		return err
+	}
+	if a == b {
+		fmt.Fprintln(os.Stderr, "(arquivos idênticos)")
+		return nil
+	}
+	for _, name := range []*string{&nameA, &nameB} {
+		if *name == "-" {
+			*name = "(stdin)"
+		}
+	}
+	diff := unifiedDiff(nameA, nameB, a, b, *ctxLines)
+	question := chooseNonEmpty(strings.TrimSpace(strings.Join(fs.Args()[2:], " ")), defaultDiffQuestion)
+
+	cfg, _ := loadConfig()
+	st, err := resolveSettings(cfg, flags)
+	if err != nil {
+		return err
+	}
+	initAppLog(st.logLevel, st.profName, st.personaName)
+	configureOutput(st)
+	client, err := buildClient(st)
+	if err != nil {
+		return err
+	}
+	sess, err := openSession(st, flags)
+	if err != nil {
+		return err
+	}
+	if len(sess.Turns) == 0 {
+		sess.addSystem(chooseNonEmpty(flags.System, diffSystem))
+	}
+
+	if !*noDiff {
+		printDiff(diff)
+		fmt.Println()
+	}
+	if full := estimateTokens(a) + estimateTokens(b); estimateTokens(diff) < full {
+		fmt.Fprintf(os.Stderr, "(diff: ~%d tokens em vez de ~%d dos dois arquivos)\n", estimateTokens(diff), full)
+	}
+
+	prompt := fmt.Sprintf("Diff unificado de %s para %s:\n\n```diff\n%s```\n\nPergunta: %s", nameA, nameB, diff, question)
+	ctx := withRetryConfig(context.Background(), st.retry)
+	err = askOnce(ctx, client, sess, st.model, st.temp, st.maxTokens, st.prof.Hooks, prompt)
+	flushTelemetry()
+	if err != nil {
+		return err
+	}
+	return sess.save()
+}
+
+func readDiffInput(name string) (string, error) {
+	var data []byte
+	var err error
+	if name == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(name)
+	}
+	if err != nil {
+		return "", err
+	}
+	if strings.IndexByte(string(data), 0) >= 0 {
+		return "", fmt.Errorf("%s parece binário", name)
+	}
+	return string(data), nil
+}
+
+// diffOp é uma linha do diff: ' ' igual, '-' só em a, '+' só em b.
+type diffOp struct {
+	kind byte
+	line string
+}
+
+// unifiedDiff produz a saída do `diff -u` com ctx linhas de contexto.
+func unifiedDiff(nameA, nameB, a, b string, ctx int) string {
+	ops := diffLines(splitLines(a), splitLines(b))
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", nameA, nameB)
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+		// o hunk vai de ctx linhas antes da mudança até ctx linhas depois da
+		// última mudança que esteja a até 2*ctx linhas iguais da anterior
+		start := max(0, i-ctx)
+		end := i
+		for j := i; j < len(ops); j++ {
+			if ops[j].kind != ' ' {
+				end = j
+			} else if j-end > 2*ctx {
+				break
+			}
+		}
+		stop := min(len(ops), end+ctx+1)
+
+		aLine, bLine := 1, 1
+		for _, op := range ops[:start] {
+			if op.kind != '+' {
+				aLine++
+			}
+			if op.kind != '-' {
+				bLine++
+			}
+		}
+		aCount, bCount := 0, 0
+		for _, op := range ops[start:stop] {
+			if op.kind != '+' {
+				aCount++
+			}
+			if op.kind != '-' {
+				bCount++
+			}
+		}
+		// como no diff -u: um lado vazio aponta para a linha anterior
+		if aCount == 0 {
+			aLine--
+		}
+		if bCount == 0 {
+			bLine--
+		}
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n", aLine, aCount, bLine, bCount)
+		for _, op := range ops[start:stop] {
+			fmt.Fprintf(&out, "%c%s\n", op.kind, op.line)
+		}
+		i = stop
+	}
+	return out.String()
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// diffLines usa o algoritmo de Myers (O(ND)) depois de cortar o prefixo e o
+// sufixo comuns, que em arquivos grandes costumam ser quase tudo.
+func diffLines(a, b []string) []diffOp {
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+	var ops []diffOp
+	for _, l := range a[:pre] {
+		ops = append(ops, diffOp{' ', l})
+	}
+	ops = append(ops, myers(a[pre:len(a)-suf], b[pre:len(b)-suf])...)
+	for _, l := range a[len(a)-suf:] {
+		ops = append(ops, diffOp{' ', l})
+	}
+	return ops
+}
+
+func myers(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+	if n+m == 0 {
+		return nil
+	}
+	offset := n + m
+	v := make([]int, 2*offset+2)
+	// trace[d] guarda v[-d..d] antes da rodada d, para refazer o caminho
+	var trace [][]int
+	for d := 0; d <= n+m; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return myersBacktrack(a, b, trace, d)
+			}
+		}
+	}
+	return nil // inalcançável: d = n+m sempre chega ao fim
+}
+
+func myersBacktrack(a, b []string, trace [][]int, last int) []diffOp {
+	at := func(d, k int) int { return trace[d][k+d] }
+	x, y := len(a), len(b)
+	var rev []diffOp
+	for d := last; d > 0; d-- {
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && at(d, k-1) < at(d, k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := at(d, prevK)
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			rev = append(rev, diffOp{' ', a[x]})
+		}
+		if x == prevX {
+			y--
+			rev = append(rev, diffOp{'+', b[y]})
+		} else {
+			x--
+			rev = append(rev, diffOp{'-', a[x]})
+		}
+	}
+	for x > 0 && y > 0 {
+		x--
+		y--
+		rev = append(rev, diffOp{' ', a[x]})
+	}
+	ops := make([]diffOp, len(rev))
+	for i, op := range rev {
+		ops[len(rev)-1-i] = op
+	}
+	return ops
+}
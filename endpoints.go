@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openai/openai-go/v2/option"
+)
+
+// ===================== Vários endpoints =====================
+//
+// `endpoints: [urlA, urlB]` no profile espalha as chamadas por vários
+// servidores de inferência (vLLM, llama.cpp, Ollama...) no lugar de uma
+// base_url. `balance` escolhe a estratégia:
+//   round_robin (default)  reveza entre os endpoints; cada execução começa
+//                          num ponto diferente
+//   failover               usa sempre o primeiro saudável da lista
+// Um endpoint que falha (erro de conexão, HTTP 5xx ou 429) fica fora por
+// endpointCooldown e a chamada segue no próximo. O estado é guardado em
+// disco, para a próxima execução do CLI já começar pelos saudáveis.
+
+const endpointCooldown = 30 * time.Second
+
+func validBalance(s string) error {
+	switch s {
+	case "", "round_robin", "failover":
+		return nil
+	}
+	return fmt.Errorf("balance inválido %q (use round_robin ou failover)", s)
+}
+
+// gatewayBase é uma base_url já ajustada ao path_style.
+type gatewayBase struct {
+	raw   string
+	u     *url.URL
+	query url.Values
+}
+
+type endpointPool struct {
+	mu       sync.Mutex
+	bases    []gatewayBase
+	failover bool
+	next     int
+}
+
+func newEndpointPool(bases []gatewayBase, balance string) *endpointPool {
+	return &endpointPool{bases: bases, failover: balance == "failover", next: rand.IntN(len(bases))}
+}
+
+// order devolve os índices a tentar: os saudáveis na ordem da estratégia e,
+// por último, os que estão fora (melhor tentar do que falhar sem tentar).
+func (p *endpointPool) order() []int {
+	p.mu.Lock()
+	start := 0
+	if !p.failover {
+		start = p.next % len(p.bases)
+		p.next++
+	}
+	p.mu.Unlock()
+	health := loadEndpointHealth()
+	var up, down []int
+	for n := range p.bases {
+		i := (start + n) % len(p.bases)
+		if time.Now().Before(health[p.bases[i].raw]) {
+			down = append(down, i)
+		} else {
+			up = append(up, i)
+		}
+	}
+	return append(up, down...)
+}
+
+func (p *endpointPool) middleware(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		b, err := io.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		body = b
+	}
+	route := strings.TrimPrefix(req.URL.Path, p.bases[0].u.Path)
+	order := p.order()
+	for n, i := range order {
+		b := p.bases[i]
+		r := req.Clone(req.Context())
+		if body != nil {
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			r.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(body)), nil }
+		}
+		u := *r.URL
+		u.Scheme, u.Host, u.Path, u.RawPath = b.u.Scheme, b.u.Host, b.u.Path+route, ""
+		q := u.Query()
+		for k, vs := range b.query {
+			q[k] = vs
+		}
+		u.RawQuery = q.Encode()
+		r.URL, r.Host = &u, ""
+
+		resp, err := next(r)
+		failed := err != nil || resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		if !failed || req.Context().Err() != nil {
+			if !failed {
+				markEndpoint(b.raw, true)
+			}
+			return resp, err
+		}
+		markEndpoint(b.raw, false)
+		if n == len(order)-1 {
+			return resp, err
+		}
+		reason := ""
+		if err != nil {
+			reason = err.Error()
+		} else {
+			reason = "HTTP " + resp.Status
+			resp.Body.Close()
+		}
+		fmt.Fprintf(os.Stderr, "nota: endpoint %s falhou (%s); fora por %s, tentando %s\n", b.raw, reason, endpointCooldown, p.bases[order[n+1]].raw)
+	}
+	return nil, errors.New("nenhum endpoint configurado")
+}
+
+// ---------- saúde ----------
+
+var endpointHealthMu sync.Mutex
+
+func endpointHealthPath() string { return filepath.Join(stateDir(), "endpoint-health.json") }
+
+// loadEndpointHealth devolve até quando cada endpoint fica fora.
+func loadEndpointHealth() map[string]time.Time {
+	endpointHealthMu.Lock()
+	defer endpointHealthMu.Unlock()
+	return readEndpointHealth()
+}
+
+// readEndpointHealth devolve sempre um mapa novo: quem lê fora do lock não
+// compete com markEndpoint.
+func readEndpointHealth() map[string]time.Time {
+	health := map[string]time.Time{}
+	if ephemeral {
+		health = maps.Clone(memEndpointHealth)
+	} else if data, err := os.ReadFile(endpointHealthPath()); err == nil {
+		_ = json.Unmarshal(data, &health)
+	}
+	if health == nil {
+		health = map[string]time.Time{}
+	}
+	return health
+}
+
+// memEndpointHealth substitui o arquivo no modo efêmero.
+var memEndpointHealth = map[string]time.Time{}
+
+func markEndpoint(raw string, ok bool) {
+	endpointHealthMu.Lock()
+	defer endpointHealthMu.Unlock()
+	health := readEndpointHealth()
+	_, wasDown := health[raw]
+	switch {
+	case ok && !wasDown:
+		return
+	case ok:
+		delete(health, raw)
+	default:
+		health[raw] = time.Now().Add(endpointCooldown)
+	}
+	for k, until := range health {
+		if time.Since(until) > time.Hour {
+			delete(health, k) // limpa endpoints que saíram do config
+		}
+	}
+	if ephemeral {
+		memEndpointHealth = health
+		return
+	}
+	if data, err := json.Marshal(health); err == nil {
+		ensureDir(stateDir())
+		_ = os.WriteFile(endpointHealthPath(), data, 0o600)
+	}
+}
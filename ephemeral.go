@@ -0,0 +1,27 @@
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// ===================== Ephemeral =====================
+//
+// --ephemeral (ou GPTCLI_EPHEMERAL=1) roda sem deixar rastros no disco:
+// nada de histórico, sessões, transcripts, fila offline nem log. Sessões
+// existentes ainda são lidas, mas os turnos novos ficam só na memória.
+// Arquivos pedidos explicitamente (imagens, áudio, flashcards, --scaffold)
+// continuam sendo gravados.
+
+// ephemeral é definido por resolveSettings e vale para o processo todo.
+var ephemeral bool
+
+var errEphemeral = errors.New("modo --ephemeral: nada é gravado em disco")
+
+func ephemeralFromEnv() bool {
+	switch os.Getenv("GPTCLI_EPHEMERAL") {
+	case "", "0", "false":
+		return false
+	}
+	return true
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ===================== REPL: Esc interrompe o stream =====================
+//
+// Durante o stream o terminal fica em modo não canônico (via stty, sem
+// dependências) e um Esc sozinho cancela a geração. Teclas de seta e afins
+// também começam com Esc, mas chegam como sequência num único read.
+
+// interruptedMarker fecha a resposta parcial guardada no contexto.
+const interruptedMarker = "[resposta interrompida pelo usuário]"
+
+// watchEsc chama onEsc quando o usuário aperta Esc. A função devolvida
+// restaura o terminal e encerra a leitura; sem terminal, nada é feito.
+func watchEsc(onEsc func()) (stop func()) {
+	tty, err := os.Open("/dev/tty")
+	if err != nil {
+		return func() {}
+	}
+	saved, err := stty(tty, "-g")
+	if err != nil {
+		tty.Close()
+		return func() {}
+	}
+	if _, err := stty(tty, "-icanon", "-echo", "min", "1"); err != nil {
+		tty.Close()
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		buf := make([]byte, 16)
+		for {
+			n, err := tty.Read(buf)
+			if err != nil {
+				return
+			}
+			if n == 1 && buf[0] == 0x1b {
+				onEsc()
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			// o deadline destrava o Read pendente; Close sozinho não garante isso
+			if tty.SetReadDeadline(time.Now()) == nil {
+				<-done
+			}
+			_, _ = stty(tty, strings.TrimSpace(saved))
+			tty.Close()
+		})
+	}
+}
+
+func stty(tty *os.File, args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = tty
+	out, err := cmd.Output()
+	return string(out), err
+}
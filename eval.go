@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// ===================== Eval =====================
+
+// EvalSuite é o arquivo passado para `gptcli eval`.
+type EvalSuite struct {
+	System string     `yaml:"system"`
+	Models []string   `yaml:"models"` // vazio = modelo resolvido (flags/persona/profile)
+	Format string     `yaml:"format"`
+	Cases  []EvalCase `yaml:"cases"`
+}
+
+type EvalCase struct {
+	Name   string     `yaml:"name"`
+	Prompt string     `yaml:"prompt"`
+	System string     `yaml:"system"` // sobrescreve o system da suíte
+	Format string     `yaml:"format"`
+	Expect EvalExpect `yaml:"expect"`
+}
+
+// EvalExpect lista as asserções; todas precisam passar.
+type EvalExpect struct {
+	Contains    []string          `yaml:"contains"`
+	NotContains []string          `yaml:"not_contains"`
+	Regex       []string          `yaml:"regex"`
+	JSON        bool              `yaml:"json"`        // resposta precisa ser JSON válido
+	JSONFields  map[string]string `yaml:"json_fields"` // caminho (a.b.0.c) => valor esperado
+}
+
+type evalResult struct {
+	name, model string
+	ok          bool
+	detail      string
+	took        time.Duration
+}
+
+func evalCmd(args []string) error {
+	fs := flag.NewFlagSet("eval", flag.ExitOnError)
+	flags := commonFlags(fs)
+	maxFailures := breakerFlag(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "uso: gptcli eval [flags] suite.yaml")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	b, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	var suite EvalSuite
+	if err := yaml.Unmarshal(b, &suite); err != nil {
+		return fmt.Errorf("suíte inválida: %w", err)
+	}
+	if len(suite.Cases) == 0 {
+		return errors.New("suíte sem casos")
+	}
+
+	cfg, _ := loadConfig()
+	st, err := resolveSettings(cfg, flags)
+	if err != nil {
+		return err
+	}
+	initAppLog(st.logLevel, st.profName, st.personaName)
+	configureTools(st)
+	client, err := buildClient(st)
+	if err != nil {
+		return err
+	}
+
+	var models []string
+	for _, m := range suite.Models {
+		models = append(models, st.aliases.resolve(m))
+	}
+	if flags.Model != "" || len(models) == 0 {
+		models = []string{st.model}
+	}
+
+	ctx := withRetryConfig(context.Background(), st.retry)
+	var results []evalResult
+	brk := newBreaker(*maxFailures)
+	total := len(suite.Cases) * len(models)
+cases:
+	for _, c := range suite.Cases {
+		for _, model := range models {
+			sess := &Session{
+				Format:   strings.ToLower(chooseNonEmpty(c.Format, suite.Format, st.format)),
+				Examples: st.persona.exampleTurns(),
+				Tools:    st.tools,
+			}
+			sess.addSystem(chooseNonEmpty(c.System, suite.System, st.system))
+			sess.addUser(c.Prompt)
+
+			started := time.Now()
+			var answer string
+			err := logOp(ctx, "eval", model, func(ctx context.Context) error {
+				return withRetries(ctx, 4, func() error {
+					var err error
+					answer, err = streamChat(ctx, client, sess, model, st.temp, st.maxTokens, func(string) {})
+					return err
+				})
+			})
+			r := evalResult{name: chooseNonEmpty(c.Name, c.Prompt), model: model, took: time.Since(started)}
+			if err != nil {
+				r.detail = "erro: " + err.Error()
+			} else {
+				r.ok, r.detail = c.Expect.check(answer)
+			}
+			results = append(results, r)
+			if brk.record(err) {
+				break cases
+			}
+		}
+	}
+	flushTelemetry()
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CASO\tMODELO\tRESULTADO\tTEMPO\tDETALHE")
+	failed := 0
+	for _, r := range results {
+		status := "PASS"
+		if !r.ok {
+			status = "FAIL"
+			failed++
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", truncate(r.name, 40), r.model, status, r.took.Round(time.Millisecond), r.detail)
+	}
+	tw.Flush()
+	fmt.Printf("\n%d/%d passaram\n", len(results)-failed, len(results))
+	if len(results) < total {
+		brk.report(len(results), total)
+		os.Exit(1)
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+	return nil
+}
+
+// check devolve se a resposta passou e, se não, o motivo da primeira falha.
+func (e EvalExpect) check(answer string) (bool, string) {
+	lower := strings.ToLower(answer)
+	for _, s := range e.Contains {
+		if !strings.Contains(lower, strings.ToLower(s)) {
+			return false, fmt.Sprintf("não contém %q", s)
+		}
+	}
+	for _, s := range e.NotContains {
+		if strings.Contains(lower, strings.ToLower(s)) {
+			return false, fmt.Sprintf("contém %q", s)
+		}
+	}
+	for _, expr := range e.Regex {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return false, fmt.Sprintf("regex inválida %q: %v", expr, err)
+		}
+		if !re.MatchString(answer) {
+			return false, fmt.Sprintf("não casa com /%s/", expr)
+		}
+	}
+	if e.JSON || len(e.JSONFields) > 0 {
+		var v any
+		if err := json.Unmarshal([]byte(stripCodeFence(answer)), &v); err != nil {
+			return false, "JSON inválido: " + err.Error()
+		}
+		for path, want := range e.JSONFields {
+			got, ok := jsonLookup(v, path)
+			if !ok {
+				return false, fmt.Sprintf("campo %s ausente", path)
+			}
+			if s := jsonScalarString(got); s != want {
+				return false, fmt.Sprintf("%s = %q (esperado %q)", path, s, want)
+			}
+		}
+	}
+	return true, ""
+}
+
+// stripCodeFence remove um bloco ```json ... ``` em volta da resposta, se houver.
+func stripCodeFence(s string) string {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "```") {
+		return s
+	}
+	s = strings.TrimPrefix(s, "```")
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = s[i+1:]
+	}
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(s), "```"))
+}
+
+// jsonLookup segue um caminho pontuado (a.b.0.c) num valor decodificado.
+func jsonLookup(v any, path string) (any, bool) {
+	if path == "" {
+		return v, true
+	}
+	for _, key := range strings.Split(path, ".") {
+		switch node := v.(type) {
+		case map[string]any:
+			next, ok := node[key]
+			if !ok {
+				return nil, false
+			}
+			v = next
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, false
+			}
+			v = node[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+func jsonScalarString(v any) string {
+	switch x := v.(type) {
+	case string:
+		return x
+	case nil:
+		return "null"
+	case float64, bool:
+		return fmt.Sprint(x)
+	default:
+		b, _ := json.Marshal(x)
+		return string(b)
+	}
+}
+
+func truncate(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ===================== Explain =====================
+//
+// `gptcli explain arquivo.go:120-180` recorta o intervalo de linhas, detecta a
+// linguagem pela extensão (ou shebang) e pede uma explicação com um system
+// próprio para ela. O trecho original é impresso antes da explicação.
+
+const explainSystem = `Você explica código para um desenvolvedor experiente que não conhece este trecho.
+Comece com um resumo de uma ou duas frases do que o trecho faz. Depois explique o fluxo
+em ordem, citando os números de linha (ex: "L124"), e aponte armadilhas, efeitos
+colaterais e suposições implícitas. Não reescreva o código nem repita o trecho inteiro.
+Responda no idioma da pergunta; sem pergunta, em português.`
+
+// codeLanguage descreve a linguagem: nome para o prompt, tag do bloco de
+// código e o que merece atenção ao explicar.
+type codeLanguage struct {
+	name, fence, focus string
+}
+
+var codeLanguages = map[string]codeLanguage{
+	".go":    {"Go", "go", "tratamento de erros, goroutines/canais, defer, interfaces e ponteiros vs. valores"},
+	".py":    {"Python", "python", "mutabilidade, geradores, context managers, exceções e tipagem dinâmica"},
+	".js":    {"JavaScript", "javascript", "assincronia (promises/async), this, closures e coerção de tipos"},
+	".mjs":   {"JavaScript", "javascript", "assincronia (promises/async), this, closures e coerção de tipos"},
+	".ts":    {"TypeScript", "typescript", "tipos e narrowing, assincronia e diferenças entre tipo e valor em runtime"},
+	".tsx":   {"TypeScript/React", "tsx", "ciclo de render, hooks e suas dependências, estado e props"},
+	".jsx":   {"JavaScript/React", "jsx", "ciclo de render, hooks e suas dependências, estado e props"},
+	".rs":    {"Rust", "rust", "ownership, empréstimos e lifetimes, Result/Option e traits"},
+	".java":  {"Java", "java", "exceções, concorrência, herança/interfaces e nulidade"},
+	".kt":    {"Kotlin", "kotlin", "nulidade, coroutines, extension functions e data classes"},
+	".c":     {"C", "c", "gerenciamento de memória, ponteiros, comportamento indefinido e limites de buffer"},
+	".h":     {"C", "c", "gerenciamento de memória, ponteiros, comportamento indefinido e limites de buffer"},
+	".cpp":   {"C++", "cpp", "RAII, ownership (smart pointers), templates e comportamento indefinido"},
+	".cc":    {"C++", "cpp", "RAII, ownership (smart pointers), templates e comportamento indefinido"},
+	".hpp":   {"C++", "cpp", "RAII, ownership (smart pointers), templates e comportamento indefinido"},
+	".cs":    {"C#", "csharp", "async/await, IDisposable, LINQ e nulidade"},
+	".rb":    {"Ruby", "ruby", "blocos, metaprogramação, mutabilidade e convenções do Rails quando houver"},
+	".php":   {"PHP", "php", "tipagem fraca, escopo de variáveis e entradas não confiáveis"},
+	".swift": {"Swift", "swift", "optionals, ARC/ciclos de referência, value vs. reference types e concorrência"},
+	".sh":    {"Shell", "sh", "quoting, expansão de variáveis, códigos de saída e portabilidade (bash vs. sh)"},
+	".bash":  {"Bash", "bash", "quoting, expansão de variáveis, códigos de saída e set -euo pipefail"},
+	".sql":   {"SQL", "sql", "joins, índices, NULL e custo da consulta"},
+	".lua":   {"Lua", "lua", "tabelas, índices a partir de 1, escopo local/global e metatables"},
+	".yaml":  {"YAML", "yaml", "estrutura, valores padrão implícitos e armadilhas de tipagem do YAML"},
+	".yml":   {"YAML", "yaml", "estrutura, valores padrão implícitos e armadilhas de tipagem do YAML"},
+	".tf":    {"Terraform", "hcl", "recursos criados, dependências implícitas e o que muda no plan"},
+}
+
+// shebangLanguages cobre scripts sem extensão.
+var shebangLanguages = map[string]string{
+	"sh": ".sh", "bash": ".bash", "zsh": ".sh", "python": ".py", "python3": ".py",
+	"node": ".js", "ruby": ".rb", "php": ".php", "lua": ".lua",
+}
+
+var explainSpecRe = regexp.MustCompile(`^(.+?):(\d+)(?:-(\d+))?$`)
+
+func explainCmd(args []string) error {
+	fs := flag.NewFlagSet("explain", flag.ExitOnError)
+	flags := commonFlags(fs)
+	noCode := fs.Bool("no-code", false, "não imprime o trecho original antes da explicação")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, `uso: gptcli explain [flags] arquivo[:início[-fim]] ["pergunta"]`)
+		fmt.Fprintln(os.Stderr, "     ex: gptcli explain main.go:120-180 \"por que o retry usa jitter?\"")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	path, start, end, err := parseExplainSpec(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	question := strings.TrimSpace(strings.Join(fs.Args()[1:], " "))
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if end == 0 || end > len(lines) {
+		end = len(lines)
+	}
+	if start > len(lines) {
+		return fmt.Errorf("%s tem só %d linhas", path, len(lines))
+	}
+	snippet := lines[start-1 : end]
+	lang := detectLanguage(path, lines[0])
+
+	cfg, _ := loadConfig()
+	st, err := resolveSettings(cfg, flags)
+	if err != nil {
+		return err
+	}
+	initAppLog(st.logLevel, st.profName, st.personaName)
+	configureOutput(st)
+	client, err := buildClient(st)
+	if err != nil {
+		return err
+	}
+
+	if !*noCode {
+		printNumbered(snippet, start, filepath.ToSlash(path))
+	}
+
+	system := explainSystem
+	if lang.name != "" {
+		system += fmt.Sprintf("\n\nO código é %s: dê atenção a %s.", lang.name, lang.focus)
+	}
+	sess := &Session{}
+	sess.addSystem(chooseNonEmpty(flags.System, system))
+	var b strings.Builder
+	fmt.Fprintf(&b, "Arquivo %s, linhas %d-%d:\n\n```%s\n", filepath.ToSlash(path), start, end, lang.fence)
+	for i, l := range snippet {
+		fmt.Fprintf(&b, "%d\t%s\n", start+i, l)
+	}
+	b.WriteString("```")
+	if question != "" {
+		b.WriteString("\n\nPergunta: " + question)
+	}
+	sess.addUser(b.String())
+
+	ctx := withRetryConfig(context.Background(), st.retry)
+	err = logOp(ctx, "explain", st.model, func(ctx context.Context) error {
+		return withRetries(ctx, 4, func() error {
+			_, err := streamOnce(ctx, client, sess, st.model, st.temp, st.maxTokens)
+			return err
+		})
+	})
+	flushTelemetry()
+	return err
+}
+
+// parseExplainSpec aceita "arquivo", "arquivo:linha" e "arquivo:início-fim";
+// end == 0 significa até o fim do arquivo.
+func parseExplainSpec(spec string) (path string, start, end int, err error) {
+	m := explainSpecRe.FindStringSubmatch(spec)
+	if m == nil {
+		return spec, 1, 0, nil
+	}
+	path = m[1]
+	start, _ = strconv.Atoi(m[2])
+	end = start
+	if m[3] != "" {
+		end, _ = strconv.Atoi(m[3])
+	}
+	if start < 1 || end < start {
+		return "", 0, 0, errors.New("intervalo inválido: use início-fim com início >= 1 e fim >= início")
+	}
+	return path, start, end, nil
+}
+
+func detectLanguage(path, firstLine string) codeLanguage {
+	if lang, ok := codeLanguages[strings.ToLower(filepath.Ext(path))]; ok {
+		return lang
+	}
+	if interp, ok := strings.CutPrefix(firstLine, "#!"); ok {
+		fields := strings.Fields(interp)
+		if len(fields) > 0 {
+			name := filepath.Base(fields[0])
+			if name == "env" && len(fields) > 1 {
+				name = fields[1]
+			}
+			if ext, ok := shebangLanguages[name]; ok {
+				return codeLanguages[ext]
+			}
+		}
+	}
+	return codeLanguage{}
+}
+
+// printNumbered mostra o trecho com números de linha; em terminal, o
+// cabeçalho e a numeração ficam esmaecidos.
+func printNumbered(lines []string, start int, label string) {
+	dim, reset := "", ""
+	if fi, err := os.Stdout.Stat(); err == nil && fi.Mode()&os.ModeCharDevice != 0 && os.Getenv("NO_COLOR") == "" {
+		dim, reset = "\033[2m", "\033[0m"
+	}
+	width := len(strconv.Itoa(start + len(lines) - 1))
+	fmt.Printf("%s── %s:%d-%d%s\n", dim, label, start, start+len(lines)-1, reset)
+	for i, l := range lines {
+		fmt.Printf("%s%*d │%s %s\n", dim, width, start+i, reset, l)
+	}
+	fmt.Printf("%s──%s\n\n", dim, reset)
+}
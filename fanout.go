@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	openai "github.com/openai/openai-go/v2"
+)
+
+// ===================== Fan-out =====================
+//
+// --profiles a,b,c manda o mesmo prompt para vários profiles em paralelo e
+// imprime as respostas rotuladas, na ordem pedida, para comparar backends.
+
+type fanOutResult struct {
+	profile, model string
+	answer         string
+	hookOutput     string // stderr dos hooks do profile
+	usage          openai.CompletionUsage
+	took           time.Duration
+	err            error
+}
+
+func parseProfileList(cfg *Config, list string) ([]string, error) {
+	var names []string
+	seen := map[string]bool{}
+	for _, n := range strings.Split(list, ",") {
+		n = strings.TrimSpace(n)
+		if n == "" || seen[n] {
+			continue
+		}
+		if cfg == nil {
+			return nil, fmt.Errorf("profile %q: config.yaml não encontrado", n)
+		}
+		if _, ok := cfg.Profiles[n]; !ok {
+			return nil, fmt.Errorf("profile %q não existe no config.yaml", n)
+		}
+		seen[n] = true
+		names = append(names, n)
+	}
+	if len(names) == 0 {
+		return nil, errors.New("--profiles vazio")
+	}
+	return names, nil
+}
+
+func fanOut(ctx context.Context, cfg *Config, flags *Flags, prompt string) error {
+	names, err := parseProfileList(cfg, flags.Profiles)
+	if err != nil {
+		return err
+	}
+
+	// resolveSettings também ajusta globais (transporte, log, modo efêmero):
+	// os profiles são resolvidos um por vez, antes das goroutines
+	results := make([]fanOutResult, len(names))
+	runs := make([]*fanOutRun, len(names))
+	for i, name := range names {
+		results[i].profile = name
+		runs[i], results[i].err = prepareProfile(cfg, flags, name)
+		if runs[i] != nil {
+			results[i].model = runs[i].st.model
+		}
+	}
+	var wg sync.WaitGroup
+	for i, run := range runs {
+		if run == nil {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			askProfile(ctx, run, prompt, &results[i])
+		}()
+	}
+	wg.Wait()
+
+	failed := 0
+	for _, r := range results {
+		fmt.Printf("=== %s • %s • %s", r.profile, chooseNonEmpty(r.model, "-"), r.took.Round(time.Millisecond))
+		if r.usage.TotalTokens > 0 {
+			fmt.Printf(" • %d+%d tokens", r.usage.PromptTokens, r.usage.CompletionTokens)
+		}
+		fmt.Println(" ===")
+		os.Stderr.WriteString(r.hookOutput)
+		if r.err != nil {
+			failed++
+			fmt.Printf("erro: %v\n\n", r.err)
+			continue
+		}
+		fmt.Printf("%s\n\n", strings.TrimRight(r.answer, "\n"))
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d de %d profiles falharam", failed, len(results))
+	}
+	return nil
+}
+
+// fanOutRun é um profile já resolvido, pronto para rodar em paralelo.
+type fanOutRun struct {
+	st     *settings
+	client openai.Client
+	sess   *Session
+}
+
+// prepareProfile resolve as configurações de um profile. O daemon não é
+// usado no fan-out: ele é ligado às credenciais do modo principal.
+func prepareProfile(cfg *Config, flags *Flags, profile string) (*fanOutRun, error) {
+	f := *flags
+	f.Profile, f.Session = profile, ""
+	st, err := resolveSettings(cfg, &f)
+	if err != nil {
+		return nil, err
+	}
+	client, err := buildClient(st)
+	if err != nil {
+		return nil, err
+	}
+	sess, err := openSession(st, &f)
+	if err != nil {
+		return nil, err
+	}
+	return &fanOutRun{st: st, client: client, sess: sess}, nil
+}
+
+// askProfile roda um turno isolado de um profile já resolvido; a saída dos
+// hooks fica em r para não se misturar com a dos outros profiles.
+func askProfile(ctx context.Context, run *fanOutRun, prompt string, r *fanOutResult) {
+	st, sess := run.st, run.sess
+	var hookOut bytes.Buffer
+	ctx = withHookOutput(withUsageTotals(withRetryConfig(ctx, st.retry), &r.usage), &hookOut)
+	ctx, span := startSpan(ctx, "gptcli.turn", attr("gen_ai.request.model", st.model), attr("gptcli.profile", r.profile))
+	started := time.Now()
+	defer func() {
+		r.took = time.Since(started)
+		r.hookOutput = hookOut.String()
+		span.end(r.err)
+	}()
+
+	p, err := st.prof.Hooks.runPre(ctx, prompt, st.model)
+	if err != nil {
+		r.err = err
+		return
+	}
+	sess.addUser(p)
+	r.err = withRetries(ctx, 4, func() error {
+		out, err := streamChat(ctx, run.client, sess, st.model, st.temp, st.maxTokens, func(string) {})
+		if err != nil {
+			return err
+		}
+		r.answer = st.prof.Hooks.runPost(ctx, p, out, st.model)
+		return nil
+	})
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestFanOutProfilesStayApart(t *testing.T) {
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Model string `json:"model"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprintf(w, "data: {\"id\":\"c\",\"object\":\"chat.completion.chunk\",\"model\":%q,\"choices\":[{\"index\":0,\"delta\":{\"content\":\"resposta de %s\"},\"finish_reason\":\"stop\"}]}\n\n", body.Model, body.Model)
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer up.Close()
+
+	hooks := Hooks{Post: `echo "hook de $GPTCLI_MODEL" >&2; cat`}
+	cfg := &Config{Profiles: map[string]Profile{
+		"a": {Model: "modelo-a", BaseURL: up.URL, Hooks: hooks},
+		"b": {Model: "modelo-b", BaseURL: up.URL, Hooks: hooks},
+	}}
+	flags := &Flags{APIKey: "k", Temp: -1}
+	names := []string{"a", "b"}
+	results := make([]fanOutResult, len(names))
+	var runs []*fanOutRun
+	for i, name := range names {
+		run, err := prepareProfile(cfg, flags, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		results[i].profile = name
+		runs = append(runs, run)
+	}
+	var wg sync.WaitGroup
+	for i, run := range runs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			askProfile(context.Background(), run, "oi", &results[i])
+		}()
+	}
+	wg.Wait()
+
+	for i, r := range results {
+		model := runs[i].st.model
+		if r.err != nil {
+			t.Fatalf("%s: %v", r.profile, r.err)
+		}
+		if r.answer != "resposta de "+model {
+			t.Errorf("%s: resposta %q", r.profile, r.answer)
+		}
+		if strings.TrimSpace(r.hookOutput) != "hook de "+model {
+			t.Errorf("%s: saída do hook %q", r.profile, r.hookOutput)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	openai "github.com/openai/openai-go/v2"
+)
+
+// ===================== Fill-in-the-middle =====================
+//
+// `gptcli fim` completa o trecho entre um prefixo e um sufixo usando a API de
+// completions com `suffix`, que os modelos de chat não oferecem.
+
+// fimModel é o default na API da OpenAI; com base_url o modelo do profile é usado.
+const fimModel = "gpt-3.5-turbo-instruct"
+
+const fimMarker = "<FIM>"
+
+func fimCmd(args []string) error {
+	fs := flag.NewFlagSet("fim", flag.ExitOnError)
+	flags := commonFlags(fs)
+	prefixFile := fs.String("prefix-file", "", "arquivo com o texto antes do trecho a completar")
+	suffixFile := fs.String("suffix-file", "", "arquivo com o texto depois do trecho a completar")
+	full := fs.Bool("full", false, "imprime prefixo + trecho gerado + sufixo (útil como filtro de editor)")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "uso: gptcli fim --prefix-file a.txt [--suffix-file b.txt] [flags]")
+		fmt.Fprintf(os.Stderr, "     cat arquivo | gptcli fim        (o trecho a completar é marcado com %s)\n", fimMarker)
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	prefix, suffix, err := fimInput(*prefixFile, *suffixFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	cfg, _ := loadConfig()
+	st, err := resolveSettings(cfg, flags)
+	if err != nil {
+		return err
+	}
+	initAppLog(st.logLevel, st.profName, st.personaName)
+	client, err := buildClient(st)
+	if err != nil {
+		return err
+	}
+	model := st.aliases.resolve(flags.Model)
+	if model == "" {
+		model = fimModel
+		if st.baseURL != "" {
+			model = st.model
+		}
+	}
+	maxTokens := st.maxTokens
+	if maxTokens <= 0 {
+		maxTokens = 256 // o default da API (16) corta quase qualquer trecho de código
+	}
+
+	if *full {
+		fmt.Print(prefix)
+	}
+	err = logOp(withRetryConfig(context.Background(), st.retry), "fim", model, func(ctx context.Context) error {
+		return streamFIM(ctx, client, model, prefix, suffix, st.temp, maxTokens)
+	})
+	flushTelemetry()
+	if err != nil {
+		return err
+	}
+	if *full {
+		fmt.Print(suffix)
+	} else {
+		fmt.Println()
+	}
+	return nil
+}
+
+// fimInput lê prefixo/sufixo dos arquivos ou, sem --prefix-file, do stdin
+// dividido no marcador <FIM>.
+func fimInput(prefixFile, suffixFile string) (prefix, suffix string, err error) {
+	if prefixFile != "" {
+		b, err := os.ReadFile(prefixFile)
+		if err != nil {
+			return "", "", err
+		}
+		prefix = string(b)
+		if suffixFile != "" {
+			b, err := os.ReadFile(suffixFile)
+			if err != nil {
+				return "", "", err
+			}
+			suffix = string(b)
+		}
+		return prefix, suffix, nil
+	}
+	if suffixFile != "" {
+		return "", "", errors.New("--suffix-file requer --prefix-file")
+	}
+	if !isPiped() {
+		return "", "", fmt.Errorf("informe --prefix-file ou envie pelo stdin um texto com %s", fimMarker)
+	}
+	b, err := io.ReadAll(os.Stdin) // sem o TrimSpace de readAllStdin: --full devolve o texto intacto
+	if err != nil {
+		return "", "", err
+	}
+	text := string(b)
+	switch strings.Count(text, fimMarker) {
+	case 0:
+		return "", "", fmt.Errorf("o stdin não contém o marcador %s", fimMarker)
+	case 1:
+	default:
+		return "", "", fmt.Errorf("o marcador %s deve aparecer uma única vez", fimMarker)
+	}
+	prefix, suffix, _ = strings.Cut(text, fimMarker)
+	return prefix, suffix, nil
+}
+
+func streamFIM(ctx context.Context, client openai.Client, model, prefix, suffix string,
+	temp float64, maxTokens int64) error {
+
+	ctx, span := startSpan(ctx, "completions", attr("gen_ai.request.model", model))
+	params := openai.CompletionNewParams{
+		Model:     openai.CompletionNewParamsModel(model),
+		Prompt:    openai.CompletionNewParamsPromptUnion{OfString: openai.String(prefix)},
+		MaxTokens: openai.Int(maxTokens),
+	}
+	if suffix != "" {
+		params.Suffix = openai.String(suffix)
+	}
+	if temp >= 0 {
+		params.Temperature = openai.Float(temp)
+	}
+	params.StreamOptions.IncludeUsage = openai.Bool(true)
+
+	// depois que o trecho começou a sair não há como repetir a chamada
+	var streamErr error
+	err := withRetries(ctx, 4, func() error {
+		stream := client.Completions.NewStreaming(ctx, params)
+		defer stream.Close()
+		printed := false
+		for stream.Next() {
+			chunk := stream.Current()
+			if chunk.Usage.TotalTokens > 0 {
+				noteUsage(ctx, chunk.Usage)
+			}
+			if len(chunk.Choices) > 0 && chunk.Choices[0].Text != "" {
+				fmt.Print(chunk.Choices[0].Text)
+				printed = true
+			}
+		}
+		if err := stream.Err(); err != nil && printed {
+			streamErr = err
+			return nil
+		}
+		return stream.Err()
+	})
+	if err == nil {
+		err = streamErr
+	}
+	span.end(err)
+	return err
+}
@@ -0,0 +1,524 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	openai "github.com/openai/openai-go/v2"
+)
+
+// ===================== Fine-tuning =====================
+//
+// `gptcli finetune` cuida do ciclo de um fine-tuning: `prepare` valida (e
+// converte de prompt/completion) o JSONL de treino, `create` sobe o arquivo e
+// cria o job, `list`/`status` acompanham e `cancel` interrompe.
+
+const finetuneUsage = `uso: gptcli finetune <prepare|create|list|status|cancel> [flags] [args]
+  prepare <treino.jsonl>        valida o JSONL de chat; converte linhas prompt/completion (--out)
+  create <treino.jsonl|file-id> valida, sobe o arquivo e cria o job (--model, --suffix, --epochs, --wait)
+  list                          jobs recentes
+  status <job-id>               estado e últimos eventos do job (--wait espera terminar)
+  cancel <job-id>               cancela o job`
+
+// finetuneDefaultModel é o modelo base quando --model não é informado.
+const finetuneDefaultModel = "gpt-4o-mini-2024-07-18"
+
+// finetuneMaxExampleTokens é o limite por exemplo; o excesso é truncado pelo provedor.
+const finetuneMaxExampleTokens = 65_536
+
+type ftMessage struct {
+	Role      string          `json:"role"`
+	Content   any             `json:"content,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	ToolCalls json.RawMessage `json:"tool_calls,omitempty"`
+	Weight    *int            `json:"weight,omitempty"`
+}
+
+type ftExample struct {
+	Messages          []ftMessage     `json:"messages"`
+	Tools             json.RawMessage `json:"tools,omitempty"`
+	ParallelToolCalls *bool           `json:"parallel_tool_calls,omitempty"`
+}
+
+// ftReport resume a validação de um arquivo de treino.
+type ftReport struct {
+	examples  []ftExample
+	errs      []string
+	warns     []string
+	converted int
+	tokens    []int
+}
+
+func finetuneCmd(args []string) error {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, finetuneUsage)
+		os.Exit(2)
+	}
+	switch args[0] {
+	case "prepare":
+		return finetunePrepare(args[1:])
+	case "create":
+		return finetuneCreate(args[1:])
+	case "list":
+		return finetuneList(args[1:])
+	case "status":
+		return finetuneStatus(args[1:])
+	case "cancel":
+		return finetuneCancel(args[1:])
+	default:
+		fmt.Fprintln(os.Stderr, finetuneUsage)
+		os.Exit(2)
+	}
+	return nil
+}
+
+func finetunePrepare(args []string) error {
+	fs := flag.NewFlagSet("finetune prepare", flag.ExitOnError)
+	out := fs.String("out", "", "grava o JSONL normalizado (linhas prompt/completion viram messages)")
+	system := fs.String("system", "", "mensagem de sistema incluída nas linhas convertidas")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "uso: gptcli finetune prepare [--out arquivo] [--system s] <treino.jsonl>")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	rep, err := validateTrainingFile(fs.Arg(0), *system)
+	if err != nil {
+		return err
+	}
+	rep.print(os.Stderr)
+	if len(rep.errs) > 0 {
+		os.Exit(1)
+	}
+	if *out == "" {
+		if rep.converted > 0 {
+			fmt.Fprintln(os.Stderr, "use --out para gravar o arquivo convertido")
+		}
+		return nil
+	}
+	b, err := rep.jsonl()
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(*out, b, 0o644); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "(%d exemplo(s) gravados em %s)\n", len(rep.examples), *out)
+	return nil
+}
+
+// validateTrainingFile lê o JSONL de treino. Cada linha é um exemplo de chat
+// ({"messages": [...]}) ou um par {"prompt", "completion"}, que é convertido.
+func validateTrainingFile(path, system string) (*ftReport, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	rep := &ftReport{}
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64<<10), 16<<20)
+	for n := 1; sc.Scan(); n++ {
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(line, &fields); err != nil {
+			rep.errs = append(rep.errs, fmt.Sprintf("linha %d: JSON inválido: %v", n, err))
+			continue
+		}
+		var ex ftExample
+		if _, ok := fields["messages"]; ok {
+			unknown := ""
+			for k := range fields {
+				if k != "messages" && k != "tools" && k != "parallel_tool_calls" {
+					unknown = k
+				}
+			}
+			if unknown != "" {
+				rep.errs = append(rep.errs, fmt.Sprintf("linha %d: campo desconhecido %q", n, unknown))
+				continue
+			}
+			if err := json.Unmarshal(line, &ex); err != nil {
+				rep.errs = append(rep.errs, fmt.Sprintf("linha %d: %v", n, err))
+				continue
+			}
+		} else {
+			var pc struct{ Prompt, Completion string }
+			if err := json.Unmarshal(line, &pc); err != nil || pc.Prompt == "" || pc.Completion == "" {
+				rep.errs = append(rep.errs, fmt.Sprintf("linha %d: esperado {\"messages\": [...]} ou {\"prompt\", \"completion\"}", n))
+				continue
+			}
+			if system != "" {
+				ex.Messages = append(ex.Messages, ftMessage{Role: "system", Content: system})
+			}
+			ex.Messages = append(ex.Messages, ftMessage{Role: "user", Content: pc.Prompt}, ftMessage{Role: "assistant", Content: pc.Completion})
+			rep.converted++
+		}
+		if msg := ex.check(); msg != "" {
+			rep.errs = append(rep.errs, fmt.Sprintf("linha %d: %s", n, msg))
+			continue
+		}
+		tokens := ex.estimateTokens()
+		if tokens > finetuneMaxExampleTokens {
+			rep.warns = append(rep.warns, fmt.Sprintf("linha %d: ~%d tokens; o que passar de %d é truncado", n, tokens, finetuneMaxExampleTokens))
+		}
+		rep.examples = append(rep.examples, ex)
+		rep.tokens = append(rep.tokens, tokens)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	switch {
+	case len(rep.examples) == 0 && len(rep.errs) == 0:
+		rep.errs = append(rep.errs, "arquivo sem exemplos")
+	case len(rep.examples) > 0 && len(rep.examples) < 10:
+		rep.errs = append(rep.errs, fmt.Sprintf("só %d exemplo(s); o mínimo aceito é 10 (o recomendado é 50 ou mais)", len(rep.examples)))
+	case len(rep.examples) < 50:
+		rep.warns = append(rep.warns, fmt.Sprintf("%d exemplos: 50 a 100 costumam dar resultados melhores", len(rep.examples)))
+	}
+	return rep, nil
+}
+
+// check devolve o primeiro problema do exemplo, ou "".
+func (ex ftExample) check() string {
+	if len(ex.Messages) == 0 {
+		return "messages vazio"
+	}
+	assistant := false
+	for i, m := range ex.Messages {
+		switch m.Role {
+		case "system", "user", "tool":
+		case "assistant":
+			assistant = true
+		default:
+			return fmt.Sprintf("mensagem %d: role inválido %q", i+1, m.Role)
+		}
+		text, isText := m.Content.(string)
+		switch {
+		case m.Role == "assistant" && len(m.ToolCalls) > 0:
+		case m.Content == nil || isText && strings.TrimSpace(text) == "":
+			return fmt.Sprintf("mensagem %d (%s): content vazio", i+1, m.Role)
+		}
+		if m.Weight != nil && (m.Role != "assistant" || *m.Weight > 1 || *m.Weight < 0) {
+			return fmt.Sprintf("mensagem %d: weight só vale 0 ou 1 e só em mensagens do assistant", i+1)
+		}
+	}
+	if !assistant {
+		return "nenhuma mensagem do assistant para aprender"
+	}
+	return ""
+}
+
+func (ex ftExample) estimateTokens() int {
+	n := 3
+	for _, m := range ex.Messages {
+		b, _ := json.Marshal(m.Content)
+		n += 4 + estimateTokens(string(b)) + estimateTokens(string(m.ToolCalls))
+	}
+	return n + estimateTokens(string(ex.Tools))
+}
+
+func (r *ftReport) print(w io.Writer) {
+	for i, e := range r.errs {
+		if i == 20 {
+			fmt.Fprintf(w, "erro: ... e mais %d\n", len(r.errs)-20)
+			break
+		}
+		fmt.Fprintln(w, "erro:", e)
+	}
+	for _, m := range r.warns {
+		fmt.Fprintln(w, "aviso:", m)
+	}
+	if len(r.tokens) == 0 {
+		return
+	}
+	total := 0
+	for _, t := range r.tokens {
+		total += t
+	}
+	fmt.Fprintf(w, "%d exemplo(s)", len(r.examples))
+	if r.converted > 0 {
+		fmt.Fprintf(w, " (%d convertidos de prompt/completion)", r.converted)
+	}
+	fmt.Fprintf(w, "; ~%d tokens (mín %d, máx %d por exemplo); ~%d tokens treinados em 3 épocas\n",
+		total, slices.Min(r.tokens), slices.Max(r.tokens), total*3)
+}
+
+func (r *ftReport) jsonl() ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	for _, ex := range r.examples {
+		if err := enc.Encode(ex); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func finetuneCreate(args []string) error {
+	fs := flag.NewFlagSet("finetune create", flag.ExitOnError)
+	flags := commonFlags(fs)
+	suffix := fs.String("suffix", "", "sufixo no nome do modelo gerado (ft:...:<sufixo>:...)")
+	epochs := fs.Int64("epochs", 0, "número de épocas (0 = o provedor escolhe)")
+	validation := fs.String("validation", "", "arquivo JSONL (ou file-id) de validação")
+	wait := fs.Bool("wait", false, "acompanha o job até terminar")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "uso: gptcli finetune create [--model base] [--suffix s] [--epochs n] [--wait] <treino.jsonl|file-id>\n(modelo base padrão: %s)\n", finetuneDefaultModel)
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	base := chooseNonEmpty(flags.Model, finetuneDefaultModel)
+	flags.Model = base
+	_, client, err := resolveClient(flags)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	var job *openai.FineTuningJob
+	err = logOp(ctx, "finetune", base, func(ctx context.Context) error {
+		training, err := uploadTrainingFile(ctx, client, fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		params := openai.FineTuningJobNewParams{
+			Model:        openai.FineTuningJobNewParamsModel(base),
+			TrainingFile: training,
+		}
+		if *validation != "" {
+			id, err := uploadTrainingFile(ctx, client, *validation)
+			if err != nil {
+				return err
+			}
+			params.ValidationFile = openai.String(id)
+		}
+		if *suffix != "" {
+			params.Suffix = openai.String(*suffix)
+		}
+		if *epochs > 0 {
+			params.Hyperparameters.NEpochs.OfInt = openai.Int(*epochs)
+		}
+		job, err = client.FineTuning.Jobs.New(ctx, params)
+		return err
+	})
+	flushTelemetry()
+	if err != nil {
+		return err
+	}
+	fmt.Println(job.ID)
+	fmt.Fprintf(os.Stderr, "(job criado sobre %s, status %s)\n", job.Model, job.Status)
+	if !*wait {
+		fmt.Fprintf(os.Stderr, "acompanhe com: gptcli finetune status --wait %s\n", job.ID)
+		return nil
+	}
+	return waitFinetune(client, job.ID, 30*time.Second)
+}
+
+// uploadTrainingFile valida e sobe um arquivo local; um file-id passa direto.
+func uploadTrainingFile(ctx context.Context, client openai.Client, arg string) (string, error) {
+	if _, err := os.Stat(arg); err != nil {
+		if strings.HasPrefix(arg, "file-") {
+			return arg, nil
+		}
+		return "", err
+	}
+	rep, err := validateTrainingFile(arg, "")
+	if err != nil {
+		return "", err
+	}
+	rep.print(os.Stderr)
+	if len(rep.errs) > 0 {
+		return "", fmt.Errorf("%s inválido; corrija ou converta com: gptcli finetune prepare --out novo.jsonl %s", arg, arg)
+	}
+	b, err := rep.jsonl()
+	if err != nil {
+		return "", err
+	}
+	file, err := client.Files.New(ctx, openai.FileNewParams{
+		File:    openai.File(bytes.NewReader(b), filepath.Base(arg), "application/jsonl"),
+		Purpose: openai.FilePurposeFineTune,
+	})
+	if err != nil {
+		return "", fmt.Errorf("upload de %s: %w", arg, err)
+	}
+	return file.ID, nil
+}
+
+func finetuneList(args []string) error {
+	fs := flag.NewFlagSet("finetune list", flag.ExitOnError)
+	flags := commonFlags(fs)
+	_ = fs.Parse(args)
+	_, client, err := resolveClient(flags)
+	if err != nil {
+		return err
+	}
+	page, err := client.FineTuning.Jobs.List(context.Background(), openai.FineTuningJobListParams{Limit: openai.Int(20)})
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tBASE\tSTATUS\tMODELO GERADO\tCRIADO")
+	for _, j := range page.Data {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", j.ID, j.Model, j.Status, chooseNonEmpty(j.FineTunedModel, "-"),
+			time.Unix(j.CreatedAt, 0).Local().Format("2006-01-02 15:04"))
+	}
+	return tw.Flush()
+}
+
+func finetuneStatus(args []string) error {
+	fs := flag.NewFlagSet("finetune status", flag.ExitOnError)
+	flags := commonFlags(fs)
+	wait := fs.Bool("wait", false, "acompanha o job até terminar, imprimindo os eventos")
+	interval := fs.Duration("interval", 30*time.Second, "com --wait: intervalo entre as consultas")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "uso: gptcli finetune status [--wait] <job-id>")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	_, client, err := resolveClient(flags)
+	if err != nil {
+		return err
+	}
+	if *wait {
+		return waitFinetune(client, fs.Arg(0), *interval)
+	}
+	ctx := context.Background()
+	job, err := client.FineTuning.Jobs.Get(ctx, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	printFinetune(job)
+	events, err := client.FineTuning.Jobs.ListEvents(ctx, job.ID, openai.FineTuningJobListEventsParams{Limit: openai.Int(10)})
+	if err != nil {
+		return err
+	}
+	if len(events.Data) > 0 {
+		fmt.Println("\núltimos eventos:")
+		for i := len(events.Data) - 1; i >= 0; i-- {
+			printFinetuneEvent(events.Data[i])
+		}
+	}
+	return nil
+}
+
+func finetuneCancel(args []string) error {
+	fs := flag.NewFlagSet("finetune cancel", flag.ExitOnError)
+	flags := commonFlags(fs)
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errors.New("uso: gptcli finetune cancel <job-id>")
+	}
+	_, client, err := resolveClient(flags)
+	if err != nil {
+		return err
+	}
+	job, err := client.FineTuning.Jobs.Cancel(context.Background(), fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s: %s\n", job.ID, job.Status)
+	return nil
+}
+
+func printFinetune(j *openai.FineTuningJob) {
+	fmt.Printf("job:       %s\n", j.ID)
+	fmt.Printf("status:    %s\n", j.Status)
+	fmt.Printf("base:      %s\n", j.Model)
+	if j.FineTunedModel != "" {
+		fmt.Printf("modelo:    %s\n", j.FineTunedModel)
+	}
+	fmt.Printf("treino:    %s", j.TrainingFile)
+	if j.ValidationFile != "" {
+		fmt.Printf(" (validação %s)", j.ValidationFile)
+	}
+	fmt.Println()
+	if j.TrainedTokens > 0 {
+		fmt.Printf("tokens:    %d treinados\n", j.TrainedTokens)
+	}
+	fmt.Printf("criado:    %s\n", time.Unix(j.CreatedAt, 0).Local().Format("2006-01-02 15:04"))
+	if j.EstimatedFinish > 0 && !finetuneDone(j.Status) {
+		fmt.Printf("previsão:  %s\n", time.Unix(j.EstimatedFinish, 0).Local().Format("2006-01-02 15:04"))
+	}
+	if j.Error.Message != "" {
+		fmt.Printf("erro:      %s (%s)\n", j.Error.Message, j.Error.Code)
+	}
+}
+
+func printFinetuneEvent(e openai.FineTuningJobEvent) {
+	fmt.Printf("  %s  %s\n", time.Unix(e.CreatedAt, 0).Local().Format("15:04:05"), e.Message)
+}
+
+func finetuneDone(s openai.FineTuningJobStatus) bool {
+	switch s {
+	case openai.FineTuningJobStatusSucceeded, openai.FineTuningJobStatusFailed, openai.FineTuningJobStatusCancelled:
+		return true
+	}
+	return false
+}
+
+// waitFinetune imprime os eventos novos até o job terminar; Ctrl+C só para de esperar.
+func waitFinetune(client openai.Client, id string, interval time.Duration) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	seen := map[string]bool{}
+	for {
+		job, err := client.FineTuning.Jobs.Get(ctx, id)
+		if err != nil {
+			if ctx.Err() != nil {
+				return fmt.Errorf("espera interrompida; o job %s continua no provedor", id)
+			}
+			return err
+		}
+		// depois do Get, para o evento final aparecer antes do resumo
+		if events, err := client.FineTuning.Jobs.ListEvents(ctx, id, openai.FineTuningJobListEventsParams{Limit: openai.Int(50)}); err == nil {
+			for i := len(events.Data) - 1; i >= 0; i-- {
+				if e := events.Data[i]; !seen[e.ID] {
+					seen[e.ID] = true
+					printFinetuneEvent(e)
+				}
+			}
+		}
+		if finetuneDone(job.Status) {
+			fmt.Println()
+			printFinetune(job)
+			if job.Status != openai.FineTuningJobStatusSucceeded {
+				return fmt.Errorf("job terminou em %s", job.Status)
+			}
+			fmt.Fprintf(os.Stderr, "use com: gptcli --model %s \"...\"\n", job.FineTunedModel)
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("espera interrompida; o job %s continua no provedor", id)
+		case <-time.After(interval):
+		}
+	}
+}
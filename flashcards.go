@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	openai "github.com/openai/openai-go/v2"
+	"github.com/openai/openai-go/v2/shared"
+)
+
+// ===================== Flashcards =====================
+//
+// --export-flashcards cartoes.csv (ou /flashcards no REPL) pede ao modelo que
+// destile a conversa em pares pergunta/resposta e grava um CSV (ou TSV, pela
+// extensão) que o Anki importa direto: frente, verso e tags.
+
+const flashcardsSystem = `Você cria flashcards para repetição espaçada a partir de uma conversa.
+Extraia os fatos, definições, comandos e raciocínios que valem a pena memorizar,
+um conceito por cartão. A pergunta deve ser específica e respondível sem a conversa;
+a resposta, curta (idealmente uma frase ou um trecho de código). Ignore conversa
+fiada e detalhes do momento. Escreva no idioma da conversa e responda SOMENTE um
+objeto JSON: {"cards": [{"q": "...", "a": "...", "tags": ["..."]}]}`
+
+// maxFlashcardTurn limita cada turno enviado; conversas longas ficam com o essencial.
+const maxFlashcardTurn = 6000
+
+type flashcard struct {
+	Q    string   `json:"q"`
+	A    string   `json:"a"`
+	Tags []string `json:"tags,omitempty"`
+}
+
+// exportFlashcards destila a sessão e grava os cartões em path (vazio =
+// flashcards-<unix>.csv no diretório de configuração).
+func exportFlashcards(ctx context.Context, client openai.Client, model string, sess *Session, path string) (string, int, error) {
+	cards, err := distillFlashcards(ctx, client, model, sess)
+	if err != nil {
+		return "", 0, err
+	}
+	if path == "" {
+		path = filepath.Join(configDir(), fmt.Sprintf("flashcards-%d.csv", time.Now().Unix()))
+	}
+	if err := writeFlashcards(path, cards); err != nil {
+		return "", 0, err
+	}
+	return path, len(cards), nil
+}
+
+// reportFlashcards avisa no stderr onde os cartões foram gravados.
+func reportFlashcards(path string, n int, err error) error {
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "(%d flashcard(s) em %s)\n", n, path)
+	return nil
+}
+
+func distillFlashcards(ctx context.Context, client openai.Client, model string, sess *Session) ([]flashcard, error) {
+	var b strings.Builder
+	for _, t := range sess.Turns {
+		if (t.Role == "user" || t.Role == "assistant") && t.Content != "" && len(t.ToolCalls) == 0 {
+			content := t.Content
+			if len(content) > maxFlashcardTurn {
+				content = content[:maxFlashcardTurn] + "\n[...]"
+			}
+			fmt.Fprintf(&b, "%s: %s\n\n", t.Role, content)
+		}
+	}
+	if b.Len() == 0 {
+		return nil, errors.New("a conversa está vazia; nada para virar flashcard")
+	}
+	params := openai.ChatCompletionNewParams{
+		Model: shared.ChatModel(model),
+		Messages: []openai.ChatCompletionMessageParamUnion{
+			openai.SystemMessage(flashcardsSystem),
+			openai.UserMessage(b.String()),
+		},
+		ResponseFormat: openai.ChatCompletionNewParamsResponseFormatUnion{
+			OfJSONObject: &shared.ResponseFormatJSONObjectParam{},
+		},
+	}
+	var cards []flashcard
+	err := logOp(ctx, "flashcards", model, func(ctx context.Context) error {
+		return withRetries(ctx, 4, func() error {
+			resp, err := client.Chat.Completions.New(ctx, params)
+			if err != nil {
+				return err
+			}
+			if len(resp.Choices) == 0 {
+				return errors.New("resposta vazia")
+			}
+			noteUsage(ctx, resp.Usage)
+			var out struct {
+				Cards []flashcard `json:"cards"`
+			}
+			if err := json.Unmarshal([]byte(stripCodeFence(resp.Choices[0].Message.Content)), &out); err != nil {
+				return fmt.Errorf("o modelo não devolveu JSON válido: %w", err)
+			}
+			cards = cards[:0]
+			for _, c := range out.Cards {
+				if strings.TrimSpace(c.Q) != "" && strings.TrimSpace(c.A) != "" {
+					cards = append(cards, c)
+				}
+			}
+			return nil
+		})
+	})
+	flushTelemetry()
+	if err == nil && len(cards) == 0 {
+		err = errors.New("o modelo não encontrou nada que valesse um flashcard")
+	}
+	return cards, err
+}
+
+// writeFlashcards grava frente;verso;tags. O Anki lê HTML nos campos, então
+// quebras de linha viram <br>; as linhas "#..." configuram o import (Anki 2.1.54+).
+func writeFlashcards(path string, cards []flashcard) error {
+	sep, name := ',', "comma"
+	if strings.EqualFold(filepath.Ext(path), ".tsv") {
+		sep, name = '\t', "tab"
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		ensureDir(dir)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(f, "#separator:%s\n#html:true\n#tags column:3\n", name)
+	w := csv.NewWriter(f)
+	w.Comma = sep
+	for _, c := range cards {
+		tags := make([]string, 0, len(c.Tags))
+		for _, t := range c.Tags {
+			// tags do Anki não têm espaços
+			if t = strings.Join(strings.Fields(t), "_"); t != "" {
+				tags = append(tags, t)
+			}
+		}
+		_ = w.Write([]string{ankiHTML(c.Q), ankiHTML(c.A), strings.Join(tags, " ")})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func ankiHTML(s string) string {
+	r := strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\n", "<br>")
+	return r.Replace(strings.TrimSpace(s))
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/openai/openai-go/v2/option"
+)
+
+// ===================== Gateways (path_style) =====================
+//
+// O SDK resolve cada rota ("chat/completions") relativa à base_url, o que
+// esconde algumas surpresas: a query da base_url some, uma base sem caminho
+// cai em /chat/completions em vez de /v1/chat/completions e quem cola a URL
+// completa do endpoint acaba com o caminho duplicado. `path_style` no profile
+// diz como montar as URLs:
+//   openai (default)  base_url é a raiz da API: sem caminho ganha /v1, e um
+//                     /chat/completions no fim é removido
+//   azure             Azure OpenAI: /openai/deployments/<modelo>/..., com
+//                     api-version (api_version no profile) e o cabeçalho api-key
+//   raw               a base_url é usada como veio, só com a rota no fim
+// Em todos, a query da base_url (ex: ?team=x de um gateway) vai em toda chamada.
+// O provider do profile acrescenta cabeçalhos e campos no corpo do chat.
+
+// defaultAzureAPIVersion é usado quando o profile não define api_version.
+const defaultAzureAPIVersion = "2024-10-21"
+
+// gatewaySettings é montado por resolveSettings a partir do profile e vai
+// junto das settings até buildClient: cada cliente leva o do seu profile.
+type gatewaySettings struct {
+	pathStyle  string
+	apiVersion string
+	headers    map[string]string
+	chatExtras func(model string) map[string]any // campos extras do chat/completions, sem sobrescrever os do pedido
+	bedrock    *bedrockTarget                    // provider bedrock: substitui base_url e path_style
+	endpoints  []string                          // endpoints do profile; o primeiro é a base_url
+	balance    string
+}
+
+// needsOwnClient diz se há opções de gateway que o daemon não recebe
+// (path_style, endpoints, bedrock, cabeçalhos e campos do provider): com
+// elas a chamada não pode passar pelo cliente do daemon.
+func (g gatewaySettings) needsOwnClient() bool {
+	return g.pathStyle != "" || g.apiVersion != "" || len(g.headers) > 0 || g.chatExtras != nil ||
+		g.bedrock != nil || len(g.endpoints) > 0
+}
+
+func validPathStyle(s string) error {
+	switch s {
+	case "", "openai", "azure", "raw":
+		return nil
+	}
+	return fmt.Errorf("path_style inválido %q (use openai, azure ou raw)", s)
+}
+
+// azureDeploymentRoutes recebem o modelo do corpo como deployment no caminho.
+var azureDeploymentRoutes = []string{"chat/completions", "completions", "embeddings", "audio/speech", "images/generations"}
+
+// options devolve a base_url já ajustada ao path_style e as opções extras
+// do cliente.
+func (g gatewaySettings) options(baseURL, apiKey string) ([]option.RequestOption, error) {
+	if g.bedrock != nil {
+		return g.bedrock.options(), nil
+	}
+	style := chooseNonEmpty(g.pathStyle, "openai")
+	var opts []option.RequestOption
+	for _, k := range sortedKeys(g.headers) {
+		opts = append(opts, option.WithHeader(k, g.headers[k]))
+	}
+	if extras := g.chatExtras; extras != nil {
+		opts = append(opts, option.WithMiddleware(func(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+			if req.Method == http.MethodPost && strings.HasSuffix(req.URL.Path, "/chat/completions") {
+				if err := mergeBody(req, extras); err != nil {
+					return nil, err
+				}
+			}
+			return next(req)
+		}))
+	}
+	if baseURL == "" {
+		if style == "azure" {
+			return nil, fmt.Errorf("path_style azure precisa da base_url do recurso (https://<recurso>.openai.azure.com)")
+		}
+		return opts, nil
+	}
+	base, err := normalizeBase(baseURL, style, g.apiVersion)
+	if err != nil {
+		return nil, err
+	}
+	u, query := base.u, base.query
+	opts = append(opts, option.WithBaseURL(u.String()))
+	if len(query) > 0 {
+		opts = append(opts, option.WithMiddleware(func(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+			q := req.URL.Query()
+			for k, vs := range query {
+				if !q.Has(k) {
+					q[k] = vs
+				}
+			}
+			req.URL.RawQuery = q.Encode()
+			return next(req)
+		}))
+	}
+	if eps := g.endpoints; len(eps) > 1 && eps[0] == baseURL {
+		bases := []gatewayBase{base}
+		for _, raw := range eps[1:] {
+			b, err := normalizeBase(raw, style, g.apiVersion)
+			if err != nil {
+				return nil, err
+			}
+			bases = append(bases, b)
+		}
+		opts = append(opts, option.WithMiddleware(newEndpointPool(bases, g.balance).middleware))
+	}
+	if style == "azure" {
+		prefix := u.Path
+		opts = append(opts, option.WithMiddleware(func(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+			req.Header.Del("Authorization")
+			if apiKey != "" {
+				req.Header.Set("Api-Key", apiKey)
+			}
+			route := strings.TrimPrefix(req.URL.Path, prefix)
+			for _, r := range azureDeploymentRoutes {
+				if route != r {
+					continue
+				}
+				model, err := requestModel(req)
+				if err != nil {
+					return nil, err
+				}
+				req.URL.Path = prefix + "deployments/" + url.PathEscape(model) + "/" + route
+				break
+			}
+			return next(req)
+		}))
+	}
+	return opts, nil
+}
+
+// normalizeBase ajusta uma base_url ao path_style; a query fica à parte.
+func normalizeBase(raw, style, apiVersion string) (gatewayBase, error) {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return gatewayBase{}, fmt.Errorf("base_url inválida %q", raw)
+	}
+	query := u.Query()
+	u.RawQuery = ""
+	path := strings.TrimRight(u.Path, "/")
+	switch style {
+	case "openai":
+		if trimmed, ok := strings.CutSuffix(path, "/chat/completions"); ok {
+			fmt.Fprintf(os.Stderr, "nota: base_url termina em /chat/completions; usando %s como raiz da API\n", chooseNonEmpty(trimmed, "/"))
+			path = trimmed
+		}
+		if path == "" {
+			path = "/v1"
+		}
+	case "azure":
+		path = strings.TrimSuffix(path, "/openai") + "/openai"
+		query.Set("api-version", chooseNonEmpty(apiVersion, defaultAzureAPIVersion))
+	}
+	u.Path = path + "/"
+	return gatewayBase{raw: raw, u: u, query: query}, nil
+}
+
+// requestModel lê o campo model do corpo JSON e devolve o corpo intacto.
+func requestModel(req *http.Request) (string, error) {
+	if req.Body == nil {
+		return "", fmt.Errorf("azure: %s sem corpo para achar o deployment", req.URL.Path)
+	}
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return "", err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	var v struct {
+		Model string `json:"model"`
+	}
+	if err := json.Unmarshal(body, &v); err != nil || v.Model == "" {
+		return "", fmt.Errorf("azure: não achei o modelo (deployment) no corpo de %s", req.URL.Path)
+	}
+	return v.Model, nil
+}
+
+// mergeBody acrescenta ao corpo JSON os campos de extras(model) que o pedido
+// ainda não tem.
+func mergeBody(req *http.Request, extras func(model string) map[string]any) error {
+	return rewriteJSONBody(req, func(m map[string]any) bool {
+		model, _ := m["model"].(string)
+		changed := false
+		for k, v := range extras(model) {
+			if _, ok := m[k]; !ok {
+				m[k], changed = v, true
+			}
+		}
+		return changed
+	})
+}
+
+// rewriteJSONBody passa o corpo JSON do pedido por fn e, se fn disser que
+// mudou algo, troca o corpo. Corpos que não são um objeto JSON passam intactos.
+func rewriteJSONBody(req *http.Request, fn func(m map[string]any) bool) error {
+	if req.Body == nil {
+		return nil
+	}
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var m map[string]any
+	if err := dec.Decode(&m); err != nil || !fn(m) {
+		return nil
+	}
+	if body, err = json.Marshal(m); err != nil {
+		return err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+	req.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(body)), nil }
+	return nil
+}
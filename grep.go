@@ -0,0 +1,166 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ===================== Grep =====================
+//
+// `gptcli grep` procura texto nas sessões salvas e nos transcripts gravados
+// com /save no diretório de config.
+
+type grepDoc struct {
+	id      string // sessão:turno ou arquivo do transcript
+	role    string
+	when    time.Time
+	content string
+}
+
+func grepCmd(args []string) error {
+	fs := flag.NewFlagSet("grep", flag.ExitOnError)
+	useRegex := fs.Bool("regex", false, "interpreta a consulta como expressão regular")
+	fs.BoolVar(useRegex, "e", false, "atalho para --regex")
+	caseSens := fs.Bool("case", false, "diferencia maiúsculas de minúsculas")
+	since := fs.String("since", "", "só resultados a partir de (YYYY-MM-DD ou duração: 12h, 7d)")
+	until := fs.String("until", "", "só resultados até (YYYY-MM-DD)")
+	role := fs.String("role", "", "filtra por papel (user|assistant)")
+	limit := fs.Int("n", 50, "máximo de resultados (0 = sem limite)")
+	width := fs.Int("context", 80, "caracteres de contexto em volta do trecho")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "uso: gptcli grep [flags] <consulta>")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	expr := strings.Join(fs.Args(), " ")
+	if !*useRegex {
+		expr = regexp.QuoteMeta(expr)
+	}
+	if !*caseSens {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return fmt.Errorf("regex inválida: %w", err)
+	}
+	from, err := parseSince(*since)
+	if err != nil {
+		return err
+	}
+	var to time.Time
+	if *until != "" {
+		if to, err = time.ParseInLocation("2006-01-02", *until, time.Local); err != nil {
+			return fmt.Errorf("--until inválido %q (use YYYY-MM-DD)", *until)
+		}
+		to = to.AddDate(0, 0, 1)
+	}
+
+	docs, err := grepDocs()
+	if err != nil {
+		return err
+	}
+	found := 0
+	for _, d := range docs {
+		if (*role != "" && d.role != *role) || (!from.IsZero() && d.when.Before(from)) ||
+			(!to.IsZero() && !d.when.Before(to)) {
+			continue
+		}
+		loc := re.FindStringIndex(d.content)
+		if loc == nil {
+			continue
+		}
+		fmt.Printf("%s [%s] %s\n  %s\n\n", d.id, d.role, d.when.Local().Format("2006-01-02 15:04"),
+			excerpt(d.content, loc, *width))
+		found++
+		if *limit > 0 && found >= *limit {
+			break
+		}
+	}
+	if found == 0 {
+		return errors.New("nenhum resultado")
+	}
+	return nil
+}
+
+// grepDocs junta turnos das sessões (mais recentes primeiro) e transcripts.
+func grepDocs() ([]grepDoc, error) {
+	sessions, err := listSessions()
+	if err != nil {
+		return nil, err
+	}
+	var docs []grepDoc
+	for _, s := range sessions {
+		if s.Title != "" {
+			docs = append(docs, grepDoc{id: s.Name, role: "title", when: s.Updated, content: s.Title})
+		}
+		for i, t := range s.Turns {
+			docs = append(docs, grepDoc{id: s.Name + ":" + strconv.Itoa(i+1), role: t.Role, when: s.Updated, content: t.Content})
+		}
+	}
+	paths, _ := filepath.Glob(filepath.Join(configDir(), "transcript-*.md"))
+	for i := len(paths) - 1; i >= 0; i-- {
+		b, err := readStorage(paths[i])
+		if err != nil {
+			continue
+		}
+		var when time.Time
+		if st, err := os.Stat(paths[i]); err == nil {
+			when = st.ModTime()
+		}
+		docs = append(docs, grepDoc{id: filepath.Base(paths[i]), role: "transcript", when: when, content: string(b)})
+	}
+	return docs, nil
+}
+
+// parseSince aceita uma data (YYYY-MM-DD) ou uma duração relativa (12h, 7d).
+func parseSince(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+	if n, ok := strings.CutSuffix(s, "d"); ok {
+		if days, err := strconv.Atoi(n); err == nil {
+			return time.Now().AddDate(0, 0, -days), nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("--since inválido %q (use YYYY-MM-DD ou 12h, 7d)", s)
+}
+
+// excerpt recorta o trecho em volta do match, em uma linha.
+func excerpt(s string, loc []int, width int) string {
+	start, end := loc[0]-width, loc[1]+width
+	prefix, suffix := "…", "…"
+	if start <= 0 {
+		start, prefix = 0, ""
+	}
+	if end >= len(s) {
+		end, suffix = len(s), ""
+	}
+	// não corta no meio de um caractere UTF-8
+	for start > 0 && !isRuneStart(s[start]) {
+		start--
+	}
+	for end < len(s) && !isRuneStart(s[end]) {
+		end++
+	}
+	return prefix + strings.Join(strings.Fields(s[start:end]), " ") + suffix
+}
+
+func isRuneStart(b byte) bool { return b&0xC0 != 0x80 }
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"unicode/utf8"
+)
+
+// ===================== Hooks =====================
+
+// Hooks são comandos de shell executados antes do envio do prompt (pre) e
+// depois da resposta (post). Cada hook recebe o texto no stdin; se escrever
+// algo no stdout, esse conteúdo substitui o texto original.
+type Hooks struct {
+	Pre  string `yaml:"pre,omitempty"`
+	Post string `yaml:"post,omitempty"`
+}
+
+type hookOutputKey struct{}
+
+// withHookOutput desvia o stderr dos hooks, e os avisos sobre eles, para w:
+// no fan-out cada profile guarda o seu e ele sai junto da resposta.
+func withHookOutput(ctx context.Context, w io.Writer) context.Context {
+	return context.WithValue(ctx, hookOutputKey{}, w)
+}
+
+func hookOutput(ctx context.Context) io.Writer {
+	if w, ok := ctx.Value(hookOutputKey{}).(io.Writer); ok {
+		return w
+	}
+	return os.Stderr
+}
+
+func runHook(ctx context.Context, command, input string, env ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Stdin = strings.NewReader(input)
+	cmd.Stderr = hookOutput(ctx)
+	cmd.Env = append(os.Environ(), env...)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out.String()), nil
+}
+
+// runPre transforma o prompt. Falha no hook aborta o envio.
+func (h Hooks) runPre(ctx context.Context, prompt, model string) (string, error) {
+	if strings.TrimSpace(h.Pre) == "" {
+		return prompt, nil
+	}
+	out, err := runHook(ctx, h.Pre, prompt, "GPTCLI_HOOK=pre", "GPTCLI_MODEL="+model)
+	if err != nil {
+		return "", fmt.Errorf("hook pre: %w", err)
+	}
+	if out == "" {
+		return prompt, nil
+	}
+	return out, nil
+}
+
+// maxHookEnvPrompt limita GPTCLI_PROMPT: uma variável grande demais faz o
+// exec falhar (E2BIG). O prompt inteiro vai em GPTCLI_PROMPT_FILE.
+const maxHookEnvPrompt = 16 << 10
+
+// runPost recebe a resposta já exibida. Se o hook produzir saída, ela passa a
+// ser a resposta registrada na sessão; mostrá-la fica com quem chamou, que
+// sabe se a resposta já foi para o stdout. Falhas só geram aviso.
+func (h Hooks) runPost(ctx context.Context, prompt, resp, model string) string {
+	if strings.TrimSpace(h.Post) == "" {
+		return resp
+	}
+	env := []string{"GPTCLI_HOOK=post", "GPTCLI_MODEL=" + model, "GPTCLI_PROMPT=" + truncateBytes(prompt, maxHookEnvPrompt)}
+	if path, err := writeHookPrompt(prompt); err == nil {
+		defer os.Remove(path)
+		env = append(env, "GPTCLI_PROMPT_FILE="+path)
+	}
+	out, err := runHook(ctx, h.Post, resp, env...)
+	if err != nil {
+		fmt.Fprintln(hookOutput(ctx), "aviso: hook post:", err)
+		return resp
+	}
+	if out == "" {
+		return resp
+	}
+	return out
+}
+
+// writeHookPrompt grava o prompt num arquivo temporário para o hook post. No
+// modo efêmero nada é gravado e só fica GPTCLI_PROMPT.
+func writeHookPrompt(prompt string) (string, error) {
+	if ephemeral {
+		return "", errEphemeral
+	}
+	f, err := os.CreateTemp("", "gptcli-prompt-*.txt")
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.WriteString(prompt); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// truncateBytes corta s em até n bytes sem partir um caractere UTF-8.
+func truncateBytes(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	openai "github.com/openai/openai-go/v2"
+)
+
+// ===================== Image REPL =====================
+
+const imageHelpText = `Comandos (imagem):
+  /help                  mostra esta ajuda
+  /exit | /quit          sai do REPL
+  /redo                  refaz o último pedido (mesmo prompt e mesma referência)
+  /size [WxH]            mostra ou define o tamanho (ex: 1024x1536); "auto" limpa
+  /save-as <caminho>     copia a última imagem para o caminho informado
+  /reset                 descarta a imagem atual; o próximo prompt gera do zero
+Qualquer outro texto refina a última imagem (ela é enviada como referência).
+`
+
+type imageReplState struct {
+	prompt string   // último prompt enviado
+	ref    string   // imagem usada como referência no último pedido
+	last   []string // imagens geradas no último pedido
+	size   string
+}
+
+func imageRepl(ctx context.Context, client openai.Client, flags *Flags, proxy string) {
+	st := &imageReplState{size: strings.TrimSpace(flags.ImageSize)}
+	fmt.Printf("gptcli • image-model=%s • ctrl+c/ctrl+d para sair\n", flags.ImageModel)
+	in := bufio.NewScanner(os.Stdin)
+	for {
+		fmt.Print("img> ")
+		if !in.Scan() {
+			break
+		}
+		line := strings.TrimSpace(in.Text())
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "/") {
+			parts := strings.Fields(line)
+			switch parts[0] {
+			case "/help":
+				fmt.Print(imageHelpText)
+			case "/exit", "/quit":
+				return
+			case "/redo":
+				if st.prompt == "" {
+					fmt.Println("nada para refazer")
+					continue
+				}
+				st.run(ctx, client, flags, proxy, st.prompt, st.ref)
+			case "/size":
+				if len(parts) < 2 {
+					size := st.size
+					if size == "" {
+						size = "auto"
+					}
+					fmt.Println("(tamanho:", size, ")")
+					continue
+				}
+				st.size = parts[1]
+				if strings.EqualFold(st.size, "auto") {
+					st.size = ""
+				}
+				fmt.Println("(tamanho:", parts[1], ")")
+			case "/save-as":
+				if len(parts) < 2 {
+					fmt.Println("uso: /save-as <caminho>")
+					continue
+				}
+				if len(st.last) == 0 {
+					fmt.Println("nenhuma imagem gerada ainda")
+					continue
+				}
+				if err := copyFile(st.last[0], parts[1]); err != nil {
+					fmt.Println("erro:", err)
+				} else {
+					fmt.Println("Imagem salva em", parts[1])
+				}
+			case "/reset":
+				st.prompt, st.ref, st.last = "", "", nil
+				fmt.Println("(imagem descartada)")
+			default:
+				fmt.Println("comando desconhecido. /help para ajuda")
+			}
+			continue
+		}
+
+		ref := ""
+		if len(st.last) > 0 {
+			ref = st.last[0]
+		}
+		st.run(ctx, client, flags, proxy, line, ref)
+	}
+}
+
+// run gera (sem referência) ou edita (com referência) e atualiza o estado.
+func (st *imageReplState) run(ctx context.Context, client openai.Client, flags *Flags, proxy, prompt, ref string) {
+	local := *flags
+	local.ImageSize = st.size
+	var saved []string
+	err := logOp(ctx, "image-repl", flags.ImageModel, func(ctx context.Context) error {
+		return withRetries(ctx, 4, func() error {
+			var err error
+			if ref == "" {
+				saved, err = generateImages(ctx, client, prompt, &local, proxy)
+			} else {
+				var img imageFile
+				if img, err = loadImageFile(ref); err == nil {
+					saved, err = editImage(ctx, client, prompt, img, &local, proxy)
+				}
+			}
+			return err
+		})
+	})
+	flushTelemetry()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		return
+	}
+	st.prompt, st.ref, st.last = prompt, ref, saved
+	if ref != "" {
+		saveHistory("IMG (refino de " + ref + "): " + prompt)
+	} else {
+		saveHistory("IMG: " + prompt)
+	}
+}
+
+// imageFile é uma imagem já lida, para que cada tentativa de edição reenvie
+// os mesmos bytes (o stdin só pode ser lido uma vez).
+type imageFile struct {
+	name string
+	mime string
+	data []byte
+}
+
+// loadImageFile lê a imagem a editar de um arquivo ou do stdin ("-").
+func loadImageFile(ref string) (imageFile, error) {
+	data, mime, err := readImage(ref)
+	if err != nil {
+		return imageFile{}, err
+	}
+	name := filepath.Base(ref)
+	if ref == "-" {
+		name = "stdin." + strings.TrimPrefix(mime, "image/")
+	}
+	if mime == "image/gif" {
+		return imageFile{}, fmt.Errorf("%s: a edição não aceita GIF (use PNG, JPEG ou WebP)", name)
+	}
+	return imageFile{name: name, mime: mime, data: data}, nil
+}
+
+func editImage(ctx context.Context, client openai.Client, prompt string, img imageFile, flags *Flags, proxy string) ([]string, error) {
+	params := openai.ImageEditParams{
+		Prompt: prompt,
+		Image: openai.ImageEditParamsImageUnion{
+			OfFile: openai.File(bytes.NewReader(img.data), img.name, img.mime),
+		},
+	}
+	if model := strings.TrimSpace(flags.ImageModel); model != "" {
+		params.Model = openai.ImageModel(model)
+	}
+	if size := strings.TrimSpace(flags.ImageSize); size != "" {
+		params.Size = openai.ImageEditParamsSize(size)
+	}
+	if quality := strings.TrimSpace(flags.ImageQuality); quality != "" {
+		params.Quality = openai.ImageEditParamsQuality(quality)
+	}
+	if format := strings.TrimSpace(flags.ImageFormat); format != "" {
+		params.OutputFormat = openai.ImageEditParamsOutputFormat(format)
+	}
+	if flags.ImageCount > 1 {
+		params.N = openai.Int(int64(flags.ImageCount))
+	}
+
+	ctx, span := startSpan(ctx, "images.edit", attr("gen_ai.request.model", flags.ImageModel))
+	resp, err := client.Images.Edit(ctx, params)
+	span.end(err)
+	if err != nil {
+		return nil, err
+	}
+	return saveImagesResponse(ctx, resp, flags, proxy)
+}
+
+func copyFile(src, dst string) error {
+	if src == "" {
+		return errors.New("origem vazia")
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if err := ensureFileDirectory(dst); err != nil {
+		return err
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
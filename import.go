@@ -0,0 +1,378 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// ===================== Import (export do ChatGPT) =====================
+//
+// `gptcli import chatgpt-export.zip` lê o export oficial de dados do ChatGPT
+// (o .zip ou o conversations.json de dentro dele) e grava cada conversa como
+// uma sessão, com título, modelo e datas originais, para continuar com
+// --session e achar com `gptcli grep`. Cada conversa é uma árvore (respostas
+// regeneradas, mensagens editadas); vale o ramo que estava na tela, do nó
+// atual até a raiz. Entram só as mensagens visíveis de usuário e assistente:
+// chamadas de ferramentas (navegação, código) e mensagens ocultas ficam de
+// fora, e imagens viram um marcador no texto.
+
+type chatgptConversation struct {
+	ID             string                 `json:"id"`
+	ConversationID string                 `json:"conversation_id"`
+	Title          string                 `json:"title"`
+	CreateTime     float64                `json:"create_time"`
+	UpdateTime     float64                `json:"update_time"`
+	CurrentNode    string                 `json:"current_node"`
+	Model          string                 `json:"default_model_slug"`
+	Mapping        map[string]chatgptNode `json:"mapping"`
+}
+
+type chatgptNode struct {
+	Parent   string          `json:"parent"`
+	Children []string        `json:"children"`
+	Message  *chatgptMessage `json:"message"`
+}
+
+type chatgptMessage struct {
+	Author struct {
+		Role string `json:"role"`
+	} `json:"author"`
+	Recipient string `json:"recipient"`
+	Content   struct {
+		ContentType string            `json:"content_type"`
+		Parts       []json.RawMessage `json:"parts"`
+	} `json:"content"`
+	Metadata struct {
+		Hidden bool `json:"is_visually_hidden_from_conversation"`
+	} `json:"metadata"`
+}
+
+func (c *chatgptConversation) id() string { return chooseNonEmpty(c.ID, c.ConversationID) }
+
+// updated é a data usada nos filtros e na ordem; exports antigos podem vir
+// sem update_time.
+func (c *chatgptConversation) updated() time.Time {
+	return unixTime(max(c.UpdateTime, c.CreateTime))
+}
+
+func unixTime(sec float64) time.Time {
+	if sec <= 0 {
+		return time.Time{}
+	}
+	whole, frac := math.Modf(sec)
+	return time.Unix(int64(whole), int64(frac*1e9))
+}
+
+// branch devolve as mensagens do ramo atual, da raiz ao nó atual.
+func (c *chatgptConversation) branch() []*chatgptMessage {
+	node := c.CurrentNode
+	if node == "" { // sem nó atual: segue o último filho a partir da raiz
+		for id, n := range c.Mapping {
+			if n.Parent == "" {
+				node = id
+				break
+			}
+		}
+		for steps := 0; steps <= len(c.Mapping); steps++ {
+			kids := c.Mapping[node].Children
+			if len(kids) == 0 {
+				break
+			}
+			node = kids[len(kids)-1]
+		}
+	}
+	var msgs []*chatgptMessage
+	for steps := 0; node != "" && steps <= len(c.Mapping); steps++ {
+		n, ok := c.Mapping[node]
+		if !ok {
+			break
+		}
+		if n.Message != nil {
+			msgs = append(msgs, n.Message)
+		}
+		node = n.Parent
+	}
+	slices.Reverse(msgs)
+	return msgs
+}
+
+// text junta as partes de texto da mensagem; "" para o que não é conversa.
+func (m *chatgptMessage) text() string {
+	if m.Metadata.Hidden || (m.Recipient != "" && m.Recipient != "all") {
+		return ""
+	}
+	switch m.Content.ContentType {
+	case "text", "multimodal_text":
+	default: // code, execution_output, tether_quote...: uso de ferramentas
+		return ""
+	}
+	var parts []string
+	for _, raw := range m.Content.Parts {
+		var s string
+		if json.Unmarshal(raw, &s) == nil {
+			parts = append(parts, s)
+			continue
+		}
+		var obj struct {
+			ContentType string `json:"content_type"`
+			Text        string `json:"text"`
+		}
+		if json.Unmarshal(raw, &obj) != nil {
+			continue
+		}
+		switch {
+		case obj.Text != "": // transcrição do modo de voz
+			parts = append(parts, obj.Text)
+		case strings.Contains(obj.ContentType, "image"):
+			parts = append(parts, "[imagem]")
+		}
+	}
+	return strings.TrimSpace(strings.Join(parts, "\n"))
+}
+
+// session converte a conversa; mensagens seguidas do mesmo papel viram um
+// turno só.
+func (c *chatgptConversation) session(name string, tags []string) *Session {
+	s := &Session{
+		Name: name, Title: strings.TrimSpace(c.Title), Model: c.Model, Tags: tags,
+		Created: unixTime(c.CreateTime), Updated: unixTime(c.UpdateTime),
+	}
+	for _, m := range c.branch() {
+		text := m.text()
+		if text == "" {
+			continue
+		}
+		role := m.Author.Role
+		switch {
+		case role == "system":
+			if s.System == "" {
+				s.System = text
+			}
+		case role != "user" && role != "assistant":
+		case len(s.Turns) > 0 && s.Turns[len(s.Turns)-1].Role == role:
+			s.Turns[len(s.Turns)-1].Content += "\n\n" + text
+		default:
+			s.Turns = append(s.Turns, Turn{Role: role, Content: text})
+		}
+	}
+	switch { // exports antigos podem vir sem uma das datas
+	case s.Created.IsZero() && s.Updated.IsZero():
+		s.Created = time.Now()
+		s.Updated = s.Created
+	case s.Updated.IsZero():
+		s.Updated = s.Created
+	case s.Created.IsZero():
+		s.Created = s.Updated
+	}
+	return s
+}
+
+// readChatGPTExport aceita o .zip do export ou o conversations.json.
+func readChatGPTExport(file string) ([]chatgptConversation, error) {
+	var convs []chatgptConversation
+	decode := func(r io.Reader, name string) error {
+		var part []chatgptConversation
+		if err := json.NewDecoder(r).Decode(&part); err != nil {
+			return fmt.Errorf("%s: não parece um export do ChatGPT: %w", name, err)
+		}
+		convs = append(convs, part...)
+		return nil
+	}
+	if !strings.EqualFold(filepath.Ext(file), ".zip") {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return convs, decode(f, file)
+	}
+	zr, err := zip.OpenReader(file)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	found := false
+	for _, zf := range zr.File {
+		// conversations.json; exports grandes podem vir em partes (conversations-000.json)
+		base := path.Base(zf.Name)
+		if !strings.HasPrefix(base, "conversations") || path.Ext(base) != ".json" {
+			continue
+		}
+		rc, err := zf.Open()
+		if err != nil {
+			return nil, err
+		}
+		err = decode(rc, zf.Name)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		found = true
+	}
+	if !found {
+		return nil, fmt.Errorf("%s: conversations.json não encontrado no zip", file)
+	}
+	return convs, nil
+}
+
+var slugReplacer = strings.NewReplacer(
+	"á", "a", "à", "a", "â", "a", "ã", "a", "ä", "a", "é", "e", "è", "e", "ê", "e", "ë", "e",
+	"í", "i", "ì", "i", "î", "i", "ï", "i", "ó", "o", "ò", "o", "ô", "o", "õ", "o", "ö", "o",
+	"ú", "u", "ù", "u", "û", "u", "ü", "u", "ç", "c", "ñ", "n",
+)
+
+var slugStrip = regexp.MustCompile(`[^a-z0-9]+`)
+
+// importName monta um nome de sessão estável: o mesmo export importado de
+// novo cai nos mesmos nomes.
+func importName(prefix string, c *chatgptConversation) string {
+	slug := strings.Trim(slugStrip.ReplaceAllString(slugReplacer.Replace(strings.ToLower(c.Title)), "-"), "-")
+	if len(slug) > 40 {
+		slug = strings.TrimRight(slug[:40], "-")
+	}
+	id := slugStrip.ReplaceAllString(strings.ToLower(c.id()), "")
+	id = id[:min(len(id), 8)]
+	parts := []string{prefix}
+	for _, p := range []string{slug, id} {
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+	return strings.Join(parts, "-")
+}
+
+const importUsage = `uso: gptcli import [flags] <export.zip|conversations.json>
+  Importa conversas do export de dados do ChatGPT (Configurações > Controles de dados > Exportar).
+`
+
+func importCmd(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	list := fs.Bool("list", false, "só lista as conversas do export, sem importar")
+	match := fs.String("match", "", "só conversas cujo título casa com a regex (sem diferenciar maiúsculas)")
+	since := fs.String("since", "", "só conversas atualizadas a partir de (YYYY-MM-DD ou 12h, 7d)")
+	until := fs.String("until", "", "só conversas atualizadas até (YYYY-MM-DD)")
+	var ids, tags stringList
+	fs.Var(&ids, "id", "importa só esta conversa (id ou começo dele; repetível)")
+	fs.Var(&tags, "tag", "tag extra nas sessões importadas, além de chatgpt (repetível)")
+	prefix := fs.String("prefix", "chatgpt", "prefixo do nome das sessões")
+	force := fs.Bool("force", false, "sobrescreve sessões já importadas")
+	transcripts := fs.Bool("transcripts", false, "grava também um transcript em Markdown por conversa")
+	fs.Usage = func() { fmt.Fprint(os.Stderr, importUsage); fs.PrintDefaults() }
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if err := validSessionName(*prefix); err != nil {
+		return fmt.Errorf("--prefix: %w", err)
+	}
+	if !*list && ephemeralFromEnv() {
+		return errEphemeral
+	}
+	var titleRe *regexp.Regexp
+	if *match != "" {
+		re, err := regexp.Compile("(?i)" + *match)
+		if err != nil {
+			return fmt.Errorf("--match inválido: %w", err)
+		}
+		titleRe = re
+	}
+	from, err := parseSince(*since)
+	if err != nil {
+		return err
+	}
+	var to time.Time
+	if *until != "" {
+		if to, err = time.ParseInLocation("2006-01-02", *until, time.Local); err != nil {
+			return fmt.Errorf("--until inválido %q (use YYYY-MM-DD)", *until)
+		}
+		to = to.AddDate(0, 0, 1)
+	}
+
+	convs, err := readChatGPTExport(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	var picked []*chatgptConversation
+	for i := range convs {
+		c := &convs[i]
+		updated := c.updated()
+		switch {
+		case titleRe != nil && !titleRe.MatchString(c.Title):
+		case !from.IsZero() && updated.Before(from):
+		case !to.IsZero() && !updated.Before(to):
+		case len(ids) > 0 && !slices.ContainsFunc(ids, func(id string) bool { return strings.HasPrefix(c.id(), id) }):
+		default:
+			picked = append(picked, c)
+		}
+	}
+	sort.SliceStable(picked, func(i, j int) bool { return picked[i].updated().After(picked[j].updated()) })
+	if len(picked) == 0 {
+		return fmt.Errorf("nenhuma conversa selecionada (%d no export)", len(convs))
+	}
+
+	if *list {
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tATUALIZADA\tTURNOS\tTÍTULO")
+		for _, c := range picked {
+			id := c.id()
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%s\n", id[:min(len(id), 8)], c.updated().Local().Format("2006-01-02 15:04"),
+				len(c.session("", nil).Turns), chooseNonEmpty(c.Title, "(sem título)"))
+		}
+		return tw.Flush()
+	}
+
+	allTags := append([]string{"chatgpt"}, tags...)
+	var imported, existing, empty int
+	for _, c := range picked {
+		name := importName(*prefix, c)
+		if !*force && sessionExists(name) {
+			existing++
+			continue
+		}
+		s := c.session(name, allTags)
+		if len(s.Turns) == 0 {
+			empty++
+			continue
+		}
+		if err := s.write(); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		if *transcripts {
+			file := filepath.Join(configDir(), fmt.Sprintf("transcript-%d-%s.md", s.Created.Unix(), name))
+			if err := saveTranscript(file, s); err != nil {
+				return fmt.Errorf("%s: %w", file, err)
+			}
+			// o grep data os transcripts pelo arquivo
+			_ = os.Chtimes(file, s.Updated, s.Updated)
+		}
+		fmt.Printf("+ %s  %s\n", name, chooseNonEmpty(s.Title, "(sem título)"))
+		imported++
+	}
+	fmt.Fprintf(os.Stderr, "%d conversas importadas", imported)
+	if existing > 0 {
+		fmt.Fprintf(os.Stderr, ", %d já existiam (--force sobrescreve)", existing)
+	}
+	if empty > 0 {
+		fmt.Fprintf(os.Stderr, ", %d sem mensagens de texto", empty)
+	}
+	fmt.Fprintln(os.Stderr)
+	if imported == 0 && existing == 0 {
+		return errors.New("nada importado")
+	}
+	return nil
+}
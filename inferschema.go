@@ -0,0 +1,430 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ===================== Esquema de JSON =====================
+//
+// --infer-schema troca um JSON grande do stdin (ou JSON Lines) por um esquema
+// inferido localmente — campos, tipos, opcionais, faixas, exemplos — e uma
+// amostra podada do documento. Assim "explique esta resposta da API" cabe no
+// contexto mesmo com megabytes de JSON.
+
+const (
+	schemaMaxFields   = 40   // acima disso o objeto vira um mapa { [chave]: valor }
+	schemaMaxDistinct = 20   // valores de texto acompanhados por campo
+	schemaEnumMax     = 6    // até quantos valores distintos um texto vira enumeração
+	schemaSampleMax   = 1500 // tokens da amostra, podada até caber
+)
+
+// jsonShape acumula tudo o que foi visto numa posição do documento.
+type jsonShape struct {
+	seen     int
+	types    map[string]int // null, boolean, integer, number, string, object, array
+	fields   map[string]*jsonShape
+	order    []string // ordem em que os campos apareceram
+	objects  int
+	elem     *jsonShape
+	minLen   int
+	maxLen   int
+	minNum   float64
+	maxNum   float64
+	nums     int
+	strs     map[string]int
+	strsFull bool // mais que schemaMaxDistinct valores distintos
+}
+
+func newShape() *jsonShape { return &jsonShape{types: map[string]int{}} }
+
+func (s *jsonShape) add(v any) {
+	s.seen++
+	switch x := v.(type) {
+	case nil:
+		s.types["null"]++
+	case bool:
+		s.types["boolean"]++
+	case json.Number:
+		kind := "integer"
+		if _, err := x.Int64(); err != nil {
+			kind = "number"
+		}
+		s.types[kind]++
+		if f, err := x.Float64(); err == nil {
+			if s.nums == 0 || f < s.minNum {
+				s.minNum = f
+			}
+			if s.nums == 0 || f > s.maxNum {
+				s.maxNum = f
+			}
+			s.nums++
+		}
+	case string:
+		s.types["string"]++
+		if s.strs == nil {
+			s.strs = map[string]int{}
+		}
+		if _, ok := s.strs[x]; ok || len(s.strs) < schemaMaxDistinct {
+			s.strs[x]++
+		} else {
+			s.strsFull = true
+		}
+	case map[string]any:
+		s.types["object"]++
+		s.objects++
+		if s.fields == nil {
+			s.fields = map[string]*jsonShape{}
+		}
+		keys := make([]string, 0, len(x))
+		for k := range x {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys) // o decoder não preserva a ordem; alfabética é estável
+		for _, k := range keys {
+			f := s.fields[k]
+			if f == nil {
+				f = newShape()
+				s.fields[k] = f
+				s.order = append(s.order, k)
+			}
+			f.add(x[k])
+		}
+	case []any:
+		s.types["array"]++
+		if s.types["array"] == 1 || len(x) < s.minLen {
+			s.minLen = len(x)
+		}
+		s.maxLen = max(s.maxLen, len(x))
+		if s.elem == nil {
+			s.elem = newShape()
+		}
+		for _, e := range x {
+			s.elem.add(e)
+		}
+	}
+}
+
+// asMap funde os campos de um objeto com chaves demais (ids, datas) num só
+// valor, como um dicionário.
+func (s *jsonShape) asMap() *jsonShape {
+	merged := newShape()
+	for _, f := range s.fields {
+		merged.merge(f)
+	}
+	return merged
+}
+
+func (s *jsonShape) merge(o *jsonShape) {
+	s.seen += o.seen
+	for t, n := range o.types {
+		s.types[t] += n
+	}
+	if o.nums > 0 {
+		if s.nums == 0 || o.minNum < s.minNum {
+			s.minNum = o.minNum
+		}
+		if s.nums == 0 || o.maxNum > s.maxNum {
+			s.maxNum = o.maxNum
+		}
+		s.nums += o.nums
+	}
+	for v, n := range o.strs {
+		if s.strs == nil {
+			s.strs = map[string]int{}
+		}
+		if _, ok := s.strs[v]; ok || len(s.strs) < schemaMaxDistinct {
+			s.strs[v] += n
+		} else {
+			s.strsFull = true
+		}
+	}
+	s.strsFull = s.strsFull || o.strsFull
+	if o.fields != nil {
+		if s.fields == nil {
+			s.fields = map[string]*jsonShape{}
+		}
+		s.objects += o.objects
+		for _, k := range o.order {
+			if s.fields[k] == nil {
+				s.fields[k] = newShape()
+				s.order = append(s.order, k)
+			}
+			s.fields[k].merge(o.fields[k])
+		}
+	}
+	if o.elem != nil {
+		if s.elem == nil {
+			s.elem = newShape()
+			s.minLen = o.minLen
+		}
+		s.minLen = min(s.minLen, o.minLen)
+		s.maxLen = max(s.maxLen, o.maxLen)
+		s.elem.merge(o.elem)
+	}
+}
+
+// render escreve o esquema numa notação parecida com TypeScript, com as
+// faixas e os exemplos como comentários.
+func (s *jsonShape) render(b *strings.Builder, indent string) {
+	var kinds []string
+	for _, t := range []string{"object", "array", "string", "integer", "number", "boolean"} {
+		if s.types[t] == 0 {
+			continue
+		}
+		switch t {
+		case "object":
+			kinds = append(kinds, s.renderObject(indent))
+		case "array":
+			var eb strings.Builder
+			if s.elem == nil || s.elem.seen == 0 {
+				eb.WriteString("never")
+			} else {
+				s.elem.render(&eb, indent)
+			}
+			kinds = append(kinds, "["+eb.String()+"]")
+		case "string":
+			kinds = append(kinds, s.renderString())
+		default:
+			kinds = append(kinds, t)
+		}
+	}
+	if s.types["null"] > 0 {
+		kinds = append(kinds, "null")
+	}
+	if len(kinds) == 0 {
+		kinds = []string{"null"}
+	}
+	b.WriteString(strings.Join(kinds, " | "))
+	var notes []string
+	if s.types["array"] > 0 {
+		notes = append(notes, schemaRange(float64(s.minLen), float64(s.maxLen))+" itens")
+	}
+	if s.nums > 0 {
+		notes = append(notes, schemaRange(s.minNum, s.maxNum))
+	}
+	if s.types["string"] > 0 && !s.isEnum() {
+		notes = append(notes, "ex: "+strings.Join(s.examples(3), ", "))
+	}
+	if len(notes) > 0 {
+		b.WriteString("  // " + strings.Join(notes, "; "))
+	}
+}
+
+// schemaRange escreve "min..max" com até 4 casas, ou um valor só.
+func schemaRange(lo, hi float64) string {
+	lo, hi = math.Round(lo*1e4)/1e4, math.Round(hi*1e4)/1e4
+	if lo == hi {
+		return formatNumber(lo)
+	}
+	return formatNumber(lo) + ".." + formatNumber(hi)
+}
+
+func (s *jsonShape) renderObject(indent string) string {
+	if len(s.fields) == 0 {
+		return "{}"
+	}
+	var b strings.Builder
+	inner := indent + "  "
+	if len(s.fields) > schemaMaxFields {
+		fmt.Fprintf(&b, "{  // %d chaves distintas\n%s[chave: string]: ", len(s.fields), inner)
+		s.asMap().render(&b, inner)
+		fmt.Fprintf(&b, "\n%s}", indent)
+		return b.String()
+	}
+	b.WriteString("{\n")
+	for _, k := range s.order {
+		f := s.fields[k]
+		name := k
+		if !isPlainKey(k) {
+			name = strconv.Quote(k)
+		}
+		b.WriteString(inner + name)
+		if f.seen < s.objects {
+			fmt.Fprintf(&b, "?")
+		}
+		b.WriteString(": ")
+		f.render(&b, inner)
+		if f.seen < s.objects {
+			fmt.Fprintf(&b, " (em %d%%)", 100*f.seen/s.objects)
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString(indent + "}")
+	return b.String()
+}
+
+func isPlainKey(k string) bool {
+	if k == "" {
+		return false
+	}
+	for _, r := range k {
+		if r != '_' && r != '-' && (r < '0' || r > '9') && (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+// isEnum: poucos valores curtos, repetidos bastante — provavelmente um status ou tipo.
+func (s *jsonShape) isEnum() bool {
+	if s.strsFull || len(s.strs) > schemaEnumMax || s.types["string"] < 2*len(s.strs) || s.types["string"] < 4 {
+		return false
+	}
+	for v := range s.strs {
+		if len(v) > 40 {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *jsonShape) renderString() string {
+	if !s.isEnum() {
+		return "string"
+	}
+	vals := s.examples(schemaEnumMax)
+	return strings.Join(vals, " | ")
+}
+
+// examples devolve os valores mais frequentes, entre aspas e encurtados.
+func (s *jsonShape) examples(n int) []string {
+	vals := make([]string, 0, len(s.strs))
+	for v := range s.strs {
+		vals = append(vals, v)
+	}
+	sort.Slice(vals, func(i, j int) bool {
+		if s.strs[vals[i]] != s.strs[vals[j]] {
+			return s.strs[vals[i]] > s.strs[vals[j]]
+		}
+		return vals[i] < vals[j]
+	})
+	if len(vals) > n {
+		vals = vals[:n]
+	}
+	for i, v := range vals {
+		vals[i] = strconv.Quote(truncate(v, 40))
+	}
+	return vals
+}
+
+// pruneJSON corta listas em keep itens e textos em maxStr caracteres,
+// deixando marcas de quanto ficou de fora.
+func pruneJSON(v any, keep, maxStr int) any {
+	switch x := v.(type) {
+	case string:
+		if len([]rune(x)) > maxStr {
+			return string([]rune(x)[:maxStr]) + fmt.Sprintf("… (+%d caracteres)", len([]rune(x))-maxStr)
+		}
+		return x
+	case []any:
+		out := []any{}
+		for i, e := range x {
+			if i == keep {
+				out = append(out, fmt.Sprintf("… +%d itens", len(x)-keep))
+				break
+			}
+			out = append(out, pruneJSON(e, keep, maxStr))
+		}
+		return out
+	case map[string]any:
+		out := map[string]any{}
+		for k, e := range x {
+			out[k] = pruneJSON(e, keep, maxStr)
+		}
+		if len(x) > schemaMaxFields {
+			// dicionário grande: algumas chaves bastam como amostra
+			keys := make([]string, 0, len(x))
+			for k := range x {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			small := map[string]any{"…": fmt.Sprintf("+%d chaves", len(keys)-keep)}
+			for _, k := range keys[:min(keep, len(keys))] {
+				small[k] = out[k]
+			}
+			return small
+		}
+		return out
+	}
+	return v
+}
+
+// sampleJSON poda cada vez mais até a amostra caber em schemaSampleMax tokens.
+func sampleJSON(v any) string {
+	var out []byte
+	for _, p := range []struct{ keep, maxStr int }{{3, 120}, {2, 80}, {1, 60}, {1, 30}} {
+		out, _ = json.MarshalIndent(pruneJSON(v, p.keep, p.maxStr), "", "  ")
+		if estimateTokens(string(out)) <= schemaSampleMax {
+			return string(out)
+		}
+	}
+	return truncate(string(out), schemaSampleMax*4)
+}
+
+// decodeJSONInput aceita um documento JSON ou JSON Lines (vira uma lista).
+func decodeJSONInput(data []byte) (any, bool, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var docs []any
+	for {
+		var v any
+		err := dec.Decode(&v)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		docs = append(docs, v)
+	}
+	switch len(docs) {
+	case 0:
+		return nil, false, errors.New("stdin vazio")
+	case 1:
+		return docs[0], false, nil
+	}
+	return docs, true, nil
+}
+
+// inferSchemaPrompt monta o prompt com esquema e amostra no lugar do JSON cru.
+func inferSchemaPrompt(question, raw string) (string, error) {
+	doc, lines, err := decodeJSONInput([]byte(raw))
+	if err != nil {
+		return "", fmt.Errorf("--infer-schema: o stdin não é JSON nem JSON Lines: %w", err)
+	}
+	shape := newShape()
+	shape.add(doc)
+	var schema strings.Builder
+	shape.render(&schema, "")
+
+	kind := "um documento JSON"
+	if lines {
+		kind = fmt.Sprintf("JSON Lines com %d registros (tratados como uma lista)", len(doc.([]any)))
+	}
+	if question == "" {
+		question = "Explique a estrutura deste JSON: o que ele representa e o que significam os principais campos."
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n\nA entrada é %s de %d bytes. Em vez do conteúdo inteiro, seguem o esquema inferido localmente "+
+		"(`?` marca campos opcionais, com a porcentagem de objetos em que aparecem; os comentários trazem faixas, "+
+		"tamanhos de listas e exemplos) e uma amostra podada (listas cortadas, com a contagem do que ficou de fora).\n\n",
+		question, kind, len(raw))
+	fmt.Fprintf(&b, "Esquema:\n```\n%s\n```\n\nAmostra:\n```json\n%s\n```", schema.String(), sampleJSON(doc))
+	prompt := b.String()
+	if estimateTokens(prompt) >= estimateTokens(raw) {
+		fmt.Fprintln(os.Stderr, "(JSON pequeno: enviado inteiro)")
+		return fmt.Sprintf("%s\n\n```json\n%s\n```", question, raw), nil
+	}
+	fmt.Fprintf(os.Stderr, "(JSON de %d bytes resumido em esquema e amostra: ~%d tokens em vez de ~%d)\n",
+		len(raw), estimateTokens(prompt), estimateTokens(raw))
+	return prompt, nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ===================== Judge =====================
+//
+// `gptcli judge` pede ao modelo que avalie uma resposta contra uma rubrica e
+// imprime a nota em JSON, para fluxos de LLM-as-judge em scripts e CI.
+
+type judgeScore struct {
+	Score    float64          `json:"score"`
+	MaxScore float64          `json:"max_score"`
+	Pass     *bool            `json:"pass,omitempty"` // só com --min-score
+	Criteria []judgeCriterion `json:"criteria"`
+	Summary  string           `json:"summary"`
+	Model    string           `json:"model"`
+}
+
+type judgeCriterion struct {
+	Name    string  `json:"name"`
+	Score   float64 `json:"score"`
+	Max     float64 `json:"max"`
+	Comment string  `json:"comment"`
+}
+
+const judgeSystem = `Você é um avaliador rigoroso e imparcial. Avalie a RESPOSTA à PERGUNTA usando apenas a RUBRICA.
+Para cada critério da rubrica, dê uma nota e um comentário curto justificando.
+Responda SOMENTE um objeto JSON neste formato:
+{"score": <soma das notas>, "max_score": %g, "criteria": [{"name": "...", "score": 0, "max": 0, "comment": "..."}], "summary": "..."}
+A soma dos "max" dos critérios deve ser %g.`
+
+func judgeCmd(args []string) error {
+	fs := flag.NewFlagSet("judge", flag.ExitOnError)
+	flags := commonFlags(fs)
+	criteria := fs.String("criteria", "", "arquivo com a rubrica (obrigatório)")
+	answerPath := fs.String("answer", "-", "arquivo com a resposta a avaliar (- = stdin)")
+	scale := fs.Float64("scale", 10, "nota máxima")
+	minScore := fs.Float64("min-score", -1, "sai com código 1 se a nota ficar abaixo deste valor")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, `uso: gptcli judge --criteria rubrica.md [--answer resposta.txt] [flags] "pergunta"`)
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	question := strings.TrimSpace(strings.Join(fs.Args(), " "))
+	if *criteria == "" || question == "" || *scale <= 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	rubric, err := os.ReadFile(*criteria)
+	if err != nil {
+		return err
+	}
+	var answer []byte
+	if *answerPath == "-" {
+		if !isPiped() {
+			return errors.New("informe --answer ou envie a resposta pelo stdin")
+		}
+		answer, err = io.ReadAll(os.Stdin)
+	} else {
+		answer, err = os.ReadFile(*answerPath)
+	}
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(string(answer)) == "" {
+		return errors.New("resposta vazia")
+	}
+
+	cfg, _ := loadConfig()
+	st, err := resolveSettings(cfg, flags)
+	if err != nil {
+		return err
+	}
+	initAppLog(st.logLevel, st.profName, st.personaName)
+	client, err := buildClient(st)
+	if err != nil {
+		return err
+	}
+
+	sess := &Session{Format: "json"}
+	sess.addSystem(fmt.Sprintf(judgeSystem, *scale, *scale))
+	sess.addUser(fmt.Sprintf("PERGUNTA:\n%s\n\nRUBRICA:\n%s\n\nRESPOSTA:\n%s",
+		question, strings.TrimSpace(string(rubric)), strings.TrimSpace(string(answer))))
+
+	ctx := withRetryConfig(context.Background(), st.retry)
+	var score judgeScore
+	err = logOp(ctx, "judge", st.model, func(ctx context.Context) error {
+		return withRetries(ctx, 4, func() error {
+			out, err := streamChat(ctx, client, sess, st.model, st.temp, st.maxTokens, func(string) {})
+			if err != nil {
+				return err
+			}
+			score = judgeScore{}
+			if err := json.Unmarshal([]byte(stripCodeFence(out)), &score); err != nil {
+				return fmt.Errorf("resposta do juiz não é JSON válido: %w", err)
+			}
+			return nil
+		})
+	})
+	flushTelemetry()
+	if err != nil {
+		return err
+	}
+
+	score.Model = st.model
+	if score.MaxScore <= 0 {
+		score.MaxScore = *scale
+	}
+	if *minScore >= 0 {
+		pass := score.Score >= *minScore
+		score.Pass = &pass
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(score); err != nil {
+		return err
+	}
+	if score.Pass != nil && !*score.Pass {
+		os.Exit(1)
+	}
+	return nil
+}
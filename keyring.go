@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// ===================== Keyring =====================
+//
+// Segredos no keyring do sistema, pelas ferramentas de linha de comando:
+// secret-tool (libsecret) no Linux e security no macOS.
+
+const keyringService = "gptcli"
+
+var errNoKeyring = errors.New("keyring indisponível (instale o secret-tool)")
+
+func keyringAvailable() bool {
+	tool := "secret-tool"
+	if runtime.GOOS == "darwin" {
+		tool = "security"
+	}
+	_, err := exec.LookPath(tool)
+	return err == nil
+}
+
+// keyringGet devolve o segredo de account; ok=false se ele não existir.
+func keyringGet(account string) (value string, ok bool, err error) {
+	if !keyringAvailable() {
+		return "", false, errNoKeyring
+	}
+	var cmd *exec.Cmd
+	if runtime.GOOS == "darwin" {
+		cmd = exec.Command("security", "find-generic-password", "-s", keyringService, "-a", account, "-w")
+	} else {
+		cmd = exec.Command("secret-tool", "lookup", "service", keyringService, "account", account)
+	}
+	out, err := cmd.Output()
+	value = string(bytes.TrimSpace(out))
+	// as duas ferramentas saem com erro quando o item não existe
+	if err != nil || value == "" {
+		return "", false, nil
+	}
+	return value, true, nil
+}
+
+func keyringSet(account, label, value string) error {
+	if !keyringAvailable() {
+		return errNoKeyring
+	}
+	var cmd *exec.Cmd
+	if runtime.GOOS == "darwin" {
+		// -U atualiza o item se ele já existir
+		cmd = exec.Command("security", "add-generic-password", "-U", "-s", keyringService, "-a", account, "-l", label, "-w", value)
+	} else {
+		cmd = exec.Command("secret-tool", "store", "--label", label, "service", keyringService, "account", account)
+		cmd.Stdin = strings.NewReader(value)
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("falha ao gravar no keyring: %v %s", err, bytes.TrimSpace(out))
+	}
+	return nil
+}
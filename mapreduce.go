@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	openai "github.com/openai/openai-go/v2"
+	"github.com/openai/openai-go/v2/shared"
+)
+
+// ===================== Map-reduce =====================
+//
+// --map-reduce responde perguntas sobre arquivos maiores que a janela do
+// modelo: o stdin é dividido em partes com sobreposição, cada parte responde
+// à pergunta sozinha (map) e as respostas parciais viram o prompt final
+// (reduce), que segue o fluxo normal: stream, sessão, --deliver. Falhas de
+// chave, cota ou rede em sequência interrompem tudo (breaker); as de uma
+// parte só deixam um buraco, avisado no stderr e no prompt final.
+
+const (
+	defaultMapOverlap = 200 // tokens repetidos entre partes vizinhas
+	maxReduceRounds   = 5   // reduces intermediários antes de desistir de encolher
+	mapNothing        = "NADA RELEVANTE"
+)
+
+// mapChunkTokens é o tamanho default das partes: um quarto da janela, para
+// sobrar espaço para system, pergunta e resposta.
+func mapChunkTokens(model string) int {
+	return compressBudget(model) / 2
+}
+
+// splitOverlapping corta text em partes de até size caracteres, terminando de
+// preferência numa quebra de linha, e começa cada parte overlap caracteres
+// antes do fim da anterior.
+func splitOverlapping(text string, size, overlap int) []string {
+	if overlap >= size/2 {
+		overlap = size / 2
+	}
+	var parts []string
+	for start := 0; start < len(text); {
+		end := start + size
+		if end >= len(text) {
+			parts = append(parts, text[start:])
+			break
+		}
+		if nl := strings.LastIndexByte(text[start+size*4/5:end], '\n'); nl >= 0 {
+			end = start + size*4/5 + nl + 1
+		}
+		for end > start && !utf8.RuneStart(text[end]) {
+			end--
+		}
+		parts = append(parts, text[start:end])
+		next := end - overlap
+		if nl := strings.IndexByte(text[next:end], '\n'); nl >= 0 {
+			next += nl + 1 // a sobreposição começa numa linha inteira
+		}
+		for next < end && !utf8.RuneStart(text[next]) {
+			next++
+		}
+		start = max(next, start+1)
+	}
+	return parts
+}
+
+// mapReducePrompt faz a fase map e devolve o prompt do reduce. Documentos que
+// cabem numa parte só viram um prompt comum.
+func mapReducePrompt(ctx context.Context, client openai.Client, st *settings, question, doc string, chunkTokens, overlapTokens int) (string, error) {
+	if chunkTokens <= 0 {
+		chunkTokens = mapChunkTokens(st.model)
+	}
+	parts := splitOverlapping(doc, chunkTokens*4, overlapTokens*4) // ~4 caracteres por token
+	if len(parts) == 1 {
+		fmt.Fprintln(os.Stderr, "(o documento cabe numa parte só; sem map-reduce)")
+		return fmt.Sprintf("%s\n\n```\n%s\n```", question, doc), nil
+	}
+	fmt.Fprintf(os.Stderr, "(map-reduce: %d partes de até ~%d tokens, sobreposição de ~%d)\n", len(parts), chunkTokens, overlapTokens)
+
+	brk := newBreaker(defaultMaxFailures)
+	partials := make([]string, len(parts))
+	var failed []int
+	for i, part := range parts {
+		start := time.Now()
+		answer, err := mapPart(ctx, client, st, question, part, i+1, len(parts))
+		switch {
+		case err != nil:
+			fmt.Fprintf(os.Stderr, "[map %d/%d] falhou: %v\n", i+1, len(parts), err)
+			failed = append(failed, i+1)
+			if brk.record(err) {
+				brk.report(i+1, len(parts))
+				return "", err
+			}
+			continue
+		case answer == mapNothing:
+			fmt.Fprintf(os.Stderr, "[map %d/%d] nada relevante (%s)\n", i+1, len(parts), time.Since(start).Round(100*time.Millisecond))
+		default:
+			fmt.Fprintf(os.Stderr, "[map %d/%d] ok (%s)\n", i+1, len(parts), time.Since(start).Round(100*time.Millisecond))
+		}
+		brk.record(nil)
+		partials[i] = answer
+	}
+
+	var found []string
+	for i, p := range partials {
+		if p != "" && p != mapNothing {
+			found = append(found, fmt.Sprintf("### Parte %d de %d\n%s", i+1, len(parts), p))
+		}
+	}
+	if len(found) == 0 && len(failed) == len(parts) {
+		return "", errors.New("map-reduce: todas as partes falharam")
+	}
+	// muitas respostas parciais: reduz em grupos até caberem numa chamada
+	for round := 1; round <= maxReduceRounds && len(found) > 1 && estimateTokens(strings.Join(found, "\n\n")) > chunkTokens; round++ {
+		fmt.Fprintf(os.Stderr, "(reduce intermediário %d: %d respostas parciais)\n", round, len(found))
+		var merged []string
+		for _, group := range groupByTokens(found, chunkTokens) {
+			answer, err := mapPart(ctx, client, st, question, strings.Join(group, "\n\n"), 0, 0)
+			if err != nil {
+				return "", err
+			}
+			merged = append(merged, answer)
+		}
+		found = merged
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Pergunta: %s\n\n", question)
+	fmt.Fprintf(&b, "O documento foi lido em %d partes; abaixo estão as respostas encontradas em cada uma.", len(parts))
+	if len(failed) > 0 {
+		fmt.Fprintf(&b, " As partes %s não puderam ser lidas.", joinInts(failed))
+	}
+	b.WriteString(" Sintetize uma resposta final única, sem repetir o que se sobrepõe e sem citar as partes, " +
+		"a menos que a pergunta peça. Se nenhuma parte respondeu, diga isso.\n\n")
+	if len(found) == 0 {
+		b.WriteString("(nenhuma parte tinha informação relevante)")
+	}
+	b.WriteString(strings.Join(found, "\n\n"))
+	return b.String(), nil
+}
+
+// mapPart responde à pergunta com uma parte do documento. n == 0 é um reduce
+// intermediário, em que text já são respostas parciais.
+func mapPart(ctx context.Context, client openai.Client, st *settings, question, text string, i, n int) (string, error) {
+	instr := fmt.Sprintf("Você recebe a parte %d de %d de um documento grande. Responda à pergunta usando só esta parte, "+
+		"com os detalhes que ela traz (nomes, números, trechos). Se a parte não tiver nada útil para a pergunta, "+
+		"responda exatamente: %s", i, n, mapNothing)
+	if n == 0 {
+		instr = "Você recebe respostas parciais à mesma pergunta, cada uma tirada de um trecho de um documento. " +
+			"Junte-as numa só, preservando todos os fatos e sem repetições."
+	}
+	msgs := []openai.ChatCompletionMessageParamUnion{}
+	if st.system != "" {
+		msgs = append(msgs, openai.SystemMessage(st.system))
+	}
+	msgs = append(msgs, openai.SystemMessage(instr),
+		openai.UserMessage(fmt.Sprintf("Pergunta: %s\n\n```\n%s\n```", question, text)))
+	params := openai.ChatCompletionNewParams{Model: shared.ChatModel(st.model), Messages: msgs}
+	if st.temp >= 0 {
+		params.Temperature = openai.Float(st.temp)
+	}
+
+	var answer string
+	err := withRetries(ctx, 3, func() error {
+		resp, err := client.Chat.Completions.New(ctx, params)
+		if err != nil {
+			return err
+		}
+		if len(resp.Choices) == 0 {
+			return errors.New("resposta vazia")
+		}
+		noteUsage(ctx, resp.Usage)
+		answer = strings.TrimSpace(resp.Choices[0].Message.Content)
+		return nil
+	})
+	if strings.Trim(answer, " .") == mapNothing {
+		answer = mapNothing
+	}
+	return answer, err
+}
+
+// groupByTokens junta itens consecutivos enquanto cabem em limit tokens.
+func groupByTokens(items []string, limit int) [][]string {
+	var groups [][]string
+	var cur []string
+	size := 0
+	for _, it := range items {
+		n := estimateTokens(it)
+		if len(cur) > 0 && size+n > limit {
+			groups = append(groups, cur)
+			cur, size = nil, 0
+		}
+		cur = append(cur, it)
+		size += n
+	}
+	if len(cur) > 0 {
+		groups = append(groups, cur)
+	}
+	// um grupo só não reduziria nada: divide ao meio
+	if len(groups) == 1 && len(items) > 1 {
+		half := len(items) / 2
+		groups = [][]string{items[:half], items[half:]}
+	}
+	return groups
+}
+
+func joinInts(ns []int) string {
+	s := make([]string, len(ns))
+	for i, n := range ns {
+		s[i] = fmt.Sprint(n)
+	}
+	return strings.Join(s, ", ")
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ===================== Métricas Prometheus =====================
+//
+// `gptcli web` e `gptcli daemon --metrics-listen` expõem GET /metrics no
+// formato texto do Prometheus: pedidos por modo, modelo, profile e status,
+// latência em histograma, tokens por tipo e pedidos em andamento. Ao contrário
+// da telemetria OTLP, que empurra os dados no fim de cada execução, aqui o
+// processo vive muito e o Prometheus vem buscar.
+
+type promKey struct {
+	mode, model, profile string
+}
+
+type promMetrics struct {
+	mu       sync.Mutex
+	started  time.Time
+	requests map[promKey]map[string]int64 // status (ok|error) => pedidos
+	latency  map[promKey]*histPoint       // ms, nos limites de durationBounds
+	tokens   map[promKey]map[string]int64 // prompt|completion|cached|reasoning => tokens
+	retries  map[promKey]int64
+	inFlight int64
+}
+
+// promStats é nil fora dos modos servidor: no CLI comum não há quem colete.
+var promStats *promMetrics
+
+func enableMetrics() {
+	if promStats == nil {
+		promStats = &promMetrics{
+			started:  time.Now(),
+			requests: map[promKey]map[string]int64{},
+			latency:  map[promKey]*histPoint{},
+			tokens:   map[promKey]map[string]int64{},
+			retries:  map[promKey]int64{},
+		}
+	}
+}
+
+// promUsage são os tokens de um pedido.
+type promUsage struct {
+	prompt, completion, cached, reasoning int64
+}
+
+// begin marca um pedido em andamento; a função devolvida o encerra.
+func (m *promMetrics) begin() func() {
+	if m == nil {
+		return func() {}
+	}
+	m.mu.Lock()
+	m.inFlight++
+	m.mu.Unlock()
+	return func() {
+		m.mu.Lock()
+		m.inFlight--
+		m.mu.Unlock()
+	}
+}
+
+// observe registra um pedido concluído.
+func (m *promMetrics) observe(k promKey, took time.Duration, u promUsage, retries int, err error) {
+	if m == nil {
+		return
+	}
+	status := "ok"
+	if err != nil {
+		status = "error"
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.requests[k] == nil {
+		m.requests[k] = map[string]int64{}
+	}
+	m.requests[k][status]++
+	h := m.latency[k]
+	if h == nil {
+		h = &histPoint{buckets: make([]int64, len(durationBounds)+1)}
+		m.latency[k] = h
+	}
+	ms := float64(took.Milliseconds())
+	h.count++
+	h.sum += ms
+	h.buckets[sort.SearchFloat64s(durationBounds, ms)]++
+	if m.tokens[k] == nil {
+		m.tokens[k] = map[string]int64{}
+	}
+	for typ, n := range map[string]int64{"prompt": u.prompt, "completion": u.completion, "cached": u.cached, "reasoning": u.reasoning} {
+		m.tokens[k][typ] += n
+	}
+	if retries > 0 {
+		m.retries[k] += int64(retries)
+	}
+}
+
+// observeOp registra uma operação do logOp.
+func (m *promMetrics) observeOp(e *logEntry, err error) {
+	if m == nil {
+		return
+	}
+	u := promUsage{e.PromptTokens, e.CompletionTokens, e.CachedTokens, e.ReasoningTokens}
+	m.observe(promKey{e.Mode, e.Model, appLog.profile}, time.Since(e.started), u, e.Retries, err)
+}
+
+func (m *promMetrics) handler(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.write(rw)
+}
+
+// write gera a exposição no formato texto, com as séries em ordem estável.
+func (m *promMetrics) write(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	keys := make([]promKey, 0, len(m.requests))
+	for k := range m.requests {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		return a.mode+"\x00"+a.model+"\x00"+a.profile < b.mode+"\x00"+b.model+"\x00"+b.profile
+	})
+
+	fmt.Fprintln(w, "# HELP gptcli_requests_total Pedidos ao modelo concluídos, por status.")
+	fmt.Fprintln(w, "# TYPE gptcli_requests_total counter")
+	for _, k := range keys {
+		for _, status := range []string{"ok", "error"} {
+			if n, ok := m.requests[k][status]; ok {
+				fmt.Fprintf(w, "gptcli_requests_total{%s,status=%q} %d\n", k.labels(), status, n)
+			}
+		}
+	}
+
+	fmt.Fprintln(w, "# HELP gptcli_request_duration_seconds Duração dos pedidos, do envio ao fim do stream.")
+	fmt.Fprintln(w, "# TYPE gptcli_request_duration_seconds histogram")
+	for _, k := range keys {
+		h := m.latency[k]
+		var cum int64
+		for i, bound := range durationBounds {
+			cum += h.buckets[i]
+			fmt.Fprintf(w, "gptcli_request_duration_seconds_bucket{%s,le=%q} %d\n", k.labels(), promFloat(bound/1000), cum)
+		}
+		fmt.Fprintf(w, "gptcli_request_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", k.labels(), h.count)
+		fmt.Fprintf(w, "gptcli_request_duration_seconds_sum{%s} %s\n", k.labels(), promFloat(h.sum/1000))
+		fmt.Fprintf(w, "gptcli_request_duration_seconds_count{%s} %d\n", k.labels(), h.count)
+	}
+
+	fmt.Fprintln(w, "# HELP gptcli_tokens_total Tokens informados pelo provedor (cached e reasoning são parte de prompt e completion).")
+	fmt.Fprintln(w, "# TYPE gptcli_tokens_total counter")
+	for _, k := range keys {
+		for _, typ := range []string{"prompt", "completion", "cached", "reasoning"} {
+			fmt.Fprintf(w, "gptcli_tokens_total{%s,type=%q} %d\n", k.labels(), typ, m.tokens[k][typ])
+		}
+	}
+
+	fmt.Fprintln(w, "# HELP gptcli_retries_total Tentativas repetidas depois de um erro.")
+	fmt.Fprintln(w, "# TYPE gptcli_retries_total counter")
+	for _, k := range keys {
+		fmt.Fprintf(w, "gptcli_retries_total{%s} %d\n", k.labels(), m.retries[k])
+	}
+
+	fmt.Fprintln(w, "# HELP gptcli_requests_in_flight Pedidos em andamento.")
+	fmt.Fprintln(w, "# TYPE gptcli_requests_in_flight gauge")
+	fmt.Fprintf(w, "gptcli_requests_in_flight %d\n", m.inFlight)
+	fmt.Fprintln(w, "# HELP gptcli_start_time_seconds Início do processo, em segundos desde a época Unix.")
+	fmt.Fprintln(w, "# TYPE gptcli_start_time_seconds gauge")
+	fmt.Fprintf(w, "gptcli_start_time_seconds %d\n", m.started.Unix())
+}
+
+func (k promKey) labels() string {
+	return fmt.Sprintf("mode=%s,model=%s,profile=%s", promLabel(k.mode), promLabel(k.model), promLabel(k.profile))
+}
+
+// promLabel escapa um valor de label (barra invertida, aspas e quebra de linha).
+func promLabel(v string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v) + `"`
+}
+
+func promFloat(v float64) string { return strconv.FormatFloat(v, 'g', -1, 64) }
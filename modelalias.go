@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ===================== Apelidos de modelo =====================
+//
+// model_aliases no config dá nomes estáveis a modelos que mudam:
+//   model_aliases: {fast: gpt-5-mini, smart: gpt-5, local: llama3.1:8b}
+// --model, model de profile/persona, /model e /new aceitam o apelido, que é
+// trocado pelo nome real antes da chamada. Um apelido pode apontar para
+// outro.
+
+// modelAliases vem do config e viaja nas settings (st.aliases).
+type modelAliases map[string]string
+
+// maxAliasDepth limita a cadeia de apelidos (e corta ciclos).
+const maxAliasDepth = 8
+
+// resolve devolve o modelo real de name; nomes sem apelido voltam
+// como vieram.
+func (a modelAliases) resolve(name string) string {
+	for i := 0; i < maxAliasDepth; i++ {
+		next, ok := a[strings.TrimSpace(name)]
+		if !ok || next == name {
+			break
+		}
+		name = next
+	}
+	return name
+}
+
+// cycle diz se a cadeia de name volta a um apelido já visto.
+func (a modelAliases) cycle(name string) bool {
+	seen := map[string]bool{}
+	for {
+		if seen[name] {
+			return true
+		}
+		seen[name] = true
+		next, ok := a[name]
+		if !ok || next == name {
+			return false
+		}
+		name = next
+	}
+}
+
+// describeModel mostra o modelo com o apelido usado, se houver: "gpt-5 (smart)".
+func describeModel(asked, model string) string {
+	if asked == "" || asked == model {
+		return model
+	}
+	return fmt.Sprintf("%s (%s)", model, asked)
+}
+
+func (a modelAliases) print(w io.Writer) {
+	if len(a) == 0 {
+		fmt.Fprintln(w, "(nenhum apelido; defina model_aliases no config.yaml)")
+		return
+	}
+	for _, name := range sortedKeys(a) {
+		fmt.Fprintf(w, "  %-12s → %s\n", name, a.resolve(name))
+	}
+}
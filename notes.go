@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// ===================== Notas (Obsidian/Markdown) =====================
+//
+// --save-note "pasta/Título" grava a pergunta e a resposta como uma nota
+// Markdown no vault da seção notes do config, com front matter YAML (date,
+// model, tags) que o Obsidian e afins entendem. /note faz o mesmo no REPL.
+// Se a nota já existe, a troca vai para o fim dela e o front matter fica.
+
+// NotesConfig é o vault de --save-note e /note.
+type NotesConfig struct {
+	Dir    string   `yaml:"dir,omitempty"`    // raiz do vault (aceita ~/)
+	Folder string   `yaml:"folder,omitempty"` // pasta das notas sem pasta no nome
+	Tags   []string `yaml:"tags,omitempty"`   // tags de toda nota (default: gptcli)
+}
+
+type noteFrontMatter struct {
+	Date    string   `yaml:"date"`
+	Model   string   `yaml:"model,omitempty"`
+	Tags    []string `yaml:"tags,omitempty"`
+	Session string   `yaml:"session,omitempty"`
+}
+
+// noteTitleReplacer troca o que o Obsidian não aceita em nomes de nota ou o
+// que quebra [[links]].
+var noteTitleReplacer = strings.NewReplacer(
+	"*", "-", `"`, "'", `\`, "-", "<", "-", ">", "-", ":", " -", "|", "-",
+	"?", "", "#", "", "^", "", "[", "(", "]", ")",
+)
+
+// notePath resolve "pasta/Título" para o arquivo .md dentro do vault.
+func notePath(cfg NotesConfig, ref string) (string, error) {
+	if cfg.Dir == "" {
+		return "", errors.New("defina notes.dir no config (a pasta do vault)")
+	}
+	dir := cfg.Dir
+	if rest, ok := strings.CutPrefix(dir, "~/"); ok {
+		dir = filepath.Join(homeDir(), rest)
+	}
+	ref = strings.TrimSuffix(strings.TrimSpace(filepath.ToSlash(ref)), ".md")
+	folder, title := path.Split(ref)
+	title = strings.Trim(strings.TrimSpace(noteTitleReplacer.Replace(title)), ".")
+	if title == "" {
+		return "", fmt.Errorf("nota %q sem título", ref)
+	}
+	if folder == "" {
+		folder = cfg.Folder
+	}
+	if path.IsAbs(folder) || filepath.IsAbs(folder) {
+		return "", fmt.Errorf("nota %q: a pasta é relativa ao vault", ref)
+	}
+	root := filepath.Clean(dir)
+	file := filepath.Join(root, filepath.FromSlash(folder), title+".md")
+	if !strings.HasPrefix(file, root+string(filepath.Separator)) {
+		return "", fmt.Errorf("nota %q fora do vault", ref)
+	}
+	return file, nil
+}
+
+// noteTags junta as tags do config e as da sessão no formato do Obsidian
+// (sem espaços nem #).
+func noteTags(cfg NotesConfig, sess *Session) []string {
+	base := cfg.Tags
+	if len(base) == 0 {
+		base = []string{"gptcli"}
+	}
+	var out []string
+	for _, t := range append(append([]string(nil), base...), sess.Tags...) {
+		t = strings.Join(strings.Fields(strings.TrimPrefix(t, "#")), "-")
+		if t != "" && !containsString(out, t) {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// saveNote grava a troca na nota e diz se ela foi acrescentada a uma nota
+// que já existia.
+func saveNote(file string, cfg NotesConfig, sess *Session, model, prompt, answer string) (appended bool, err error) {
+	var buf bytes.Buffer
+	if _, err := os.Stat(file); err == nil {
+		appended = true
+		buf.WriteString("\n---\n\n")
+	} else {
+		buf.WriteString("---\n")
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2) // como o Obsidian grava as propriedades
+		err := enc.Encode(noteFrontMatter{
+			Date:    time.Now().Format("2006-01-02T15:04:05"),
+			Model:   model,
+			Tags:    noteTags(cfg, sess),
+			Session: sess.Name,
+		})
+		if err != nil {
+			return false, err
+		}
+		buf.WriteString("---\n\n")
+	}
+	if prompt != "" {
+		buf.WriteString("> [!question] Pergunta\n")
+		for _, l := range strings.Split(strings.TrimRight(prompt, "\n"), "\n") {
+			buf.WriteString(strings.TrimRight("> "+l, " ") + "\n")
+		}
+		buf.WriteString("\n")
+	}
+	buf.WriteString(strings.TrimRight(answer, "\n") + "\n")
+	if err := ensureFileDirectory(file); err != nil {
+		return false, err
+	}
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return false, err
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return false, err
+	}
+	return appended, f.Close()
+}
+
+// saveLastNote grava a última troca da sessão em file.
+func saveLastNote(file string, cfg NotesConfig, sess *Session, model string) error {
+	prompt, answer := lastExchange(sess)
+	if answer == "" {
+		return errors.New("não há resposta para salvar")
+	}
+	appended, err := saveNote(file, cfg, sess, model, prompt, answer)
+	if err != nil {
+		return err
+	}
+	if appended {
+		fmt.Fprintf(os.Stderr, "(troca acrescentada à nota %s)\n", file)
+	} else {
+		fmt.Fprintf(os.Stderr, "(nota salva em %s)\n", file)
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	openai "github.com/openai/openai-go/v2"
+	"github.com/openai/openai-go/v2/shared"
+)
+
+// ===================== OCR =====================
+//
+// `gptcli ocr print.png` manda a imagem a um modelo com visão e imprime só o
+// texto extraído; com --json, a estrutura (blocos, tabelas) em JSON.
+
+const ocrSystem = `Você é um OCR. Transcreva todo o texto visível na imagem, exatamente como está
+(mesmo idioma, grafia, números e pontuação), na ordem natural de leitura. Preserve
+quebras de linha e parágrafos; tabelas viram linhas com colunas separadas por " | ".
+Não traduza, não resuma, não comente e não descreva elementos sem texto. Se não
+houver texto, responda vazio.`
+
+const ocrJSONSystem = `Você é um OCR. Transcreva todo o texto visível na imagem, exatamente como está,
+e responda SOMENTE um objeto JSON neste formato:
+{"language": "<código ISO 639-1 do texto>",
+ "blocks": [{"type": "heading|paragraph|list|table|code|other", "text": "<texto do bloco>",
+             "rows": [["célula", ...], ...]}]}
+"rows" só aparece em tabelas. Mantenha a ordem natural de leitura; não traduza nem resuma.`
+
+// maxOCRImage é o limite de tamanho de imagem da API.
+const maxOCRImage = 20 << 20
+
+// ocrResult é o formato do --json.
+type ocrResult struct {
+	Language string     `json:"language"`
+	Blocks   []ocrBlock `json:"blocks"`
+}
+
+type ocrBlock struct {
+	Type string     `json:"type"`
+	Text string     `json:"text"`
+	Rows [][]string `json:"rows,omitempty"`
+}
+
+func ocrCmd(args []string) error {
+	fs := flag.NewFlagSet("ocr", flag.ExitOnError)
+	flags := commonFlags(fs)
+	asJSON := fs.Bool("json", false, "saída estruturada: idioma e blocos (títulos, parágrafos, tabelas)")
+	detail := fs.String("detail", "high", "resolução enviada ao modelo: low, high ou auto")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "uso: gptcli ocr [flags] <imagem|URL|-> [...]")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	switch *detail {
+	case "low", "high", "auto":
+	default:
+		return fmt.Errorf("--detail inválido %q (use low, high ou auto)", *detail)
+	}
+
+	cfg, _ := loadConfig()
+	st, err := resolveSettings(cfg, flags)
+	if err != nil {
+		return err
+	}
+	initAppLog(st.logLevel, st.profName, st.personaName)
+	client, err := buildClient(st)
+	if err != nil {
+		return err
+	}
+
+	ctx := withRetryConfig(context.Background(), st.retry)
+	var results []ocrResult
+	for i, src := range fs.Args() {
+		url, err := imageDataURL(src)
+		if err != nil {
+			return err
+		}
+		var text string
+		err = logOp(ctx, "ocr", st.model, func(ctx context.Context) error {
+			text, err = ocrImage(ctx, client, st, flags.System, url, *detail, *asJSON)
+			return err
+		})
+		if err != nil {
+			flushTelemetry()
+			return fmt.Errorf("%s: %w", src, err)
+		}
+		if *asJSON {
+			var r ocrResult
+			if err := json.Unmarshal([]byte(stripCodeFence(text)), &r); err != nil {
+				flushTelemetry()
+				return fmt.Errorf("%s: o modelo não devolveu JSON válido: %w", src, err)
+			}
+			results = append(results, r)
+			continue
+		}
+		if fs.NArg() > 1 {
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Printf("==> %s <==\n", src)
+		}
+		fmt.Println(text)
+	}
+	flushTelemetry()
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		if len(results) == 1 {
+			return enc.Encode(results[0])
+		}
+		return enc.Encode(results)
+	}
+	return nil
+}
+
+func ocrImage(ctx context.Context, client openai.Client, st *settings, system, url, detail string, asJSON bool) (string, error) {
+	sys := ocrSystem
+	if asJSON {
+		sys = ocrJSONSystem
+	}
+	params := openai.ChatCompletionNewParams{
+		Model: shared.ChatModel(st.model),
+		Messages: []openai.ChatCompletionMessageParamUnion{
+			openai.SystemMessage(chooseNonEmpty(system, sys)),
+			openai.UserMessage([]openai.ChatCompletionContentPartUnionParam{
+				openai.ImageContentPart(openai.ChatCompletionContentPartImageImageURLParam{URL: url, Detail: detail}),
+			}),
+		},
+	}
+	if asJSON {
+		params.ResponseFormat = openai.ChatCompletionNewParamsResponseFormatUnion{
+			OfJSONObject: &shared.ResponseFormatJSONObjectParam{},
+		}
+	}
+	if st.temp >= 0 {
+		params.Temperature = openai.Float(st.temp)
+	}
+	if st.maxTokens > 0 {
+		params.MaxTokens = openai.Int(st.maxTokens)
+	}
+	var text string
+	err := withRetries(ctx, 4, func() error {
+		resp, err := client.Chat.Completions.New(ctx, params)
+		if err != nil {
+			return err
+		}
+		if len(resp.Choices) == 0 {
+			return errors.New("resposta vazia")
+		}
+		noteUsage(ctx, resp.Usage)
+		if r := resp.Choices[0].Message.Refusal; r != "" {
+			return fmt.Errorf("o modelo recusou: %s", r)
+		}
+		text = strings.TrimSpace(resp.Choices[0].Message.Content)
+		return nil
+	})
+	return text, err
+}
+
+// imageDataURL devolve URLs http(s) como estão e converte arquivos (ou "-",
+// o stdin) em data URL base64.
+func imageDataURL(src string) (string, error) {
+	if strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://") {
+		return src, nil
+	}
+	data, mime, err := readImage(src)
+	if err != nil {
+		return "", err
+	}
+	return "data:" + mime + ";base64," + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// readImage lê a imagem e identifica o formato pelos primeiros bytes, não
+// pela extensão: um print vindo de maim ou screencapture pelo pipe não tem nome.
+func readImage(src string) ([]byte, string, error) {
+	var data []byte
+	var err error
+	if src == "-" {
+		if !isPiped() {
+			return nil, "", errors.New("-: o stdin é um terminal; mande a imagem por um pipe (ex: maim -s | gptcli --attach - \"...\")")
+		}
+		data, err = io.ReadAll(io.LimitReader(os.Stdin, maxOCRImage+1))
+		src = "stdin"
+	} else {
+		data, err = os.ReadFile(src)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	if len(data) == 0 {
+		return nil, "", fmt.Errorf("%s: vazio, nenhuma imagem recebida", src)
+	}
+	if len(data) > maxOCRImage {
+		return nil, "", fmt.Errorf("%s: imagem maior que 20MB", src)
+	}
+	mime := http.DetectContentType(data)
+	switch mime {
+	case "image/png", "image/jpeg", "image/gif", "image/webp":
+	default:
+		return nil, "", fmt.Errorf("%s: formato %s não suportado (use PNG, JPEG, GIF ou WebP)", src, mime)
+	}
+	return data, mime, nil
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	openai "github.com/openai/openai-go/v2"
+	"github.com/openai/openai-go/v2/shared"
+)
+
+// ===================== Onboarding =====================
+//
+// Na primeira execução interativa, sem config.yaml e sem chave (--api-key ou
+// OPENAI_API_KEY), um assistente curto pede a chave (oferecendo o keyring),
+// o modelo padrão e o nome do profile, faz uma chamada de teste e grava o
+// config, em vez de só sair com erro.
+
+var onboardingModels = []string{"gpt-5-mini", "gpt-5", "gpt-4.1-mini", "gpt-4.1"}
+
+func needsOnboarding(flags *Flags) bool {
+	if strings.TrimSpace(flags.APIKey) != "" || os.Getenv("OPENAI_API_KEY") != "" || flags.Provider != "" || ephemeral || ephemeralFromEnv() {
+		return false
+	}
+	if _, err := os.Stat(configPath()); !os.IsNotExist(err) {
+		return false
+	}
+	out, err := os.Stdout.Stat()
+	return err == nil && out.Mode()&os.ModeCharDevice != 0 && !isPiped()
+}
+
+func runOnboarding(ctx context.Context) (*Config, error) {
+	in := bufio.NewReader(os.Stdin)
+	ask := func(question, def string) string {
+		if def != "" {
+			fmt.Printf("%s [%s]: ", question, def)
+		} else {
+			fmt.Printf("%s: ", question)
+		}
+		line, _ := in.ReadString('\n')
+		return chooseNonEmpty(strings.TrimSpace(line), def)
+	}
+
+	fmt.Println("Bem-vindo ao gptcli! Não há config nem API key; vamos criar um config em", configPath())
+	fmt.Println("(Ctrl+C cancela; depois dá para editar o arquivo à vontade)")
+	fmt.Println()
+
+	key, err := readHidden(in, "API key (sk-...): ")
+	if err != nil {
+		return nil, err
+	}
+	if key == "" {
+		return nil, errors.New("nenhuma chave informada; defina OPENAI_API_KEY ou rode de novo")
+	}
+	baseURL := ask("Base URL (Enter = API da OpenAI)", "")
+
+	fmt.Println("\nModelo padrão:")
+	for i, m := range onboardingModels {
+		fmt.Printf("  %d. %s\n", i+1, m)
+	}
+	model := ask("Número ou nome do modelo", "1")
+	if n, err := strconv.Atoi(model); err == nil && n >= 1 && n <= len(onboardingModels) {
+		model = onboardingModels[n-1]
+	}
+	profile := ask("Nome do profile", "default")
+
+	fmt.Print("\n(testando a chave...) ")
+	if err := onboardingTest(ctx, key, baseURL, model); err != nil {
+		fmt.Println("falhou:", err)
+		if strings.ToLower(ask("Gravar o config mesmo assim? [s/N]", "")) != "s" {
+			return nil, errors.New("onboarding cancelado; nada foi gravado")
+		}
+	} else {
+		fmt.Println("ok")
+	}
+
+	cfg := &Config{
+		Default:  profile,
+		Profiles: map[string]Profile{profile: {Model: model, BaseURL: baseURL}},
+		Personas: map[string]Persona{},
+	}
+	cfg.APIKey = key
+	if keyringAvailable() && strings.ToLower(ask("Guardar a chave no keyring do sistema em vez do config? [S/n]", "")) != "n" {
+		if err := keyringSet(keyringAPIKeyAccount, "gptcli OpenAI API key", key); err != nil {
+			fmt.Fprintln(os.Stderr, "aviso:", err, "(a chave fica no config)")
+		} else {
+			cfg.APIKey, cfg.APIKeyKeyring = "", true
+		}
+	}
+	if err := saveConfig(cfg); err != nil {
+		return nil, err
+	}
+	fmt.Printf("\nConfig gravado em %s (profile %q, modelo %s).\n", configPath(), profile, model)
+	fmt.Println(`Experimente: gptcli "olá" ou gptcli --repl`)
+	fmt.Println()
+	return cfg, nil
+}
+
+// readHidden lê uma linha do terminal sem eco.
+func readHidden(in *bufio.Reader, prompt string) (string, error) {
+	fmt.Print(prompt)
+	if saved, err := stty(os.Stdin, "-g"); err == nil {
+		_, _ = stty(os.Stdin, "-echo")
+		defer func() { _, _ = stty(os.Stdin, strings.TrimSpace(saved)) }()
+	}
+	line, err := in.ReadString('\n')
+	fmt.Println()
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// onboardingTest faz uma chamada mínima para validar chave, URL e modelo.
+func onboardingTest(ctx context.Context, key, baseURL, model string) error {
+	client, err := buildClient(&settings{apiKey: key, baseURL: baseURL})
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	resp, err := client.Chat.Completions.New(ctx, openai.ChatCompletionNewParams{
+		Model:    shared.ChatModel(model),
+		Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("Responda só: ok")},
+	})
+	if err != nil {
+		return err
+	}
+	if len(resp.Choices) == 0 {
+		return errors.New("resposta vazia")
+	}
+	return nil
+}
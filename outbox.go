@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	openai "github.com/openai/openai-go/v2"
+)
+
+// ===================== Outbox =====================
+//
+// Com --queue-on-failure, um prompt que falhou por falta de rede vai para
+// ~/.local/state/gptcli/outbox/<id>.json. `gptcli flush` reenvia a fila em
+// ordem e grava as respostas nas sessões de origem.
+
+// exitQueued sinaliza que o prompt não foi respondido, mas ficou na fila
+// (EX_TEMPFAIL do sysexits.h).
+const exitQueued = 75
+
+// queuedPrompt guarda só o que foi passado na linha de comando; chave e
+// profile são resolvidos de novo no flush. A chave da API nunca vai para o disco.
+type queuedPrompt struct {
+	ID        string    `json:"id"`
+	Created   time.Time `json:"created"`
+	Prompt    string    `json:"prompt"`
+	Session   string    `json:"session,omitempty"`
+	Profile   string    `json:"profile,omitempty"`
+	Persona   string    `json:"persona,omitempty"`
+	Model     string    `json:"model,omitempty"`
+	System    string    `json:"system,omitempty"`
+	Format    string    `json:"format,omitempty"`
+	Temp      float64   `json:"temp"`
+	MaxTokens int64     `json:"max_tokens,omitempty"`
+	BaseURL   string    `json:"base_url,omitempty"`
+	Proxy     string    `json:"proxy,omitempty"`
+	Deliver   []string  `json:"deliver,omitempty"`
+}
+
+func outboxDir() string { return filepath.Join(stateDir(), "outbox") }
+
+// isNetworkError separa falhas de conexão (DNS, recusa, timeout) de erros
+// devolvidos pela API, que não adianta reenviar.
+func isNetworkError(err error) bool {
+	var apiErr *openai.Error
+	if errors.As(err, &apiErr) || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+func enqueuePrompt(flags *Flags, prompt string) (string, error) {
+	if ephemeral {
+		return "", errEphemeral
+	}
+	now := time.Now()
+	q := queuedPrompt{
+		ID:        now.Format("20060102-150405.000000"),
+		Created:   now,
+		Prompt:    prompt,
+		Session:   strings.TrimSpace(flags.Session),
+		Profile:   flags.Profile,
+		Persona:   flags.Persona,
+		Model:     flags.Model,
+		System:    flags.System,
+		Format:    flags.Format,
+		Temp:      flags.Temp,
+		MaxTokens: flags.MaxTokens,
+		BaseURL:   flags.BaseURL,
+		Proxy:     flags.Proxy,
+		Deliver:   flags.Deliver,
+	}
+	b, err := json.MarshalIndent(q, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(outboxDir(), 0o700); err != nil {
+		return "", err
+	}
+	return q.ID, os.WriteFile(filepath.Join(outboxDir(), q.ID+".json"), b, 0o600)
+}
+
+func loadOutbox() ([]queuedPrompt, error) {
+	paths, err := filepath.Glob(filepath.Join(outboxDir(), "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths) // o id começa pelo timestamp
+	var out []queuedPrompt
+	for _, p := range paths {
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		var q queuedPrompt
+		if err := json.Unmarshal(b, &q); err != nil {
+			fmt.Fprintf(os.Stderr, "aviso: %s inválido: %v\n", filepath.Base(p), err)
+			continue
+		}
+		out = append(out, q)
+	}
+	return out, nil
+}
+
+func flushCmd(args []string) error {
+	fs := flag.NewFlagSet("flush", flag.ExitOnError)
+	apiKey := fs.String("api-key", "", "OpenAI API key (ou use OPENAI_API_KEY)")
+	list := fs.Bool("list", false, "só lista a fila, sem enviar")
+	drop := fs.String("drop", "", "remove da fila o prompt com este id")
+	maxFailures := breakerFlag(fs)
+	_ = fs.Parse(args)
+
+	if *drop != "" {
+		if err := os.Remove(filepath.Join(outboxDir(), filepath.Base(*drop)+".json")); err != nil {
+			if os.IsNotExist(err) {
+				return fmt.Errorf("prompt %q não está na fila", *drop)
+			}
+			return err
+		}
+		return nil
+	}
+
+	queue, err := loadOutbox()
+	if err != nil {
+		return err
+	}
+	if len(queue) == 0 {
+		fmt.Println("(fila vazia)")
+		return nil
+	}
+	if *list {
+		for _, q := range queue {
+			fmt.Printf("%s  %s  %s\n", q.ID, chooseNonEmpty(q.Session, "-"), truncate(q.Prompt, 60))
+		}
+		return nil
+	}
+
+	cfg, _ := loadConfig()
+	ctx := context.Background()
+	sent := 0
+	brk := newBreaker(*maxFailures)
+	for i, q := range queue {
+		err := flushOne(ctx, cfg, q, *apiKey)
+		if err == nil {
+			_ = os.Remove(filepath.Join(outboxDir(), q.ID+".json"))
+			sent++
+			brk.record(nil)
+			continue
+		}
+		if isNetworkError(err) {
+			return fmt.Errorf("ainda sem conexão (%d de %d enviados): %w", sent, len(queue), err)
+		}
+		fmt.Fprintf(os.Stderr, "aviso: %s mantido na fila: %v\n", q.ID, err)
+		if brk.record(err) {
+			flushTelemetry()
+			brk.report(i+1, len(queue))
+			return fmt.Errorf("%d de %d enviados; o restante continua na fila", sent, len(queue))
+		}
+	}
+	flushTelemetry()
+	fmt.Fprintf(os.Stderr, "(%d de %d enviados)\n", sent, len(queue))
+	return nil
+}
+
+func flushOne(ctx context.Context, cfg *Config, q queuedPrompt, apiKey string) error {
+	flags := &Flags{
+		APIKey: apiKey, Model: q.Model, System: q.System, Temp: q.Temp, BaseURL: q.BaseURL, Proxy: q.Proxy,
+		Format: q.Format, Profile: q.Profile, Persona: q.Persona, Session: q.Session, MaxTokens: q.MaxTokens,
+		Deliver: q.Deliver,
+	}
+	st, err := resolveSettings(cfg, flags)
+	if err != nil {
+		return err
+	}
+	initAppLog(st.logLevel, st.profName, st.personaName)
+	configureTools(st)
+	client, err := buildClient(st)
+	if err != nil {
+		return err
+	}
+	ctx = withRetryConfig(ctx, st.retry)
+	sess, err := openSession(st, flags)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("== %s", q.ID)
+	if q.Session != "" {
+		fmt.Printf(" (sessão %s)", q.Session)
+	}
+	fmt.Printf(" ==\n> %s\n", truncate(q.Prompt, 200))
+	if err := askOnce(ctx, client, sess, st.model, st.temp, st.maxTokens, st.prof.Hooks, q.Prompt); err != nil {
+		return err
+	}
+	fmt.Println()
+	ensureTitle(ctx, client, sess, st)
+	if err := sess.save(); err != nil {
+		return err
+	}
+	saveHistory("Q: " + q.Prompt)
+	if err := deliverAnswer(ctx, st, sess); err != nil {
+		// a resposta já está na sessão: reenviar o prompt não resolve a entrega
+		fmt.Fprintln(os.Stderr, "aviso:", err)
+	}
+	return nil
+}
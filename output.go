@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	openai "github.com/openai/openai-go/v2"
+	"github.com/openai/openai-go/v2/shared"
+)
+
+// ===================== Output Presets =====================
+//
+// Um profile pode nomear formatos de saída JSON reutilizáveis:
+//
+//	outputs:
+//	  todo_list:
+//	    schema: {type: object, properties: {todos: {type: array, ...}}, required: [todos]}
+//	    select: todos.*.title
+//
+// --output-preset todo_list manda o schema para a API (response_format
+// json_schema), valida a resposta e imprime só o que `select` escolher.
+
+type OutputPreset struct {
+	Schema map[string]any `yaml:"schema"`
+	Select string         `yaml:"select,omitempty"` // caminho pontuado; * percorre listas
+	Strict bool           `yaml:"strict,omitempty"` // structured outputs estrito (exige additionalProperties: false)
+
+	name string
+}
+
+// outputPresetAttempts inclui a primeira tentativa; as outras recebem o erro
+// de validação para o modelo corrigir.
+const outputPresetAttempts = 3
+
+// a API só aceita estes caracteres no nome do schema
+var outputPresetNameRe = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+func resolveOutputPreset(prof Profile, profName, name string) (*OutputPreset, error) {
+	p, ok := prof.Outputs[name]
+	if !ok {
+		var names []string
+		for n := range prof.Outputs {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		if len(names) == 0 {
+			return nil, fmt.Errorf("--output-preset %q: o profile %q não define outputs", name, profName)
+		}
+		return nil, fmt.Errorf("--output-preset %q não existe no profile %q (disponíveis: %s)",
+			name, profName, strings.Join(names, ", "))
+	}
+	if !outputPresetNameRe.MatchString(name) {
+		return nil, fmt.Errorf("outputs.%s: nome inválido (use letras, números, '_' ou '-')", name)
+	}
+	if len(p.Schema) == 0 {
+		return nil, fmt.Errorf("outputs.%s: schema vazio", name)
+	}
+	if _, err := json.Marshal(p.Schema); err != nil {
+		return nil, fmt.Errorf("outputs.%s: schema inválido: %w", name, err)
+	}
+	if p.Select != "" && strings.Contains("."+p.Select+".", "..") {
+		return nil, fmt.Errorf("outputs.%s: select inválido %q (ex: todos.*.title)", name, p.Select)
+	}
+	p.name = name
+	return &p, nil
+}
+
+func (p *OutputPreset) responseFormat() openai.ChatCompletionNewParamsResponseFormatUnion {
+	js := shared.ResponseFormatJSONSchemaJSONSchemaParam{Name: p.name, Schema: p.Schema}
+	if p.Strict {
+		js.Strict = openai.Bool(true)
+	}
+	return openai.ChatCompletionNewParamsResponseFormatUnion{
+		OfJSONSchema: &shared.ResponseFormatJSONSchemaParam{JSONSchema: js},
+	}
+}
+
+// instruction vai no lugar do pedido genérico de JSON; repetir o schema no
+// prompt ajuda endpoints compatíveis que ignoram response_format.
+func (p *OutputPreset) instruction() string {
+	b, _ := json.Marshal(p.Schema)
+	return "Responda SOMENTE um JSON válido, sem texto extra, seguindo este JSON Schema:\n" + string(b)
+}
+
+// runOutputPreset faz o turno sem stream no stdout, valida a resposta contra
+// o schema e imprime o JSON (ou os campos escolhidos por select).
+func runOutputPreset(ctx context.Context, client openai.Client, st *settings, sess *Session, prompt string) error {
+	p := st.outputPreset
+	prompt, err := st.prof.Hooks.runPre(ctx, prompt, st.model)
+	if err != nil {
+		return err
+	}
+	gen := *sess
+	gen.Format = "json"
+	gen.Output = p
+	gen.addUser(prompt)
+
+	var value any
+	for attempt := 1; ; attempt++ {
+		var out string
+		err := withRetries(ctx, 4, func() error {
+			var err error
+			out, err = streamChat(ctx, client, &gen, st.model, st.temp, st.maxTokens, func(d string) {
+				if streamOut != os.Stdout {
+					fmt.Fprint(streamOut, d)
+				}
+			})
+			return err
+		})
+		if streamOut != os.Stdout {
+			fmt.Fprintln(streamOut)
+		}
+		if err != nil {
+			return err
+		}
+		value, err = decodeAndValidate(out, p.Schema)
+		if err == nil {
+			out = st.prof.Hooks.runPost(ctx, prompt, out, st.model)
+			sess.Turns = gen.Turns
+			sess.addAssistant(out)
+			break
+		}
+		if attempt == outputPresetAttempts {
+			return fmt.Errorf("resposta fora do schema %s: %w", p.name, err)
+		}
+		fmt.Fprintf(os.Stderr, "(resposta fora do schema: %v; pedindo de novo)\n", err)
+		gen.addAssistant(out)
+		gen.addUser("Essa resposta não segue o JSON Schema pedido: " + err.Error() +
+			". Responda de novo só com o JSON corrigido.")
+	}
+
+	if p.Select == "" {
+		b, _ := json.MarshalIndent(value, "", "  ")
+		fmt.Println(string(b))
+		return nil
+	}
+	picked, ok := jsonSelect(value, p.Select)
+	if !ok {
+		return fmt.Errorf("select %q não encontrou nada na resposta", p.Select)
+	}
+	for _, v := range picked {
+		fmt.Println(jsonScalarString(v))
+	}
+	return nil
+}
+
+func decodeAndValidate(out string, schema map[string]any) (any, error) {
+	var v any
+	if err := json.Unmarshal([]byte(stripCodeFence(out)), &v); err != nil {
+		return nil, fmt.Errorf("JSON inválido: %w", err)
+	}
+	if err := validateSchema(v, schema, "$"); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// validateSchema cobre o subconjunto de JSON Schema usado em structured
+// outputs: type, enum, properties, required, additionalProperties e items.
+func validateSchema(v any, schema map[string]any, path string) error {
+	if t, ok := schema["type"]; ok && !matchesSchemaType(v, t) {
+		return fmt.Errorf("%s: esperado %v, veio %s", path, t, jsonTypeName(v))
+	}
+	if enum, ok := schema["enum"].([]any); ok {
+		found := false
+		for _, e := range enum {
+			if jsonScalarString(e) == jsonScalarString(v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: valor %s fora do enum", path, jsonScalarString(v))
+		}
+	}
+	switch x := v.(type) {
+	case map[string]any:
+		props, _ := schema["properties"].(map[string]any)
+		if req, ok := schema["required"].([]any); ok {
+			for _, r := range req {
+				if _, ok := x[fmt.Sprint(r)]; !ok {
+					return fmt.Errorf("%s: falta o campo obrigatório %q", path, r)
+				}
+			}
+		}
+		keys := make([]string, 0, len(x))
+		for k := range x {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			sub, ok := props[k].(map[string]any)
+			if !ok {
+				if extra, ok := schema["additionalProperties"].(bool); ok && !extra {
+					return fmt.Errorf("%s: campo não previsto %q", path, k)
+				}
+				continue
+			}
+			if err := validateSchema(x[k], sub, path+"."+k); err != nil {
+				return err
+			}
+		}
+	case []any:
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, e := range x {
+				if err := validateSchema(e, items, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// matchesSchemaType aceita "type" como string ou lista de tipos.
+func matchesSchemaType(v any, t any) bool {
+	if list, ok := t.([]any); ok {
+		for _, e := range list {
+			if matchesSchemaType(v, e) {
+				return true
+			}
+		}
+		return false
+	}
+	name := fmt.Sprint(t)
+	if f, ok := v.(float64); ok && name == "integer" {
+		return f == float64(int64(f))
+	}
+	return jsonTypeName(v) == name
+}
+
+func jsonTypeName(v any) string {
+	switch v.(type) {
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	default:
+		return "null"
+	}
+}
+
+// jsonSelect é o jsonLookup com "*" para percorrer todos os itens de uma
+// lista (ou valores de um objeto); devolve cada valor encontrado.
+func jsonSelect(v any, path string) ([]any, bool) {
+	if path == "" {
+		return []any{v}, true
+	}
+	head, rest, _ := strings.Cut(path, ".")
+	if head != "*" {
+		next, ok := jsonLookup(v, head)
+		if !ok {
+			return nil, false
+		}
+		return jsonSelect(next, rest)
+	}
+	var items []any
+	switch x := v.(type) {
+	case []any:
+		items = x
+	case map[string]any:
+		keys := make([]string, 0, len(x))
+		for k := range x {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			items = append(items, x[k])
+		}
+	default:
+		return nil, false
+	}
+	var out []any
+	for _, item := range items {
+		if got, ok := jsonSelect(item, rest); ok {
+			out = append(out, got...)
+		}
+	}
+	return out, len(out) > 0
+}
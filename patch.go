@@ -0,0 +1,364 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ===================== Patch =====================
+//
+// `gptcli patch -f arquivo "instrução"` pede ao modelo um diff unificado,
+// confere se ele aplica sem conflito, mostra e só então grava (com .bak).
+
+const patchSystem = `Você edita código. Responda SOMENTE com um diff unificado (formato do "diff -u"),
+dentro de um bloco ` + "```diff" + `, com cabeçalhos "--- a/<caminho>" e "+++ b/<caminho>" usando
+exatamente os caminhos informados e 3 linhas de contexto em cada hunk. Não altere
+outros arquivos nem inclua explicações.`
+
+// patchAttempts: se o diff não aplicar, o erro volta para o modelo corrigir.
+const patchAttempts = 3
+
+// hunkWindow é até quantas linhas longe do @@ um hunk ainda é procurado
+// quando não confere na posição indicada.
+const hunkWindow = 50
+
+type filePatch struct {
+	path  string
+	hunks []hunk
+}
+
+type hunk struct {
+	oldStart int // 1-based, como no cabeçalho @@
+	lines    []string
+}
+
+var (
+	hunkHeaderRe = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+\d+(?:,(\d+))? @@`)
+	// o bloco vai até a última cerca: um ``` dentro do diff (num README, num
+	// contexto sem o espaço) não o corta
+	diffFenceRe = regexp.MustCompile("(?s)```+(?:diff|patch)?[ \t]*\n(.*)\n```+[ \t]*(?:\n|$)")
+)
+
+func patchCmd(args []string) error {
+	fs := flag.NewFlagSet("patch", flag.ExitOnError)
+	flags := commonFlags(fs)
+	var files stringList
+	fs.Var(&files, "f", "arquivo que o modelo pode alterar (repetível)")
+	dryRun := fs.Bool("dry-run", false, "só mostra o diff, sem aplicar")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, `uso: gptcli patch -f arquivo [-f outro] [flags] "instrução"`)
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	instruction := strings.TrimSpace(strings.Join(fs.Args(), " "))
+	if len(files) == 0 || instruction == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	originals := map[string]string{}
+	var b strings.Builder
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return err
+		}
+		path := filepath.ToSlash(filepath.Clean(f))
+		originals[path] = string(data)
+		fmt.Fprintf(&b, "=== %s ===\n%s\n", path, data)
+	}
+
+	cfg, _ := loadConfig()
+	st, err := resolveSettings(cfg, flags)
+	if err != nil {
+		return err
+	}
+	initAppLog(st.logLevel, st.profName, st.personaName)
+	client, err := buildClient(st)
+	if err != nil {
+		return err
+	}
+
+	sess := &Session{}
+	sess.addSystem(patchSystem)
+	sess.addUser(fmt.Sprintf("Arquivos:\n\n%s\nInstrução: %s", b.String(), instruction))
+
+	ctx := withRetryConfig(context.Background(), st.retry)
+	var diff string
+	var patched map[string]string
+	err = logOp(ctx, "patch", st.model, func(ctx context.Context) error {
+		for attempt := 1; ; attempt++ {
+			fmt.Fprintln(os.Stderr, "(gerando diff...)")
+			var out string
+			err := withRetries(ctx, 4, func() error {
+				var err error
+				out, err = streamChat(ctx, client, sess, st.model, st.temp, st.maxTokens, func(string) {})
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			diff = extractDiff(out)
+			patched, err = applyUnifiedDiff(diff, originals)
+			if err == nil {
+				return nil
+			}
+			if attempt == patchAttempts {
+				return fmt.Errorf("o diff não aplica: %w", err)
+			}
+			fmt.Fprintf(os.Stderr, "(diff não aplica: %v; pedindo outro)\n", err)
+			sess.addAssistant(out)
+			sess.addUser("Esse diff não aplica nos arquivos originais: " + err.Error() +
+				". Gere o diff completo de novo, com o contexto copiado exatamente dos arquivos.")
+		}
+	})
+	flushTelemetry()
+	if err != nil {
+		return err
+	}
+
+	printDiff(diff)
+	if *dryRun {
+		return nil
+	}
+	if !flags.Yes { // --yes de commonFlags também vale para aplicar o patch
+		ok, err := confirmTTY("Aplicar o patch?")
+		if err != nil {
+			return errors.New("sem terminal para confirmar; use --yes ou --dry-run")
+		}
+		if !ok {
+			fmt.Fprintln(os.Stderr, "(patch descartado)")
+			return nil
+		}
+	}
+	for path, content := range patched {
+		if err := writeWithBackup(path, content); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "(%s atualizado; original em %s.bak)\n", path, path)
+	}
+	return nil
+}
+
+// printDiff mostra o diff, colorido quando o stdout é um terminal.
+func printDiff(diff string) {
+	color := os.Getenv("NO_COLOR") == ""
+	if st, err := os.Stdout.Stat(); err != nil || st.Mode()&os.ModeCharDevice == 0 {
+		color = false
+	}
+	for _, l := range strings.Split(strings.TrimRight(diff, "\n"), "\n") {
+		switch {
+		case !color:
+		case strings.HasPrefix(l, "+++"), strings.HasPrefix(l, "---"):
+			l = "\033[1m" + l + "\033[0m"
+		case strings.HasPrefix(l, "+"):
+			l = "\033[32m" + l + "\033[0m"
+		case strings.HasPrefix(l, "-"):
+			l = "\033[31m" + l + "\033[0m"
+		case strings.HasPrefix(l, "@@"):
+			l = "\033[36m" + l + "\033[0m"
+		}
+		fmt.Println(l)
+	}
+}
+
+func writeWithBackup(path, content string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if err := copyFile(path, path+".bak"); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(content), info.Mode().Perm())
+}
+
+// ---------- diff unificado ----------
+
+// extractDiff tira o diff do bloco de código, mesmo com texto em volta.
+func extractDiff(out string) string {
+	if m := diffFenceRe.FindStringSubmatch(out); m != nil {
+		return m[1]
+	}
+	return stripCodeFence(out)
+}
+
+func parseUnifiedDiff(diff string) ([]filePatch, error) {
+	var patches []filePatch
+	var cur *filePatch
+	var h *hunk
+	// linhas que ainda faltam no hunk, pelas contagens do @@: até lá, um
+	// "--- " seguido de "+++ " é uma linha "-- " removida e uma "++ " somada
+	var oldLeft, newLeft int
+	// a quebra final não é uma linha de contexto vazia
+	lines := strings.Split(strings.TrimRight(strings.ReplaceAll(diff, "\r\n", "\n"), "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		l := lines[i]
+		switch {
+		case strings.HasPrefix(l, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ ") &&
+			(h == nil || oldLeft <= 0 && newLeft <= 0 || i+2 < len(lines) && strings.HasPrefix(lines[i+2], "@@")):
+			patches = append(patches, filePatch{path: diffPath(lines[i+1][4:])})
+			cur, h = &patches[len(patches)-1], nil
+			i++
+		case strings.HasPrefix(l, "@@"):
+			if cur == nil {
+				return nil, errors.New("hunk sem cabeçalho de arquivo")
+			}
+			m := hunkHeaderRe.FindStringSubmatch(l)
+			if m == nil {
+				return nil, fmt.Errorf("cabeçalho de hunk inválido: %q", l)
+			}
+			start, _ := strconv.Atoi(m[1])
+			oldLeft, newLeft = hunkCount(m[2]), hunkCount(m[3])
+			cur.hunks = append(cur.hunks, hunk{oldStart: start})
+			h = &cur.hunks[len(cur.hunks)-1]
+		case h == nil:
+			// texto antes do primeiro hunk (diff --git, index...)
+		case strings.HasPrefix(l, `\`):
+			// "\ No newline at end of file"
+		case l == "":
+			// modelos costumam omitir o espaço das linhas de contexto vazias
+			h.lines = append(h.lines, " ")
+			oldLeft, newLeft = oldLeft-1, newLeft-1
+		case l[0] == ' ' || l[0] == '+' || l[0] == '-':
+			h.lines = append(h.lines, l)
+			if l[0] != '+' {
+				oldLeft--
+			}
+			if l[0] != '-' {
+				newLeft--
+			}
+		default:
+			h = nil
+		}
+	}
+	if len(patches) == 0 {
+		return nil, errors.New("nenhum arquivo no diff")
+	}
+	return patches, nil
+}
+
+// hunkCount lê a contagem de um lado do @@; omitida, ela vale 1.
+func hunkCount(s string) int {
+	if s == "" {
+		return 1
+	}
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+func diffPath(p string) string {
+	if i := strings.IndexByte(p, '\t'); i >= 0 {
+		p = p[:i]
+	}
+	p = strings.TrimSpace(p)
+	if strings.HasPrefix(p, "a/") || strings.HasPrefix(p, "b/") {
+		p = p[2:]
+	}
+	return filepath.ToSlash(filepath.Clean(p))
+}
+
+// applyUnifiedDiff aplica o diff sobre os conteúdos originais (só os arquivos
+// permitidos) e devolve os novos conteúdos.
+func applyUnifiedDiff(diff string, originals map[string]string) (map[string]string, error) {
+	patches, err := parseUnifiedDiff(diff)
+	if err != nil {
+		return nil, err
+	}
+	out := map[string]string{}
+	for _, p := range patches {
+		src, ok := out[p.path]
+		if !ok {
+			if src, ok = originals[p.path]; !ok {
+				return nil, fmt.Errorf("o diff altera %s, que não foi passado com -f", p.path)
+			}
+		}
+		res, err := applyHunks(src, p.hunks)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", p.path, err)
+		}
+		out[p.path] = res
+	}
+	return out, nil
+}
+
+func applyHunks(src string, hunks []hunk) (string, error) {
+	trailingNL := strings.HasSuffix(src, "\n")
+	lines := strings.Split(strings.TrimSuffix(src, "\n"), "\n")
+	if src == "" {
+		lines = nil
+	}
+	offset := 0
+	for n, h := range hunks {
+		var old, repl []string
+		for _, l := range h.lines {
+			switch l[0] {
+			case ' ':
+				old, repl = append(old, l[1:]), append(repl, l[1:])
+			case '-':
+				old = append(old, l[1:])
+			case '+':
+				repl = append(repl, l[1:])
+			}
+		}
+		want := h.oldStart - 1 + offset
+		if len(old) == 0 {
+			want++ // hunk só de inserção: @@ -N,0 insere depois da linha N
+		}
+		at, also := findBlock(lines, old, max(want, 0))
+		if at < 0 {
+			return "", fmt.Errorf("hunk %d (linha %d) não confere com o arquivo", n+1, h.oldStart)
+		}
+		if also >= 0 {
+			return "", fmt.Errorf("hunk %d (linha %d) é ambíguo: confere nas linhas %d e %d", n+1, h.oldStart, min(at, also)+1, max(at, also)+1)
+		}
+		lines = append(lines[:at], append(repl, lines[at+len(old):]...)...)
+		offset += len(repl) - len(old) + (at - want)
+	}
+	res := strings.Join(lines, "\n")
+	if trailingNL || src == "" {
+		res += "\n"
+	}
+	return res, nil
+}
+
+// findBlock procura old na posição esperada e, se não conferir, até
+// hunkWindow linhas dela; espaços no fim da linha são ignorados. Fora da
+// posição esperada, o bloco precisa ser único na janela: also traz o segundo
+// lugar onde ele confere (-1 se não há).
+func findBlock(lines, old []string, want int) (at, also int) {
+	matches := func(at int) bool {
+		if at < 0 || at+len(old) > len(lines) {
+			return false
+		}
+		for i, l := range old {
+			if strings.TrimRight(lines[at+i], " \t") != strings.TrimRight(l, " \t") {
+				return false
+			}
+		}
+		return true
+	}
+	if matches(want) {
+		return want, -1
+	}
+	at, also = -1, -1
+	for d := 1; d <= hunkWindow; d++ {
+		for _, c := range []int{want - d, want + d} {
+			switch {
+			case !matches(c):
+			case at < 0:
+				at = c
+			default:
+				return at, c
+			}
+		}
+	}
+	return at, -1
+}
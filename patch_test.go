@@ -0,0 +1,141 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestApplyUnifiedDiff(t *testing.T) {
+	src := "package main\n\nfunc a() {\n\treturn\n}\n\nfunc b() {}\n"
+	tests := []struct {
+		name    string
+		diff    string
+		want    string
+		wantErr string
+	}{
+		{
+			name: "substitui linha",
+			diff: "--- a/x.go\n+++ b/x.go\n@@ -3,3 +3,3 @@\n func a() {\n-\treturn\n+\treturn // ok\n }\n",
+			want: "package main\n\nfunc a() {\n\treturn // ok\n}\n\nfunc b() {}\n",
+		},
+		{
+			name: "contexto vazio sem o espaço",
+			diff: "--- a/x.go\n+++ b/x.go\n@@ -5,3 +5,4 @@\n }\n\n+// b faz nada\n func b() {}\n",
+			want: "package main\n\nfunc a() {\n\treturn\n}\n\n// b faz nada\nfunc b() {}\n",
+		},
+		{
+			name: "número de linha errado acha o bloco perto",
+			diff: "--- a/x.go\n+++ b/x.go\n@@ -1,1 +1,1 @@\n-func b() {}\n+func b() { a() }\n",
+			want: "package main\n\nfunc a() {\n\treturn\n}\n\nfunc b() { a() }\n",
+		},
+		{
+			name: "inserção pura",
+			diff: "--- a/x.go\n+++ b/x.go\n@@ -1,0 +2,1 @@\n+// pacote de teste\n",
+			want: "package main\n// pacote de teste\n\nfunc a() {\n\treturn\n}\n\nfunc b() {}\n",
+		},
+		{
+			name:    "contexto que não confere",
+			diff:    "--- a/x.go\n+++ b/x.go\n@@ -3,3 +3,3 @@\n func a() {\n-\treturn nil\n+\treturn\n }\n",
+			wantErr: "não confere",
+		},
+		{
+			name:    "arquivo fora do -f",
+			diff:    "--- a/../y.go\n+++ b/../y.go\n@@ -1,1 +1,1 @@\n-package main\n+package y\n",
+			wantErr: "não foi passado com -f",
+		},
+		{
+			name:    "bloco repetido longe da linha indicada",
+			diff:    "--- a/x.go\n+++ b/x.go\n@@ -3,1 +3,2 @@\n \n+// nota\n",
+			wantErr: "ambíguo",
+		},
+		{
+			name:    "bloco fora da janela",
+			diff:    "--- a/x.go\n+++ b/x.go\n@@ -500,1 +500,1 @@\n-func b() {}\n+func b() { a() }\n",
+			wantErr: "não confere",
+		},
+		{
+			name:    "sem arquivo",
+			diff:    "só texto",
+			wantErr: "nenhum arquivo",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := applyUnifiedDiff(tt.diff, map[string]string{"x.go": src})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("erro = %v, queria %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := out["x.go"]; got != tt.want {
+				t.Errorf("resultado:\n%q\nqueria:\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyHunksTwoHunksOffset(t *testing.T) {
+	src := "a\nb\nc\nd\ne\nf\n"
+	hunks := []hunk{
+		{oldStart: 1, lines: []string{" a", "+a2", " b"}},
+		{oldStart: 5, lines: []string{" e", "-f"}},
+	}
+	got, err := applyHunks(src, hunks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "a\na2\nb\nc\nd\ne\n"; got != want {
+		t.Errorf("resultado %q, queria %q", got, want)
+	}
+}
+
+func TestApplyHunksRepeatedBlockUsesHeaderLine(t *testing.T) {
+	src := "x\nfim\ny\nfim\nz\n"
+	got, err := applyUnifiedDiff("--- a/f\n+++ b/f\n@@ -4,1 +4,1 @@\n-fim\n+FIM\n", map[string]string{"f": src})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "x\nfim\ny\nFIM\nz\n"; got["f"] != want {
+		t.Errorf("resultado %q, queria %q", got["f"], want)
+	}
+}
+
+func TestParseUnifiedDiffDashLines(t *testing.T) {
+	// "-- comentário" removido e "++ i" somado viram "--- " e "+++ " no diff
+	diff := "--- a/q.sql\n+++ b/q.sql\n@@ -1,2 +1,2 @@\n--- comentário\n+++ i\n select 1;\n--- a/r.sql\n+++ b/r.sql\n@@ -1 +1 @@\n-a\n+b\n"
+	patches, err := parseUnifiedDiff(diff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(patches) != 2 || patches[0].path != "q.sql" || patches[1].path != "r.sql" {
+		t.Fatalf("arquivos: %+v", patches)
+	}
+	if want := []string{"--- comentário", "+++ i", " select 1;"}; !slices.Equal(patches[0].hunks[0].lines, want) {
+		t.Errorf("linhas do hunk = %q, queria %q", patches[0].hunks[0].lines, want)
+	}
+}
+
+func TestExtractDiff(t *testing.T) {
+	body := "--- a/README.md\n+++ b/README.md\n@@ -1,3 +1,4 @@\n ```go\n```\n+fmt.Println()\n x"
+	tests := []struct {
+		name string
+		out  string
+		want string
+	}{
+		{name: "cerca dentro do diff", out: "Segue:\n```diff\n" + body + "\n```\nPronto.", want: body},
+		{name: "cerca de quatro crases", out: "````diff\n" + body + "\n````", want: body},
+		{name: "sem cerca", out: body, want: body},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := extractDiff(tt.out); got != tt.want {
+				t.Errorf("extractDiff = %q, queria %q", got, tt.want)
+			}
+		})
+	}
+}
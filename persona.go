@@ -0,0 +1,212 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// ===================== Personas =====================
+
+// Persona descreve "com quem estou falando" (instruções, modelo, exemplos),
+// independente das configurações de conexão do profile.
+type Persona struct {
+	System   string    `yaml:"system"`
+	Model    string    `yaml:"model,omitempty"`
+	Temp     *float64  `yaml:"temp,omitempty"` // nil = herda do profile
+	Tools    []string  `yaml:"tools,omitempty"`
+	Examples []Example `yaml:"examples,omitempty"`
+}
+
+// Example é um par few-shot enviado antes da conversa.
+type Example struct {
+	User      string `yaml:"user"`
+	Assistant string `yaml:"assistant"`
+}
+
+func (p Persona) exampleTurns() []Turn {
+	var turns []Turn
+	for _, ex := range p.Examples {
+		turns = append(turns, Turn{Role: "user", Content: ex.User}, Turn{Role: "assistant", Content: ex.Assistant})
+	}
+	return turns
+}
+
+type stringList []string
+
+func (l *stringList) String() string     { return strings.Join(*l, ",") }
+func (l *stringList) Set(v string) error { *l = append(*l, v); return nil }
+
+const personaUsage = `uso: gptcli persona <create|list|show|use|delete> [args]
+  create <nome> [--system txt|--system-file f] [--model m] [--temp t] [--tool x]... [--example "pergunta => resposta"]...
+  list                   lista as personas (* = padrão)
+  show <nome>            mostra a persona
+  use <nome>|-           define a persona padrão ("-" remove)
+  delete <nome>          remove a persona
+`
+
+func personaCmd(args []string) error {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, personaUsage)
+		os.Exit(2)
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	switch args[0] {
+	case "create":
+		return personaCreate(cfg, args[1:])
+	case "list":
+		names := make([]string, 0, len(cfg.Personas))
+		for name := range cfg.Personas {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			mark := " "
+			if name == cfg.DefaultPersona {
+				mark = "*"
+			}
+			p := cfg.Personas[name]
+			fmt.Printf("%s %-16s model=%s exemplos=%d\n", mark, name, chooseNonEmpty(p.Model, "-"), len(p.Examples))
+		}
+		return nil
+	case "show":
+		if len(args) < 2 {
+			return errors.New("uso: gptcli persona show <nome>")
+		}
+		p, ok := cfg.Personas[args[1]]
+		if !ok {
+			return fmt.Errorf("persona %q não encontrada", args[1])
+		}
+		fmt.Printf("model: %s\n", chooseNonEmpty(p.Model, "-"))
+		if p.Temp != nil {
+			fmt.Printf("temp: %g\n", *p.Temp)
+		}
+		if len(p.Tools) > 0 {
+			fmt.Printf("tools: %s\n", strings.Join(p.Tools, ", "))
+		}
+		fmt.Printf("system:\n%s\n", p.System)
+		for i, ex := range p.Examples {
+			fmt.Printf("exemplo %d:\n  user: %s\n  assistant: %s\n", i+1, ex.User, ex.Assistant)
+		}
+		return nil
+	case "use":
+		if len(args) < 2 {
+			return errors.New("uso: gptcli persona use <nome>|-")
+		}
+		name := args[1]
+		if name == "-" {
+			name = ""
+		} else if _, ok := cfg.Personas[name]; !ok {
+			return fmt.Errorf("persona %q não encontrada", name)
+		}
+		err := editConfig(func(root *yaml.Node) error {
+			if name == "" {
+				deleteYAMLKey(root, "default_persona")
+			} else {
+				setYAMLKey(root, "default_persona", &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: name})
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if name == "" {
+			fmt.Println("(nenhuma persona padrão)")
+		} else {
+			fmt.Println("(persona padrão:", name, ")")
+		}
+		return nil
+	case "delete":
+		if len(args) < 2 {
+			return errors.New("uso: gptcli persona delete <nome>")
+		}
+		if _, ok := cfg.Personas[args[1]]; !ok {
+			return fmt.Errorf("persona %q não encontrada", args[1])
+		}
+		return editConfig(func(root *yaml.Node) error {
+			if personas := yamlKey(root, "personas"); personas != nil {
+				deleteYAMLKey(personas, args[1])
+			}
+			if cfg.DefaultPersona == args[1] {
+				deleteYAMLKey(root, "default_persona")
+			}
+			return nil
+		})
+	default:
+		fmt.Fprint(os.Stderr, personaUsage)
+		os.Exit(2)
+	}
+	return nil
+}
+
+func personaCreate(cfg *Config, args []string) error {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return errors.New("uso: gptcli persona create <nome> [flags]")
+	}
+	name := args[0]
+	fs := flag.NewFlagSet("persona create", flag.ExitOnError)
+	system := fs.String("system", "", "mensagem de sistema")
+	systemFile := fs.String("system-file", "", "arquivo com a mensagem de sistema")
+	model := fs.String("model", "", "modelo da persona")
+	temp := fs.String("temp", "", "temperature (vazio = herda do profile)")
+	var tools, examples stringList
+	fs.Var(&tools, "tool", "ferramenta habilitada (repetível)")
+	fs.Var(&examples, "example", `exemplo few-shot "pergunta => resposta" (repetível)`)
+	_ = fs.Parse(args[1:])
+
+	p := Persona{System: strings.TrimSpace(*system), Model: strings.TrimSpace(*model), Tools: tools}
+	if *systemFile != "" {
+		b, err := os.ReadFile(*systemFile)
+		if err != nil {
+			return err
+		}
+		p.System = strings.TrimSpace(string(b))
+	}
+	if strings.TrimSpace(*temp) != "" {
+		t, err := strconv.ParseFloat(strings.TrimSpace(*temp), 64)
+		if err != nil {
+			return fmt.Errorf("temp inválida: %w", err)
+		}
+		p.Temp = &t
+	}
+	for _, ex := range examples {
+		user, assistant, ok := strings.Cut(ex, "=>")
+		if !ok {
+			return fmt.Errorf("exemplo inválido %q (use \"pergunta => resposta\")", ex)
+		}
+		p.Examples = append(p.Examples, Example{strings.TrimSpace(user), strings.TrimSpace(assistant)})
+	}
+
+	_, existed := cfg.Personas[name]
+	err := editConfig(func(root *yaml.Node) error {
+		var value yaml.Node
+		if err := value.Encode(p); err != nil {
+			return err
+		}
+		personas := yamlKey(root, "personas")
+		if personas == nil || personas.Kind != yaml.MappingNode {
+			personas = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			setYAMLKey(root, "personas", personas)
+		}
+		setYAMLKey(personas, name, &value)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if existed {
+		fmt.Println("(persona atualizada:", name, ")")
+	} else {
+		fmt.Println("(persona criada:", name, ")")
+	}
+	return nil
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// ===================== Provedores =====================
+//
+// `provider:` no profile (ou --provider) escolhe um provedor compatível com a
+// API da OpenAI: a base_url, a variável de ambiente da chave e os cabeçalhos
+// vêm do preset, e base_url/api_key explícitos continuam valendo por cima.
+// Sem model no profile, vale o primeiro modelo conhecido do preset. bedrock é
+// a exceção: tem tradução própria (bedrock.go).
+
+type providerPreset struct {
+	baseURL string
+	keyEnv  string            // variável de ambiente com a chave do provedor
+	headers map[string]string // enviados em toda chamada
+	models  []string          // modelos conhecidos; o primeiro é o default sem model no profile
+}
+
+var providerPresets = map[string]providerPreset{
+	"bedrock": {}, // não é compatível com a OpenAI: veja bedrock.go
+	"openrouter": {
+		baseURL: "https://openrouter.ai/api/v1",
+		keyEnv:  "OPENROUTER_API_KEY",
+		// identificam o app no ranking do OpenRouter; opcionais
+		headers: map[string]string{"HTTP-Referer": "https://github.com/thiagozs/go-gptcli", "X-Title": "gptcli"},
+		models:  []string{"openai/gpt-5-mini", "anthropic/claude-sonnet-4", "google/gemini-2.5-flash"},
+	},
+	"xai": {
+		baseURL: "https://api.x.ai/v1",
+		keyEnv:  "XAI_API_KEY",
+		models:  []string{"grok-3-mini", "grok-4", "grok-3", "grok-code-fast-1"},
+	},
+	"mistral": {
+		baseURL: "https://api.mistral.ai/v1",
+		keyEnv:  "MISTRAL_API_KEY",
+		models:  []string{"mistral-small-latest", "mistral-medium-latest", "mistral-large-latest", "codestral-latest", "magistral-medium-latest"},
+	},
+	"deepseek": {
+		baseURL: "https://api.deepseek.com/v1",
+		keyEnv:  "DEEPSEEK_API_KEY",
+		models:  []string{"deepseek-chat", "deepseek-reasoner"},
+	},
+	"groq": {
+		baseURL: "https://api.groq.com/openai/v1",
+		keyEnv:  "GROQ_API_KEY",
+		models:  []string{"llama-3.3-70b-versatile", "llama-3.1-8b-instant", "openai/gpt-oss-120b", "moonshotai/kimi-k2-instruct"},
+	},
+}
+
+func lookupProvider(name string) (providerPreset, error) {
+	p, ok := providerPresets[name]
+	if !ok {
+		names := sortedKeys(providerPresets)
+		return p, fmt.Errorf("provider desconhecido %q (disponíveis: %s)", name, strings.Join(names, ", "))
+	}
+	return p, nil
+}
+
+// defaultModel é o primeiro modelo conhecido do provedor ("" sem provider).
+func (p providerPreset) defaultModel() string {
+	if len(p.models) == 0 {
+		return ""
+	}
+	return p.models[0]
+}
+
+// providersCmd lista os presets: base_url, variável da chave (e se ela está
+// definida) e os modelos conhecidos.
+func providersCmd(args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("uso: gptcli providers")
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PROVIDER\tBASE_URL\tCHAVE\tMODELOS")
+	for _, name := range sortedKeys(providerPresets) {
+		p := providerPresets[name]
+		if name == "bedrock" {
+			fmt.Fprintln(tw, "bedrock\t(região da AWS)\tcredenciais AWS\t(model ID do Bedrock)")
+			continue
+		}
+		key := p.keyEnv
+		if os.Getenv(p.keyEnv) == "" {
+			key += " (não definida)"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", name, p.baseURL, key, strings.Join(p.models, ", "))
+	}
+	return tw.Flush()
+}
+
+// ---------- OpenRouter ----------
+
+// OpenRouterConfig são as preferências de roteamento do OpenRouter no profile.
+type OpenRouterConfig struct {
+	Route    string         `yaml:"route,omitempty"`    // fallback: tenta os models em ordem
+	Models   []string       `yaml:"models,omitempty"`   // modelos reserva, depois do model do profile
+	Provider map[string]any `yaml:"provider,omitempty"` // preferências de provedor, repassadas como vieram (order, allow_fallbacks, sort, ignore...)
+}
+
+func (c OpenRouterConfig) validate() error {
+	switch c.Route {
+	case "", "fallback":
+		return nil
+	}
+	return fmt.Errorf("openrouter.route inválido %q (use fallback)", c.Route)
+}
+
+// chatExtras devolve os campos que o OpenRouter espera no corpo do chat. A
+// lista models leva o modelo do pedido na frente.
+func (c OpenRouterConfig) chatExtras(model string) map[string]any {
+	extras := map[string]any{}
+	if c.Route == "fallback" || len(c.Models) > 0 {
+		extras["models"] = append([]string{model}, c.Models...)
+	}
+	if c.Route != "" {
+		extras["route"] = c.Route
+	}
+	if len(c.Provider) > 0 {
+		extras["provider"] = c.Provider
+	}
+	return extras
+}
+
+// openRouterModel é um item de GET /models do OpenRouter; os preços vêm em
+// US$ por token, como texto.
+type openRouterModel struct {
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	ContextLength int    `json:"context_length"`
+	Pricing       struct {
+		Prompt     string `json:"prompt"`
+		Completion string `json:"completion"`
+	} `json:"pricing"`
+}
+
+func (m openRouterModel) info() modelInfo {
+	in, _ := strconv.ParseFloat(m.Pricing.Prompt, 64)
+	out, _ := strconv.ParseFloat(m.Pricing.Completion, 64)
+	return modelInfo{window: m.ContextLength, inPerMillion: in * 1e6, outPerMillion: out * 1e6}
+}
+
+func openRouterCachePath() string { return filepath.Join(stateDir(), "openrouter-models.json") }
+
+var (
+	openRouterOnce   sync.Once
+	openRouterModels map[string]modelInfo
+)
+
+// openRouterModelInfo consulta a lista guardada pelo último `gptcli
+// openrouter models`, para o custo de modelos fora da tabela da OpenAI.
+func openRouterModelInfo(model string) (modelInfo, bool) {
+	openRouterOnce.Do(func() {
+		var list []openRouterModel
+		if data, err := os.ReadFile(openRouterCachePath()); err == nil && json.Unmarshal(data, &list) == nil {
+			openRouterModels = map[string]modelInfo{}
+			for _, m := range list {
+				openRouterModels[m.ID] = m.info()
+			}
+		}
+	})
+	info, ok := openRouterModels[model]
+	return info, ok
+}
+
+func fetchOpenRouterModels(ctx context.Context, baseURL, proxy string) ([]openRouterModel, error) {
+	hc, err := sharedHTTPClient(proxy)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(baseURL, "/")+"/models", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "gptcli")
+	resp, err := hc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: HTTP %d", req.URL, resp.StatusCode)
+	}
+	var page struct {
+		Data []openRouterModel `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return nil, err
+	}
+	return page.Data, nil
+}
+
+const openRouterUsage = `uso: gptcli openrouter models [--search texto] [--sort name|price|context]
+  models   lista os modelos do OpenRouter com janela de contexto e preço por milhão de tokens
+`
+
+func openRouterCmd(args []string) error {
+	if len(args) == 0 || args[0] != "models" {
+		fmt.Fprint(os.Stderr, openRouterUsage)
+		return errors.New("subcomando inválido")
+	}
+	fs := flag.NewFlagSet("openrouter models", flag.ExitOnError)
+	search := fs.String("search", "", "filtra pelo id ou nome (sem diferenciar maiúsculas)")
+	sortBy := fs.String("sort", "name", "ordem: name, price (entrada mais barata primeiro) ou context (maior primeiro)")
+	baseURL := fs.String("base-url", providerPresets["openrouter"].baseURL, "base da API do OpenRouter")
+	proxy := fs.String("proxy", "", "HTTP(S) proxy")
+	_ = fs.Parse(args[1:])
+
+	models, err := fetchOpenRouterModels(context.Background(), *baseURL, *proxy)
+	if err != nil {
+		return err
+	}
+	if !ephemeralFromEnv() {
+		if data, err := json.Marshal(models); err == nil {
+			ensureDir(stateDir())
+			_ = os.WriteFile(openRouterCachePath(), data, 0o600)
+		}
+	}
+
+	q := strings.ToLower(*search)
+	var shown []openRouterModel
+	for _, m := range models {
+		if q == "" || strings.Contains(strings.ToLower(m.ID+" "+m.Name), q) {
+			shown = append(shown, m)
+		}
+	}
+	switch *sortBy {
+	case "name":
+		sort.Slice(shown, func(i, j int) bool { return shown[i].ID < shown[j].ID })
+	case "price":
+		sort.SliceStable(shown, func(i, j int) bool { return shown[i].info().inPerMillion < shown[j].info().inPerMillion })
+	case "context":
+		sort.SliceStable(shown, func(i, j int) bool { return shown[i].ContextLength > shown[j].ContextLength })
+	default:
+		return fmt.Errorf("--sort inválido %q (use name, price ou context)", *sortBy)
+	}
+	if len(shown) == 0 {
+		fmt.Println("(nenhum modelo encontrado)")
+		return nil
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "MODELO\tCONTEXTO\tENTRADA US$/M\tSAÍDA US$/M")
+	for _, m := range shown {
+		info := m.info()
+		fmt.Fprintf(tw, "%s\t%d\t%.2f\t%.2f\n", m.ID, m.ContextLength, info.inPerMillion, info.outPerMillion)
+	}
+	tw.Flush()
+	fmt.Fprintf(os.Stderr, "(%d de %d modelos)\n", len(shown), len(models))
+	return nil
+}
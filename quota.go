@@ -0,0 +1,311 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	openai "github.com/openai/openai-go/v2"
+	"github.com/openai/openai-go/v2/option"
+)
+
+// ===================== Quota =====================
+//
+// `gptcli quota` mostra quanto ainda dá para gastar: o gasto do mês contra o
+// `budget_usd` do config, os limites de requisição vistos nos cabeçalhos
+// x-ratelimit-* das últimas respostas e o gasto recente por modelo. Com uma
+// chave de admin (--admin-key ou OPENAI_ADMIN_KEY), os números vêm dos
+// endpoints de uso e custo da organização; sem ela, do registro local
+// (log.jsonl), que só conhece as chamadas feitas deste computador.
+
+// modelSpend é o consumo de um modelo numa janela.
+type modelSpend struct {
+	model         string
+	calls         int64
+	input, output int64
+}
+
+func (m modelSpend) cost() (float64, bool) {
+	info, ok := lookupModel(m.model)
+	if !ok {
+		return 0, false
+	}
+	return (float64(m.input)*info.inPerMillion + float64(m.output)*info.outPerMillion) / 1e6, true
+}
+
+func quotaCmd(args []string) error {
+	fs := flag.NewFlagSet("quota", flag.ExitOnError)
+	flags := commonFlags(fs)
+	days := fs.Int("days", 7, "janela do gasto por modelo, em dias (1 a 31)")
+	adminKey := fs.String("admin-key", "", "chave de admin da organização, para os endpoints de uso e custo (default: $OPENAI_ADMIN_KEY)")
+	local := fs.Bool("local", false, "usa só o registro local, sem consultar a organização")
+	_ = fs.Parse(args)
+	if *days < 1 || *days > 31 {
+		return errors.New("--days deve estar entre 1 e 31")
+	}
+	st, client, err := resolveClient(flags)
+	if err != nil {
+		return err
+	}
+	key := chooseNonEmpty(*adminKey, os.Getenv("OPENAI_ADMIN_KEY"))
+	now := time.Now()
+	since := now.AddDate(0, 0, -*days)
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+
+	from := monthStart
+	if since.Before(from) {
+		from = since
+	}
+	entries, err := readAppLog(from)
+	if err != nil {
+		return err
+	}
+
+	source := "registro local"
+	var spend []modelSpend
+	var monthCost float64
+	monthKnown := false
+	if key != "" && !*local {
+		ctx := context.Background()
+		spend, err = orgUsageByModel(ctx, client, key, since)
+		if err == nil {
+			monthCost, err = orgCosts(ctx, client, key, monthStart)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "aviso: uso da organização indisponível (%v); usando o registro local\n", err)
+			spend = nil
+		} else {
+			source, monthKnown = "organização", true
+		}
+	}
+	if !monthKnown {
+		spend = localSpend(entries, since)
+		for _, m := range localSpend(entries, monthStart) {
+			c, _ := m.cost()
+			monthCost += c
+		}
+	}
+
+	fmt.Println("Orçamento")
+	fmt.Printf("  gasto no mês:  US$ %.2f (%s)\n", monthCost, source)
+	if st.budget > 0 {
+		fmt.Printf("  limite:        US$ %.2f (budget_usd)\n", st.budget)
+		fmt.Printf("  restante:      US$ %.2f (%.0f%%)\n", st.budget-monthCost, 100*(st.budget-monthCost)/st.budget)
+	} else {
+		fmt.Println("  (defina budget_usd no config para ver o restante)")
+	}
+	if !monthKnown {
+		fmt.Println("  (estimado pelos preços públicos; só conta as chamadas deste computador)")
+	}
+
+	fmt.Println("\nLimites de requisição (x-ratelimit-* da última resposta de cada modelo)")
+	printRateLimits(entries)
+
+	fmt.Printf("\nGasto por modelo (últimos %d dia(s), %s)\n", *days, source)
+	printModelSpend(spend)
+	return nil
+}
+
+// readAppLog lê as entradas do log.jsonl a partir de since.
+func readAppLog(since time.Time) ([]*logEntry, error) {
+	f, err := os.Open(appLogPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var out []*logEntry
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64<<10), 4<<20)
+	for sc.Scan() {
+		e := &logEntry{}
+		if json.Unmarshal(sc.Bytes(), e) != nil {
+			continue
+		}
+		if t, err := time.Parse(time.RFC3339, e.Time); err == nil && !t.Before(since) {
+			e.started = t
+			out = append(out, e)
+		}
+	}
+	return out, sc.Err()
+}
+
+func localSpend(entries []*logEntry, since time.Time) []modelSpend {
+	by := map[string]*modelSpend{}
+	for _, e := range entries {
+		if e.Model == "" || e.TotalTokens == 0 || e.started.Before(since) {
+			continue
+		}
+		m := by[e.Model]
+		if m == nil {
+			m = &modelSpend{model: e.Model}
+			by[e.Model] = m
+		}
+		m.calls++
+		m.input += e.PromptTokens
+		m.output += e.CompletionTokens
+	}
+	out := make([]modelSpend, 0, len(by))
+	for _, m := range by {
+		out = append(out, *m)
+	}
+	return out
+}
+
+func printRateLimits(entries []*logEntry) {
+	latest := map[string]*logEntry{}
+	for _, e := range entries {
+		if e.RateLimits != nil && e.Model != "" {
+			latest[e.Model] = e // o log é cronológico: fica a última
+		}
+	}
+	if len(latest) == 0 {
+		fmt.Println("  nenhuma resposta com esses cabeçalhos no registro")
+		return
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "  MODELO\tREQUISIÇÕES\tTOKENS\tVISTO EM")
+	for _, model := range sortedKeys(latest) {
+		e := latest[model]
+		rl := e.RateLimits
+		fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\n", model,
+			remaining(rl.RemainingRequests, rl.LimitRequests, rl.ResetRequests),
+			remaining(rl.RemainingTokens, rl.LimitTokens, rl.ResetTokens),
+			e.started.Local().Format("2006-01-02 15:04"))
+	}
+	tw.Flush()
+}
+
+func remaining(left, limit int64, reset string) string {
+	if limit == 0 {
+		return "-"
+	}
+	s := fmt.Sprintf("%d/%d", left, limit)
+	if reset != "" {
+		s += " (reinicia em " + reset + ")"
+	}
+	return s
+}
+
+func printModelSpend(spend []modelSpend) {
+	if len(spend) == 0 {
+		fmt.Println("  nenhum consumo na janela")
+		return
+	}
+	sort.Slice(spend, func(i, j int) bool {
+		ci, _ := spend[i].cost()
+		cj, _ := spend[j].cost()
+		if ci != cj {
+			return ci > cj
+		}
+		return spend[i].model < spend[j].model
+	})
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "  MODELO\tCHAMADAS\tENTRADA\tSAÍDA\tCUSTO")
+	var total float64
+	unpriced := false
+	for _, m := range spend {
+		cost := "-"
+		if c, ok := m.cost(); ok {
+			cost = fmt.Sprintf("US$ %.4f", c)
+			total += c
+		} else {
+			unpriced = true
+		}
+		fmt.Fprintf(tw, "  %s\t%d\t%d\t%d\t%s\n", m.model, m.calls, m.input, m.output, cost)
+	}
+	suffix := ""
+	if unpriced {
+		suffix = "+" // algum modelo sem preço conhecido: o total é um piso
+	}
+	fmt.Fprintf(tw, "  total\t\t\t\tUS$ %.4f%s\n", total, suffix)
+	tw.Flush()
+}
+
+// ---------- endpoints da organização ----------
+
+// orgPage é a página de buckets dos endpoints /organization/usage e /costs.
+type orgPage[T any] struct {
+	Data []struct {
+		Results []T `json:"results"`
+	} `json:"data"`
+	HasMore  bool   `json:"has_more"`
+	NextPage string `json:"next_page"`
+}
+
+// orgGet percorre as páginas de um endpoint da organização com a chave de admin.
+func orgGet[T any](ctx context.Context, client openai.Client, adminKey, path string, q url.Values) ([]T, error) {
+	var out []T
+	for {
+		var page orgPage[T]
+		if err := client.Get(ctx, path+"?"+q.Encode(), nil, &page, option.WithAPIKey(adminKey)); err != nil {
+			return nil, err
+		}
+		for _, b := range page.Data {
+			out = append(out, b.Results...)
+		}
+		if !page.HasMore || page.NextPage == "" {
+			return out, nil
+		}
+		q.Set("page", page.NextPage)
+	}
+}
+
+func orgUsageByModel(ctx context.Context, client openai.Client, adminKey string, since time.Time) ([]modelSpend, error) {
+	type result struct {
+		Model        string `json:"model"`
+		InputTokens  int64  `json:"input_tokens"`
+		OutputTokens int64  `json:"output_tokens"`
+		Requests     int64  `json:"num_model_requests"`
+	}
+	q := url.Values{"start_time": {strconv.FormatInt(since.Unix(), 10)}, "bucket_width": {"1d"}, "group_by": {"model"}, "limit": {"31"}}
+	results, err := orgGet[result](ctx, client, adminKey, "organization/usage/completions", q)
+	if err != nil {
+		return nil, err
+	}
+	by := map[string]*modelSpend{}
+	for _, r := range results {
+		m := by[r.Model]
+		if m == nil {
+			m = &modelSpend{model: chooseNonEmpty(r.Model, "(sem modelo)")}
+			by[r.Model] = m
+		}
+		m.calls += r.Requests
+		m.input += r.InputTokens
+		m.output += r.OutputTokens
+	}
+	out := make([]modelSpend, 0, len(by))
+	for _, m := range by {
+		out = append(out, *m)
+	}
+	return out, nil
+}
+
+func orgCosts(ctx context.Context, client openai.Client, adminKey string, since time.Time) (float64, error) {
+	type result struct {
+		Amount struct {
+			Value float64 `json:"value"`
+		} `json:"amount"`
+	}
+	q := url.Values{"start_time": {strconv.FormatInt(since.Unix(), 10)}, "bucket_width": {"1d"}, "limit": {"31"}}
+	results, err := orgGet[result](ctx, client, adminKey, "organization/costs", q)
+	if err != nil {
+		return 0, err
+	}
+	var total float64
+	for _, r := range results {
+		total += r.Amount.Value
+	}
+	return total, nil
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	openai "github.com/openai/openai-go/v2"
+)
+
+// ===================== Rate limits =====================
+//
+// Cada resposta da API traz x-ratelimit-*: quanto resta da cota de
+// requisições e de tokens e em quanto tempo ela se renova. O rateBook guarda a
+// última leitura de cada modelo (em memória e em ratelimits.json, para a
+// próxima execução já começar sabendo), avisa uma vez quando sobra pouco e,
+// antes de cada requisição, espaça as chamadas para a cota durar até a
+// renovação em vez de esbarrar num 429.
+
+// rateLimits são os cabeçalhos x-ratelimit-* de uma resposta.
+type rateLimits struct {
+	LimitRequests     int64  `json:"limit_requests,omitempty"`
+	RemainingRequests int64  `json:"remaining_requests,omitempty"`
+	ResetRequests     string `json:"reset_requests,omitempty"`
+	LimitTokens       int64  `json:"limit_tokens,omitempty"`
+	RemainingTokens   int64  `json:"remaining_tokens,omitempty"`
+	ResetTokens       string `json:"reset_tokens,omitempty"`
+}
+
+// parseRateLimits devolve nil se a resposta não trouxer os cabeçalhos.
+func parseRateLimits(h http.Header) *rateLimits {
+	if h.Get("x-ratelimit-limit-requests") == "" && h.Get("x-ratelimit-limit-tokens") == "" {
+		return nil
+	}
+	num := func(name string) int64 {
+		n, _ := strconv.ParseInt(h.Get(name), 10, 64)
+		return n
+	}
+	return &rateLimits{
+		LimitRequests:     num("x-ratelimit-limit-requests"),
+		RemainingRequests: num("x-ratelimit-remaining-requests"),
+		ResetRequests:     h.Get("x-ratelimit-reset-requests"),
+		LimitTokens:       num("x-ratelimit-limit-tokens"),
+		RemainingTokens:   num("x-ratelimit-remaining-tokens"),
+		ResetTokens:       h.Get("x-ratelimit-reset-tokens"),
+	}
+}
+
+// lowRateFraction: abaixo de 1/10 da cota, avisa e começa a espaçar.
+const lowRateFraction = 10
+
+// maxRatePause limita cada espera do pacing; o 429, se vier, cai no retry.
+const maxRatePause = 30 * time.Second
+
+// rateReading é a última leitura de um modelo.
+type rateReading struct {
+	rateLimits
+	Seen time.Time `json:"seen"`
+}
+
+type rateWindow struct {
+	kind         string // "requisições" | "tokens"
+	left, limit  int64
+	resetAt      time.Time
+	resetPending bool // a janela ainda não renovou
+}
+
+func (r rateReading) windows(now time.Time) []rateWindow {
+	var out []rateWindow
+	for _, w := range []struct {
+		kind        string
+		left, limit int64
+		reset       string
+	}{
+		{"requisições", r.RemainingRequests, r.LimitRequests, r.ResetRequests},
+		{"tokens", r.RemainingTokens, r.LimitTokens, r.ResetTokens},
+	} {
+		if w.limit == 0 {
+			continue
+		}
+		d, _ := time.ParseDuration(w.reset)
+		at := r.Seen.Add(d)
+		out = append(out, rateWindow{w.kind, w.left, w.limit, at, at.After(now)})
+	}
+	return out
+}
+
+type rateBook struct {
+	mu       sync.Mutex
+	loaded   bool
+	readings map[string]rateReading // "host modelo" => última leitura
+	warned   map[string]bool
+}
+
+var rates = &rateBook{}
+
+func rateBookPath() string { return filepath.Join(stateDir(), "ratelimits.json") }
+
+func (b *rateBook) load() {
+	if b.loaded {
+		return
+	}
+	b.loaded = true
+	b.readings, b.warned = map[string]rateReading{}, map[string]bool{}
+	if data, err := os.ReadFile(rateBookPath()); err == nil {
+		_ = json.Unmarshal(data, &b.readings)
+	}
+}
+
+// record guarda a leitura de uma resposta e avisa se a cota está no fim.
+func (b *rateBook) record(key, model string, rl *rateLimits) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.load()
+	r := rateReading{rateLimits: *rl, Seen: time.Now()}
+	b.readings[key] = r
+	for _, w := range r.windows(r.Seen) {
+		if w.left*lowRateFraction < w.limit && !b.warned[key+w.kind] {
+			b.warned[key+w.kind] = true
+			fmt.Fprintf(os.Stderr, "aviso: restam %d de %d %s por janela para %s (renova em %s)\n",
+				w.left, w.limit, w.kind, chooseNonEmpty(model, "este endpoint"), w.resetAt.Sub(r.Seen).Round(time.Millisecond))
+		}
+	}
+	if ephemeral {
+		return
+	}
+	if data, err := json.Marshal(b.readings); err == nil {
+		ensureDir(stateDir())
+		_ = os.WriteFile(rateBookPath(), data, 0o600)
+	}
+}
+
+// delay diz quanto esperar antes da próxima requisição: até a renovação se a
+// cota acabou; abaixo de 1/10, o tempo até a renovação dividido pelo que resta.
+func (b *rateBook) delay(key string, now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.load()
+	r, ok := b.readings[key]
+	if !ok {
+		return 0
+	}
+	var d time.Duration
+	for _, w := range r.windows(now) {
+		if !w.resetPending {
+			continue
+		}
+		wait := time.Duration(0)
+		switch {
+		case w.left <= 0:
+			wait = w.resetAt.Sub(now)
+		case w.left*lowRateFraction < w.limit:
+			wait = w.resetAt.Sub(now) / time.Duration(w.left+1)
+		}
+		d = max(d, wait)
+	}
+	if r.RemainingRequests > 0 {
+		r.RemainingRequests-- // chamadas em paralelo não esperam todas o mesmo tanto
+		b.readings[key] = r
+	}
+	return min(d, maxRatePause)
+}
+
+// pace espera o delay do modelo, ou até o contexto acabar.
+func (b *rateBook) pace(ctx context.Context, key, model string) error {
+	d := b.delay(key, time.Now())
+	if d <= 0 {
+		return nil
+	}
+	if d >= time.Second {
+		fmt.Fprintf(os.Stderr, "(aguardando %s pelo limite de requisições de %s)\n", d.Round(100*time.Millisecond), model)
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// retryDelay usa o retry-after (ou o x-ratelimit-reset-*) de um 429 como
+// espera mínima antes da próxima tentativa.
+func retryDelay(err error, backoff time.Duration) time.Duration {
+	var apiErr *openai.Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 429 || apiErr.Response == nil {
+		return backoff
+	}
+	h := apiErr.Response.Header
+	var wait time.Duration
+	if ms, err := strconv.ParseFloat(h.Get("retry-after-ms"), 64); err == nil {
+		wait = time.Duration(ms * float64(time.Millisecond))
+	} else if s, err := strconv.ParseFloat(h.Get("retry-after"), 64); err == nil {
+		wait = time.Duration(s * float64(time.Second))
+	} else if rl := parseRateLimits(h); rl != nil {
+		for _, r := range []struct {
+			left  int64
+			reset string
+		}{{rl.RemainingRequests, rl.ResetRequests}, {rl.RemainingTokens, rl.ResetTokens}} {
+			if d, err := time.ParseDuration(r.reset); err == nil && r.left <= 0 {
+				wait = max(wait, d)
+			}
+		}
+	}
+	return min(max(backoff, wait), time.Minute)
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	openai "github.com/openai/openai-go/v2"
+	"github.com/openai/openai-go/v2/shared"
+)
+
+// ===================== Recusas =====================
+//
+// Uma resposta recusada (campo refusal do stream ou finish_reason
+// content_filter) não é uma resposta: no modo não interativo o gptcli avisa
+// e sai com exitRefused, para scripts não tratarem a recusa como resultado.
+// --on-refusal retry-softer reformula o pedido uma vez antes de desistir e
+// também trata como recusa um "não posso ajudar" curto; ignore mantém o
+// comportamento antigo.
+
+// exitRefused sinaliza que o modelo ou o provedor recusou o pedido.
+const exitRefused = 3
+
+var onRefusalModes = []string{"fail", "retry-softer", "ignore"}
+
+func validOnRefusal(v string) error {
+	if v == "" || containsString(onRefusalModes, v) {
+		return nil
+	}
+	return fmt.Errorf("--on-refusal inválido %q (use %s)", v, strings.Join(onRefusalModes, ", "))
+}
+
+// refusalError é devolvido por askTurn quando a resposta foi uma recusa.
+type refusalError struct{ reason string }
+
+func (e *refusalError) Error() string { return "o pedido foi recusado (" + e.reason + ")" }
+
+func isRefusal(err error) bool {
+	var re *refusalError
+	return errors.As(err, &re)
+}
+
+// refusalPhrases são começos típicos de recusa; só valem em respostas curtas,
+// para não pegar uma explicação que cita a frase.
+var refusalPhrases = []string{
+	"i can't help", "i cannot help", "i can't assist", "i cannot assist", "i'm sorry, but i can't",
+	"i'm sorry, but i cannot", "i won't be able to help", "i'm unable to help", "i can't provide", "i cannot provide",
+	"não posso ajudar", "não consigo ajudar", "desculpe, mas não posso", "sinto muito, mas não posso",
+	"não posso fornecer", "não posso atender",
+}
+
+// refusalReason diz por que a última resposta conta como recusa ("" = não conta).
+// O texto só é olhado com retry-softer: no fail, uma resposta parcial válida
+// ("I can't provide the exact figure, but...") sairia com erro.
+func refusalReason(sess *Session, answer string) string {
+	switch {
+	case sess.refusal != "":
+		return sess.refusal
+	case sess.OnRefusal == "retry-softer" && refusalText(answer):
+		return "recusa no texto"
+	}
+	return ""
+}
+
+func refusalText(answer string) bool {
+	text := strings.ToLower(strings.TrimSpace(answer))
+	if len(text) > 400 {
+		return false
+	}
+	text = strings.ReplaceAll(text, "’", "'")
+	for _, p := range refusalPhrases {
+		if strings.HasPrefix(text, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// softenPrompt pede a um modelo que reescreva o pedido de forma neutra,
+// deixando explícito o propósito legítimo, sem mudar o que se quer saber.
+func softenPrompt(ctx context.Context, client openai.Client, model, prompt string) (string, error) {
+	params := openai.ChatCompletionNewParams{
+		Model: shared.ChatModel(model),
+		Messages: []openai.ChatCompletionMessageParamUnion{
+			openai.SystemMessage("Reescreva o pedido do usuário para que ele não seja recusado por engano: tom neutro, " +
+				"contexto e propósito legítimos explícitos, sem pedir nada perigoso. Mantenha a língua e o que se quer saber. " +
+				"Responda só com o pedido reescrito."),
+			openai.UserMessage(prompt),
+		},
+	}
+	var out string
+	err := withRetries(ctx, 3, func() error {
+		resp, err := client.Chat.Completions.New(ctx, params)
+		if err != nil {
+			return err
+		}
+		if len(resp.Choices) == 0 || strings.TrimSpace(resp.Choices[0].Message.Content) == "" {
+			return errors.New("resposta vazia")
+		}
+		noteUsage(ctx, resp.Usage)
+		out = strings.TrimSpace(resp.Choices[0].Message.Content)
+		return nil
+	})
+	if err == nil && refusalText(out) {
+		err = errors.New("a reformulação também foi recusada")
+	}
+	return out, err
+}
+
+// handleRefusal aplica --on-refusal a uma resposta recusada. Devolve o novo
+// prompt quando vale tentar de novo ("" = não tentar).
+func handleRefusal(ctx context.Context, client openai.Client, sess *Session, model, prompt, reason string, retried bool) (string, error) {
+	mode := chooseNonEmpty(sess.OnRefusal, "fail")
+	if mode == "ignore" {
+		return "", nil
+	}
+	if mode == "retry-softer" && !retried {
+		softer, err := softenPrompt(ctx, client, model, prompt)
+		if err == nil {
+			fmt.Fprintf(os.Stderr, "(pedido recusado: %s; tentando de novo reformulado: %s)\n", reason, truncate(softer, 200))
+			return softer, nil
+		}
+		fmt.Fprintf(os.Stderr, "aviso: não deu para reformular o pedido: %v\n", err)
+	}
+	return "", &refusalError{reason: reason}
+}
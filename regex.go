@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// ===================== Regex =====================
+//
+// `gptcli regex "datas ISO"` pede uma expressão regular, confere se ela
+// compila no regexp do Go (RE2) e a testa nas linhas do stdin, se houver.
+// `gptcli regex --explain '<padrão>'` faz o caminho inverso.
+
+const regexSystem = `Você escreve expressões regulares para o pacote regexp do Go (sintaxe RE2):
+não há lookahead, lookbehind nem backreferences. Responda exatamente neste formato:
+
+REGEX: <o padrão, numa linha, sem barras nem aspas em volta>
+<uma a três frases explicando as partes do padrão>
+
+Prefira padrões simples e ancorados quando o pedido for validar a linha inteira.`
+
+const regexExplainSystem = `Você explica expressões regulares. Explique o padrão parte por parte, numa
+lista curta, e termine com dois exemplos que casam e um que não casa. Se o padrão usar
+recursos que o regexp do Go (RE2) não aceita, diga qual e sugira uma alternativa.
+Responda no idioma da pergunta; sem pergunta, em português.`
+
+// regexAttempts: se o padrão não compilar, o erro volta para o modelo.
+const regexAttempts = 3
+
+// maxRegexSamples limita as linhas do stdin usadas como exemplo e teste.
+const maxRegexSamples = 50
+
+var regexAnswerRe = regexp.MustCompile(`(?m)^\s*REGEX:\s*(.+?)\s*$`)
+
+func regexCmd(args []string) error {
+	fs := flag.NewFlagSet("regex", flag.ExitOnError)
+	flags := commonFlags(fs)
+	explain := fs.String("explain", "", "explica este padrão em vez de gerar um")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, `uso: gptcli regex [flags] "descrição do que casar"`)
+		fmt.Fprintln(os.Stderr, `     gptcli regex --explain '<padrão>'`)
+		fmt.Fprintln(os.Stderr, "     linhas no stdin viram exemplos e são testadas contra o padrão")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	desc := strings.TrimSpace(strings.Join(fs.Args(), " "))
+	if desc == "" && *explain == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	var samples []string
+	if isPiped() {
+		text, err := readAllStdin()
+		if err != nil {
+			return err
+		}
+		for _, l := range strings.Split(text, "\n") {
+			if len(samples) == maxRegexSamples {
+				fmt.Fprintf(os.Stderr, "(usando só as primeiras %d linhas do stdin)\n", maxRegexSamples)
+				break
+			}
+			samples = append(samples, strings.TrimRight(l, "\r"))
+		}
+	}
+
+	cfg, _ := loadConfig()
+	st, err := resolveSettings(cfg, flags)
+	if err != nil {
+		return err
+	}
+	initAppLog(st.logLevel, st.profName, st.personaName)
+	configureOutput(st)
+	client, err := buildClient(st)
+	if err != nil {
+		return err
+	}
+	ctx := withRetryConfig(context.Background(), st.retry)
+
+	if *explain != "" {
+		re, compileErr := regexp.Compile(*explain)
+		sess := &Session{}
+		sess.addSystem(chooseNonEmpty(flags.System, regexExplainSystem))
+		prompt := "Padrão: " + *explain
+		if compileErr != nil {
+			prompt += "\n\nNo regexp do Go ele não compila: " + compileErr.Error()
+		}
+		if desc != "" {
+			prompt += "\n\nPergunta: " + desc
+		}
+		sess.addUser(prompt)
+		err = logOp(ctx, "regex", st.model, func(ctx context.Context) error {
+			return withRetries(ctx, 4, func() error {
+				_, err := streamOnce(ctx, client, sess, st.model, st.temp, st.maxTokens)
+				return err
+			})
+		})
+		flushTelemetry()
+		if err != nil {
+			return err
+		}
+		if compileErr != nil {
+			fmt.Fprintln(os.Stderr, "(o padrão não compila no regexp do Go:", compileErr.Error()+")")
+		} else if len(samples) > 0 {
+			fmt.Println()
+			printRegexMatches(re, samples)
+		}
+		return nil
+	}
+
+	sess := &Session{}
+	sess.addSystem(chooseNonEmpty(flags.System, regexSystem))
+	prompt := "Quero uma regex que case: " + desc
+	if len(samples) > 0 {
+		prompt += "\n\nLinhas de exemplo (nem todas precisam casar):\n" + strings.Join(samples, "\n")
+	}
+	sess.addUser(prompt)
+
+	var re *regexp.Regexp
+	var note string
+	err = logOp(ctx, "regex", st.model, func(ctx context.Context) error {
+		for attempt := 1; ; attempt++ {
+			var out string
+			err := withRetries(ctx, 4, func() error {
+				var err error
+				out, err = streamChat(ctx, client, sess, st.model, st.temp, st.maxTokens, func(string) {})
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			pattern, rest, perr := parseRegexAnswer(out)
+			if perr == nil {
+				re, perr = regexp.Compile(pattern)
+			}
+			if perr == nil {
+				note = rest
+				return nil
+			}
+			if attempt == regexAttempts {
+				return fmt.Errorf("o modelo não gerou um padrão válido: %w", perr)
+			}
+			fmt.Fprintf(os.Stderr, "(padrão inválido: %v; pedindo outro)\n", perr)
+			sess.addAssistant(out)
+			sess.addUser("Esse padrão não serve: " + perr.Error() + ". Responda de novo no formato pedido, com um padrão RE2 válido.")
+		}
+	})
+	flushTelemetry()
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(re.String())
+	if note != "" {
+		fmt.Fprintln(os.Stderr, note)
+	}
+	if len(samples) > 0 {
+		fmt.Println()
+		printRegexMatches(re, samples)
+	}
+	return nil
+}
+
+// parseRegexAnswer separa a linha "REGEX: ..." da explicação.
+func parseRegexAnswer(out string) (pattern, rest string, err error) {
+	out = stripCodeFence(out)
+	loc := regexAnswerRe.FindStringSubmatchIndex(out)
+	if loc == nil {
+		return "", "", errors.New("resposta sem a linha REGEX:")
+	}
+	pattern = strings.Trim(out[loc[2]:loc[3]], "`")
+	if len(pattern) > 1 && pattern[0] == '/' && strings.HasSuffix(pattern, "/") {
+		pattern = pattern[1 : len(pattern)-1] // estilo /.../ do JavaScript
+	}
+	rest = strings.TrimSpace(out[:loc[0]] + out[loc[1]:])
+	return pattern, rest, nil
+}
+
+// printRegexMatches marca cada linha com ✓/✗; em terminal, o trecho casado
+// fica em negrito.
+func printRegexMatches(re *regexp.Regexp, lines []string) {
+	bold, reset := "", ""
+	if fi, err := os.Stdout.Stat(); err == nil && fi.Mode()&os.ModeCharDevice != 0 && os.Getenv("NO_COLOR") == "" {
+		bold, reset = "\033[1m", "\033[0m"
+	}
+	hits := 0
+	for _, l := range lines {
+		if !re.MatchString(l) {
+			fmt.Printf("✗ %s\n", l)
+			continue
+		}
+		hits++
+		if bold != "" {
+			l = re.ReplaceAllStringFunc(l, func(m string) string { return bold + m + reset })
+		}
+		fmt.Printf("✓ %s\n", l)
+	}
+	fmt.Fprintf(os.Stderr, "(%d de %d linha(s) casaram)\n", hits, len(lines))
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// ===================== Release Notes =====================
+//
+// `gptcli release-notes v1.2.0..HEAD` junta as mensagens de commit do
+// intervalo (e, com --diff, o diff) e pede notas de versão agrupadas no
+// formato escolhido por --template.
+
+const releaseNotesSystem = `Você escreve notas de versão a partir de commits. Agrupe as mudanças por tipo,
+reescreva cada item numa frase curta voltada a quem usa o projeto (não a quem o
+desenvolve) e junte commits que tratam da mesma coisa. Omita commits sem efeito
+visível (merges, typos, CI, refatorações internas) a menos que não sobre nada.
+Não invente mudanças que não estejam nos commits. Responda só com as notas.`
+
+// releaseTemplates descreve o formato de saída de cada --template.
+var releaseTemplates = map[string]string{
+	"keep-a-changelog": `Use o formato Keep a Changelog (https://keepachangelog.com): um cabeçalho
+"## [%s] - %s" seguido das seções "### Added", "### Changed", "### Deprecated",
+"### Removed", "### Fixed" e "### Security", só as que tiverem itens, com itens em "- ".`,
+	"github": `Use o formato de corpo de release do GitHub: "## Destaques" com 1 a 3 itens
+principais, depois "## Novidades", "## Correções" e "## Outras mudanças" (só as que
+tiverem itens), com itens em "- " e o hash curto do commit entre parênteses no fim.
+A versão é %s (%s); não repita um título com ela.`,
+}
+
+// maxReleaseDiff limita o diff enviado com --diff.
+const maxReleaseDiff = 60 << 10
+
+func releaseNotesCmd(args []string) error {
+	fs := flag.NewFlagSet("release-notes", flag.ExitOnError)
+	flags := commonFlags(fs)
+	withDiff := fs.Bool("diff", false, "envia também o diff do intervalo (truncado em 60KB)")
+	tplName := fs.String("template", "keep-a-changelog", "formato: keep-a-changelog ou github")
+	version := fs.String("version", "", "versão no cabeçalho (default: o fim do intervalo, ou Unreleased se for HEAD)")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "uso: gptcli release-notes [flags] <de>..<até>")
+		fmt.Fprintln(os.Stderr, "     ex: gptcli release-notes --template github v1.2.0..HEAD")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 || !strings.Contains(fs.Arg(0), "..") {
+		fs.Usage()
+		os.Exit(2)
+	}
+	rng := fs.Arg(0)
+	tpl, ok := releaseTemplates[*tplName]
+	if !ok {
+		return fmt.Errorf("--template inválido %q (use keep-a-changelog ou github)", *tplName)
+	}
+	if *version == "" {
+		*version = rng[strings.LastIndex(rng, "..")+2:]
+		if *version == "" || *version == "HEAD" {
+			*version = "Unreleased"
+		}
+	}
+
+	// %x1f separa campos e %x1e separa commits: mensagens podem ter qualquer coisa
+	log, err := gitOutput("log", "--no-merges", "--format=%h%x1f%s%x1f%b%x1e", rng, "--")
+	if err != nil {
+		return err
+	}
+	var commits strings.Builder
+	n := 0
+	for _, rec := range strings.Split(log, "\x1e") {
+		f := strings.Split(strings.TrimSpace(rec), "\x1f")
+		if len(f) < 2 {
+			continue
+		}
+		n++
+		fmt.Fprintf(&commits, "- %s %s\n", f[0], f[1])
+		if len(f) > 2 && strings.TrimSpace(f[2]) != "" {
+			for _, l := range strings.Split(strings.TrimSpace(f[2]), "\n") {
+				fmt.Fprintf(&commits, "    %s\n", l)
+			}
+		}
+	}
+	if n == 0 {
+		return fmt.Errorf("nenhum commit em %s", rng)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Commits de %s (%d):\n\n%s", rng, n, commits.String())
+	if *withDiff {
+		diff, err := gitOutput("diff", "--stat", "--patch", rng, "--")
+		if err != nil {
+			return err
+		}
+		if len(diff) > maxReleaseDiff {
+			diff = diff[:maxReleaseDiff] + "\n[diff truncado]"
+		}
+		fmt.Fprintf(&b, "\nDiff:\n```diff\n%s\n```\n", diff)
+	}
+
+	cfg, _ := loadConfig()
+	st, err := resolveSettings(cfg, flags)
+	if err != nil {
+		return err
+	}
+	initAppLog(st.logLevel, st.profName, st.personaName)
+	configureOutput(st)
+	client, err := buildClient(st)
+	if err != nil {
+		return err
+	}
+
+	date := time.Now().Format("2006-01-02")
+	sess := &Session{}
+	sess.addSystem(chooseNonEmpty(flags.System, releaseNotesSystem+"\n\n"+fmt.Sprintf(tpl, *version, date)))
+	sess.addUser(b.String())
+	fmt.Fprintf(os.Stderr, "(%d commit(s) em %s)\n", n, rng)
+
+	ctx := withRetryConfig(context.Background(), st.retry)
+	err = logOp(ctx, "release-notes", st.model, func(ctx context.Context) error {
+		return withRetries(ctx, 4, func() error {
+			_, err := streamOnce(ctx, client, sess, st.model, st.temp, st.maxTokens)
+			return err
+		})
+	})
+	flushTelemetry()
+	return err
+}
+
+// gitOutput roda git no diretório atual e devolve o stdout; o stderr do git
+// vira a mensagem de erro.
+func gitOutput(args ...string) (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", errors.New("git: " + msg)
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return string(out), nil
+}
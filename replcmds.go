@@ -0,0 +1,339 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	openai "github.com/openai/openai-go/v2"
+)
+
+// ===================== REPL: anexos =====================
+//
+// Comandos como /sh e /web trazem conteúdo para a conversa. O conteúdo fica
+// pendente e vai junto da próxima mensagem digitada, como anexo do turno
+// (veja attachments.go).
+
+// maxAttachment limita cada anexo, como a saída das ferramentas.
+const maxAttachment = maxToolOutput
+
+// runLocalShell roda o comando com o ambiente do usuário (não é a ferramenta
+// `shell` do modelo, então não passa pelo sandbox), mostrando a saída enquanto
+// ela é produzida.
+func runLocalShell(ctx context.Context, command string) (string, error) {
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	var out bytes.Buffer
+	w := io.MultiWriter(os.Stdout, &out)
+	cmd.Stdout, cmd.Stderr = w, w
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		// código != 0 é justamente o caso de "rode os testes e veja por que falham"
+		if out.Len() > 0 && !bytes.HasSuffix(out.Bytes(), []byte("\n")) {
+			out.WriteByte('\n')
+		}
+		fmt.Fprintf(&out, "[exit %d]", exitErr.ExitCode())
+		return out.String(), nil
+	}
+	return out.String(), err
+}
+
+// maxPageBytes limita o download do /web antes da extração do texto.
+const maxPageBytes = 4 << 20
+
+var (
+	htmlDropRe  = regexp.MustCompile(`(?is)<(head|script|style|noscript|svg|template)\b.*?</(head|script|style|noscript|svg|template)>|<!--.*?-->`)
+	htmlBreakRe = regexp.MustCompile(`(?i)<(br|/p|/div|/li|/tr|/h[1-6]|/pre|/blockquote|/section|/article|/header|/footer)\b[^>]*>`)
+	htmlTagRe   = regexp.MustCompile(`(?s)<[^>]*>`)
+	htmlTitleRe = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+	blankRunRe  = regexp.MustCompile(`\n{3,}`)
+)
+
+// fetchPageText baixa a URL e devolve o título e o texto legível da página.
+// Conteúdo que não é HTML (texto, JSON) volta como veio.
+func fetchPageText(ctx context.Context, rawURL string) (title, text string, err error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", "", fmt.Errorf("URL inválida %q (use http:// ou https://)", rawURL)
+	}
+	ctx, cancel := context.WithTimeout(ctx, 20*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", "", err
+	}
+	req.Header.Set("User-Agent", "gptcli")
+	hc, err := sharedHTTPClient("")
+	if err != nil {
+		return "", "", err
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return "", "", fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxPageBytes))
+	if err != nil {
+		return "", "", err
+	}
+	ct := resp.Header.Get("Content-Type")
+	if !strings.Contains(ct, "html") && !(ct == "" && bytes.Contains(bytes.ToLower(body[:min(len(body), 512)]), []byte("<html"))) {
+		return "", strings.TrimSpace(string(body)), nil
+	}
+	page := string(body)
+	if m := htmlTitleRe.FindStringSubmatch(page); m != nil {
+		title = strings.Join(strings.Fields(html.UnescapeString(m[1])), " ")
+	}
+	return title, htmlText(page), nil
+}
+
+// htmlText remove scripts, estilos e tags, mantendo as quebras de bloco.
+func htmlText(page string) string {
+	page = htmlDropRe.ReplaceAllString(page, "")
+	page = htmlBreakRe.ReplaceAllString(page, "\n")
+	page = html.UnescapeString(htmlTagRe.ReplaceAllString(page, ""))
+	var lines []string
+	for _, l := range strings.Split(page, "\n") {
+		lines = append(lines, strings.Join(strings.Fields(l), " "))
+	}
+	return strings.TrimSpace(blankRunRe.ReplaceAllString(strings.Join(lines, "\n"), "\n\n"))
+}
+
+// switchProfile resolve as settings de outro profile para o /profile,
+// relendo o config. A chave, a persona e as flags de diagnóstico ativas
+// continuam valendo; gateway, retry e apelidos vêm só das settings novas.
+func switchProfile(cur *settings, name string) (*settings, openai.Client, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, openai.Client{}, err
+	}
+	if _, ok := cfg.Profiles[name]; !ok {
+		names := make([]string, 0, len(cfg.Profiles))
+		for n := range cfg.Profiles {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, openai.Client{}, fmt.Errorf("profile %q não encontrado (disponíveis: %s)", name, chooseNonEmpty(strings.Join(names, ", "), "nenhum"))
+	}
+	next, err := resolveSettings(cfg, &Flags{Profile: name, Persona: cur.personaName, APIKey: cur.apiKey, Temp: -1, Yes: cur.assumeYes, Ephemeral: cur.ephemeral,
+		ShowRequestID: cur.showRequestID, ShowUsage: cur.showUsage})
+	if err != nil {
+		return nil, openai.Client{}, err
+	}
+	client, err := buildClient(next)
+	if err != nil {
+		return nil, openai.Client{}, err
+	}
+	return next, client, nil
+}
+
+// editText abre o texto no $VISUAL/$EDITOR (default vi) e devolve o
+// conteúdo salvo.
+func editText(initial, pattern string) (string, error) {
+	if ephemeral {
+		return "", errors.New("modo --ephemeral: o $EDITOR precisaria de um arquivo temporário")
+	}
+	f, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString(initial); err != nil {
+		f.Close()
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+	editor := chooseNonEmpty(os.Getenv("VISUAL"), os.Getenv("EDITOR"), "vi")
+	// o editor pode ter argumentos ("code --wait"), então passa pelo shell
+	cmd := exec.Command("sh", "-c", editor+` "$1"`, "sh", f.Name())
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("editor %q: %w", editor, err)
+	}
+	b, err := os.ReadFile(f.Name())
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// autosaveOnExit grava o transcript e a sessão de cada conversa quando o REPL
+// termina, seja por /exit, Ctrl+D ou sinal (Ctrl+C, SIGTERM, SIGHUP). Devolve
+// a função de gravação para o defer do REPL; ela só roda uma vez.
+func autosaveOnExit(sess *Session, convs *replConversations) func() {
+	var once sync.Once
+	save := func() {
+		once.Do(func() {
+			stamp := time.Now().Unix()
+			convs.each(sess, func(name string, s *Session) {
+				if len(s.Turns) == 0 {
+					return
+				}
+				file := fmt.Sprintf("transcript-%d.md", stamp)
+				if len(convs.order) > 1 {
+					file = fmt.Sprintf("transcript-%d-%s.md", stamp, name)
+				}
+				path := filepath.Join(configDir(), file)
+				if err := saveTranscript(path, s); err != nil {
+					fmt.Fprintln(os.Stderr, "aviso: falha ao gravar transcript:", err)
+				} else {
+					fmt.Fprintf(os.Stderr, "\n(transcript salvo em %s)\n", path)
+				}
+				if err := s.save(); err != nil {
+					fmt.Fprintln(os.Stderr, "aviso: falha ao gravar sessão:", err)
+				}
+			})
+		})
+	}
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	go func() {
+		<-sig
+		save()
+		flushTelemetry()
+		os.Exit(130)
+	}()
+	return save
+}
+
+// askYesNo pergunta s/N lendo a resposta do próprio scanner do REPL.
+func askYesNo(in *bufio.Scanner, question string) bool {
+	fmt.Printf("%s [s/N] ", question)
+	if !in.Scan() {
+		return false
+	}
+	switch strings.ToLower(strings.TrimSpace(in.Text())) {
+	case "s", "sim", "y", "yes":
+		return true
+	}
+	return false
+}
+
+// ===================== REPL: várias conversas =====================
+//
+// /new <nome> abre outra conversa no mesmo REPL e /switch <nome> volta a uma
+// delas. Cada conversa tem o próprio system, turnos, modelo e anexos
+// pendentes. A ativa continua sendo o *Session do REPL (o conteúdo é trocado,
+// como no /fork); as demais ficam estacionadas aqui.
+
+type replConversation struct {
+	sess    Session
+	model   string
+	pending []Attachment
+}
+
+type replConversations struct {
+	active string
+	order  []string // ordem de criação
+	parked map[string]*replConversation
+}
+
+func newReplConversations(sess *Session) *replConversations {
+	name := chooseNonEmpty(sess.Name, "principal")
+	return &replConversations{active: name, order: []string{name}, parked: map[string]*replConversation{}}
+}
+
+// open estaciona a conversa ativa e começa uma vazia, com o system, o formato
+// e as ferramentas de base.
+func (c *replConversations) open(name string, base *settings, sess *Session, model *string, pending *[]Attachment) error {
+	if err := validSessionName(name); err != nil {
+		return err
+	}
+	if slices.Contains(c.order, name) {
+		return fmt.Errorf("a conversa %q já existe; use /switch %s", name, name)
+	}
+	c.parked[c.active] = &replConversation{sess: *sess, model: *model, pending: *pending}
+	fresh := Session{Format: sess.Format, Examples: sess.Examples, Tools: sess.Tools, Context: sess.Context, AutoContinue: sess.AutoContinue}
+	fresh.addSystem(base.system)
+	*sess, *pending = fresh, nil
+	c.active = name
+	c.order = append(c.order, name)
+	return nil
+}
+
+// switchTo troca a conversa ativa pela estacionada em name.
+func (c *replConversations) switchTo(name string, sess *Session, model *string, pending *[]Attachment) error {
+	if name == c.active {
+		return fmt.Errorf("a conversa %q já está ativa", name)
+	}
+	next, ok := c.parked[name]
+	if !ok {
+		return fmt.Errorf("conversa %q não existe (abertas: %s)", name, strings.Join(c.order, ", "))
+	}
+	c.parked[c.active] = &replConversation{sess: *sess, model: *model, pending: *pending}
+	delete(c.parked, name)
+	*sess, *model, *pending = next.sess, next.model, next.pending
+	c.active = name
+	return nil
+}
+
+// label é o que a linha de status mostra; vazio enquanto só há uma conversa.
+func (c *replConversations) label() string {
+	if len(c.order) < 2 {
+		return ""
+	}
+	return fmt.Sprintf("conversa %s (%d/%d)", c.active, slices.Index(c.order, c.active)+1, len(c.order))
+}
+
+func (c *replConversations) print(sess *Session, model string) {
+	for _, name := range c.order {
+		s, m, mark := sess, model, "*"
+		if p, ok := c.parked[name]; ok {
+			s, m, mark = &p.sess, p.model, " "
+		}
+		fmt.Printf("%s %-16s %-14s %d turno(s)\n", mark, name, m, len(s.Turns))
+	}
+}
+
+// each visita todas as conversas, a ativa incluída, na ordem de criação.
+func (c *replConversations) each(sess *Session, fn func(name string, s *Session)) {
+	for _, name := range c.order {
+		if p, ok := c.parked[name]; ok {
+			fn(name, &p.sess)
+		} else {
+			fn(name, sess)
+		}
+	}
+}
+
+// ===================== REPL por script =====================
+//
+// Com --repl e o stdin vindo de um pipe, o REPL lê comandos e prompts linha a
+// linha, sem prompt nem linha de status, e antes de cada leitura escreve
+// replReadyMarker no stdout. Um script (expect, testes de integração) lê até o
+// marcador, manda a próxima linha, e assim por diante; /exit ou o fim do stdin
+// encerram.
+
+// replReadyMarker abre a linha de pronto; segue "ok" ou "error", conforme o
+// último turno enviado ao modelo.
+const replReadyMarker = "--- gptcli:ready"
+
+func printReplReady(failed bool) {
+	result := "ok"
+	if failed {
+		result = "error"
+	}
+	fmt.Println(replReadyMarker, result)
+}
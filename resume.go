@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	openai "github.com/openai/openai-go/v2"
+)
+
+// ===================== Retomada de stream =====================
+//
+// Quando a conexão cai no meio de uma resposta, recomeçar do zero cobra de
+// novo os tokens já gerados e repete o texto que já está na tela. Em vez
+// disso, o gptcli manda o trecho recebido como resposta parcial, pede a
+// continuação e costura as duas partes, descartando o que o modelo repetir
+// na emenda.
+
+const resumePrompt = "A conexão caiu no meio da sua resposta. Continue exatamente de onde o texto parou, " +
+	"sem repetir nada do que já escreveu e sem comentar a interrupção."
+
+const (
+	maxStreamResumes = 3   // retomadas seguidas antes de devolver o erro
+	resumeProbeChars = 200 // início da continuação comparado com o fim do parcial
+	resumeMinOverlap = 12  // sobreposições menores podem ser coincidência
+)
+
+// canResume diz se vale retomar em vez de recomeçar: já veio texto, não foi
+// o usuário que interrompeu e o erro não é de chave ou cota.
+func canResume(ctx context.Context, partial string, err error) bool {
+	return err != nil && strings.TrimSpace(partial) != "" && ctx.Err() == nil && !isFatalAPIError(err)
+}
+
+// resumeStream continua uma resposta interrompida. Como em continueTruncated,
+// os turnos intermediários não ficam na sessão; o texto devolvido já é a
+// resposta inteira, e o que não foi retomado fica para o retry de quem chamou.
+func resumeStream(ctx context.Context, client openai.Client, sess *Session,
+	model string, temp float64, maxTokens int64, onDelta func(string), text string, err error) (completion, error) {
+
+	base := len(sess.Turns)
+	defer func() { sess.Turns = sess.Turns[:base] }()
+	retry := retryConfigFrom(ctx)
+	backoff, maxBackoff := retry.backoff()
+	for i := 1; i <= maxStreamResumes; i++ {
+		fmt.Fprintf(os.Stderr, "\n(stream interrompido: %v; retomando após ~%d tokens, %d/%d)\n", err, estimateTokens(text), i, maxStreamResumes)
+		spanFromContext(ctx).addEvent("stream.resume", attr("attempt", i), attr("error", err.Error()))
+		recordCounter("gptcli.stream_resumes", 1)
+		noteRetry(ctx, err)
+		time.Sleep(retryDelay(err, retry.jitter(backoff)))
+		backoff = min(2*backoff, maxBackoff)
+
+		sess.Turns = append(sess.Turns[:base],
+			Turn{Role: "assistant", Content: text},
+			Turn{Role: "user", Content: resumePrompt})
+		seam := &seamTrimmer{prev: text, out: onDelta}
+		var c completion
+		c, err = streamCompletion(ctx, client, sess, model, temp, maxTokens, seam.write)
+		seam.flush()
+		text += seam.kept.String()
+		if err == nil {
+			c.content = text
+			return c, nil
+		}
+		if !canResume(ctx, text, err) {
+			break
+		}
+	}
+	return completion{content: text}, err
+}
+
+// seamTrimmer segura o começo da continuação até poder compará-lo com o fim
+// do texto anterior e corta a parte repetida; depois repassa tudo direto.
+type seamTrimmer struct {
+	prev    string
+	out     func(string)
+	pending strings.Builder
+	kept    strings.Builder
+	done    bool
+}
+
+func (s *seamTrimmer) write(delta string) {
+	if s.done {
+		s.emit(delta)
+		return
+	}
+	s.pending.WriteString(delta)
+	if s.pending.Len() >= resumeProbeChars {
+		s.flush()
+	}
+}
+
+func (s *seamTrimmer) flush() {
+	if s.done {
+		return
+	}
+	s.done = true
+	next := s.pending.String()
+	s.emit(next[seamOverlap(s.prev, next):])
+}
+
+func (s *seamTrimmer) emit(text string) {
+	if text == "" {
+		return
+	}
+	s.kept.WriteString(text)
+	s.out(text)
+}
+
+// seamOverlap é o tamanho do maior prefixo de next que repete o fim de prev,
+// ignorando espaços na emenda; 0 se for curto demais para não ser acaso.
+func seamOverlap(prev, next string) int {
+	trimmed := strings.TrimLeft(next, " \t\n")
+	skipped := len(next) - len(trimmed)
+	core := strings.TrimRight(prev, " \t\n")
+	for k := min(len(core), len(trimmed)); k >= resumeMinOverlap; k-- {
+		if !strings.HasSuffix(core, trimmed[:k]) {
+			continue
+		}
+		if len(core) < len(prev) {
+			// o espaço depois da parte repetida já está no texto anterior
+			rest := trimmed[k:]
+			k += len(rest) - len(strings.TrimLeft(rest, " \t\n"))
+		}
+		return skipped + k
+	}
+	return 0
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"time"
+)
+
+// ===================== Retry configurável =====================
+//
+// withRetries usa por padrão 4 tentativas (3 nas chamadas auxiliares), backoff
+// de 500ms dobrando até 8s e até 250ms de jitter. A seção `retry` do profile e
+// as flags --retry-* mudam esses valores: pipelines em lote querem mais
+// tentativas e paciência, o uso interativo quer desistir logo.
+
+type RetryConfig struct {
+	Attempts   int    `yaml:"attempts,omitempty"`    // tentativas no total, incluindo a primeira (0 = default da chamada)
+	Backoff    string `yaml:"backoff,omitempty"`     // espera antes da 2ª tentativa, dobrada a cada falha (default 500ms)
+	MaxBackoff string `yaml:"max_backoff,omitempty"` // teto da espera (default 8s)
+	Jitter     string `yaml:"jitter,omitempty"`      // acréscimo aleatório de 0 até este valor (default 250ms)
+}
+
+type retryKey struct{}
+
+// withRetryConfig prende ao contexto a política de retry de um profile; é dela
+// que withRetries e resumeStream leem. Sem ela valem os defaults.
+func withRetryConfig(ctx context.Context, c RetryConfig) context.Context {
+	return context.WithValue(ctx, retryKey{}, c)
+}
+
+func retryConfigFrom(ctx context.Context) RetryConfig {
+	c, _ := ctx.Value(retryKey{}).(RetryConfig)
+	return c
+}
+
+func (c RetryConfig) validate() error {
+	if c.Attempts < 0 {
+		return fmt.Errorf("retry.attempts não pode ser negativo")
+	}
+	for _, d := range []struct{ field, v string }{
+		{"backoff", c.Backoff}, {"max_backoff", c.MaxBackoff}, {"jitter", c.Jitter},
+	} {
+		if d.v == "" {
+			continue
+		}
+		if v, err := time.ParseDuration(d.v); err != nil || v < 0 {
+			return fmt.Errorf("retry.%s inválido %q (ex: 500ms, 2s)", d.field, d.v)
+		}
+	}
+	return nil
+}
+
+func retryFlags(fs *flag.FlagSet, c *RetryConfig) {
+	fs.IntVar(&c.Attempts, "retry-attempts", 0, "tentativas por chamada à API, incluindo a primeira (0 = profile ou default)")
+	fs.StringVar(&c.Backoff, "retry-backoff", "", "espera antes da 2ª tentativa, dobrada a cada falha (default 500ms)")
+	fs.StringVar(&c.MaxBackoff, "retry-max-backoff", "", "teto da espera entre tentativas (default 8s)")
+	fs.StringVar(&c.Jitter, "retry-jitter", "", "acréscimo aleatório máximo a cada espera (default 250ms; 0 desliga)")
+}
+
+// merge devolve c com os campos definidos em o por cima.
+func (c RetryConfig) merge(o RetryConfig) RetryConfig {
+	if o.Attempts > 0 {
+		c.Attempts = o.Attempts
+	}
+	c.Backoff = chooseNonEmpty(o.Backoff, c.Backoff)
+	c.MaxBackoff = chooseNonEmpty(o.MaxBackoff, c.MaxBackoff)
+	c.Jitter = chooseNonEmpty(o.Jitter, c.Jitter)
+	return c
+}
+
+func (c RetryConfig) attempts(def int) int {
+	if c.Attempts > 0 {
+		return c.Attempts
+	}
+	return def
+}
+
+// backoff devolve a espera inicial e o teto.
+func (c RetryConfig) backoff() (time.Duration, time.Duration) {
+	first := durationOr(c.Backoff, 500*time.Millisecond)
+	return first, max(first, durationOr(c.MaxBackoff, 8*time.Second))
+}
+
+// jitter soma a d um valor aleatório entre 0 e o jitter configurado.
+func (c RetryConfig) jitter(d time.Duration) time.Duration {
+	limit := durationOr(c.Jitter, 250*time.Millisecond)
+	if limit <= 0 {
+		return d
+	}
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return d + time.Duration(binary.LittleEndian.Uint64(b[:])%uint64(limit))
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ===================== Shell Sandbox =====================
+//
+// Restrições para as ferramentas `shell` e `run_code` (seção `shell_sandbox` do config):
+// diretório de trabalho, variáveis de ambiente permitidas, timeout, sem rede
+// (via unshare) e, opcionalmente, execução dentro de um container.
+
+type ShellSandbox struct {
+	WorkDir   string   `yaml:"workdir,omitempty"`    // default: diretório atual
+	Env       []string `yaml:"env,omitempty"`        // variáveis repassadas; default: PATH, HOME, LANG, TERM
+	Timeout   string   `yaml:"timeout,omitempty"`    // ex: 30s (default 60s)
+	NoNetwork bool     `yaml:"no_network,omitempty"` // isola a rede do comando
+	Container string   `yaml:"container,omitempty"`  // imagem; roda com docker/podman
+	Runtime   string   `yaml:"runtime,omitempty"`    // docker|podman (default: o que existir)
+}
+
+var defaultSandboxEnv = []string{"PATH", "HOME", "LANG", "TERM"}
+
+// shellBox é definido por configureTools junto com as políticas.
+var shellBox ShellSandbox
+
+func (b ShellSandbox) timeout() time.Duration {
+	if d, err := time.ParseDuration(b.Timeout); err == nil && d > 0 {
+		return d
+	}
+	return time.Minute
+}
+
+func (b ShellSandbox) validate() error {
+	if b.Timeout != "" {
+		if d, err := time.ParseDuration(b.Timeout); err != nil || d <= 0 {
+			return fmt.Errorf("shell_sandbox.timeout inválido %q (ex: 30s)", b.Timeout)
+		}
+	}
+	if b.WorkDir != "" {
+		if st, err := os.Stat(b.WorkDir); err != nil || !st.IsDir() {
+			return fmt.Errorf("shell_sandbox.workdir %q não é um diretório", b.WorkDir)
+		}
+	}
+	if b.Runtime != "" && b.Runtime != "docker" && b.Runtime != "podman" {
+		return fmt.Errorf("shell_sandbox.runtime inválido %q (use docker|podman)", b.Runtime)
+	}
+	return nil
+}
+
+func (b ShellSandbox) environ() []string {
+	names := b.Env
+	if len(names) == 0 {
+		names = defaultSandboxEnv
+	}
+	var env []string
+	for _, n := range names {
+		if v, ok := os.LookupEnv(n); ok {
+			env = append(env, n+"="+v)
+		}
+	}
+	return env
+}
+
+// command monta o exec.Cmd do comando já com as restrições aplicadas.
+func (b ShellSandbox) command(ctx context.Context, script string) (*exec.Cmd, error) {
+	dir := b.WorkDir
+	if dir == "" {
+		dir, _ = os.Getwd()
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var cmd *exec.Cmd
+	switch {
+	case b.Container != "":
+		runtime := b.Runtime
+		if runtime == "" {
+			runtime = "docker"
+			if _, err := exec.LookPath("docker"); err != nil {
+				runtime = "podman"
+			}
+		}
+		if _, err := exec.LookPath(runtime); err != nil {
+			return nil, fmt.Errorf("shell_sandbox.container requer %s instalado", runtime)
+		}
+		args := []string{"run", "--rm", "-i", "-v", dir + ":/work", "-w", "/work"}
+		if b.NoNetwork {
+			args = append(args, "--network", "none")
+		}
+		for _, kv := range b.environ() {
+			if !strings.HasPrefix(kv, "PATH=") && !strings.HasPrefix(kv, "HOME=") {
+				args = append(args, "-e", kv)
+			}
+		}
+		args = append(args, b.Container, "sh", "-c", script)
+		cmd = exec.CommandContext(ctx, runtime, args...)
+		cmd.Env = os.Environ() // o ambiente filtrado vai para o container, não para o cliente
+	case b.NoNetwork:
+		if _, err := exec.LookPath("unshare"); err != nil {
+			return nil, errors.New("shell_sandbox.no_network requer o unshare (util-linux) ou um container")
+		}
+		// -r mapeia o usuário atual para root num user namespace, dispensando privilégios
+		cmd = exec.CommandContext(ctx, "unshare", "-rn", "sh", "-c", script)
+		cmd.Env = b.environ()
+	default:
+		cmd = exec.CommandContext(ctx, "sh", "-c", script)
+		cmd.Env = b.environ()
+	}
+	cmd.Dir = dir
+	cmd.WaitDelay = 2 * time.Second // não espera filhos que herdaram a saída
+	return cmd, nil
+}
+
+func runSandboxedShell(ctx context.Context, script string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, shellBox.timeout())
+	defer cancel()
+	cmd, err := shellBox.command(ctx, script)
+	if err != nil {
+		return "", err
+	}
+	out, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Sprintf("%s\n[timeout após %s]", out, shellBox.timeout()), nil
+	}
+	if err != nil {
+		// saída com código != 0 ainda é informação útil para o modelo
+		return fmt.Sprintf("%s\n[%v]", out, err), nil
+	}
+	return string(out), nil
+}
+
+// codeRunners diz como rodar cada linguagem do run_code: arquivo e comando.
+var codeRunners = map[string]struct{ file, cmd string }{
+	"python": {"main.py", "python3 main.py"},
+	"go":     {"main.go", "go run main.go"},
+}
+
+// runCodeSnippet grava o código num diretório temporário e o executa com as
+// mesmas restrições do shell; o diretório vira o workdir (e o /work do container).
+func runCodeSnippet(ctx context.Context, language, code string) (string, error) {
+	runner, ok := codeRunners[language]
+	if !ok {
+		return "", fmt.Errorf("linguagem não suportada %q (use python ou go)", language)
+	}
+	if strings.TrimSpace(code) == "" {
+		return "", errors.New("código vazio")
+	}
+	if language == "go" && !strings.Contains(code, "package ") {
+		code = "package main\n\n" + code
+	}
+	if shellBox.Container == "" {
+		if bin, _, _ := strings.Cut(runner.cmd, " "); bin != "" {
+			if _, err := exec.LookPath(bin); err != nil {
+				return "", fmt.Errorf("%s não encontrado no PATH", bin)
+			}
+		}
+	}
+	dir, err := os.MkdirTemp("", "gptcli-code-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+	if err := os.WriteFile(filepath.Join(dir, runner.file), []byte(code), 0o600); err != nil {
+		return "", err
+	}
+	box := shellBox
+	box.WorkDir = dir
+	ctx, cancel := context.WithTimeout(ctx, box.timeout())
+	defer cancel()
+	cmd, err := box.command(ctx, runner.cmd)
+	if err != nil {
+		return "", err
+	}
+	out, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Sprintf("%s\n[timeout após %s]", out, box.timeout()), nil
+	}
+	if err != nil {
+		// erro de sintaxe ou exceção: o modelo lê o traceback e corrige
+		return fmt.Sprintf("%s\n[%v]", out, err), nil
+	}
+	return string(out), nil
+}
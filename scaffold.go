@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	openai "github.com/openai/openai-go/v2"
+)
+
+// ===================== Scaffold =====================
+//
+// --scaffold <dir> pede ao modelo vários arquivos num formato estruturado,
+// mostra a lista do que será criado e grava tudo em <dir> após confirmação.
+
+const scaffoldInstruction = `Responda SOMENTE com os arquivos do projeto, um após o outro, neste formato:
+
+<<<FILE caminho/relativo/do/arquivo>>>
+conteúdo completo do arquivo
+<<<END>>>
+
+Use caminhos relativos com "/" (sem ".." nem caminhos absolutos) e não escreva nada fora dos blocos.`
+
+var scaffoldFileRe = regexp.MustCompile(`(?s)<<<FILE\s+(.+?)>>>[ \t]*\r?\n(.*?)\r?\n?<<<END>>>`)
+
+type scaffoldFile struct {
+	path    string // relativo ao diretório de destino
+	content string
+}
+
+// parseScaffold extrai os arquivos da resposta e recusa caminhos que
+// escapariam do diretório de destino.
+func parseScaffold(out string) ([]scaffoldFile, error) {
+	var files []scaffoldFile
+	seen := map[string]bool{}
+	for _, m := range scaffoldFileRe.FindAllStringSubmatch(out, -1) {
+		p := strings.TrimSpace(m[1])
+		clean := filepath.Clean(filepath.FromSlash(p))
+		if p == "" || filepath.IsAbs(clean) || clean == "." || clean == ".." ||
+			strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+			return nil, fmt.Errorf("caminho inválido na resposta: %q", p)
+		}
+		if seen[clean] {
+			return nil, fmt.Errorf("arquivo repetido na resposta: %s", p)
+		}
+		seen[clean] = true
+		content := m[2]
+		if content != "" && !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+		files = append(files, scaffoldFile{path: clean, content: content})
+	}
+	if len(files) == 0 {
+		return nil, errors.New("a resposta não contém nenhum bloco <<<FILE ...>>>")
+	}
+	return files, nil
+}
+
+func runScaffold(ctx context.Context, client openai.Client, st *settings, sess *Session,
+	prompt, dir string, assumeYes bool) error {
+
+	prompt, err := st.prof.Hooks.runPre(ctx, prompt, st.model)
+	if err != nil {
+		return err
+	}
+	gen := *sess
+	gen.System = strings.TrimSpace(sess.System + "\n\n" + scaffoldInstruction)
+	gen.Format = "" // o formato de arquivos substitui --format
+	gen.addUser(prompt)
+
+	fmt.Fprintln(os.Stderr, "(gerando arquivos...)")
+	var out string
+	err = withRetries(ctx, 4, func() error {
+		var err error
+		out, err = streamChat(ctx, client, &gen, st.model, st.temp, st.maxTokens, func(string) {})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	sess.Turns = gen.Turns
+	sess.addAssistant(out)
+
+	files, err := parseScaffold(out)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Arquivos em %s:\n", dir)
+	for _, f := range files {
+		note := ""
+		if _, err := os.Stat(filepath.Join(dir, f.path)); err == nil {
+			note = "  (sobrescreve)"
+		}
+		fmt.Printf("  %-40s %4d linhas%s\n", filepath.ToSlash(f.path), strings.Count(f.content, "\n"), note)
+	}
+	if !assumeYes {
+		ok, err := confirmTTY(fmt.Sprintf("Gravar %d arquivo(s)?", len(files)))
+		if err != nil {
+			return errors.New("sem terminal para confirmar; use --yes")
+		}
+		if !ok {
+			fmt.Fprintln(os.Stderr, "(nada foi gravado)")
+			return nil
+		}
+	}
+	for _, f := range files {
+		path := filepath.Join(dir, f.path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, []byte(f.content), 0o644); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(os.Stderr, "(%d arquivo(s) gravados em %s)\n", len(files), dir)
+	return nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseScaffold(t *testing.T) {
+	tests := []struct {
+		name    string
+		out     string
+		want    []scaffoldFile
+		wantErr string
+	}{
+		{
+			name: "dois arquivos",
+			out:  "<<<FILE main.go>>>\npackage main\n<<<END>>>\n<<<FILE cmd/app/app.go>>>\npackage app<<<END>>>",
+			want: []scaffoldFile{
+				{path: "main.go", content: "package main\n"},
+				{path: filepath.Join("cmd", "app", "app.go"), content: "package app\n"},
+			},
+		},
+		{
+			name: "caminho com ./ é limpo",
+			out:  "<<<FILE ./docs/../README.md>>>\n# x\n<<<END>>>",
+			want: []scaffoldFile{{path: "README.md", content: "# x\n"}},
+		},
+		{
+			name:    "sobe de diretório",
+			out:     "<<<FILE ../fora.txt>>>\nx\n<<<END>>>",
+			wantErr: "caminho inválido",
+		},
+		{
+			name:    "sobe no meio do caminho",
+			out:     "<<<FILE a/../../fora.txt>>>\nx\n<<<END>>>",
+			wantErr: "caminho inválido",
+		},
+		{
+			name:    "caminho absoluto",
+			out:     "<<<FILE /etc/passwd>>>\nx\n<<<END>>>",
+			wantErr: "caminho inválido",
+		},
+		{
+			name:    "só ponto",
+			out:     "<<<FILE .>>>\nx\n<<<END>>>",
+			wantErr: "caminho inválido",
+		},
+		{
+			name:    "repetido",
+			out:     "<<<FILE a.txt>>>\n1\n<<<END>>>\n<<<FILE ./a.txt>>>\n2\n<<<END>>>",
+			wantErr: "repetido",
+		},
+		{
+			name:    "sem blocos",
+			out:     "aqui está o projeto",
+			wantErr: "nenhum bloco",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, err := parseScaffold(tt.out)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("erro = %v, queria %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(files) != len(tt.want) {
+				t.Fatalf("%d arquivos, queria %d: %+v", len(files), len(tt.want), files)
+			}
+			for i, f := range files {
+				if f != tt.want[i] {
+					t.Errorf("arquivo %d = %+v, queria %+v", i, f, tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// ===================== Screenshot =====================
+//
+// --screenshot captura a tela com a ferramenta da plataforma (screencapture
+// no macOS; grim no Wayland; maim, scrot, import ou gnome-screenshot no X11)
+// e anexa a imagem ao prompt, como --attach. O arquivo temporário é apagado
+// logo depois de lido. `screenshot_command` no config troca a ferramenta:
+// {file} é o caminho onde gravar; sem {file}, o stdout do comando é a imagem.
+
+// screenshotTimeout cobre ferramentas interativas (seleção de área).
+const screenshotTimeout = 2 * time.Minute
+
+// screenshotTools são os comandos tentados em ordem quando não há
+// screenshot_command; o primeiro instalado vence.
+func screenshotTools() []string {
+	switch {
+	case runtime.GOOS == "darwin":
+		return []string{"screencapture -x {file}"}
+	case os.Getenv("WAYLAND_DISPLAY") != "":
+		return []string{"grim {file}", "gnome-screenshot -f {file}"}
+	}
+	return []string{"maim {file}", "scrot -o {file}", "import -window root {file}", "gnome-screenshot -f {file}"}
+}
+
+// screenshotCommand escolhe o comando: o do config ou a primeira ferramenta
+// instalada.
+func screenshotCommand(configured string) (string, error) {
+	if strings.TrimSpace(configured) != "" {
+		return configured, nil
+	}
+	var names []string
+	for _, c := range screenshotTools() {
+		bin := strings.Fields(c)[0]
+		if _, err := exec.LookPath(bin); err == nil {
+			return c, nil
+		}
+		names = append(names, bin)
+	}
+	return "", fmt.Errorf("--screenshot: nenhuma ferramenta de captura encontrada (%s); instale uma ou defina screenshot_command no config", strings.Join(names, ", "))
+}
+
+// takeScreenshot captura a tela e devolve a imagem como anexo, sem deixar o
+// arquivo temporário para trás.
+func takeScreenshot(configured string) (Attachment, error) {
+	command, err := screenshotCommand(configured)
+	if err != nil {
+		return Attachment{}, err
+	}
+	dir, err := os.MkdirTemp("", "gptcli-screenshot-")
+	if err != nil {
+		return Attachment{}, err
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "screen.png")
+
+	ctx, cancel := context.WithTimeout(context.Background(), screenshotTimeout)
+	defer cancel()
+	toStdout := !strings.Contains(command, "{file}")
+	// o caminho vai como $1, sem precisar escapar para o shell
+	cmd := exec.CommandContext(ctx, "sh", "-c", strings.ReplaceAll(command, "{file}", `"$1"`), "sh", path)
+	cmd.WaitDelay = time.Second
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	if toStdout {
+		out, err := os.Create(path)
+		if err != nil {
+			return Attachment{}, err
+		}
+		defer out.Close()
+		cmd.Stdout = out
+	}
+	switch err := cmd.Run(); {
+	case ctx.Err() == context.DeadlineExceeded:
+		return Attachment{}, fmt.Errorf("--screenshot: %q não terminou em %s", command, screenshotTimeout)
+	case err != nil:
+		return Attachment{}, fmt.Errorf("--screenshot: %q: %v: %s", command, err, strings.TrimSpace(stderr.String()))
+	}
+	if info, err := os.Stat(path); err != nil || info.Size() == 0 {
+		// seleção cancelada (Esc no screencapture -i, no maim -s...)
+		return Attachment{}, errors.New("--screenshot: a captura não gerou imagem (cancelada?)")
+	}
+	a, err := newImageAttachment(path)
+	if err != nil {
+		return Attachment{}, fmt.Errorf("--screenshot: %w", err)
+	}
+	a.Source = "screenshot"
+	return a, nil
+}
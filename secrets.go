@@ -0,0 +1,144 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ===================== Higiene de segredos =====================
+//
+// Na inicialização, avisa se o config.yaml tem api_key e pode ser lido por
+// outros usuários. `gptcli config harden` corrige as permissões e move a
+// chave para o keyring (api_key_keyring: true); com `strict_secrets: true`,
+// uma api_key em texto puro no config é recusada.
+
+const keyringAPIKeyAccount = "api-key"
+
+var errStrictSecrets = errors.New("strict_secrets: api_key em texto puro no config.yaml; rode `gptcli config harden` ou use OPENAI_API_KEY")
+
+// warnConfigHygiene imprime os avisos de inicialização sobre o config.yaml.
+func warnConfigHygiene(cfg *Config) {
+	if cfg == nil || strings.TrimSpace(cfg.APIKey) == "" || cfg.StrictSecrets || isEncryptedConfig(configPath()) {
+		return // com strict_secrets, resolveSettings já recusa a chave
+	}
+	info, err := os.Stat(configPath())
+	if err != nil {
+		return
+	}
+	if perm := info.Mode().Perm(); perm&0o044 != 0 {
+		fmt.Fprintf(os.Stderr, "aviso: %s contém api_key e pode ser lido por outros usuários (%04o); rode `gptcli config harden`\n", configPath(), perm)
+	}
+}
+
+// configAPIKey devolve a chave do config: do keyring, se api_key_keyring
+// estiver ligado, ou a api_key em texto puro.
+func configAPIKey(cfg *Config) (string, error) {
+	if cfg == nil {
+		return "", nil
+	}
+	if key := strings.TrimSpace(cfg.APIKey); key != "" {
+		return key, nil
+	}
+	if !cfg.APIKeyKeyring {
+		return "", nil
+	}
+	key, ok, err := keyringGet(keyringAPIKeyAccount)
+	if err != nil {
+		return "", fmt.Errorf("api_key_keyring: %w", err)
+	}
+	if !ok {
+		return "", errors.New("api_key_keyring: a chave não está no keyring; rode `gptcli config harden` com api_key no config")
+	}
+	return key, nil
+}
+
+const configUsage = `uso: gptcli config harden [--no-keyring]
+  harden   permissões 600 no config.yaml, histórico, transcripts e sessões (700 no diretório)
+           e move a api_key do config para o keyring do sistema`
+
+func configCmd(args []string) error {
+	if len(args) == 0 || args[0] != "harden" {
+		fmt.Fprintln(os.Stderr, configUsage)
+		os.Exit(2)
+	}
+	fs := flag.NewFlagSet("config harden", flag.ExitOnError)
+	noKeyring := fs.Bool("no-keyring", false, "só corrige as permissões; a api_key fica no config")
+	_ = fs.Parse(args[1:])
+
+	if ephemeral || ephemeralFromEnv() {
+		return errEphemeral
+	}
+	dir := configDir()
+	if _, err := os.Stat(configPath()); err != nil {
+		return fmt.Errorf("%s não encontrado", configPath())
+	}
+	if err := tightenPerm(dir, 0o700); err != nil {
+		return err
+	}
+	files := []string{configPath(), historyPath(), filepath.Join(dir, "storage.salt")}
+	for _, pattern := range []string{"transcript-*.md", "flashcards-*.csv", "sessions/*.yaml"} {
+		matches, _ := filepath.Glob(filepath.Join(dir, pattern))
+		files = append(files, matches...)
+	}
+	if _, err := os.Stat(sessionsDir()); err == nil {
+		if err := tightenPerm(sessionsDir(), 0o700); err != nil {
+			return err
+		}
+	}
+	for _, f := range files {
+		if _, err := os.Stat(f); err != nil {
+			continue
+		}
+		if err := tightenPerm(f, 0o600); err != nil {
+			return err
+		}
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	key := strings.TrimSpace(cfg.APIKey)
+	switch {
+	case isEncryptedConfig(configPath()):
+		fmt.Println("(config cifrado: a api_key já está protegida pela cifra)")
+	case key == "":
+		fmt.Println("(o config não tem api_key em texto puro)")
+	case *noKeyring:
+		fmt.Println("(api_key mantida no config, agora legível só por você)")
+	case !keyringAvailable():
+		fmt.Println("(keyring indisponível: a api_key continua no config, agora legível só por você;")
+		fmt.Println(" instale o secret-tool ou troque por OPENAI_API_KEY e apague api_key do config)")
+	default:
+		if err := keyringSet(keyringAPIKeyAccount, "gptcli OpenAI API key", key); err != nil {
+			return err
+		}
+		cfg.APIKey, cfg.APIKeyKeyring = "", true
+		if err := saveConfig(cfg); err != nil {
+			return err
+		}
+		fmt.Println("(api_key movida para o keyring; o config agora tem api_key_keyring: true)")
+	}
+	return nil
+}
+
+// tightenPerm só remove permissões: nunca alarga o que já é mais restrito.
+func tightenPerm(path string, limit os.FileMode) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	perm := info.Mode().Perm()
+	if perm&^limit == 0 {
+		return nil
+	}
+	if err := os.Chmod(path, perm&limit); err != nil {
+		return err
+	}
+	fmt.Printf("%s: %04o -> %04o\n", path, perm, perm&limit)
+	return nil
+}
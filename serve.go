@@ -0,0 +1,818 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	openai "github.com/openai/openai-go/v2"
+	"github.com/openai/openai-go/v2/option"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// ===================== Serve (gateway compatível com a OpenAI) =====================
+//
+// `gptcli serve` expõe POST /v1/chat/completions e GET /v1/models no formato
+// da API da OpenAI e repassa os pedidos ao upstream dos profiles do config,
+// com a chave, a base_url e o provider de cada um. Ferramentas que já falam
+// com a OpenAI só trocam a base URL.
+//
+// Com --auth, cada cliente usa o próprio token (Authorization: Bearer ...),
+// que dá acesso a alguns profiles e a uma cota diária de tokens: um time
+// pequeno divide uma chave do upstream com a conta separada por pessoa. O
+// uso do dia fica em serve-usage.json, no diretório de estado.
+
+// maxServeBody limita o corpo de um pedido (imagens em base64 incluídas).
+const maxServeBody = 32 << 20
+
+const (
+	serveHeartbeat    = 15 * time.Second // comentário SSE enquanto não chega chunk
+	serveWriteTimeout = time.Minute      // cliente que não lê um chunk nesse tempo cai
+)
+
+type serveUser struct {
+	Name        string   `yaml:"name"`
+	Token       string   `yaml:"token,omitempty"`        // em texto puro
+	TokenSHA256 string   `yaml:"token_sha256,omitempty"` // ou só o hash: echo -n token | sha256sum
+	Profiles    []string `yaml:"profiles,omitempty"`     // profiles permitidos (vazio = todos)
+	DailyTokens int64    `yaml:"daily_tokens,omitempty"` // cota diária de tokens (0 = sem limite)
+}
+
+type serveAuth struct {
+	Users  []serveUser `yaml:"users"`
+	byHash map[string]*serveUser
+}
+
+func loadServeAuth(path string, profiles map[string]*serveProfile) (*serveAuth, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var a serveAuth
+	if err := yaml.Unmarshal(b, &a); err != nil {
+		return nil, fmt.Errorf("%s inválido: %w", path, err)
+	}
+	if len(a.Users) == 0 {
+		return nil, fmt.Errorf("%s: nenhum usuário em users", path)
+	}
+	a.byHash = map[string]*serveUser{}
+	names := map[string]bool{}
+	for i := range a.Users {
+		u := &a.Users[i]
+		switch {
+		case strings.TrimSpace(u.Name) == "":
+			return nil, fmt.Errorf("%s: usuário %d sem name", path, i+1)
+		case names[u.Name]:
+			return nil, fmt.Errorf("%s: usuário %q repetido", path, u.Name)
+		case (u.Token == "") == (u.TokenSHA256 == ""):
+			return nil, fmt.Errorf("%s: %s precisa de token ou token_sha256 (um dos dois)", path, u.Name)
+		case u.DailyTokens < 0:
+			return nil, fmt.Errorf("%s: %s: daily_tokens não pode ser negativo", path, u.Name)
+		}
+		names[u.Name] = true
+		hash := strings.ToLower(u.TokenSHA256)
+		if u.Token != "" {
+			hash = tokenHash(u.Token)
+		} else if b, err := hex.DecodeString(hash); err != nil || len(b) != sha256.Size {
+			return nil, fmt.Errorf("%s: %s: token_sha256 deve ter 64 dígitos hexadecimais", path, u.Name)
+		}
+		if _, dup := a.byHash[hash]; dup {
+			return nil, fmt.Errorf("%s: %s usa o mesmo token de outro usuário", path, u.Name)
+		}
+		for _, p := range u.Profiles {
+			if profiles[p] == nil {
+				return nil, fmt.Errorf("%s: %s: profile %q não está sendo servido", path, u.Name, p)
+			}
+		}
+		a.byHash[hash] = u
+	}
+	return &a, nil
+}
+
+func tokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// lookup compara pelo hash, então o tempo não depende do token do cliente.
+func (a *serveAuth) lookup(token string) *serveUser {
+	if token == "" {
+		return nil
+	}
+	return a.byHash[tokenHash(token)]
+}
+
+func (u *serveUser) allows(profile string) bool {
+	return len(u.Profiles) == 0 || containsString(u.Profiles, profile)
+}
+
+// ---------- uso diário ----------
+
+type serveDay struct {
+	Requests         int64 `json:"requests"`
+	PromptTokens     int64 `json:"prompt_tokens"`
+	CompletionTokens int64 `json:"completion_tokens"`
+	EstimatedTokens  int64 `json:"estimated_tokens,omitempty"` // pedidos cobrados pela reserva: o upstream não informou o uso
+}
+
+func (d serveDay) total() int64 { return d.PromptTokens + d.CompletionTokens + d.EstimatedTokens }
+
+// serveUsage acumula o uso por dia (data local) e usuário e o grava no
+// máximo a cada serveUsageFlush, para a cota sobreviver a um restart. Cada
+// pedido em andamento reserva uma estimativa do que vai gastar, e a cota
+// conta essas reservas: pedidos simultâneos não passam todos pela mesma
+// sobra.
+type serveUsage struct {
+	mu       sync.Mutex
+	path     string
+	Days     map[string]map[string]*serveDay `json:"days"`
+	reserved map[string]int64                // usuário => tokens reservados por pedidos em andamento
+	flushing bool                            // gravação agendada
+}
+
+const (
+	serveUsageFlush     = 2 * time.Second
+	serveTelemetryFlush = 10 * time.Second // exportação OTLP dos servidores
+	serveUsageKeep      = 90               // dias de uso guardados no arquivo
+	serveReserveReply   = 1024             // reserva da resposta quando o pedido não traz max_tokens
+)
+
+func serveUsagePath() string { return filepath.Join(stateDir(), "serve-usage.json") }
+
+func loadServeUsage(path string) (*serveUsage, error) {
+	u := &serveUsage{path: path, Days: map[string]map[string]*serveDay{}, reserved: map[string]int64{}}
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return u, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, u); err != nil {
+		return nil, fmt.Errorf("%s inválido: %w", path, err)
+	}
+	if u.Days == nil {
+		u.Days = map[string]map[string]*serveDay{}
+	}
+	return u, nil
+}
+
+func today() string { return time.Now().Format("2006-01-02") }
+
+// reserve separa estimate tokens da cota de user se o uso do dia, as
+// reservas e o próprio pedido cabem em limit. Devolve quanto já está
+// comprometido.
+func (u *serveUsage) reserve(user string, limit, estimate int64) (int64, bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	committed := u.reserved[user]
+	if d := u.Days[today()][user]; d != nil {
+		committed += d.total()
+	}
+	if committed+estimate > limit {
+		return committed, false
+	}
+	u.reserved[user] += estimate
+	return committed, true
+}
+
+// release devolve uma reserva de pedido que não chegou ao upstream.
+func (u *serveUsage) release(user string, reserved int64) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.releaseLocked(user, reserved)
+}
+
+func (u *serveUsage) releaseLocked(user string, reserved int64) {
+	if u.reserved[user] -= reserved; u.reserved[user] <= 0 {
+		delete(u.reserved, user)
+	}
+}
+
+// add libera a reserva do pedido e soma o uso real. Sem uso informado (o
+// backend não manda o chunk de uso, o stream caiu no meio), o pedido é
+// cobrado pela reserva, salvo se o upstream o recusou.
+func (u *serveUsage) add(user string, reserved int64, usage openai.CompletionUsage, rejected bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.releaseLocked(user, reserved)
+	day := today()
+	if u.Days[day] == nil {
+		u.Days[day] = map[string]*serveDay{}
+	}
+	d := u.Days[day][user]
+	if d == nil {
+		d = &serveDay{}
+		u.Days[day][user] = d
+	}
+	d.Requests++
+	d.PromptTokens += usage.PromptTokens
+	d.CompletionTokens += usage.CompletionTokens
+	if usage.TotalTokens == 0 && !rejected {
+		d.EstimatedTokens += reserved
+	}
+	if !u.flushing {
+		u.flushing = true
+		time.AfterFunc(serveUsageFlush, u.flush)
+	}
+}
+
+// flush grava o uso acumulado; chamado pelo timer de add e no encerramento.
+func (u *serveUsage) flush() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.flushing = false
+	if err := u.saveLocked(); err != nil {
+		fmt.Fprintln(os.Stderr, "aviso: uso do serve não gravado:", err)
+	}
+}
+
+func (u *serveUsage) saveLocked() error {
+	if ephemeral {
+		return nil
+	}
+	oldest := time.Now().AddDate(0, 0, 1-serveUsageKeep).Format("2006-01-02")
+	for day := range u.Days {
+		if day < oldest {
+			delete(u.Days, day)
+		}
+	}
+	b, err := json.MarshalIndent(u, "", "  ")
+	if err != nil {
+		return err
+	}
+	ensureDir(filepath.Dir(u.path))
+	tmp := u.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, u.path)
+}
+
+// ---------- servidor ----------
+
+type serveProfile struct {
+	name   string
+	st     *settings
+	client openai.Client
+}
+
+type serveServer struct {
+	auth     *serveAuth // nil = sem autenticação
+	profiles map[string]*serveProfile
+	order    []string // profiles servidos, em ordem alfabética
+	def      string   // profile de quem não escolhe
+	usage    *serveUsage
+	rules    []serveRule
+}
+
+const serveUsageText = `uso: gptcli serve [--listen 127.0.0.1:8080] [--auth usuarios.yaml] [--profiles a,b] [--profile padrão] [--rules regras.yaml] [--audit]
+       gptcli serve usage [--days 7]
+`
+
+func serveCmd(args []string) error {
+	if len(args) > 0 && args[0] == "usage" {
+		return serveUsageCmd(args[1:])
+	}
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	listen := fs.String("listen", "127.0.0.1:8080", "endereço para escutar")
+	authPath := fs.String("auth", "", "arquivo YAML com os tokens dos clientes, profiles permitidos e cotas diárias")
+	list := fs.String("profiles", "", "profiles servidos, separados por vírgula (default: todos do config)")
+	def := fs.String("profile", "", "profile de quem não escolhe um (default: o default do config)")
+	eph := fs.Bool("ephemeral", false, "não grava o uso nem o log em disco (ou GPTCLI_EPHEMERAL=1)")
+	audit := fs.Bool("audit", false, "grava a trilha de auditoria (ou audit.enabled no config)")
+	rules := fs.String("rules", "", "arquivo YAML com regras aplicadas aos pedidos (modelo, max_tokens, preâmbulo, campos removidos)")
+	fs.Usage = func() { fmt.Fprint(os.Stderr, serveUsageText); fs.PrintDefaults() }
+	_ = fs.Parse(args)
+	if fs.NArg() > 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	s := &serveServer{profiles: map[string]*serveProfile{}}
+	names := sortedKeys(cfg.Profiles)
+	if *list != "" {
+		if names, err = parseProfileList(cfg, *list); err != nil {
+			return err
+		}
+	}
+	if len(names) == 0 {
+		names = []string{chooseNonEmpty(cfg.Default, "default")} // sem profiles: o da linha de comando e do ambiente
+	}
+	// cada profile tem as próprias settings (gateway, retry, transport) e cliente
+	for _, name := range names {
+		st, err := resolveSettings(cfg, &Flags{Profile: name, Temp: -1, Ephemeral: *eph})
+		if err != nil {
+			return fmt.Errorf("profile %s: %w", name, err)
+		}
+		client, err := buildClient(st)
+		if err != nil {
+			return fmt.Errorf("profile %s: %w", name, err)
+		}
+		s.profiles[name] = &serveProfile{name: name, st: st, client: client}
+		s.order = append(s.order, name)
+	}
+	sort.Strings(s.order)
+	s.def = chooseNonEmpty(*def, cfg.Default)
+	if s.profiles[s.def] == nil {
+		if *def != "" {
+			return fmt.Errorf("--profile %s não está entre os servidos (%s)", *def, strings.Join(s.order, ", "))
+		}
+		s.def = s.order[0]
+	}
+	initAppLog(s.profiles[s.def].st.logLevel, s.def, "")
+
+	if *authPath != "" {
+		if s.auth, err = loadServeAuth(*authPath, s.profiles); err != nil {
+			return err
+		}
+	} else if host, _, err := net.SplitHostPort(*listen); err == nil {
+		if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+			fmt.Fprintln(os.Stderr, "aviso: escutando fora do loopback sem --auth; qualquer um na rede poderá usar a sua chave")
+		}
+	}
+	if *rules != "" {
+		if s.rules, err = loadServeRules(*rules, s); err != nil {
+			return err
+		}
+	}
+	if s.usage, err = loadServeUsage(serveUsagePath()); err != nil {
+		return err
+	}
+	if *audit || cfg.Audit.Enabled {
+		if err := openAudit(cfg.Audit); err != nil {
+			return err
+		}
+	}
+
+	enableMetrics()
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/chat/completions", s.chat)
+	mux.HandleFunc("GET /v1/models", s.models)
+	mux.HandleFunc("GET /metrics", s.metrics)
+
+	users := "sem autenticação"
+	if s.auth != nil {
+		users = fmt.Sprintf("%d usuários", len(s.auth.Users))
+	}
+	if len(s.rules) > 0 {
+		users += fmt.Sprintf(" • %d regras", len(s.rules))
+	}
+	if auditLog != nil {
+		users += " • " + auditLog.summary()
+	}
+	fmt.Fprintf(os.Stderr, "gptcli serve • profiles=%s (padrão %s) • %s • http://%s/v1\n", strings.Join(s.order, ","), s.def, users, *listen)
+
+	// no ctrl+c, espera os pedidos em andamento e grava o uso pendente
+	srv := &http.Server{Addr: *listen, Handler: mux}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go flushTelemetryEvery(ctx, serveTelemetryFlush)
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdown)
+	}()
+	if err = srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	<-closed
+	s.usage.flush()
+	flushTelemetry()
+	return nil
+}
+
+// serveError responde no formato de erro da API da OpenAI.
+func serveError(rw http.ResponseWriter, status int, typ, code, msg string) {
+	if aw, ok := rw.(*auditWriter); ok {
+		aw.err = msg
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+	_ = json.NewEncoder(rw).Encode(serveErrorBody(typ, code, msg))
+}
+
+func serveErrorBody(typ, code, msg string) map[string]any {
+	return map[string]any{"error": map[string]any{
+		"message": msg, "type": typ, "code": code, "param": nil,
+	}}
+}
+
+// upstreamErrorFields traduz um erro do upstream: o status e a mensagem da
+// API quando ela respondeu; 502 nos demais (rede, TLS, stream cortado).
+func upstreamErrorFields(err error) (status int, typ, code, msg string) {
+	var apiErr *openai.Error
+	if errors.As(err, &apiErr) && apiErr.StatusCode >= 400 {
+		return apiErr.StatusCode, chooseNonEmpty(apiErr.Type, "upstream_error"), apiErr.Code, chooseNonEmpty(apiErr.Message, err.Error())
+	}
+	return http.StatusBadGateway, "upstream_error", "", err.Error()
+}
+
+func upstreamError(rw http.ResponseWriter, err error) {
+	status, typ, code, msg := upstreamErrorFields(err)
+	serveError(rw, status, typ, code, msg)
+}
+
+// authenticate devolve o usuário do token; sem --auth, um usuário anônimo
+// sem restrições.
+func (s *serveServer) authenticate(rw http.ResponseWriter, r *http.Request) (*serveUser, bool) {
+	if s.auth == nil {
+		return &serveUser{}, true
+	}
+	token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	u := s.auth.lookup(strings.TrimSpace(token))
+	if u == nil {
+		serveError(rw, http.StatusUnauthorized, "invalid_request_error", "invalid_api_key", "token inválido ou ausente (Authorization: Bearer <token>)")
+		return nil, false
+	}
+	return u, true
+}
+
+// pickProfile escolhe o profile: o header X-Gptcli-Profile, um model com o
+// nome de um profile ou o padrão (o primeiro permitido, se o padrão não for).
+func (s *serveServer) pickProfile(r *http.Request, u *serveUser, model string) (*serveProfile, error) {
+	name := r.Header.Get("X-Gptcli-Profile")
+	if name == "" && s.profiles[model] != nil {
+		name = model
+	}
+	if name == "" {
+		name = s.def
+		if !u.allows(name) {
+			name = u.Profiles[0]
+		}
+	}
+	p := s.profiles[name]
+	switch {
+	case p == nil:
+		return nil, fmt.Errorf("profile %q não existe (servidos: %s)", name, strings.Join(s.order, ", "))
+	case !u.allows(name):
+		return nil, fmt.Errorf("%s não tem acesso ao profile %q", u.Name, name)
+	}
+	return p, nil
+}
+
+func (s *serveServer) models(rw http.ResponseWriter, r *http.Request) {
+	u, ok := s.authenticate(rw, r)
+	if !ok {
+		return
+	}
+	var data []map[string]any
+	for _, name := range s.order {
+		if u.allows(name) {
+			data = append(data, map[string]any{"id": name, "object": "model", "created": 0, "owned_by": "gptcli"})
+		}
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(rw).Encode(map[string]any{"object": "list", "data": data})
+}
+
+// metrics exige o mesmo token de /v1: as séries mostram o tráfego por modelo
+// e profile.
+func (s *serveServer) metrics(rw http.ResponseWriter, r *http.Request) {
+	if _, ok := s.authenticate(rw, r); !ok {
+		return
+	}
+	promStats.handler(rw, r)
+}
+
+func (s *serveServer) chat(w http.ResponseWriter, r *http.Request) {
+	rw := &auditWriter{ResponseWriter: w}
+	a := &auditEntry{Mode: "serve", Remote: r.RemoteAddr, started: time.Now()}
+	ctx, span := startSpan(r.Context(), "gptcli.serve")
+	r = r.WithContext(ctx)
+	defer func() {
+		a.Status, a.Error = rw.status, chooseNonEmpty(a.Error, rw.err)
+		auditLog.record(a)
+		span.setAttr("gen_ai.request.model", a.Model)
+		span.setAttr("gptcli.profile", a.Profile)
+		span.setAttr("gen_ai.usage.input_tokens", a.PromptTokens)
+		span.setAttr("gen_ai.usage.output_tokens", a.CompletionTokens)
+		span.setAttr("http.response.status_code", a.Status)
+		var err error
+		if a.Error != "" {
+			err = errors.New(a.Error)
+		}
+		span.end(err)
+	}()
+	u, ok := s.authenticate(rw, r)
+	if !ok {
+		return
+	}
+	a.User = u.Name
+	raw, err := io.ReadAll(http.MaxBytesReader(rw, r.Body, maxServeBody))
+	if err != nil {
+		serveError(rw, http.StatusRequestEntityTooLarge, "invalid_request_error", "", "corpo do pedido muito grande ou ilegível")
+		return
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber() // números repassados sem virar float
+	var body map[string]any
+	if err := dec.Decode(&body); err != nil {
+		serveError(rw, http.StatusBadRequest, "invalid_request_error", "", "json inválido: "+err.Error())
+		return
+	}
+	auditLog.auditPrompt(a, body["messages"])
+	model, _ := body["model"].(string)
+	a.Model = model
+	p, err := s.pickProfile(r, u, model)
+	if err != nil {
+		serveError(rw, http.StatusForbidden, "invalid_request_error", "model_not_allowed", err.Error())
+		return
+	}
+	if model == "" || model == p.name {
+		body["model"] = p.st.model
+	}
+	if applied := applyServeRules(s.rules, body, u.Name, p.name); len(applied) > 0 {
+		a.Rules = applied
+		rw.Header().Set("X-Gptcli-Rules", strings.Join(applied, ","))
+	}
+	model, _ = body["model"].(string)
+	a.Profile, a.Model = p.name, model
+	var reserved int64
+	if u.DailyTokens > 0 {
+		estimate := int64(estimateTokens(string(raw))) + chooseInt64(requestMaxTokens(body), p.st.maxTokens, serveReserveReply)
+		committed, ok := s.usage.reserve(u.Name, u.DailyTokens, estimate)
+		if !ok {
+			serveError(rw, http.StatusTooManyRequests, "insufficient_quota", "daily_quota_exceeded",
+				fmt.Sprintf("cota diária de %d tokens esgotada para %s (usados ou reservados: %d); renova à meia-noite", u.DailyTokens, u.Name, committed))
+			return
+		}
+		reserved = estimate
+		rw.Header().Set("X-Gptcli-Quota-Remaining", fmt.Sprint(u.DailyTokens-committed))
+	}
+
+	stream, _ := body["stream"].(bool)
+	a.Stream = stream
+	wantsUsage := false
+	if stream {
+		// o uso chega num chunk final; só vai ao cliente se ele pediu
+		opts, _ := body["stream_options"].(map[string]any)
+		if opts == nil {
+			opts = map[string]any{}
+		}
+		wantsUsage, _ = opts["include_usage"].(bool)
+		opts["include_usage"] = true
+		body["stream_options"] = opts
+	}
+	if raw, err = json.Marshal(body); err != nil {
+		s.usage.release(u.Name, reserved)
+		serveError(rw, http.StatusBadRequest, "invalid_request_error", "", err.Error())
+		return
+	}
+
+	done, started := promStats.begin(), time.Now()
+	var usage openai.CompletionUsage
+	if stream {
+		err = s.relayStream(rw, r, p, raw, wantsUsage, &usage)
+	} else {
+		err = s.relay(rw, r, p, raw, &usage)
+	}
+	done()
+	promStats.observe(promKey{"serve", model, p.name}, time.Since(started),
+		promUsage{usage.PromptTokens, usage.CompletionTokens, usage.PromptTokensDetails.CachedTokens, usage.CompletionTokensDetails.ReasoningTokens}, 0, err)
+	var apiErr *openai.Error
+	s.usage.add(u.Name, reserved, usage, errors.As(err, &apiErr) && apiErr.StatusCode >= 400)
+	a.PromptTokens, a.CompletionTokens = usage.PromptTokens, usage.CompletionTokens
+	if err != nil {
+		a.Error = err.Error()
+	}
+}
+
+// requestMaxTokens devolve o limite de resposta do pedido (0 = não definido).
+func requestMaxTokens(body map[string]any) int64 {
+	for _, k := range []string{"max_completion_tokens", "max_tokens"} {
+		switch v := body[k].(type) {
+		case json.Number:
+			if n, err := v.Int64(); err == nil && n > 0 {
+				return n
+			}
+		case int64:
+			if v > 0 {
+				return v
+			}
+		}
+	}
+	return 0
+}
+
+func (s *serveServer) relay(rw http.ResponseWriter, r *http.Request, p *serveProfile, raw []byte, usage *openai.CompletionUsage) error {
+	resp, err := p.client.Chat.Completions.New(r.Context(), openai.ChatCompletionNewParams{},
+		option.WithRequestBody("application/json", raw))
+	if err != nil {
+		upstreamError(rw, err)
+		return err
+	}
+	*usage = resp.Usage
+	rw.Header().Set("Content-Type", "application/json")
+	_, err = io.WriteString(rw, resp.RawJSON())
+	return err
+}
+
+// relayStream repassa os chunks como SSE, um a um: o próximo só é lido do
+// upstream depois que o anterior foi escrito, então um cliente lento segura o
+// upstream em vez de acumular memória, e um que para de ler por mais de
+// serveWriteTimeout é desconectado. Se o cliente sai, o contexto do pedido
+// cancela a chamada upstream. O status só é enviado com o primeiro chunk
+// (ou heartbeat), para um erro logo de cara (chave, cota) manter o seu
+// código; depois disso, um erro vira um evento no próprio stream.
+func (s *serveServer) relayStream(rw http.ResponseWriter, r *http.Request, p *serveProfile, raw []byte, wantsUsage bool, usage *openai.CompletionUsage) error {
+	ctx, cancel := context.WithCancel(r.Context())
+	stream := p.client.Chat.Completions.NewStreaming(ctx, openai.ChatCompletionNewParams{},
+		option.WithRequestBody("application/json", raw), option.WithMiddleware(streamFallback))
+	// o upstream é lido numa goroutine só para o heartbeat poder sair
+	// enquanto ele pensa; o canal sem buffer mantém um chunk por vez
+	chunks := make(chan openai.ChatCompletionChunk)
+	go func() {
+		defer close(chunks)
+		for stream.Next() {
+			select {
+			case chunks <- stream.Current():
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	defer func() {
+		cancel()
+		for range chunks { // espera a goroutine antes de fechar o stream
+		}
+		stream.Close()
+	}()
+
+	rc := http.NewResponseController(rw)
+	started := false
+	send := func(frame string) error {
+		if !started {
+			h := rw.Header()
+			h.Set("Content-Type", "text/event-stream")
+			h.Set("Cache-Control", "no-cache")
+			h.Set("X-Accel-Buffering", "no") // o nginx não segura o stream
+			started = true
+		}
+		_ = rc.SetWriteDeadline(time.Now().Add(serveWriteTimeout))
+		if _, err := io.WriteString(rw, frame); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+
+	heartbeat := time.NewTicker(serveHeartbeat)
+	defer heartbeat.Stop()
+relay:
+	for {
+		select {
+		case <-heartbeat.C:
+			// comentário SSE: proxies e clientes não desistem enquanto o
+			// modelo raciocina antes do primeiro token
+			if err := send(": keep-alive\n\n"); err != nil {
+				return err
+			}
+		case chunk, ok := <-chunks:
+			if !ok {
+				break relay
+			}
+			if chunk.Usage.TotalTokens > 0 {
+				*usage = chunk.Usage
+				if !wantsUsage && len(chunk.Choices) == 0 {
+					continue
+				}
+			}
+			if err := send("data: " + chunk.RawJSON() + "\n\n"); err != nil {
+				return err
+			}
+			heartbeat.Reset(serveHeartbeat)
+		}
+	}
+	if err := stream.Err(); err != nil {
+		if r.Context().Err() != nil {
+			return fmt.Errorf("cliente desconectou: %w", err)
+		}
+		if !started {
+			upstreamError(rw, err)
+			return err
+		}
+		_, typ, code, msg := upstreamErrorFields(err)
+		b, _ := json.Marshal(serveErrorBody(typ, code, msg))
+		_ = send("data: " + string(b) + "\n\n")
+		return err
+	}
+	return send("data: [DONE]\n\n")
+}
+
+// streamFallback traduz a resposta de um backend que ignora "stream": true e
+// devolve o JSON inteiro: a resposta vira a sequência SSE equivalente, e o
+// cliente recebe um stream do mesmo jeito.
+func streamFallback(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+	resp, err := next(req)
+	if err != nil || resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+		return resp, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	sse, err := completionToSSE(body)
+	if err != nil {
+		return nil, err
+	}
+	resp.Header.Set("Content-Type", "text/event-stream")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Body = io.NopCloser(bytes.NewReader(sse))
+	return resp, nil
+}
+
+// completionToSSE monta os chunks de uma resposta completa: o conteúdo de
+// cada choice, o finish_reason e, se houver, o uso.
+func completionToSSE(body []byte) ([]byte, error) {
+	var c struct {
+		ID      string `json:"id"`
+		Created int64  `json:"created"`
+		Model   string `json:"model"`
+		Choices []struct {
+			Index        int            `json:"index"`
+			Message      map[string]any `json:"message"`
+			FinishReason string         `json:"finish_reason"`
+		} `json:"choices"`
+		Usage json.RawMessage `json:"usage"`
+	}
+	if err := json.Unmarshal(body, &c); err != nil {
+		return nil, fmt.Errorf("resposta sem stream ilegível: %w", err)
+	}
+	var out bytes.Buffer
+	frame := func(choices []any, usage json.RawMessage) {
+		chunk := map[string]any{"id": c.ID, "object": "chat.completion.chunk", "created": c.Created, "model": c.Model, "choices": choices}
+		if usage != nil {
+			chunk["usage"] = usage
+		}
+		b, _ := json.Marshal(chunk)
+		fmt.Fprintf(&out, "data: %s\n\n", b)
+	}
+	for _, ch := range c.Choices {
+		delta := ch.Message
+		if calls, ok := delta["tool_calls"].([]any); ok {
+			for i, tc := range calls { // nos chunks, cada tool call leva a posição
+				if m, ok := tc.(map[string]any); ok {
+					m["index"] = i
+				}
+			}
+		}
+		frame([]any{map[string]any{"index": ch.Index, "delta": delta, "finish_reason": nil}}, nil)
+		frame([]any{map[string]any{"index": ch.Index, "delta": map[string]any{}, "finish_reason": ch.FinishReason}}, nil)
+	}
+	if len(c.Usage) > 0 && string(c.Usage) != "null" {
+		frame([]any{}, c.Usage)
+	}
+	out.WriteString("data: [DONE]\n\n")
+	return out.Bytes(), nil
+}
+
+// ---------- gptcli serve usage ----------
+
+func serveUsageCmd(args []string) error {
+	fs := flag.NewFlagSet("serve usage", flag.ExitOnError)
+	days := fs.Int("days", 7, "dias mostrados, contando hoje")
+	_ = fs.Parse(args)
+	u, err := loadServeUsage(serveUsagePath())
+	if err != nil {
+		return err
+	}
+	since := time.Now().AddDate(0, 0, 1-max(*days, 1)).Format("2006-01-02")
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "DIA\tUSUÁRIO\tPEDIDOS\tENTRADA\tSAÍDA\tTOTAL")
+	for _, day := range sortedKeys(u.Days) {
+		if day < since {
+			continue
+		}
+		for _, name := range sortedKeys(u.Days[day]) {
+			d := u.Days[day][name]
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%d\n", day, chooseNonEmpty(name, "(anônimo)"), d.Requests, d.PromptTokens, d.CompletionTokens, d.total())
+		}
+	}
+	return tw.Flush()
+}
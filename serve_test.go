@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/openai/openai-go/v2"
+	"github.com/openai/openai-go/v2/option"
+)
+
+const completionJSON = `{
+	"id": "chatcmpl-1", "object": "chat.completion", "created": 1700000000, "model": "gpt-5-mini",
+	"choices": [{
+		"index": 0, "finish_reason": "tool_calls",
+		"message": {"role": "assistant", "content": "olá", "tool_calls": [
+			{"id": "call_a", "type": "function", "function": {"name": "ler", "arguments": "{}"}},
+			{"id": "call_b", "type": "function", "function": {"name": "ver", "arguments": "{}"}}
+		]}
+	}],
+	"usage": {"prompt_tokens": 7, "completion_tokens": 3, "total_tokens": 10}
+}`
+
+// sseFrames separa os eventos "data:" de um corpo SSE, sem comentários.
+func sseFrames(t *testing.T, body string) []string {
+	t.Helper()
+	var frames []string
+	for _, ev := range strings.Split(strings.TrimSuffix(body, "\n\n"), "\n\n") {
+		if strings.HasPrefix(ev, ":") {
+			continue
+		}
+		data, ok := strings.CutPrefix(ev, "data: ")
+		if !ok {
+			t.Fatalf("evento sem data: %q", ev)
+		}
+		frames = append(frames, data)
+	}
+	return frames
+}
+
+func TestCompletionToSSE(t *testing.T) {
+	sse, err := completionToSSE([]byte(completionJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	frames := sseFrames(t, string(sse))
+	if len(frames) != 4 || frames[3] != "[DONE]" {
+		t.Fatalf("frames = %q; queria conteúdo, finish_reason, uso e [DONE]", frames)
+	}
+	var chunks []openai.ChatCompletionChunk
+	for _, f := range frames[:3] {
+		var c openai.ChatCompletionChunk
+		if err := json.Unmarshal([]byte(f), &c); err != nil {
+			t.Fatalf("chunk %q: %v", f, err)
+		}
+		if c.ID != "chatcmpl-1" || c.Model != "gpt-5-mini" || c.Created != 1700000000 || string(c.Object) != "chat.completion.chunk" {
+			t.Errorf("cabeçalho do chunk = %+v", c)
+		}
+		chunks = append(chunks, c)
+	}
+
+	delta := chunks[0].Choices[0].Delta
+	if delta.Content != "olá" || delta.Role != "assistant" || chunks[0].Choices[0].FinishReason != "" {
+		t.Errorf("primeiro chunk = %+v", chunks[0].Choices[0])
+	}
+	if len(delta.ToolCalls) != 2 || delta.ToolCalls[0].Index != 0 || delta.ToolCalls[1].Index != 1 || delta.ToolCalls[1].Function.Name != "ver" {
+		t.Errorf("tool calls = %+v", delta.ToolCalls)
+	}
+	if fr := chunks[1].Choices[0].FinishReason; fr != "tool_calls" {
+		t.Errorf("finish_reason = %q", fr)
+	}
+	if len(chunks[2].Choices) != 0 || chunks[2].Usage.TotalTokens != 10 || chunks[2].Usage.PromptTokens != 7 {
+		t.Errorf("chunk de uso = %+v", chunks[2])
+	}
+}
+
+func TestCompletionToSSEWithoutUsage(t *testing.T) {
+	sse, err := completionToSSE([]byte(`{"id":"x","choices":[{"index":0,"message":{"content":"oi"},"finish_reason":"stop"}],"usage":null}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frames := sseFrames(t, string(sse)); len(frames) != 3 || frames[2] != "[DONE]" {
+		t.Fatalf("frames = %q; queria conteúdo, finish_reason e [DONE]", frames)
+	}
+	if _, err := completionToSSE([]byte("<html>")); err == nil || !strings.Contains(err.Error(), "ilegível") {
+		t.Fatalf("erro = %v, queria ilegível", err)
+	}
+}
+
+func TestRelayStream(t *testing.T) {
+	streamed := "data: {\"id\":\"c\",\"object\":\"chat.completion.chunk\",\"model\":\"m\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"oi\"}}]}\n\n" +
+		"data: {\"id\":\"c\",\"object\":\"chat.completion.chunk\",\"model\":\"m\",\"choices\":[],\"usage\":{\"prompt_tokens\":2,\"completion_tokens\":1,\"total_tokens\":3}}\n\n" +
+		"data: [DONE]\n\n"
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantsUsage  bool
+		wantFrames  int // sem contar o [DONE]
+		wantTotal   int64
+	}{
+		{name: "backend sem stream", contentType: "application/json", body: completionJSON, wantsUsage: true, wantFrames: 3, wantTotal: 10},
+		{name: "stream repassado", contentType: "text/event-stream", body: streamed, wantsUsage: true, wantFrames: 2, wantTotal: 3},
+		{name: "uso omitido se não pedido", contentType: "text/event-stream", body: streamed, wantFrames: 1, wantTotal: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				io.WriteString(w, tt.body)
+			}))
+			defer up.Close()
+			p := &serveProfile{client: openai.NewClient(option.WithBaseURL(up.URL), option.WithAPIKey("k"), option.WithMaxRetries(0))}
+			req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+			rec := httptest.NewRecorder()
+			var usage openai.CompletionUsage
+			raw := []byte(`{"model":"m","stream":true,"messages":[{"role":"user","content":"oi"}]}`)
+			if err := (&serveServer{}).relayStream(rec, req, p, raw, tt.wantsUsage, &usage); err != nil {
+				t.Fatal(err)
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != "text/event-stream" {
+				t.Errorf("Content-Type = %q", ct)
+			}
+			frames := sseFrames(t, rec.Body.String())
+			if len(frames) != tt.wantFrames+1 || frames[len(frames)-1] != "[DONE]" {
+				t.Fatalf("frames = %q", frames)
+			}
+			if usage.TotalTokens != tt.wantTotal {
+				t.Errorf("uso = %d, queria %d", usage.TotalTokens, tt.wantTotal)
+			}
+		})
+	}
+}
+
+func TestServeUsageQuota(t *testing.T) {
+	u := &serveUsage{Days: map[string]map[string]*serveDay{}, reserved: map[string]int64{}, flushing: true}
+	if _, ok := u.reserve("ana", 1000, 600); !ok {
+		t.Fatal("a primeira reserva cabe na cota")
+	}
+	if committed, ok := u.reserve("ana", 1000, 500); ok || committed != 600 {
+		t.Fatalf("reserva que estoura a cota: comprometido %d, ok %v", committed, ok)
+	}
+	u.add("ana", 600, openai.CompletionUsage{PromptTokens: 100, CompletionTokens: 50, TotalTokens: 150}, false)
+	if _, ok := u.reserve("ana", 1000, 850); !ok {
+		t.Fatal("com a reserva trocada pelo uso real, sobram 850")
+	}
+	// sem chunk de uso, vale a reserva; recusado pelo upstream, não conta
+	u.add("ana", 850, openai.CompletionUsage{}, false)
+	if _, ok := u.reserve("ana", 1000, 1); ok {
+		t.Fatal("pedido sem uso informado deveria ser cobrado pela reserva")
+	}
+	if _, ok := u.reserve("bia", 1000, 900); !ok {
+		t.Fatal("a cota é por usuário")
+	}
+	u.add("bia", 900, openai.CompletionUsage{}, true)
+	if d := u.Days[today()]["bia"]; d.total() != 0 || d.Requests != 1 {
+		t.Fatalf("pedido recusado cobrado: %+v", d)
+	}
+}
+
+func TestServeMetricsRequiresToken(t *testing.T) {
+	enableMetrics()
+	s := &serveServer{auth: &serveAuth{byHash: map[string]*serveUser{tokenHash("t-ana"): {Name: "ana"}}}}
+	for _, tt := range []struct {
+		auth string
+		want int
+	}{
+		{"", http.StatusUnauthorized},
+		{"Bearer errado", http.StatusUnauthorized},
+		{"Bearer t-ana", http.StatusOK},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		if tt.auth != "" {
+			r.Header.Set("Authorization", tt.auth)
+		}
+		rec := httptest.NewRecorder()
+		s.metrics(rec, r)
+		if rec.Code != tt.want {
+			t.Errorf("Authorization %q: status %d, queria %d", tt.auth, rec.Code, tt.want)
+		}
+	}
+}
+
+func TestServeUsagePrunesOldDays(t *testing.T) {
+	u := &serveUsage{path: filepath.Join(t.TempDir(), "serve-usage.json"), Days: map[string]map[string]*serveDay{
+		"2020-01-01": {"ana": {Requests: 1}},
+		today():      {"ana": {Requests: 2}},
+	}}
+	if err := u.saveLocked(); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := loadServeUsage(u.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.Days) != 1 || loaded.Days[today()]["ana"].Requests != 2 {
+		t.Fatalf("dias gravados: %v", sortedKeys(loaded.Days))
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// ===================== Serve: regras =====================
+//
+// `gptcli serve --rules regras.yaml` aplica regras declarativas a cada pedido
+// antes de repassá-lo ao upstream: trocar o modelo, limitar max_tokens, pôr
+// um preâmbulo de sistema e remover campos. Uma regra vale para os pedidos
+// que casam com todos os seus filtros (users, profiles, models; vazio = todos).
+// Todas as que casam são aplicadas, na ordem do arquivo, e os filtros olham o
+// pedido como chegou, antes de qualquer regra mudar o modelo.
+
+type serveRule struct {
+	Name     string   `yaml:"name,omitempty"`
+	Users    []string `yaml:"users,omitempty"`
+	Profiles []string `yaml:"profiles,omitempty"`
+	Models   []string `yaml:"models,omitempty"` // padrões como gpt-5*, comparados com o modelo já resolvido
+
+	ForceModel     string   `yaml:"force_model,omitempty"`
+	MaxTokens      int64    `yaml:"max_tokens,omitempty"`      // teto de max_tokens e max_completion_tokens; sem nenhum no pedido, vira max_tokens
+	SystemPreamble string   `yaml:"system_preamble,omitempty"` // mensagem de sistema posta antes de todas as outras
+	Strip          []string `yaml:"strip,omitempty"`           // campos removidos do corpo, ex: logit_bias
+}
+
+// serveProtected não podem sair por strip: sem eles não há pedido, ou o
+// gateway deixa de contar o uso do stream.
+var serveProtected = []string{"model", "messages", "stream", "stream_options"}
+
+func loadServeRules(file string, s *serveServer) ([]serveRule, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var f struct {
+		Rules []serveRule `yaml:"rules"`
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true) // uma ação com o nome errado não pode passar calada
+	if err := dec.Decode(&f); err != nil {
+		return nil, fmt.Errorf("%s inválido: %w", file, err)
+	}
+	for i := range f.Rules {
+		r := &f.Rules[i]
+		if r.Name == "" {
+			r.Name = fmt.Sprintf("regra-%d", i+1)
+		}
+		where := fmt.Sprintf("%s: %s", file, r.Name)
+		if r.ForceModel == "" && r.MaxTokens == 0 && r.SystemPreamble == "" && len(r.Strip) == 0 {
+			return nil, fmt.Errorf("%s: nenhuma ação (force_model, max_tokens, system_preamble ou strip)", where)
+		}
+		if r.MaxTokens < 0 {
+			return nil, fmt.Errorf("%s: max_tokens não pode ser negativo", where)
+		}
+		for _, u := range r.Users {
+			if s.auth == nil {
+				return nil, fmt.Errorf("%s: users só vale com --auth", where)
+			}
+			if !containsString(serveUserNames(s.auth), u) {
+				return nil, fmt.Errorf("%s: usuário %q não está no arquivo de --auth", where, u)
+			}
+		}
+		for _, p := range r.Profiles {
+			if s.profiles[p] == nil {
+				return nil, fmt.Errorf("%s: profile %q não está sendo servido", where, p)
+			}
+		}
+		for _, m := range r.Models {
+			if _, err := path.Match(m, ""); err != nil {
+				return nil, fmt.Errorf("%s: padrão de modelo inválido %q", where, m)
+			}
+		}
+		for _, k := range r.Strip {
+			if containsString(serveProtected, k) {
+				return nil, fmt.Errorf("%s: %s não pode ser removido", where, k)
+			}
+		}
+	}
+	return f.Rules, nil
+}
+
+func serveUserNames(a *serveAuth) []string {
+	names := make([]string, len(a.Users))
+	for i, u := range a.Users {
+		names[i] = u.Name
+	}
+	return names
+}
+
+func (r *serveRule) matches(user, profile, model string) bool {
+	if len(r.Users) > 0 && !containsString(r.Users, user) {
+		return false
+	}
+	if len(r.Profiles) > 0 && !containsString(r.Profiles, profile) {
+		return false
+	}
+	if len(r.Models) == 0 {
+		return true
+	}
+	for _, m := range r.Models {
+		if ok, _ := path.Match(m, model); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// applyServeRules altera o corpo do pedido e devolve os nomes das regras
+// aplicadas.
+func applyServeRules(rules []serveRule, body map[string]any, user, profile string) []string {
+	model, _ := body["model"].(string)
+	var applied []string
+	var preambles []any
+	for i := range rules {
+		r := &rules[i]
+		if !r.matches(user, profile, model) {
+			continue
+		}
+		applied = append(applied, r.Name)
+		if r.ForceModel != "" {
+			body["model"] = r.ForceModel
+		}
+		if r.MaxTokens > 0 {
+			capTokens(body, r.MaxTokens)
+		}
+		if r.SystemPreamble != "" {
+			preambles = append(preambles, map[string]any{"role": "system", "content": r.SystemPreamble})
+		}
+		for _, k := range r.Strip {
+			delete(body, k)
+		}
+	}
+	if msgs, ok := body["messages"].([]any); ok && len(preambles) > 0 {
+		body["messages"] = append(preambles, msgs...)
+	}
+	return applied
+}
+
+// capTokens limita os campos de tamanho da resposta que o pedido trouxer.
+func capTokens(body map[string]any, limit int64) {
+	found := false
+	for _, k := range []string{"max_tokens", "max_completion_tokens"} {
+		v, ok := body[k]
+		if !ok {
+			continue
+		}
+		found = true
+		if n, ok := v.(json.Number); ok {
+			if i, err := n.Int64(); err == nil && i > 0 && i <= limit {
+				continue
+			}
+		}
+		body[k] = limit
+	}
+	if !found {
+		body["max_tokens"] = limit
+	}
+}
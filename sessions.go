@@ -0,0 +1,316 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	openai "github.com/openai/openai-go/v2"
+	"github.com/openai/openai-go/v2/shared"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// ===================== Sessions =====================
+//
+// Sessões nomeadas ficam em ~/.config/gptcli/sessions/<nome>.yaml e guardam
+// system, formato e turnos, para continuar uma conversa com --session.
+
+var sessionNameRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+func sessionsDir() string { return filepath.Join(configDir(), "sessions") }
+
+func sessionPath(name string) string { return filepath.Join(sessionsDir(), name+".yaml") }
+
+func validSessionName(name string) error {
+	if !sessionNameRe.MatchString(name) {
+		return fmt.Errorf("nome de sessão inválido %q (use letras, números, '.', '_' ou '-')", name)
+	}
+	return nil
+}
+
+func sessionExists(name string) bool {
+	_, err := os.Stat(sessionPath(name))
+	return err == nil
+}
+
+func loadSession(name string) (*Session, error) {
+	if err := validSessionName(name); err != nil {
+		return nil, err
+	}
+	b, err := readStorage(sessionPath(name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("sessão %q não encontrada", name)
+		}
+		return nil, err
+	}
+	var s Session
+	if err := yaml.Unmarshal(b, &s); err != nil {
+		return nil, fmt.Errorf("sessão %q corrompida: %w", name, err)
+	}
+	s.Name = name
+	return &s, nil
+}
+
+// save grava a sessão se ela tiver nome; sessões efêmeras são ignoradas.
+func (s *Session) save() error {
+	if s.Name == "" || ephemeral {
+		return nil
+	}
+	if err := validSessionName(s.Name); err != nil {
+		return err
+	}
+	now := time.Now()
+	if s.Created.IsZero() {
+		s.Created = now
+	}
+	s.Updated = now
+	return s.write()
+}
+
+// write grava a sessão como está, sem mexer nas datas.
+func (s *Session) write() error {
+	b, err := yaml.Marshal(s)
+	if err != nil {
+		return err
+	}
+	ensureDir(sessionsDir())
+	return writeStorage(sessionPath(s.Name), b, 0o600)
+}
+
+// fork copia o estado atual para uma nova sessão nomeada.
+func (s *Session) fork(name string) (*Session, error) {
+	if err := validSessionName(name); err != nil {
+		return nil, err
+	}
+	if sessionExists(name) {
+		return nil, fmt.Errorf("sessão %q já existe", name)
+	}
+	f := *s
+	f.Turns = append([]Turn(nil), s.Turns...)
+	f.Name, f.Parent = name, s.Name
+	f.Tags = append([]string(nil), s.Tags...)
+	f.Created, f.Updated = time.Time{}, time.Time{}
+	if err := f.save(); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+func listSessions() ([]*Session, error) {
+	entries, err := os.ReadDir(sessionsDir())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var out []*Session
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), ".yaml")
+		if !ok || e.IsDir() {
+			continue
+		}
+		s, err := loadSession(name)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "aviso:", err)
+			continue
+		}
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Updated.After(out[j].Updated) })
+	return out, nil
+}
+
+const sessionUsage = `uso: gptcli session <list|show|fork|tag|untag|title|delete> [args]
+  list [--tag t]         lista as sessões (mais recentes primeiro)
+  show <nome>            mostra os turnos da sessão
+  fork <origem> <nova>   duplica a sessão para explorar outro caminho
+  tag <nome> <tags...>   adiciona tags
+  untag <nome> <tags...> remove tags
+  title <nome> <texto>   define o título manualmente
+  delete <nome>          remove a sessão
+`
+
+func sessionCmd(args []string) error {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, sessionUsage)
+		os.Exit(2)
+	}
+	switch args[0] {
+	case "list":
+		tag := ""
+		if len(args) >= 3 && args[1] == "--tag" {
+			tag = args[2]
+		}
+		sessions, err := listSessions()
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "NOME\tTÍTULO\tTAGS\tTURNOS\tORIGEM\tATUALIZADA")
+		for _, s := range sessions {
+			if tag != "" && !containsString(s.Tags, tag) {
+				continue
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\t%s\n", s.Name, truncate(chooseNonEmpty(s.Title, "-"), 50),
+				chooseNonEmpty(strings.Join(s.Tags, ","), "-"), len(s.Turns),
+				chooseNonEmpty(s.Parent, "-"), s.Updated.Local().Format("2006-01-02 15:04"))
+		}
+		return tw.Flush()
+	case "tag", "untag":
+		if len(args) < 3 {
+			return fmt.Errorf("uso: gptcli session %s <nome> <tags...>", args[0])
+		}
+		s, err := loadSession(args[1])
+		if err != nil {
+			return err
+		}
+		for _, t := range args[2:] {
+			t = strings.TrimSpace(t)
+			switch {
+			case args[0] == "tag" && t != "" && !containsString(s.Tags, t):
+				s.Tags = append(s.Tags, t)
+			case args[0] == "untag":
+				s.Tags = removeString(s.Tags, t)
+			}
+		}
+		sort.Strings(s.Tags)
+		if err := s.save(); err != nil {
+			return err
+		}
+		fmt.Printf("(%s: %s)\n", s.Name, chooseNonEmpty(strings.Join(s.Tags, ", "), "sem tags"))
+		return nil
+	case "title":
+		if len(args) < 3 {
+			return errors.New("uso: gptcli session title <nome> <texto>")
+		}
+		s, err := loadSession(args[1])
+		if err != nil {
+			return err
+		}
+		s.Title = strings.TrimSpace(strings.Join(args[2:], " "))
+		return s.save()
+	case "show":
+		if len(args) < 2 {
+			return errors.New("uso: gptcli session show <nome>")
+		}
+		s, err := loadSession(args[1])
+		if err != nil {
+			return err
+		}
+		if s.Title != "" {
+			fmt.Printf("# %s\n\n", s.Title)
+		}
+		if s.System != "" {
+			fmt.Printf("[system]\n%s\n\n", s.System)
+		}
+		for _, t := range s.Turns {
+			fmt.Printf("[%s]\n%s\n", t.Role, t.Content)
+			for _, call := range t.ToolCalls {
+				fmt.Printf("⚙ %s %s\n", call.Name, call.Arguments)
+			}
+			for _, a := range t.Attachments {
+				fmt.Printf("📎 %s\n", a.describe())
+			}
+			fmt.Println()
+		}
+		return nil
+	case "fork":
+		if len(args) < 3 {
+			return errors.New("uso: gptcli session fork <origem> <nova>")
+		}
+		s, err := loadSession(args[1])
+		if err != nil {
+			return err
+		}
+		if _, err := s.fork(args[2]); err != nil {
+			return err
+		}
+		fmt.Printf("(sessão %s criada a partir de %s)\n", args[2], args[1])
+		return nil
+	case "delete":
+		if len(args) < 2 {
+			return errors.New("uso: gptcli session delete <nome>")
+		}
+		if err := validSessionName(args[1]); err != nil {
+			return err
+		}
+		if err := os.Remove(sessionPath(args[1])); err != nil {
+			if os.IsNotExist(err) {
+				return fmt.Errorf("sessão %q não encontrada", args[1])
+			}
+			return err
+		}
+		return nil
+	default:
+		fmt.Fprint(os.Stderr, sessionUsage)
+		os.Exit(2)
+	}
+	return nil
+}
+
+// ensureTitle gera um título curto para sessões nomeadas depois da primeira
+// troca. Falhas são silenciosas: o título é só conveniência para o `session list`.
+func ensureTitle(ctx context.Context, client openai.Client, sess *Session, st *settings) {
+	if sess.Name == "" || sess.Title != "" || len(sess.Turns) < 2 || st.titleModel == "off" {
+		return
+	}
+	model := st.titleModel
+	if model == "" {
+		// endpoints customizados raramente têm os modelos baratos da OpenAI
+		model = "gpt-5-nano"
+		if st.baseURL != "" {
+			model = st.model
+		}
+	}
+	// primeira pergunta e primeira resposta com texto (pula tool calls)
+	var b strings.Builder
+	seen := map[string]bool{}
+	for _, t := range sess.Turns {
+		if (t.Role == "user" || t.Role == "assistant") && t.Content != "" && len(t.ToolCalls) == 0 && !seen[t.Role] {
+			seen[t.Role] = true
+			fmt.Fprintf(&b, "%s: %s\n\n", t.Role, truncate(t.Content, 1500))
+		}
+	}
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+	resp, err := client.Chat.Completions.New(ctx, openai.ChatCompletionNewParams{
+		Model: shared.ChatModel(model),
+		Messages: []openai.ChatCompletionMessageParamUnion{
+			openai.SystemMessage("Crie um título curto (até 6 palavras) para esta conversa, no idioma dela. " +
+				"Responda só o título, sem aspas nem pontuação final."),
+			openai.UserMessage(b.String()),
+		},
+	})
+	if err != nil || len(resp.Choices) == 0 {
+		return
+	}
+	sess.Title = truncate(strings.Trim(strings.TrimSpace(resp.Choices[0].Message.Content), `"'.`), 80)
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func removeString(list []string, s string) []string {
+	out := list[:0]
+	for _, v := range list {
+		if v != s {
+			out = append(out, v)
+		}
+	}
+	return out
+}
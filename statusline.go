@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	openai "github.com/openai/openai-go/v2"
+)
+
+// ===================== REPL: linha de status =====================
+//
+// Uma linha acima do prompt com modelo, conversa, profile, formato, turnos,
+// tokens e custo acumulados na execução. Só aparece quando o stdout é um terminal;
+// /status liga e desliga.
+
+type replStatus struct {
+	enabled   bool
+	usage     openai.CompletionUsage
+	cost      float64
+	costKnown bool // algum turno usou um modelo com preço conhecido
+	unpriced  bool // algum turno usou um modelo sem preço: o custo é um piso
+
+	conversation string // conversa ativa, quando o REPL tem mais de uma (/new)
+}
+
+func newReplStatus() *replStatus {
+	st, err := os.Stdout.Stat()
+	return &replStatus{enabled: err == nil && st.Mode()&os.ModeCharDevice != 0}
+}
+
+// add soma o consumo de um turno, com o preço do modelo usado nele.
+func (s *replStatus) add(model string, u openai.CompletionUsage) {
+	s.usage.PromptTokens += u.PromptTokens
+	s.usage.CompletionTokens += u.CompletionTokens
+	s.usage.TotalTokens += u.TotalTokens
+	if u.TotalTokens == 0 {
+		return
+	}
+	info, ok := lookupModel(model)
+	if !ok {
+		s.unpriced = true
+		return
+	}
+	s.costKnown = true
+	s.cost += (float64(u.PromptTokens)*info.inPerMillion + float64(u.CompletionTokens)*info.outPerMillion) / 1e6
+}
+
+func (s *replStatus) line(st *settings, sess *Session, model string) string {
+	parts := []string{model}
+	if s.conversation != "" {
+		parts = append(parts, s.conversation)
+	}
+	if st.profName != "" {
+		parts = append(parts, "profile "+st.profName)
+	}
+	if st.personaName != "" {
+		parts = append(parts, "persona "+st.personaName)
+	}
+	parts = append(parts, chooseNonEmpty(sess.Format, "text"))
+	if sess.Name != "" {
+		parts = append(parts, "sessão "+sess.Name)
+	}
+	parts = append(parts, fmt.Sprintf("%d turnos", len(sess.Turns)),
+		fmt.Sprintf("%d tokens (%d+%d)", s.usage.TotalTokens, s.usage.PromptTokens, s.usage.CompletionTokens))
+	switch {
+	case s.costKnown && s.unpriced:
+		parts = append(parts, fmt.Sprintf("US$ %.4f+", s.cost))
+	case s.costKnown:
+		parts = append(parts, fmt.Sprintf("US$ %.4f", s.cost))
+	}
+	return strings.Join(parts, " • ")
+}
+
+func (s *replStatus) print(st *settings, sess *Session, model string) {
+	if !s.enabled {
+		return
+	}
+	line := "── " + s.line(st, sess, model)
+	if os.Getenv("NO_COLOR") == "" {
+		line = "\033[2m" + line + "\033[0m"
+	}
+	fmt.Println(line)
+}
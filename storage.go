@@ -0,0 +1,347 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ===================== Armazenamento cifrado =====================
+//
+// Com `encrypt_storage: true` no config.yaml, sessões, transcripts e
+// histórico são gravados com AES-256-GCM. A chave vem do keyring do sistema
+// (secret-tool no Linux, security no macOS), onde é criada na primeira vez,
+// ou de uma passphrase (GPTCLI_PASSPHRASE ou pergunta no terminal) passada
+// pelo PBKDF2. Arquivos em texto puro continuam legíveis, e a leitura
+// reconhece os cifrados pelo cabeçalho, então ligar a opção não quebra nada.
+//
+// Formato: magic | fonte (k/p) | salt[16] | impressão da chave[8] | nonce[12] | dados.
+
+const (
+	storageMagic          = "GPTCLIENC1"
+	storageHistoryTag     = "gptcli-enc:" // prefixo das linhas cifradas do history.txt
+	storageSaltSize       = 16
+	storageFPSize         = 8
+	storagePBKDF2Iters    = 600_000
+	keyringStorageAccount = "storage-key"
+)
+
+var storage struct {
+	once    sync.Once
+	encrypt bool
+	source  string // keyring|passphrase
+
+	mu   sync.Mutex
+	keys map[string][]byte // fonte+salt => chave, derivada uma vez por processo
+}
+
+// storageConfig lê encrypt_storage/storage_key do config uma vez; subcomandos
+// que não carregam o config (session, grep) gravam do mesmo jeito.
+func storageConfig() {
+	storage.once.Do(func() {
+		cfg, err := loadConfig()
+		if err != nil || cfg == nil || !cfg.EncryptStorage {
+			return
+		}
+		storage.encrypt = true
+		storage.source = cfg.StorageKey
+		if storage.source == "" {
+			storage.source = "passphrase"
+			if keyringAvailable() {
+				storage.source = "keyring"
+			}
+		}
+	})
+}
+
+// writeStorage grava data em path, cifrado se encrypt_storage estiver ligado.
+func writeStorage(path string, data []byte, perm os.FileMode) error {
+	storageConfig()
+	if storage.encrypt {
+		blob, err := sealStorage(data)
+		if err != nil {
+			return err
+		}
+		data = blob
+	}
+	return os.WriteFile(path, data, perm)
+}
+
+// readStorage lê path decifrando se ele tiver o cabeçalho de arquivo cifrado.
+func readStorage(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil || !bytes.HasPrefix(data, []byte(storageMagic)) {
+		return data, err
+	}
+	plain, err := openStorage(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return plain, nil
+}
+
+// storageRecord prepara uma linha para arquivos só de append (history.txt).
+func storageRecord(text string) (string, error) {
+	storageConfig()
+	if !storage.encrypt {
+		return text, nil
+	}
+	blob, err := sealStorage([]byte(text))
+	if err != nil {
+		return "", err
+	}
+	return storageHistoryTag + base64.StdEncoding.EncodeToString(blob) + "\n", nil
+}
+
+func sealStorage(plain []byte) ([]byte, error) {
+	salt := make([]byte, storageSaltSize)
+	if storage.source == "passphrase" {
+		var err error
+		if salt, err = storageSalt(); err != nil {
+			return nil, err
+		}
+	}
+	key, err := storageKey(storage.source, salt)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newStorageGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	header := append([]byte(storageMagic), storage.source[0])
+	header = append(header, salt...)
+	header = append(header, keyFingerprint(key)...)
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out := append(header, nonce...)
+	return gcm.Seal(out, nonce, plain, header), nil
+}
+
+func openStorage(blob []byte) ([]byte, error) {
+	headerLen := len(storageMagic) + 1 + storageSaltSize + storageFPSize
+	if len(blob) < headerLen+12 {
+		return nil, errors.New("arquivo cifrado truncado")
+	}
+	header := blob[:headerLen]
+	var source string
+	switch header[len(storageMagic)] {
+	case 'k':
+		source = "keyring"
+	case 'p':
+		source = "passphrase"
+	default:
+		return nil, errors.New("arquivo cifrado com fonte de chave desconhecida")
+	}
+	salt := header[len(storageMagic)+1 : len(storageMagic)+1+storageSaltSize]
+	key, err := storageKey(source, salt)
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal(keyFingerprint(key), header[headerLen-storageFPSize:]) {
+		storage.mu.Lock()
+		delete(storage.keys, source+string(salt)) // deixa tentar outra passphrase
+		storage.mu.Unlock()
+		return nil, fmt.Errorf("chave incorreta (%s)", source)
+	}
+	gcm, err := newStorageGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	rest := blob[headerLen:]
+	plain, err := gcm.Open(nil, rest[:gcm.NonceSize()], rest[gcm.NonceSize():], header)
+	if err != nil {
+		return nil, errors.New("arquivo cifrado corrompido ou alterado")
+	}
+	return plain, nil
+}
+
+func newStorageGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// keyFingerprint identifica a chave sem revelá-la; serve para dar um erro
+// claro de passphrase errada antes de tentar decifrar.
+func keyFingerprint(key []byte) []byte {
+	sum := sha256.Sum256(append([]byte("gptcli-storage-key:"), key...))
+	return sum[:storageFPSize]
+}
+
+func storageKey(source string, salt []byte) ([]byte, error) {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+	id := source + string(salt)
+	if k, ok := storage.keys[id]; ok {
+		return k, nil
+	}
+	var key []byte
+	var err error
+	switch source {
+	case "keyring":
+		key, err = keyringKey()
+	case "passphrase":
+		var pass string
+		if pass, err = storagePassphrase(); err == nil {
+			key = pbkdf2SHA256([]byte(pass), salt, storagePBKDF2Iters, 32)
+		}
+	default:
+		err = fmt.Errorf("storage_key inválido %q (use keyring ou passphrase)", source)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if storage.keys == nil {
+		storage.keys = map[string][]byte{}
+	}
+	storage.keys[id] = key
+	return key, nil
+}
+
+// storageSalt é o salt do PBKDF2, criado na primeira gravação. Ele também
+// vai em cada arquivo, então perder storage.salt não impede a leitura.
+func storageSalt() ([]byte, error) {
+	path := filepath.Join(configDir(), "storage.salt")
+	if b, err := os.ReadFile(path); err == nil && len(b) == storageSaltSize {
+		return b, nil
+	}
+	salt := make([]byte, storageSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	ensureDir(configDir())
+	return salt, os.WriteFile(path, salt, 0o600)
+}
+
+func storagePassphrase() (string, error) {
+	if p := os.Getenv("GPTCLI_PASSPHRASE"); p != "" {
+		return p, nil
+	}
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return "", errors.New("armazenamento cifrado: defina GPTCLI_PASSPHRASE (sem terminal para perguntar)")
+	}
+	defer tty.Close()
+	if saved, err := stty(tty, "-g"); err == nil {
+		_, _ = stty(tty, "-echo")
+		defer func() { _, _ = stty(tty, strings.TrimSpace(saved)) }()
+	}
+	fmt.Fprint(tty, "passphrase do armazenamento do gptcli: ")
+	line, err := bufio.NewReader(tty).ReadString('\n')
+	fmt.Fprintln(tty)
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	pass := strings.TrimRight(line, "\r\n")
+	if pass == "" {
+		return "", errors.New("passphrase vazia")
+	}
+	return pass, nil
+}
+
+// keyringKey busca a chave no keyring; na primeira vez gera uma e a guarda.
+func keyringKey() ([]byte, error) {
+	enc, ok, err := keyringGet(keyringStorageAccount)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		key, derr := base64.StdEncoding.DecodeString(enc)
+		if derr != nil || len(key) != 32 {
+			return nil, errors.New("chave do keyring inválida")
+		}
+		return key, nil
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, keyringSet(keyringStorageAccount, "gptcli storage key", base64.StdEncoding.EncodeToString(key))
+}
+
+// pbkdf2SHA256 implementa o PBKDF2 (RFC 8018) com HMAC-SHA256.
+func pbkdf2SHA256(password, salt []byte, iter, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	hashLen := prf.Size()
+	var dk []byte
+	var idx [4]byte
+	u := make([]byte, hashLen)
+	for block := uint32(1); len(dk) < keyLen; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.BigEndian.PutUint32(idx[:], block)
+		prf.Write(idx[:])
+		dk = prf.Sum(dk)
+		t := dk[len(dk)-hashLen:]
+		copy(u, t)
+		for n := 1; n < iter; n++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for i := range u {
+				t[i] ^= u[i]
+			}
+		}
+	}
+	return dk[:keyLen]
+}
+
+// ===================== decrypt =====================
+
+// decryptCmd imprime arquivos do armazenamento cifrado em texto puro.
+func decryptCmd(args []string) error {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "--help" {
+		fmt.Fprintln(os.Stderr, "uso: gptcli decrypt <arquivo> [...]   (sessões, transcripts ou history.txt cifrados)")
+		os.Exit(2)
+	}
+	for _, path := range args {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if bytes.HasPrefix(data, []byte(storageMagic)) {
+			plain, err := openStorage(data)
+			if err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			os.Stdout.Write(plain)
+			continue
+		}
+		// history.txt: linhas cifradas misturadas com as antigas em texto puro
+		for _, line := range strings.SplitAfter(string(data), "\n") {
+			enc, ok := strings.CutPrefix(line, storageHistoryTag)
+			if !ok {
+				fmt.Print(line)
+				continue
+			}
+			blob, err := base64.StdEncoding.DecodeString(strings.TrimSpace(enc))
+			if err != nil {
+				return fmt.Errorf("%s: linha cifrada inválida", path)
+			}
+			plain, err := openStorage(blob)
+			if err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			os.Stdout.Write(plain)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	openai "github.com/openai/openai-go/v2"
+	"github.com/openai/openai-go/v2/shared"
+)
+
+// ===================== Sugestões =====================
+//
+// --suggest: depois da resposta, pede ao modelo 3 perguntas curtas de
+// continuação e as imprime numeradas. No REPL, digitar o número envia a
+// pergunta correspondente.
+
+const suggestSystem = `Sugira perguntas de continuação para uma conversa. Dada a última pergunta e a
+resposta, proponha exatamente 3 perguntas curtas (até 15 palavras) que o usuário
+provavelmente faria a seguir: aprofundar um ponto, pedir um exemplo, explorar uma
+alternativa. Não repita o que já foi respondido. Escreva no idioma da conversa, na
+voz do usuário, e responda SOMENTE um objeto JSON: {"questions": ["...", "...", "..."]}`
+
+const numSuggestions = 3
+
+// suggestFollowUps pede as perguntas de continuação para a troca question/answer.
+func suggestFollowUps(ctx context.Context, client openai.Client, model, question, answer string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	params := openai.ChatCompletionNewParams{
+		Model: shared.ChatModel(model),
+		Messages: []openai.ChatCompletionMessageParamUnion{
+			openai.SystemMessage(suggestSystem),
+			openai.UserMessage(fmt.Sprintf("Pergunta: %s\n\nResposta: %s", truncate(question, 2000), truncate(answer, 6000))),
+		},
+		ResponseFormat: openai.ChatCompletionNewParamsResponseFormatUnion{
+			OfJSONObject: &shared.ResponseFormatJSONObjectParam{},
+		},
+	}
+	var questions []string
+	err := logOp(ctx, "suggest", model, func(ctx context.Context) error {
+		resp, err := client.Chat.Completions.New(ctx, params)
+		if err != nil {
+			return err
+		}
+		if len(resp.Choices) == 0 {
+			return errors.New("resposta vazia")
+		}
+		noteUsage(ctx, resp.Usage)
+		var out struct {
+			Questions []string `json:"questions"`
+		}
+		if err := json.Unmarshal([]byte(stripCodeFence(resp.Choices[0].Message.Content)), &out); err != nil {
+			return fmt.Errorf("o modelo não devolveu JSON válido: %w", err)
+		}
+		for _, q := range out.Questions {
+			if q = truncate(q, 200); q != "" && len(questions) < numSuggestions {
+				questions = append(questions, q)
+			}
+		}
+		return nil
+	})
+	return questions, err
+}
+
+// lastExchange devolve a última pergunta do usuário e a resposta que veio depois.
+func lastExchange(sess *Session) (question, answer string) {
+	for i := len(sess.Turns) - 1; i >= 0; i-- {
+		t := sess.Turns[i]
+		switch {
+		case t.Role == "assistant" && answer == "" && t.Content != "":
+			answer = t.Content
+		case t.Role == "user" && answer != "":
+			return t.Content, answer
+		}
+	}
+	return "", answer
+}
+
+func printSuggestions(w io.Writer, questions []string) {
+	fmt.Fprintln(w)
+	for i, q := range questions {
+		fmt.Fprintf(w, "  %d. %s\n", i+1, q)
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	openai "github.com/openai/openai-go/v2"
+	"github.com/openai/openai-go/v2/shared"
+)
+
+// ===================== Context Summarization =====================
+
+// SummarizeConfig controla a compressão automática do contexto no REPL.
+// Quando a sessão passa de max_turns turnos ou de ~max_tokens tokens, os turnos
+// mais antigos viram uma única nota do assistente e os `keep` mais recentes
+// ficam intactos.
+type SummarizeConfig struct {
+	MaxTurns  int    `yaml:"max_turns,omitempty"`  // 0 = sem limite por turnos
+	MaxTokens int    `yaml:"max_tokens,omitempty"` // 0 = sem limite por tokens (estimativa)
+	Keep      int    `yaml:"keep,omitempty"`       // default 6
+	Model     string `yaml:"model,omitempty"`      // default: modelo da sessão
+}
+
+const summaryMarker = "[resumo da conversa anterior]"
+
+func (c SummarizeConfig) enabled() bool { return c.MaxTurns > 0 || c.MaxTokens > 0 }
+
+func (c SummarizeConfig) keep() int {
+	keep := c.Keep
+	if keep <= 0 {
+		keep = 6
+	}
+	// sem folga abaixo de max_turns, resumiria a cada turno
+	if c.MaxTurns > 0 && keep >= c.MaxTurns {
+		keep = c.MaxTurns / 2
+	}
+	return keep
+}
+
+// estimateTokens usa a heurística de ~4 caracteres por token.
+func estimateTokens(s string) int { return (len([]rune(s)) + 3) / 4 }
+
+func (s *Session) estimatedTokens() int {
+	n := estimateTokens(s.System)
+	for _, t := range append(append([]Turn{}, s.Examples...), s.Turns...) {
+		n += t.tokenEstimate()
+	}
+	return n
+}
+
+func (s *Session) needsSummary(c SummarizeConfig) bool {
+	if !c.enabled() || len(s.Turns) <= c.keep() {
+		return false
+	}
+	return (c.MaxTurns > 0 && len(s.Turns) > c.MaxTurns) ||
+		(c.MaxTokens > 0 && s.estimatedTokens() > c.MaxTokens)
+}
+
+// summarizeOldTurns substitui os turnos antigos por uma nota-resumo e devolve
+// quantos turnos foram condensados.
+func summarizeOldTurns(ctx context.Context, client openai.Client, sess *Session, c SummarizeConfig, model string) (int, error) {
+	cut := len(sess.Turns) - c.keep()
+	// o trecho preservado deve começar num turno do usuário
+	for cut > 0 && sess.Turns[cut].Role != "user" {
+		cut--
+	}
+	if cut <= 1 {
+		return 0, nil
+	}
+	// turnos fixados (/pin) saem do resumo e continuam inteiros, na ordem
+	var old, pinned []Turn
+	for _, t := range sess.Turns[:cut] {
+		if t.Pinned {
+			pinned = append(pinned, t)
+		} else {
+			old = append(old, t)
+		}
+	}
+	if len(old) <= 1 {
+		return 0, nil
+	}
+
+	var b strings.Builder
+	for _, t := range old {
+		fmt.Fprintf(&b, "%s: %s\n\n", t.Role, t.apiContent())
+	}
+	params := openai.ChatCompletionNewParams{
+		Model: shared.ChatModel(chooseNonEmpty(c.Model, model)),
+		Messages: []openai.ChatCompletionMessageParamUnion{
+			openai.SystemMessage("Resuma a conversa a seguir em poucos parágrafos, preservando fatos, decisões, " +
+				"nomes, números, código relevante e pedidos pendentes do usuário. Escreva só o resumo."),
+			openai.UserMessage(b.String()),
+		},
+	}
+
+	var summary string
+	err := withRetries(ctx, 4, func() error {
+		resp, err := client.Chat.Completions.New(ctx, params)
+		if err != nil {
+			return err
+		}
+		if len(resp.Choices) == 0 {
+			return errors.New("resumo vazio")
+		}
+		noteUsage(ctx, resp.Usage)
+		summary = strings.TrimSpace(resp.Choices[0].Message.Content)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	turns := append([]Turn{{Role: "assistant", Content: summaryMarker + "\n" + summary}}, pinned...)
+	sess.Turns = append(turns, sess.Turns[cut:]...)
+	return len(old), nil
+}
+
+// ===================== Pin =====================
+
+// pinnedTurns devolve cópias dos turnos fixados, na ordem da conversa.
+func (s *Session) pinnedTurns() []Turn {
+	var out []Turn
+	for _, t := range s.Turns {
+		if t.Pinned {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// pinLastExchange fixa a última pergunta do usuário e tudo o que veio depois
+// dela (resposta, tool calls) e devolve quantos turnos foram marcados.
+func (s *Session) pinLastExchange() (int, error) {
+	start := -1
+	for i := len(s.Turns) - 1; i >= 0; i-- {
+		if s.Turns[i].Role == "user" {
+			start = i
+			break
+		}
+	}
+	if start < 0 {
+		return 0, errors.New("nenhuma pergunta para fixar")
+	}
+	n := 0
+	for i := start; i < len(s.Turns); i++ {
+		if !s.Turns[i].Pinned {
+			s.Turns[i].Pinned = true
+			n++
+		}
+	}
+	if n == 0 {
+		return 0, errors.New("a última troca já está fixada")
+	}
+	return n, nil
+}
+
+// unpin desafixa pelo número de /pin list ("" = o último, "all" = todos).
+func (s *Session) unpin(arg string) (int, error) {
+	var idx []int
+	for i, t := range s.Turns {
+		if t.Pinned {
+			idx = append(idx, i)
+		}
+	}
+	if len(idx) == 0 {
+		return 0, errors.New("nenhum turno fixado")
+	}
+	switch arg {
+	case "all":
+	case "":
+		idx = idx[len(idx)-1:]
+	default:
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 1 || n > len(idx) {
+			return 0, fmt.Errorf("use /unpin <1-%d> ou /unpin all (veja /pin list)", len(idx))
+		}
+		idx = idx[n-1 : n]
+	}
+	for _, i := range idx {
+		s.Turns[i].Pinned = false
+	}
+	return len(idx), nil
+}
+
+func printPinned(s *Session) {
+	n := 0
+	for _, t := range s.Turns {
+		if t.Pinned {
+			n++
+			fmt.Printf("%d. [%s] %s\n", n, t.Role, truncate(t.Content, 100))
+		}
+	}
+	if n == 0 {
+		fmt.Println("(nenhum turno fixado; /pin fixa a última pergunta e resposta)")
+	}
+}
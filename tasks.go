@@ -0,0 +1,433 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// ===================== Tarefas: cron e k8s =====================
+//
+// Subcomandos que geram um artefato pequeno e o validam localmente antes de
+// imprimir: `gptcli cron "dias úteis às 7h"` (parser de cron próprio) e
+// `gptcli k8s "deployment do nginx com 3 réplicas"` (YAML + campos obrigatórios).
+// Se a validação falhar, o erro volta para o modelo corrigir.
+
+const cronSystem = `Você converte descrições de agendamento em expressões cron de 5 campos
+(minuto hora dia-do-mês mês dia-da-semana), no formato do crontab do Linux.
+Responda exatamente neste formato:
+
+CRON: <a expressão, numa linha>
+<uma frase confirmando quando ela dispara>`
+
+const k8sSystem = `Você gera manifests Kubernetes. Responda SOMENTE com o YAML, dentro de um bloco
+` + "```yaml" + `, com vários recursos separados por "---" quando necessário. Use apiVersions
+estáveis (apps/v1, v1, networking.k8s.io/v1, batch/v1), labels consistentes entre
+selector e template e nenhum campo inventado. Não escreva nada fora do bloco.`
+
+// taskAttempts inclui a primeira tentativa; as outras recebem o erro de validação.
+const taskAttempts = 3
+
+// generateValidated pede o artefato e repete com o erro de validação até ele
+// passar. check devolve o texto final a imprimir; example aparece no uso.
+func generateValidated(ctx context.Context, args []string, name, system, example string,
+	check func(out string) (string, error)) (string, error) {
+
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	flags := commonFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "uso: gptcli %s [flags] \"%s\"\n", name, example)
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	desc := strings.TrimSpace(strings.Join(fs.Args(), " "))
+	if desc == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	cfg, _ := loadConfig()
+	st, err := resolveSettings(cfg, flags)
+	if err != nil {
+		return "", err
+	}
+	initAppLog(st.logLevel, st.profName, st.personaName)
+	client, err := buildClient(st)
+	if err != nil {
+		return "", err
+	}
+	ctx = withRetryConfig(ctx, st.retry)
+
+	sess := &Session{}
+	sess.addSystem(chooseNonEmpty(flags.System, system))
+	sess.addUser(desc)
+	var result string
+	err = logOp(ctx, name, st.model, func(ctx context.Context) error {
+		for attempt := 1; ; attempt++ {
+			var out string
+			err := withRetries(ctx, 4, func() error {
+				var err error
+				out, err = streamChat(ctx, client, sess, st.model, st.temp, st.maxTokens, func(string) {})
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			result, err = check(out)
+			if err == nil {
+				return nil
+			}
+			if attempt == taskAttempts {
+				return fmt.Errorf("a resposta não passou na validação: %w", err)
+			}
+			fmt.Fprintf(os.Stderr, "(inválido: %v; pedindo de novo)\n", err)
+			sess.addAssistant(out)
+			sess.addUser("Isso não passou na validação: " + err.Error() + ". Corrija e responda de novo no mesmo formato.")
+		}
+	})
+	flushTelemetry()
+	return result, err
+}
+
+// ----- cron -----
+
+var cronAnswerRe = regexp.MustCompile(`(?m)^\s*CRON:\s*(.+?)\s*$`)
+
+func cronCmd(args []string) error {
+	var sched *cronSchedule
+	var note string
+	expr, err := generateValidated(context.Background(), args, "cron", cronSystem, "dias úteis às 7h",
+		func(out string) (string, error) {
+			m := cronAnswerRe.FindStringSubmatchIndex(out)
+			if m == nil {
+				return "", errors.New("resposta sem a linha CRON:")
+			}
+			expr := strings.Trim(out[m[2]:m[3]], "`\"'")
+			s, err := parseCron(expr)
+			if err != nil {
+				return "", err
+			}
+			if s.next(time.Now()).IsZero() {
+				return "", fmt.Errorf("%q nunca dispara (data inexistente?)", expr)
+			}
+			sched, note = s, strings.TrimSpace(out[:m[0]]+out[m[1]:])
+			return expr, nil
+		})
+	if err != nil {
+		return err
+	}
+	fmt.Println(expr)
+	if note != "" {
+		fmt.Fprintln(os.Stderr, note)
+	}
+	printNextRuns(os.Stderr, sched, time.Now(), 5)
+	return nil
+}
+
+// cronSchedule guarda cada campo como bitmask dos valores aceitos.
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	domAny, dowAny                bool // campo era "*": o outro dia decide sozinho
+}
+
+var cronMacros = map[string]string{
+	"@yearly": "0 0 1 1 *", "@annually": "0 0 1 1 *", "@monthly": "0 0 1 * *",
+	"@weekly": "0 0 * * 0", "@daily": "0 0 * * *", "@midnight": "0 0 * * *", "@hourly": "0 * * * *",
+}
+
+var (
+	cronMonths = map[string]int{"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12}
+	cronDays = map[string]int{"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6}
+)
+
+// parseCron valida uma expressão de 5 campos (ou uma macro @daily...).
+func parseCron(expr string) (*cronSchedule, error) {
+	if m, ok := cronMacros[strings.ToLower(expr)]; ok {
+		expr = m
+	} else if strings.HasPrefix(expr, "@") {
+		return nil, fmt.Errorf("macro %q não suportada (use @yearly, @monthly, @weekly, @daily ou @hourly)", expr)
+	}
+	f := strings.Fields(expr)
+	if len(f) != 5 {
+		return nil, fmt.Errorf("%q tem %d campos; o cron usa 5 (minuto hora dia mês dia-da-semana)", expr, len(f))
+	}
+	s := &cronSchedule{domAny: f[2] == "*", dowAny: f[4] == "*"}
+	var err error
+	fields := []struct {
+		dst      *uint64
+		name     string
+		min, max int
+		names    map[string]int
+	}{
+		{&s.minute, "minuto", 0, 59, nil},
+		{&s.hour, "hora", 0, 23, nil},
+		{&s.dom, "dia do mês", 1, 31, nil},
+		{&s.month, "mês", 1, 12, cronMonths},
+		{&s.dow, "dia da semana", 0, 7, cronDays},
+	}
+	for i, fd := range fields {
+		if *fd.dst, err = parseCronField(f[i], fd.min, fd.max, fd.names); err != nil {
+			return nil, fmt.Errorf("campo %s (%q): %w", fd.name, f[i], err)
+		}
+	}
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1 // 7 também é domingo
+	}
+	return s, nil
+}
+
+func parseCronField(field string, min, max int, names map[string]int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepStr)
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("passo inválido %q", stepStr)
+			}
+			step = n
+		}
+		lo, hi := min, max
+		if rng != "*" {
+			a, b, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = cronValue(a, min, max, names); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if isRange {
+				if hi, err = cronValue(b, min, max, names); err != nil {
+					return 0, err
+				}
+				if hi < lo {
+					return 0, fmt.Errorf("intervalo invertido %q", rng)
+				}
+			} else if hasStep {
+				hi = max // "5/15" = a partir de 5, de 15 em 15
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+func cronValue(s string, min, max int, names map[string]int) (int, error) {
+	if v, ok := names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("valor inválido %q", s)
+	}
+	if v < min || v > max {
+		return 0, fmt.Errorf("%d fora de %d-%d", v, min, max)
+	}
+	return v, nil
+}
+
+func (s *cronSchedule) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<t.Day()) != 0
+	dow := s.dow&(1<<int(t.Weekday())) != 0
+	switch {
+	case s.domAny && s.dowAny:
+		return true
+	case s.domAny:
+		return dow
+	case s.dowAny:
+		return dom
+	default:
+		return dom || dow // como no cron do Linux: basta um dos dois
+	}
+}
+
+// next devolve o próximo disparo depois de t (zero se não houver em 5 anos,
+// ex: 31 de fevereiro).
+func (s *cronSchedule) next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case s.month&(1<<int(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case s.hour&(1<<t.Hour()) == 0:
+			t = t.Truncate(time.Hour).Add(time.Hour)
+		case s.minute&(1<<t.Minute()) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func printNextRuns(w io.Writer, s *cronSchedule, from time.Time, n int) {
+	fmt.Fprintln(w, "Próximas execuções (horário local):")
+	t := from
+	for i := 0; i < n; i++ {
+		if t = s.next(t); t.IsZero() {
+			fmt.Fprintln(w, "  (nenhuma nos próximos 5 anos)")
+			return
+		}
+		fmt.Fprintf(w, "  %s\n", t.Format("Mon 2006-01-02 15:04"))
+	}
+}
+
+// ----- k8s -----
+
+var yamlFenceRe = regexp.MustCompile("(?s)```(?:ya?ml)?[ \t]*\n(.*?)\n```")
+
+func k8sCmd(args []string) error {
+	var summary []string
+	manifest, err := generateValidated(context.Background(), args, "k8s", k8sSystem,
+		"deployment do nginx com 3 réplicas", func(out string) (string, error) {
+			text := strings.TrimSpace(out)
+			if m := yamlFenceRe.FindStringSubmatch(text); m != nil {
+				text = strings.TrimSpace(m[1])
+			}
+			s, err := validateManifests(text)
+			if err != nil {
+				return "", err
+			}
+			summary = s
+			return text, nil
+		})
+	if err != nil {
+		return err
+	}
+	fmt.Println(manifest)
+	fmt.Fprintf(os.Stderr, "(%d recurso(s) válidos: %s)\n", len(summary), strings.Join(summary, ", "))
+	return nil
+}
+
+// validateManifests decodifica cada documento e confere os campos que o
+// kubectl recusaria; não substitui um `kubectl apply --dry-run=server`.
+func validateManifests(text string) ([]string, error) {
+	dec := yaml.NewDecoder(strings.NewReader(text))
+	var summary []string
+	for i := 1; ; i++ {
+		var doc map[string]any
+		err := dec.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("documento %d: YAML inválido: %w", i, err)
+		}
+		if doc == nil {
+			continue // documento vazio entre "---"
+		}
+		kind, _ := doc["kind"].(string)
+		name, _ := yamlPath(doc, "metadata", "name").(string)
+		where := fmt.Sprintf("documento %d (%s)", i, chooseNonEmpty(kind, "sem kind"))
+		for _, req := range []struct {
+			ok    bool
+			field string
+		}{
+			{doc["apiVersion"] != nil, "apiVersion"},
+			{kind != "", "kind"},
+			{name != "", "metadata.name"},
+		} {
+			if !req.ok {
+				return nil, fmt.Errorf("%s: falta %s", where, req.field)
+			}
+		}
+		if err := validateKind(kind, doc); err != nil {
+			return nil, fmt.Errorf("%s %s: %w", kind, name, err)
+		}
+		summary = append(summary, kind+"/"+name)
+	}
+	if len(summary) == 0 {
+		return nil, errors.New("nenhum manifest na resposta")
+	}
+	return summary, nil
+}
+
+func validateKind(kind string, doc map[string]any) error {
+	switch kind {
+	case "Deployment", "StatefulSet", "DaemonSet", "ReplicaSet":
+		selector, _ := yamlPath(doc, "spec", "selector", "matchLabels").(map[string]any)
+		if len(selector) == 0 {
+			return errors.New("falta spec.selector.matchLabels")
+		}
+		labels, _ := yamlPath(doc, "spec", "template", "metadata", "labels").(map[string]any)
+		for k, v := range selector {
+			if fmt.Sprint(labels[k]) != fmt.Sprint(v) {
+				return fmt.Errorf("spec.selector %s=%v não bate com os labels do template", k, v)
+			}
+		}
+		return validateContainers(yamlPath(doc, "spec", "template", "spec", "containers"))
+	case "Job":
+		return validateContainers(yamlPath(doc, "spec", "template", "spec", "containers"))
+	case "CronJob":
+		sched, _ := yamlPath(doc, "spec", "schedule").(string)
+		if _, err := parseCron(sched); err != nil {
+			return fmt.Errorf("spec.schedule: %w", err)
+		}
+		return validateContainers(yamlPath(doc, "spec", "jobTemplate", "spec", "template", "spec", "containers"))
+	case "Pod":
+		return validateContainers(yamlPath(doc, "spec", "containers"))
+	case "Service":
+		ports, _ := yamlPath(doc, "spec", "ports").([]any)
+		if len(ports) == 0 && yamlPath(doc, "spec", "type") != "ExternalName" {
+			return errors.New("falta spec.ports")
+		}
+		for i, p := range ports {
+			pm, _ := p.(map[string]any)
+			if _, ok := pm["port"].(int); !ok {
+				return fmt.Errorf("spec.ports[%d].port precisa ser um número", i)
+			}
+		}
+	}
+	return nil
+}
+
+func validateContainers(v any) error {
+	list, _ := v.([]any)
+	if len(list) == 0 {
+		return errors.New("nenhum container no template")
+	}
+	for i, c := range list {
+		cm, _ := c.(map[string]any)
+		if name, _ := cm["name"].(string); name == "" {
+			return fmt.Errorf("containers[%d] sem name", i)
+		}
+		if image, _ := cm["image"].(string); image == "" {
+			return fmt.Errorf("containers[%d] sem image", i)
+		}
+		ports, _ := cm["ports"].([]any)
+		for j, p := range ports {
+			pm, _ := p.(map[string]any)
+			if _, ok := pm["containerPort"].(int); !ok {
+				return fmt.Errorf("containers[%d].ports[%d].containerPort precisa ser um número", i, j)
+			}
+		}
+	}
+	return nil
+}
+
+// yamlPath segue chaves de mapas aninhados; nil se algum nível faltar.
+func yamlPath(v any, keys ...string) any {
+	for _, k := range keys {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		v = m[k]
+	}
+	return v
+}
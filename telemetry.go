@@ -0,0 +1,387 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ===================== Telemetry (OTLP/HTTP JSON) =====================
+//
+// Exportador mínimo de spans e métricas no formato OTLP/JSON, sem depender do
+// SDK do OpenTelemetry. Como o CLI vive pouco, tudo fica em memória e é
+// enviado em flushTelemetry (fim da execução ou após cada turno do REPL).
+
+type otelAttr struct {
+	Key   string
+	Value any
+}
+
+func attr(k string, v any) otelAttr { return otelAttr{k, v} }
+
+type otelEvent struct {
+	name  string
+	at    time.Time
+	attrs []otelAttr
+}
+
+type otelSpan struct {
+	traceID, spanID, parentID string
+	name                      string
+	start, finish             time.Time
+	attrs                     []otelAttr
+	events                    []otelEvent
+	err                       error
+}
+
+type histPoint struct {
+	count   int64
+	sum     float64
+	buckets []int64
+}
+
+type telemetryExporter struct {
+	endpoint string
+	headers  map[string]string
+	service  string
+	started  time.Time
+
+	mu       sync.Mutex
+	spans    []*otelSpan
+	counters map[string]int64 // chave: nome|atributos
+	hists    map[string]*histPoint
+}
+
+var telemetry *telemetryExporter // nil = desabilitado
+
+var durationBounds = []float64{50, 100, 250, 500, 1000, 2500, 5000, 10000, 30000, 60000}
+
+// initTelemetry habilita o exportador se houver endpoint (flag ou
+// OTEL_EXPORTER_OTLP_ENDPOINT).
+func initTelemetry(endpoint string) {
+	endpoint = chooseNonEmpty(endpoint, os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"))
+	if strings.TrimSpace(endpoint) == "" {
+		return
+	}
+	headers := map[string]string{}
+	for _, kv := range strings.Split(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"), ",") {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			headers[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+	telemetry = &telemetryExporter{
+		endpoint: strings.TrimSuffix(strings.TrimSpace(endpoint), "/"),
+		headers:  headers,
+		service:  chooseNonEmpty(os.Getenv("OTEL_SERVICE_NAME"), "gptcli"),
+		started:  time.Now(),
+		counters: map[string]int64{},
+		hists:    map[string]*histPoint{},
+	}
+}
+
+type spanKey struct{}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// startSpan abre um span filho do span presente em ctx (se houver).
+// Com a telemetria desligada devolve um span nil, cujos métodos são no-op.
+func startSpan(ctx context.Context, name string, attrs ...otelAttr) (context.Context, *otelSpan) {
+	if telemetry == nil {
+		return ctx, nil
+	}
+	s := &otelSpan{name: name, spanID: randomHex(8), start: time.Now(), attrs: attrs}
+	if parent, ok := ctx.Value(spanKey{}).(*otelSpan); ok && parent != nil {
+		s.traceID, s.parentID = parent.traceID, parent.spanID
+	} else {
+		s.traceID = randomHex(16)
+	}
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+func spanFromContext(ctx context.Context) *otelSpan {
+	s, _ := ctx.Value(spanKey{}).(*otelSpan)
+	return s
+}
+
+func (s *otelSpan) setAttr(k string, v any) {
+	if s != nil {
+		s.attrs = append(s.attrs, attr(k, v))
+	}
+}
+
+func (s *otelSpan) addEvent(name string, attrs ...otelAttr) {
+	if s != nil {
+		s.events = append(s.events, otelEvent{name, time.Now(), attrs})
+	}
+}
+
+// end fecha o span e registra contagem/duração da operação.
+func (s *otelSpan) end(err error) {
+	if s == nil || telemetry == nil {
+		return
+	}
+	s.finish = time.Now()
+	s.err = err
+	status := "ok"
+	if err != nil {
+		status = "error"
+	}
+	labels := []otelAttr{attr("operation", s.name), attr("status", status)}
+	for _, a := range s.attrs {
+		if a.Key == "gen_ai.request.model" {
+			labels = append(labels, attr("model", a.Value))
+		}
+	}
+	telemetry.mu.Lock()
+	defer telemetry.mu.Unlock()
+	telemetry.spans = append(telemetry.spans, s)
+	telemetry.addCounterLocked("gptcli.operations", 1, labels)
+	telemetry.observeLocked("gptcli.operation.duration", float64(s.finish.Sub(s.start).Milliseconds()), labels)
+}
+
+func recordCounter(name string, n int64, labels ...otelAttr) {
+	if telemetry == nil {
+		return
+	}
+	telemetry.mu.Lock()
+	defer telemetry.mu.Unlock()
+	telemetry.addCounterLocked(name, n, labels)
+}
+
+func metricKey(name string, labels []otelAttr) string {
+	parts := []string{name}
+	for _, l := range labels {
+		parts = append(parts, fmt.Sprintf("%s=%v", l.Key, l.Value))
+	}
+	return strings.Join(parts, "|")
+}
+
+func parseMetricKey(key string) (string, []otelAttr) {
+	parts := strings.Split(key, "|")
+	var labels []otelAttr
+	for _, p := range parts[1:] {
+		k, v, _ := strings.Cut(p, "=")
+		labels = append(labels, attr(k, v))
+	}
+	return parts[0], labels
+}
+
+func (t *telemetryExporter) addCounterLocked(name string, n int64, labels []otelAttr) {
+	t.counters[metricKey(name, labels)] += n
+}
+
+func (t *telemetryExporter) observeLocked(name string, v float64, labels []otelAttr) {
+	key := metricKey(name, labels)
+	h := t.hists[key]
+	if h == nil {
+		h = &histPoint{buckets: make([]int64, len(durationBounds)+1)}
+		t.hists[key] = h
+	}
+	h.count++
+	h.sum += v
+	i := sort.SearchFloat64s(durationBounds, v)
+	h.buckets[i]++
+}
+
+// ---------- serialização OTLP/JSON ----------
+
+func otlpValue(v any) map[string]any {
+	switch x := v.(type) {
+	case bool:
+		return map[string]any{"boolValue": x}
+	case int:
+		return map[string]any{"intValue": strconv.Itoa(x)}
+	case int64:
+		return map[string]any{"intValue": strconv.FormatInt(x, 10)}
+	case float64:
+		return map[string]any{"doubleValue": x}
+	default:
+		return map[string]any{"stringValue": fmt.Sprint(x)}
+	}
+}
+
+func otlpAttrs(attrs []otelAttr) []map[string]any {
+	out := make([]map[string]any, 0, len(attrs))
+	for _, a := range attrs {
+		out = append(out, map[string]any{"key": a.Key, "value": otlpValue(a.Value)})
+	}
+	return out
+}
+
+func nanos(t time.Time) string { return strconv.FormatInt(t.UnixNano(), 10) }
+
+func (t *telemetryExporter) resource() map[string]any {
+	return map[string]any{"attributes": otlpAttrs([]otelAttr{attr("service.name", t.service)})}
+}
+
+func (t *telemetryExporter) tracesPayload(spans []*otelSpan) map[string]any {
+	var out []map[string]any
+	for _, s := range spans {
+		status := map[string]any{"code": 1}
+		if s.err != nil {
+			status = map[string]any{"code": 2, "message": s.err.Error()}
+		}
+		var events []map[string]any
+		for _, e := range s.events {
+			events = append(events, map[string]any{"name": e.name, "timeUnixNano": nanos(e.at), "attributes": otlpAttrs(e.attrs)})
+		}
+		span := map[string]any{
+			"traceId":           s.traceID,
+			"spanId":            s.spanID,
+			"name":              s.name,
+			"kind":              3, // CLIENT
+			"startTimeUnixNano": nanos(s.start),
+			"endTimeUnixNano":   nanos(s.finish),
+			"attributes":        otlpAttrs(s.attrs),
+			"events":            events,
+			"status":            status,
+		}
+		if s.parentID != "" {
+			span["parentSpanId"] = s.parentID
+		}
+		out = append(out, span)
+	}
+	return map[string]any{"resourceSpans": []map[string]any{{
+		"resource":   t.resource(),
+		"scopeSpans": []map[string]any{{"scope": map[string]any{"name": "gptcli"}, "spans": out}},
+	}}}
+}
+
+func (t *telemetryExporter) metricsPayload(now time.Time) map[string]any {
+	byName := map[string][]map[string]any{}
+	var names []string
+	for key, n := range t.counters {
+		name, labels := parseMetricKey(key)
+		if _, ok := byName[name]; !ok {
+			names = append(names, name)
+		}
+		byName[name] = append(byName[name], map[string]any{
+			"attributes": otlpAttrs(labels), "startTimeUnixNano": nanos(t.started),
+			"timeUnixNano": nanos(now), "asInt": strconv.FormatInt(n, 10),
+		})
+	}
+	var metrics []map[string]any
+	for _, name := range names {
+		metrics = append(metrics, map[string]any{
+			"name": name, "unit": "1",
+			"sum": map[string]any{"dataPoints": byName[name], "aggregationTemporality": 2, "isMonotonic": true},
+		})
+	}
+	hists := map[string][]map[string]any{}
+	var hnames []string
+	for key, h := range t.hists {
+		name, labels := parseMetricKey(key)
+		if _, ok := hists[name]; !ok {
+			hnames = append(hnames, name)
+		}
+		buckets := make([]string, len(h.buckets))
+		for i, b := range h.buckets {
+			buckets[i] = strconv.FormatInt(b, 10)
+		}
+		hists[name] = append(hists[name], map[string]any{
+			"attributes": otlpAttrs(labels), "startTimeUnixNano": nanos(t.started), "timeUnixNano": nanos(now),
+			"count": strconv.FormatInt(h.count, 10), "sum": h.sum,
+			"bucketCounts": buckets, "explicitBounds": durationBounds,
+		})
+	}
+	for _, name := range hnames {
+		metrics = append(metrics, map[string]any{
+			"name": name, "unit": "ms",
+			"histogram": map[string]any{"dataPoints": hists[name], "aggregationTemporality": 2},
+		})
+	}
+	return map[string]any{"resourceMetrics": []map[string]any{{
+		"resource":     t.resource(),
+		"scopeMetrics": []map[string]any{{"scope": map[string]any{"name": "gptcli"}, "metrics": metrics}},
+	}}}
+}
+
+func (t *telemetryExporter) post(path string, payload any) error {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, t.endpoint+path, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	shared, err := sharedHTTPClient("")
+	if err != nil {
+		return err
+	}
+	hc := &http.Client{Transport: shared.Transport, Timeout: 5 * time.Second}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s: %s", path, resp.Status)
+	}
+	return nil
+}
+
+// flushTelemetry envia os spans pendentes e o estado atual das métricas.
+// Erros de exportação nunca interrompem o CLI; viram só um aviso.
+// flushTelemetryEvery exporta a telemetria a cada d até ctx acabar: os
+// servidores (serve, daemon) não têm um fim de comando onde exportar.
+func flushTelemetryEvery(ctx context.Context, d time.Duration) {
+	if telemetry == nil {
+		return
+	}
+	t := time.NewTicker(d)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			flushTelemetry()
+		}
+	}
+}
+
+// exitFlushed encerra com code depois de exportar a telemetria pendente:
+// os.Exit não roda os defers.
+func exitFlushed(code int) {
+	flushTelemetry()
+	os.Exit(code)
+}
+
+func flushTelemetry() {
+	if telemetry == nil {
+		return
+	}
+	telemetry.mu.Lock()
+	spans := telemetry.spans
+	telemetry.spans = nil
+	metrics := telemetry.metricsPayload(time.Now())
+	telemetry.mu.Unlock()
+
+	if len(spans) > 0 {
+		if err := telemetry.post("/v1/traces", telemetry.tracesPayload(spans)); err != nil {
+			fmt.Fprintln(os.Stderr, "aviso: otel:", err)
+		}
+	}
+	if err := telemetry.post("/v1/metrics", metrics); err != nil {
+		fmt.Fprintln(os.Stderr, "aviso: otel:", err)
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// ===================== Conversation Templates =====================
+//
+// Um template de conversa (--conversation-template) pré-carrega system e
+// turnos, com placeholders {{.nome}} preenchidos por --var nome=valor. O texto
+// passado como prompt (args ou stdin) fica disponível como {{.input}}.
+//
+// Funções disponíveis: date, now, {{ env "USER" }}, {{ file "notas.md" }},
+// {{ ask "ticket_id" }} (pergunta no terminal se não houver --var) e
+// {{ shell "git log -5" }}; esta última só com --template-shell, já que um
+// template baixado de terceiros não deve rodar comandos sem o usuário saber.
+
+type ConvTemplate struct {
+	System string            `yaml:"system"`
+	Model  string            `yaml:"model"`
+	Format string            `yaml:"format"`
+	Vars   map[string]string `yaml:"vars"`   // valores padrão
+	Turns  []Turn            `yaml:"turns"`  // turnos pré-carregados
+	Prompt string            `yaml:"prompt"` // mensagem final do usuário (modo não interativo)
+
+	name       string
+	vars       map[string]string
+	allowShell bool // --template-shell
+}
+
+// templateShellTimeout limita cada {{ shell }}.
+const templateShellTimeout = 30 * time.Second
+
+func templatesDir() string { return filepath.Join(configDir(), "templates") }
+
+// loadConvTemplate aceita um caminho ou o nome de um arquivo em
+// ~/.config/gptcli/templates.
+func loadConvTemplate(ref string, vars []string, allowShell bool) (*ConvTemplate, error) {
+	path := ref
+	if _, err := os.Stat(path); err != nil && !strings.ContainsRune(ref, os.PathSeparator) {
+		path = filepath.Join(templatesDir(), strings.TrimSuffix(ref, ".yaml")+".yaml")
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("template %q não encontrado", ref)
+		}
+		return nil, err
+	}
+	var t ConvTemplate
+	if err := yaml.Unmarshal(b, &t); err != nil {
+		return nil, fmt.Errorf("template %s inválido: %w", ref, err)
+	}
+	for _, turn := range t.Turns {
+		if turn.Role != "user" && turn.Role != "assistant" {
+			return nil, fmt.Errorf("template %s: role inválido %q (use user|assistant)", ref, turn.Role)
+		}
+	}
+	t.name, t.allowShell = ref, allowShell
+	given, err := parseVars(vars)
+	if err != nil {
+		return nil, err
+	}
+	t.vars = map[string]string{}
+	for k, v := range t.Vars {
+		t.vars[k] = v
+	}
+	for k, v := range given {
+		t.vars[k] = v
+	}
+	return &t, nil
+}
+
+// parseVars lê os --var chave=valor.
+func parseVars(vars []string) (map[string]string, error) {
+	out := map[string]string{}
+	for _, kv := range vars {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok || strings.TrimSpace(k) == "" {
+			return nil, fmt.Errorf("--var inválido %q (use chave=valor)", kv)
+		}
+		out[strings.TrimSpace(k)] = v
+	}
+	return out, nil
+}
+
+func (t *ConvTemplate) funcs() template.FuncMap {
+	return template.FuncMap{
+		"date": func() string { return time.Now().Format("2006-01-02") },
+		"now":  func() string { return time.Now().Format("2006-01-02 15:04") },
+		"env":  os.Getenv,
+		"file": func(path string) (string, error) {
+			b, err := os.ReadFile(path)
+			if err != nil {
+				return "", err
+			}
+			return strings.TrimRight(string(b), "\n"), nil
+		},
+		"shell": t.shell,
+		"ask":   func(name string) (string, error) { return askVar(name, t.vars) },
+	}
+}
+
+// shell roda o comando com sh -c e devolve o stdout; falha do comando
+// interrompe o template em vez de mandar um contexto incompleto ao modelo.
+func (t *ConvTemplate) shell(command string) (string, error) {
+	if !t.allowShell {
+		return "", fmt.Errorf("{{ shell %q }} desabilitado; rode com --template-shell para permitir", command)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), templateShellTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.WaitDelay = time.Second
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		return "", fmt.Errorf("shell %q: timeout após %s", command, templateShellTimeout)
+	case err != nil:
+		return "", fmt.Errorf("shell %q: %v: %s", command, err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimRight(string(out), "\n"), nil
+}
+
+func (t *ConvTemplate) render(field, text, input string) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+	tpl, err := template.New(field).Funcs(t.funcs()).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("template %s (%s): %w", t.name, field, err)
+	}
+	data := map[string]string{"input": input}
+	for k, v := range t.vars {
+		data[k] = v
+	}
+	var b strings.Builder
+	if err := tpl.Execute(&b, data); err != nil {
+		if strings.Contains(err.Error(), "no entry for key") {
+			return "", fmt.Errorf("template %s (%s): %w (defina com --var chave=valor)", t.name, field, err)
+		}
+		return "", fmt.Errorf("template %s (%s): %w", t.name, field, err)
+	}
+	return b.String(), nil
+}
+
+// hasPrompt indica se o template sozinho já tem uma pergunta a enviar.
+func (t *ConvTemplate) hasPrompt() bool {
+	return t.Prompt != "" || (len(t.Turns) > 0 && t.Turns[len(t.Turns)-1].Role == "user")
+}
+
+// apply preenche a sessão com os turnos do template e devolve o prompt final
+// (vazio se não houver nada a enviar). O system já entrou via flags em main.
+// Sem `prompt:` no template, um último turno do usuário vira o prompt; o
+// input do usuário é anexado quando o template não usa {{.input}}.
+func (t *ConvTemplate) apply(sess *Session, input string) (string, error) {
+	usesInput := strings.Contains(t.Prompt, ".input")
+	var turns []Turn
+	for i, turn := range t.Turns {
+		content, err := t.render(fmt.Sprintf("turns[%d]", i), turn.Content, input)
+		if err != nil {
+			return "", err
+		}
+		usesInput = usesInput || strings.Contains(turn.Content, ".input")
+		turns = append(turns, Turn{Role: turn.Role, Content: content})
+	}
+
+	prompt := ""
+	if t.Prompt != "" {
+		p, err := t.render("prompt", t.Prompt, input)
+		if err != nil {
+			return "", err
+		}
+		prompt = p
+	} else if n := len(turns); n > 0 && turns[n-1].Role == "user" {
+		prompt, turns = turns[n-1].Content, turns[:n-1]
+	}
+	switch {
+	case prompt == "":
+		prompt = input
+	case input != "" && !usesInput:
+		prompt = strings.TrimRight(prompt, "\n") + "\n\n" + input
+	}
+
+	// numa sessão retomada os turnos do template já estão no histórico
+	if len(sess.Turns) == 0 {
+		sess.Turns = turns
+	}
+	return strings.TrimSpace(prompt), nil
+}
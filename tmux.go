@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// ===================== tmux =====================
+//
+// --tmux-pane <alvo> digita a resposta final em outro painel do tmux: gera o
+// comando aqui, ele aparece no shell de lá. Por padrão vai só o código dos
+// blocos ``` da resposta (ou a resposta inteira, se não houver bloco). O
+// bracketed paste só vale se o programa do painel o ligou; sem ele o tmux
+// troca cada LF por CR e cada linha roda. Por isso um texto de várias linhas
+// só vai com --tmux-enter, e sem ele nada é executado. Caracteres de
+// controle (ESC e afins) são removidos antes: um ESC[201~ na resposta
+// encerraria a colagem no meio.
+
+// tmuxTarget é o painel de --tmux-pane já conferido.
+type tmuxTarget struct {
+	pane  string // id estável (%N) do painel
+	send  string // code|text
+	enter bool
+}
+
+// resolveTmuxPane confere que o alvo existe agora, antes de gastar a chamada
+// ao modelo, e guarda o id do painel, que não muda se a janela mudar de
+// nome ou de lugar.
+func resolveTmuxPane(target, send string, enter bool) (*tmuxTarget, error) {
+	switch send {
+	case "":
+		send = "code"
+	case "code", "text":
+	default:
+		return nil, fmt.Errorf("--tmux-send: use code ou text, não %q", send)
+	}
+	if _, err := exec.LookPath("tmux"); err != nil {
+		return nil, errors.New("--tmux-pane: tmux não encontrado no PATH")
+	}
+	out, err := tmuxRun(nil, "display-message", "-p", "-t", target, "#{pane_id}")
+	if err != nil {
+		return nil, fmt.Errorf("--tmux-pane %s: %w", target, err)
+	}
+	pane := strings.TrimSpace(out)
+	if !strings.HasPrefix(pane, "%") {
+		// sem o painel, algumas versões do tmux saem com 0 e não imprimem nada
+		return nil, fmt.Errorf("--tmux-pane %s: painel não encontrado", target)
+	}
+	return &tmuxTarget{pane: pane, send: send, enter: enter}, nil
+}
+
+func tmuxRun(stdin []byte, args ...string) (string, error) {
+	cmd := exec.Command("tmux", args...)
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", errors.New(msg)
+		}
+		return "", err
+	}
+	return string(out), nil
+}
+
+// codeBlocks devolve o conteúdo dos blocos ``` ou ~~~ do texto, na ordem.
+func codeBlocks(text string) []string {
+	var blocks []string
+	var cur []string
+	fence := ""
+	for _, ln := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		m := mdFenceRe.FindStringSubmatch(ln)
+		switch {
+		case fence == "" && m != nil:
+			fence, cur = m[1], nil
+		case fence != "" && m != nil && m[1] == fence && strings.TrimSpace(ln) == fence:
+			blocks = append(blocks, strings.Join(cur, "\n"))
+			fence = ""
+		case fence != "":
+			cur = append(cur, ln)
+		}
+	}
+	return blocks
+}
+
+// stripControl remove os caracteres de controle, exceto \n e \t.
+func stripControl(s string) string {
+	return strings.Map(func(r rune) rune {
+		if (r < 0x20 && r != '\n' && r != '\t') || r == 0x7f || (r >= 0x80 && r < 0xa0) {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// sendToTmux cola a resposta no painel e, com --tmux-enter, aperta Enter.
+func sendToTmux(t *tmuxTarget, answer string) error {
+	text := answer
+	if t.send == "code" {
+		if blocks := codeBlocks(answer); len(blocks) > 0 {
+			text = strings.Join(blocks, "\n")
+		}
+	}
+	text = strings.Trim(stripControl(text), "\n")
+	if strings.TrimSpace(text) == "" {
+		return errors.New("nada para enviar")
+	}
+	if strings.Contains(text, "\n") && !t.enter {
+		return fmt.Errorf("a resposta tem %d linhas e o painel pode rodar cada uma ao colar; use --tmux-enter para colar e executar, ou peça um comando de uma linha", strings.Count(text, "\n")+1)
+	}
+	// o buffer nomeado não mexe no buffer de colagem do usuário; -d o apaga
+	if _, err := tmuxRun([]byte(text), "load-buffer", "-b", "gptcli", "-"); err != nil {
+		return err
+	}
+	if _, err := tmuxRun(nil, "paste-buffer", "-p", "-d", "-b", "gptcli", "-t", t.pane); err != nil {
+		return err
+	}
+	if t.enter {
+		if _, err := tmuxRun(nil, "send-keys", "-t", t.pane, "Enter"); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(os.Stderr, "(resposta enviada ao painel %s do tmux)\n", t.pane)
+	return nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ===================== Token Report =====================
+//
+// Base do /tokens no REPL: tamanho estimado do contexto, folga na janela do
+// modelo e custo projetado do próximo turno.
+
+// modelInfo guarda a janela de contexto e o preço (US$ por 1M tokens).
+type modelInfo struct {
+	window        int
+	inPerMillion  float64
+	outPerMillion float64
+}
+
+// knownModels usa os preços públicos da OpenAI; a busca é pelo prefixo mais
+// longo, então "gpt-4.1-mini-2025-04-14" cai em "gpt-4.1-mini".
+var knownModels = map[string]modelInfo{
+	"gpt-5":         {400_000, 1.25, 10},
+	"gpt-5-mini":    {400_000, 0.25, 2},
+	"gpt-5-nano":    {400_000, 0.05, 0.40},
+	"gpt-4.1":       {1_047_576, 2, 8},
+	"gpt-4.1-mini":  {1_047_576, 0.40, 1.60},
+	"gpt-4.1-nano":  {1_047_576, 0.10, 0.40},
+	"gpt-4o":        {128_000, 2.50, 10},
+	"gpt-4o-mini":   {128_000, 0.15, 0.60},
+	"o3":            {200_000, 2, 8},
+	"o4-mini":       {200_000, 1.10, 4.40},
+	"gpt-3.5-turbo": {16_385, 0.50, 1.50},
+}
+
+// projectedAnswer é o tamanho de resposta assumido no custo projetado quando
+// não há max_tokens.
+const projectedAnswer = 1000
+
+func lookupModel(model string) (modelInfo, bool) {
+	best := ""
+	for name := range knownModels {
+		if strings.HasPrefix(model, name) && len(name) > len(best) {
+			best = name
+		}
+	}
+	if best == "" {
+		return openRouterModelInfo(model)
+	}
+	info, ok := knownModels[best]
+	return info, ok
+}
+
+func printTokenReport(w io.Writer, sess *Session, model string, maxTokens int64) {
+	byRole := map[string]int{}
+	turns := map[string]int{}
+	for _, t := range sess.Turns {
+		n := t.tokenEstimate()
+		for _, c := range t.ToolCalls {
+			n += estimateTokens(c.Name + c.Arguments)
+		}
+		byRole[t.Role] += n
+		turns[t.Role]++
+	}
+	system := estimateTokens(sess.System)
+	examples := 0
+	for _, t := range sess.Examples {
+		examples += estimateTokens(t.Content) + 4
+	}
+	total := system + examples
+	for _, n := range byRole {
+		total += n
+	}
+
+	fmt.Fprintln(w, "Contexto (estimativa de ~4 caracteres/token):")
+	fmt.Fprintf(w, "  %-10s %8d\n", "system", system)
+	if examples > 0 {
+		fmt.Fprintf(w, "  %-10s %8d\n", "exemplos", examples)
+	}
+	for _, role := range []string{"user", "assistant", "tool"} {
+		if turns[role] > 0 {
+			fmt.Fprintf(w, "  %-10s %8d  (%d turno(s))\n", role, byRole[role], turns[role])
+		}
+	}
+	fmt.Fprintf(w, "  %-10s %8d\n", "total", total)
+
+	info, ok := lookupModel(model)
+	if !ok {
+		fmt.Fprintf(w, "Modelo %s: janela e preço desconhecidos.\n", model)
+		return
+	}
+	answer := int64(projectedAnswer)
+	if maxTokens > 0 {
+		answer = maxTokens
+	}
+	free := info.window - total - int(answer)
+	fmt.Fprintf(w, "Janela de %s: %d tokens • livre após a resposta: %d (%.0f%%)\n",
+		model, info.window, max(free, 0), 100*float64(max(free, 0))/float64(info.window))
+	in := float64(total) * info.inPerMillion / 1e6
+	out := float64(answer) * info.outPerMillion / 1e6
+	fmt.Fprintf(w, "Próximo turno: ≈ US$ %.4f (entrada US$ %.4f + até %d tokens de resposta US$ %.4f)\n",
+		in+out, in, answer, out)
+	if free < info.window/10 {
+		fmt.Fprintln(w, "(contexto quase cheio: considere /clear ou summarize no profile)")
+	}
+}
@@ -0,0 +1,370 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	openai "github.com/openai/openai-go/v2"
+	"github.com/openai/openai-go/v2/shared"
+)
+
+// ===================== Tools =====================
+//
+// Ferramentas que o modelo pode chamar (function calling). São habilitadas
+// por nome com --tool, pela persona ou pelo profile (`tools: [read_file]`).
+
+// ToolCall é um pedido do modelo para executar uma ferramenta.
+type ToolCall struct {
+	ID        string `yaml:"id"`
+	Name      string `yaml:"name"`
+	Arguments string `yaml:"arguments"` // JSON gerado pelo modelo
+}
+
+type tool struct {
+	description string
+	params      map[string]any // JSON Schema dos argumentos
+	readOnly    bool           // sem efeitos colaterais: política padrão "auto"
+	run         func(ctx context.Context, args map[string]any) (string, error)
+}
+
+// maxToolRounds limita quantas vezes seguidas o modelo pode pedir ferramentas
+// num mesmo turno.
+const maxToolRounds = 8
+
+// maxToolOutput corta resultados grandes antes de devolvê-los ao modelo.
+const maxToolOutput = 32 << 10
+
+var builtinTools = map[string]tool{
+	"read_file": {
+		description: "Lê um arquivo de texto local.",
+		params:      objectSchema(map[string]string{"path": "caminho do arquivo"}),
+		readOnly:    true,
+		run: func(ctx context.Context, args map[string]any) (string, error) {
+			b, err := os.ReadFile(stringArg(args, "path"))
+			return string(b), err
+		},
+	},
+	"list_dir": {
+		description: "Lista os arquivos de um diretório local.",
+		params:      objectSchema(map[string]string{"path": "caminho do diretório"}),
+		readOnly:    true,
+		run: func(ctx context.Context, args map[string]any) (string, error) {
+			entries, err := os.ReadDir(chooseNonEmpty(stringArg(args, "path"), "."))
+			if err != nil {
+				return "", err
+			}
+			var b strings.Builder
+			for _, e := range entries {
+				name := e.Name()
+				if e.IsDir() {
+					name += "/"
+				}
+				b.WriteString(name + "\n")
+			}
+			return b.String(), nil
+		},
+	},
+	"http_get": {
+		description: "Faz um GET HTTP e devolve o status e o corpo da resposta.",
+		params:      objectSchema(map[string]string{"url": "URL http(s)"}),
+		readOnly:    true,
+		run: func(ctx context.Context, args map[string]any) (string, error) {
+			ctx, cancel := context.WithTimeout(ctx, 20*time.Second)
+			defer cancel()
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, stringArg(args, "url"), nil)
+			if err != nil {
+				return "", err
+			}
+			hc, err := sharedHTTPClient("")
+			if err != nil {
+				return "", err
+			}
+			resp, err := hc.Do(req)
+			if err != nil {
+				return "", err
+			}
+			defer resp.Body.Close()
+			body, err := io.ReadAll(io.LimitReader(resp.Body, maxToolOutput+1))
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("HTTP %d\n\n%s", resp.StatusCode, body), nil
+		},
+	},
+	"file_search": {
+		description: "Busca trechos relevantes nos documentos dos vector stores do profile.",
+		params:      objectSchema(map[string]string{"query": "o que procurar"}),
+		readOnly:    true,
+		run: func(ctx context.Context, args map[string]any) (string, error) {
+			return runFileSearch(ctx, stringArg(args, "query"))
+		},
+	},
+	"run_code": {
+		description: "Executa um programa curto em Python ou Go e devolve a saída. Use para contas, estatísticas " +
+			"e transformações de dados em vez de calcular de cabeça; imprima o resultado.",
+		params: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"language": map[string]any{"type": "string", "enum": []string{"python", "go"}},
+				"code":     map[string]any{"type": "string", "description": "código completo; em Go, um package main"},
+			},
+			"required": []string{"code", "language"},
+		},
+		run: func(ctx context.Context, args map[string]any) (string, error) {
+			return runCodeSnippet(ctx, stringArg(args, "language"), stringArg(args, "code"))
+		},
+	},
+	"csv_query": {
+		description: "Filtra, agrupa e agrega o arquivo inteiro do gptcli csv (count, sum, avg, min, max) e devolve a tabela do resultado.",
+		params:      csvQuerySchema,
+		readOnly:    true,
+		run:         runCSVQuery,
+	},
+	"shell": {
+		description: "Executa um comando no shell (sh -c) e devolve a saída combinada.",
+		params:      objectSchema(map[string]string{"command": "comando a executar"}),
+		run: func(ctx context.Context, args map[string]any) (string, error) {
+			return runSandboxedShell(ctx, stringArg(args, "command"))
+		},
+	},
+}
+
+// objectSchema monta um schema de objeto com propriedades string obrigatórias.
+func objectSchema(props map[string]string) map[string]any {
+	properties := map[string]any{}
+	required := []string{}
+	for name, desc := range props {
+		properties[name] = map[string]any{"type": "string", "description": desc}
+		required = append(required, name)
+	}
+	sort.Strings(required)
+	return map[string]any{"type": "object", "properties": properties, "required": required}
+}
+
+func stringArg(args map[string]any, name string) string {
+	s, _ := args[name].(string)
+	return s
+}
+
+func toolNames() []string {
+	names := make([]string, 0, len(builtinTools))
+	for name := range builtinTools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func validateTools(names []string) error {
+	for _, n := range names {
+		if _, ok := builtinTools[n]; !ok {
+			return fmt.Errorf("ferramenta desconhecida %q (disponíveis: %s)", n, strings.Join(toolNames(), ", "))
+		}
+	}
+	return nil
+}
+
+func toolParams(names []string) []openai.ChatCompletionToolUnionParam {
+	var out []openai.ChatCompletionToolUnionParam
+	for _, n := range names {
+		t, ok := builtinTools[n]
+		if !ok {
+			continue
+		}
+		out = append(out, openai.ChatCompletionFunctionTool(shared.FunctionDefinitionParam{
+			Name:        n,
+			Description: openai.String(t.description),
+			Parameters:  shared.FunctionParameters(t.params),
+		}))
+	}
+	return out
+}
+
+func assistantToolCallMessage(t Turn) openai.ChatCompletionMessageParamUnion {
+	msg := openai.ChatCompletionAssistantMessageParam{}
+	if t.Content != "" {
+		msg.Content.OfString = openai.String(t.Content)
+	}
+	for _, c := range t.ToolCalls {
+		msg.ToolCalls = append(msg.ToolCalls, openai.ChatCompletionMessageToolCallUnionParam{
+			OfFunction: &openai.ChatCompletionMessageFunctionToolCallParam{
+				ID: c.ID,
+				Function: openai.ChatCompletionMessageFunctionToolCallFunctionParam{
+					Name: c.Name, Arguments: c.Arguments,
+				},
+			},
+		})
+	}
+	return openai.ChatCompletionMessageParamUnion{OfAssistant: &msg}
+}
+
+// runToolCall executa a ferramenta e devolve o texto que volta para o modelo;
+// erros também voltam como texto, para o modelo poder reagir.
+func runToolCall(ctx context.Context, enabled []string, call ToolCall) string {
+	ctx, span := startSpan(ctx, "gptcli.tool", attr("gen_ai.tool.name", call.Name))
+	result, err := execTool(ctx, enabled, call)
+	span.end(err)
+	if err != nil {
+		result = "erro: " + err.Error()
+	}
+	if len(result) > maxToolOutput {
+		result = result[:maxToolOutput] + "\n[saída truncada]"
+	}
+	toolStatus("  ↳ %s\n", truncate(result, 100))
+	return result
+}
+
+func execTool(ctx context.Context, enabled []string, call ToolCall) (string, error) {
+	t, ok := builtinTools[call.Name]
+	if !ok || !containsString(enabled, call.Name) {
+		return "", fmt.Errorf("ferramenta %q não está habilitada", call.Name)
+	}
+	args := map[string]any{}
+	if strings.TrimSpace(call.Arguments) != "" {
+		if err := json.Unmarshal([]byte(call.Arguments), &args); err != nil {
+			return "", fmt.Errorf("argumentos inválidos: %w", err)
+		}
+	}
+	if err := toolGate.approve(call, t); err != nil {
+		return "", err
+	}
+	return t.run(ctx, args)
+}
+
+// ---------- políticas de aprovação ----------
+
+// toolApproval guarda `tool_policy` do config e --yes; é definido por
+// configureTools assim que as settings são resolvidas.
+type toolApproval struct {
+	policy    map[string]string // ferramenta => auto|confirm|deny
+	assumeYes bool
+	mu        sync.Mutex // uma pergunta por vez no terminal (fan-out, self-consistency)
+}
+
+var toolGate = &toolApproval{}
+
+var toolPolicies = map[string]bool{"auto": true, "confirm": true, "deny": true}
+
+func configureTools(st *settings) {
+	toolGate = &toolApproval{policy: st.toolPolicy, assumeYes: st.assumeYes}
+	shellBox = st.sandbox
+	fileSearch.st, fileSearch.stores = st, st.prof.VectorStores
+}
+
+func validateToolPolicy(policy map[string]string) error {
+	for name, p := range policy {
+		if _, ok := builtinTools[name]; !ok {
+			return fmt.Errorf("tool_policy: ferramenta desconhecida %q", name)
+		}
+		if !toolPolicies[p] {
+			return fmt.Errorf("tool_policy: política inválida %q para %s (use auto|confirm|deny)", p, name)
+		}
+	}
+	return nil
+}
+
+// policyFor: o que estiver no config; sem entrada, ferramentas só de leitura
+// rodam direto e as demais pedem confirmação.
+func (a *toolApproval) policyFor(name string, t tool) string {
+	if p, ok := a.policy[name]; ok {
+		return p
+	}
+	if t.readOnly {
+		return "auto"
+	}
+	return "confirm"
+}
+
+func (a *toolApproval) approve(call ToolCall, t tool) error {
+	switch a.policyFor(call.Name, t) {
+	case "auto":
+		return nil
+	case "deny":
+		return fmt.Errorf("execução de %s negada pela política (tool_policy)", call.Name)
+	}
+	if a.assumeYes {
+		return nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	ok, err := confirmTTY(fmt.Sprintf("Executar %s %s?", call.Name, call.Arguments))
+	if err != nil {
+		return fmt.Errorf("execução de %s requer confirmação, mas não há terminal (use --yes)", call.Name)
+	}
+	if !ok {
+		return fmt.Errorf("execução de %s recusada pelo usuário", call.Name)
+	}
+	return nil
+}
+
+// confirmTTY pergunta s/N no terminal. O stdin pode ser o pipe com o prompt,
+// então a resposta é lida de /dev/tty.
+func confirmTTY(question string) (bool, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return false, err
+	}
+	defer tty.Close()
+	fmt.Fprintf(tty, "%s [s/N] ", question)
+	answer, _ := bufio.NewReader(tty).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "s", "sim", "y", "yes":
+		return true, nil
+	}
+	return false, nil
+}
+
+// ---------- exibição dos tool calls durante o stream ----------
+
+// toolCallBuilder junta os deltas dos tool calls e os mostra no stderr
+// conforme chegam: nome da ferramenta e os argumentos sendo montados.
+type toolCallBuilder struct {
+	calls []ToolCall
+	open  bool
+}
+
+func (b *toolCallBuilder) add(d openai.ChatCompletionChunkChoiceDeltaToolCall) {
+	i := int(d.Index)
+	if i >= len(b.calls) {
+		b.finish()
+		b.calls = append(b.calls, make([]ToolCall, i+1-len(b.calls))...)
+	}
+	c := &b.calls[i]
+	if d.ID != "" {
+		c.ID = d.ID
+	}
+	if d.Function.Name != "" {
+		c.Name += d.Function.Name
+		toolStatus("\n⚙ %s ", d.Function.Name)
+		b.open = true
+	}
+	if d.Function.Arguments != "" {
+		c.Arguments += d.Function.Arguments
+		toolStatus("%s", d.Function.Arguments)
+	}
+}
+
+func (b *toolCallBuilder) finish() {
+	if b.open {
+		toolStatus("\n")
+		b.open = false
+	}
+}
+
+// toolStatus escreve no stderr, esmaecido quando é um terminal.
+func toolStatus(format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	if st, err := os.Stderr.Stat(); err == nil && st.Mode()&os.ModeCharDevice != 0 && os.Getenv("NO_COLOR") == "" {
+		msg = "\033[2m" + msg + "\033[0m"
+	}
+	fmt.Fprint(os.Stderr, msg)
+}
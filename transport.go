@@ -0,0 +1,130 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// ===================== HTTP transport =====================
+//
+// Todas as conexões HTTP (API, download de imagens, /web, http_get, doctor,
+// telemetria) saem de um mesmo *http.Client por proxy, com keep-alive, HTTP/2
+// e timeouts de conexão. Assim um REPL longo reaproveita a conexão TLS com a
+// API em vez de abrir uma por turno. A seção `transport` do config ajusta os
+// valores.
+
+type TransportConfig struct {
+	MaxIdleConns        int    `yaml:"max_idle_conns,omitempty"`          // default 100
+	MaxIdleConnsPerHost int    `yaml:"max_idle_conns_per_host,omitempty"` // default 10
+	IdleTimeout         string `yaml:"idle_timeout,omitempty"`            // conexão ociosa é fechada depois disso (default 90s)
+	DialTimeout         string `yaml:"dial_timeout,omitempty"`            // default 10s
+	TLSTimeout          string `yaml:"tls_timeout,omitempty"`             // handshake TLS (default 10s)
+	KeepAlive           string `yaml:"keepalive,omitempty"`               // intervalo do keep-alive TCP (default 30s; 0 desliga o reuso)
+	DisableHTTP2        bool   `yaml:"disable_http2,omitempty"`           // força HTTP/1.1 (proxies que não falam h2)
+}
+
+// transportConfig é definido por resolveSettings a partir do config; vale
+// para as conexões fora do cliente da API (downloads, /web, telemetria).
+var transportConfig TransportConfig
+
+// httpClientKey separa os clientes por configuração e proxy: um cliente
+// criado antes de o config ser lido não fica preso aos defaults.
+type httpClientKey struct {
+	cfg   TransportConfig
+	proxy string // "" = ambiente
+}
+
+var (
+	httpClientsMu sync.Mutex
+	httpClients   = map[httpClientKey]*http.Client{}
+)
+
+func (c TransportConfig) validate() error {
+	for _, d := range []struct{ field, v string }{
+		{"idle_timeout", c.IdleTimeout}, {"dial_timeout", c.DialTimeout},
+		{"tls_timeout", c.TLSTimeout}, {"keepalive", c.KeepAlive},
+	} {
+		if d.v == "" {
+			continue
+		}
+		if v, err := time.ParseDuration(d.v); err != nil || v < 0 {
+			return fmt.Errorf("transport.%s inválido %q (ex: 30s)", d.field, d.v)
+		}
+	}
+	if c.MaxIdleConns < 0 || c.MaxIdleConnsPerHost < 0 {
+		return fmt.Errorf("transport: max_idle_conns e max_idle_conns_per_host não podem ser negativos")
+	}
+	return nil
+}
+
+func durationOr(s string, def time.Duration) time.Duration {
+	if d, err := time.ParseDuration(s); err == nil {
+		return d
+	}
+	return def
+}
+
+func (c TransportConfig) transport(proxy *url.URL) *http.Transport {
+	keepAlive := durationOr(c.KeepAlive, 30*time.Second)
+	dialer := &net.Dialer{Timeout: durationOr(c.DialTimeout, 10*time.Second), KeepAlive: keepAlive}
+	tr := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     !c.DisableHTTP2,
+		MaxIdleConns:          chooseInt(c.MaxIdleConns, 100),
+		MaxIdleConnsPerHost:   chooseInt(c.MaxIdleConnsPerHost, 10),
+		IdleConnTimeout:       durationOr(c.IdleTimeout, 90*time.Second),
+		TLSHandshakeTimeout:   durationOr(c.TLSTimeout, 10*time.Second),
+		ExpectContinueTimeout: time.Second,
+	}
+	if proxy != nil {
+		tr.Proxy = http.ProxyURL(proxy)
+	}
+	if keepAlive == 0 {
+		dialer.KeepAlive = -1
+		tr.DisableKeepAlives = true
+	}
+	if c.DisableHTTP2 {
+		tr.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{} // mapa vazio desliga o h2
+	}
+	return tr
+}
+
+func chooseInt(v, def int) int {
+	if v > 0 {
+		return v
+	}
+	return def
+}
+
+// sharedHTTPClient devolve o cliente compartilhado do proxy (vazio: o do
+// ambiente, HTTPS_PROXY e afins) com a seção transport do config.
+func sharedHTTPClient(proxy string) (*http.Client, error) {
+	return transportConfig.client(proxy)
+}
+
+// client devolve o cliente compartilhado para c e o proxy. Sem timeout
+// total: streams podem ser longos; quem precisa de prazo usa o contexto.
+func (c TransportConfig) client(proxy string) (*http.Client, error) {
+	key := httpClientKey{c, proxy}
+	httpClientsMu.Lock()
+	defer httpClientsMu.Unlock()
+	if hc, ok := httpClients[key]; ok {
+		return hc, nil
+	}
+	var u *url.URL
+	if proxy != "" {
+		var err error
+		if u, err = url.Parse(proxy); err != nil {
+			return nil, err
+		}
+	}
+	hc := &http.Client{Transport: c.transport(u)}
+	httpClients[key] = hc
+	return hc, nil
+}
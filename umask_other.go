@@ -0,0 +1,6 @@
+//go:build !unix
+
+package main
+
+// withUmask só roda fn: fora do Unix não há umask.
+func withUmask(_ int, fn func() error) error { return fn() }
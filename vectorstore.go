@@ -0,0 +1,338 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	openai "github.com/openai/openai-go/v2"
+)
+
+// ===================== Vector stores =====================
+//
+// `gptcli vectorstore` organiza os vector stores hospedados no provedor: cria
+// com um nome, sobe arquivos, lista e apaga. O profile cita os stores pelo
+// nome (`vector_stores: [manuais]`) e ganha a ferramenta file_search, que
+// busca trechos neles pela API de busca de vector stores.
+
+const vectorstoreUsage = `uso: gptcli vectorstore <create|add|list|delete> [flags] [args]
+  create <nome> [arquivo...]     cria o vector store (e sobe os arquivos, se houver)
+  add <store> <arquivo|file-id>  sobe e indexa arquivos num vector store
+  list [store]                   vector stores (com store: os arquivos dele)
+  delete <store> [file-id...]    apaga o vector store, ou só tira os arquivos dele
+
+<store> é o nome ou o id (vs_...).`
+
+func vectorstoreCmd(args []string) error {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, vectorstoreUsage)
+		os.Exit(2)
+	}
+	switch args[0] {
+	case "create":
+		return vectorstoreCreate(args[1:])
+	case "add":
+		return vectorstoreAdd(args[1:])
+	case "list":
+		return vectorstoreList(args[1:])
+	case "delete":
+		return vectorstoreDelete(args[1:])
+	default:
+		fmt.Fprintln(os.Stderr, vectorstoreUsage)
+		os.Exit(2)
+	}
+	return nil
+}
+
+func vectorstoreCreate(args []string) error {
+	fs := flag.NewFlagSet("vectorstore create", flag.ExitOnError)
+	flags := commonFlags(fs)
+	expire := fs.Int("expire-days", 0, "apaga o store depois de N dias sem uso (0 = nunca)")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "uso: gptcli vectorstore create [--expire-days N] <nome> [arquivo|file-id...]")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() < 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	name := fs.Arg(0)
+	if strings.HasPrefix(name, "vs_") {
+		return fmt.Errorf("nome %q parece um id; escolha outro", name)
+	}
+	_, client, err := resolveClient(flags)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	if ids, err := findVectorStores(ctx, client, name); err != nil {
+		return err
+	} else if len(ids) > 0 {
+		return fmt.Errorf("já existe um vector store %q (%s)", name, ids[0])
+	}
+	params := openai.VectorStoreNewParams{Name: openai.String(name)}
+	if *expire > 0 {
+		params.ExpiresAfter = openai.VectorStoreNewParamsExpiresAfter{Days: int64(*expire)}
+	}
+	vs, err := client.VectorStores.New(ctx, params)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "(vector store %s criado: %s)\n", name, vs.ID)
+	fmt.Println(vs.ID)
+	if fs.NArg() > 1 {
+		return addVectorStoreFiles(ctx, client, vs.ID, fs.Args()[1:])
+	}
+	return nil
+}
+
+func vectorstoreAdd(args []string) error {
+	fs := flag.NewFlagSet("vectorstore add", flag.ExitOnError)
+	flags := commonFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "uso: gptcli vectorstore add <store> <arquivo|file-id...>")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() < 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	_, client, err := resolveClient(flags)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	id, err := resolveVectorStore(ctx, client, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	return addVectorStoreFiles(ctx, client, id, fs.Args()[1:])
+}
+
+// addVectorStoreFiles sobe os arquivos locais (ids file-... passam direto),
+// anexa tudo num lote e espera a indexação terminar.
+func addVectorStoreFiles(ctx context.Context, client openai.Client, storeID string, paths []string) error {
+	var ids []string
+	for _, p := range paths {
+		if _, err := os.Stat(p); err != nil {
+			if strings.HasPrefix(p, "file-") {
+				ids = append(ids, p)
+				continue
+			}
+			return err
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		file, err := client.Files.New(ctx, openai.FileNewParams{
+			File:    openai.File(f, filepath.Base(p), ""),
+			Purpose: openai.FilePurposeAssistants,
+		})
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("upload de %s: %w", p, err)
+		}
+		fmt.Fprintf(os.Stderr, "(%s enviado: %s)\n", p, file.ID)
+		ids = append(ids, file.ID)
+	}
+	batch, err := client.VectorStores.FileBatches.New(ctx, storeID, openai.VectorStoreFileBatchNewParams{FileIDs: ids})
+	if err != nil {
+		return err
+	}
+	for batch.Status == openai.VectorStoreFileBatchStatusInProgress {
+		time.Sleep(time.Second)
+		if batch, err = client.VectorStores.FileBatches.Get(ctx, storeID, batch.ID); err != nil {
+			return err
+		}
+	}
+	c := batch.FileCounts
+	fmt.Fprintf(os.Stderr, "(%d arquivo(s) indexado(s), %d com falha)\n", c.Completed, c.Failed)
+	if c.Failed > 0 || batch.Status != openai.VectorStoreFileBatchStatusCompleted {
+		return fmt.Errorf("lote %s terminou como %s; veja: gptcli vectorstore list %s", batch.ID, batch.Status, storeID)
+	}
+	return nil
+}
+
+func vectorstoreList(args []string) error {
+	fs := flag.NewFlagSet("vectorstore list", flag.ExitOnError)
+	flags := commonFlags(fs)
+	_ = fs.Parse(args)
+	_, client, err := resolveClient(flags)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	if fs.NArg() == 0 {
+		fmt.Fprintln(tw, "ID\tNOME\tARQUIVOS\tTAMANHO\tSTATUS\tCRIADO")
+		iter := client.VectorStores.ListAutoPaging(ctx, openai.VectorStoreListParams{Limit: openai.Int(100)})
+		for iter.Next() {
+			vs := iter.Current()
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\t%s\n", vs.ID, chooseNonEmpty(vs.Name, "-"), vs.FileCounts.Total,
+				humanBytes(vs.UsageBytes), vs.Status, time.Unix(vs.CreatedAt, 0).Local().Format("2006-01-02 15:04"))
+		}
+		if err := iter.Err(); err != nil {
+			return err
+		}
+		return tw.Flush()
+	}
+
+	id, err := resolveVectorStore(ctx, client, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(tw, "ARQUIVO\tNOME\tTAMANHO\tSTATUS")
+	iter := client.VectorStores.Files.ListAutoPaging(ctx, id, openai.VectorStoreFileListParams{Limit: openai.Int(100)})
+	for iter.Next() {
+		f := iter.Current()
+		name := "-"
+		if info, err := client.Files.Get(ctx, f.ID); err == nil {
+			name = info.Filename
+		}
+		status := string(f.Status)
+		if f.LastError.Message != "" {
+			status += ": " + f.LastError.Message
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", f.ID, name, humanBytes(f.UsageBytes), status)
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+	return tw.Flush()
+}
+
+func vectorstoreDelete(args []string) error {
+	fs := flag.NewFlagSet("vectorstore delete", flag.ExitOnError)
+	flags := commonFlags(fs)
+	_ = fs.Parse(args)
+	if fs.NArg() < 1 {
+		return errors.New("uso: gptcli vectorstore delete <store> [file-id...]")
+	}
+	_, client, err := resolveClient(flags)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	id, err := resolveVectorStore(ctx, client, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if fs.NArg() == 1 {
+		if _, err := client.VectorStores.Delete(ctx, id); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "(vector store %s apagado; os arquivos continuam em /v1/files)\n", id)
+		return nil
+	}
+	for _, fileID := range fs.Args()[1:] {
+		if _, err := client.VectorStores.Files.Delete(ctx, id, fileID); err != nil {
+			return fmt.Errorf("%s: %w", fileID, err)
+		}
+		fmt.Fprintf(os.Stderr, "(%s removido de %s)\n", fileID, id)
+	}
+	return nil
+}
+
+// resolveVectorStore aceita um id (vs_...) ou o nome de um store existente.
+func resolveVectorStore(ctx context.Context, client openai.Client, ref string) (string, error) {
+	if strings.HasPrefix(ref, "vs_") {
+		return ref, nil
+	}
+	ids, err := findVectorStores(ctx, client, ref)
+	if err != nil {
+		return "", err
+	}
+	switch len(ids) {
+	case 0:
+		return "", fmt.Errorf("vector store %q não encontrado (veja: gptcli vectorstore list)", ref)
+	case 1:
+		return ids[0], nil
+	}
+	return "", fmt.Errorf("há %d vector stores chamados %q (%s); use o id", len(ids), ref, strings.Join(ids, ", "))
+}
+
+func findVectorStores(ctx context.Context, client openai.Client, name string) ([]string, error) {
+	var ids []string
+	iter := client.VectorStores.ListAutoPaging(ctx, openai.VectorStoreListParams{Limit: openai.Int(100)})
+	for iter.Next() {
+		if vs := iter.Current(); vs.Name == name {
+			ids = append(ids, vs.ID)
+		}
+	}
+	return ids, iter.Err()
+}
+
+func humanBytes(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", n)
+}
+
+// ---------- ferramenta file_search ----------
+
+// fileSearch é definido por configureTools: a conexão e os stores do profile.
+var fileSearch struct {
+	st     *settings
+	stores []string
+}
+
+// fileSearchMaxResults limita os trechos devolvidos ao modelo por busca.
+const fileSearchMaxResults = 8
+
+func runFileSearch(ctx context.Context, query string) (string, error) {
+	if len(fileSearch.stores) == 0 {
+		return "", errors.New("nenhum vector store no profile (vector_stores: [nome])")
+	}
+	if strings.TrimSpace(query) == "" {
+		return "", errors.New("consulta vazia")
+	}
+	client, err := buildClient(fileSearch.st)
+	if err != nil {
+		return "", err
+	}
+	var hits []openai.VectorStoreSearchResponse
+	for _, ref := range fileSearch.stores {
+		id, err := resolveVectorStore(ctx, client, ref)
+		if err != nil {
+			return "", err
+		}
+		page, err := client.VectorStores.Search(ctx, id, openai.VectorStoreSearchParams{
+			Query:         openai.VectorStoreSearchParamsQueryUnion{OfString: openai.String(query)},
+			MaxNumResults: openai.Int(fileSearchMaxResults),
+		})
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", ref, err)
+		}
+		hits = append(hits, page.Data...)
+	}
+	if len(hits) == 0 {
+		return "nenhum trecho encontrado", nil
+	}
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].Score > hits[j].Score })
+	if len(hits) > fileSearchMaxResults {
+		hits = hits[:fileSearchMaxResults]
+	}
+	var b strings.Builder
+	for _, h := range hits {
+		fmt.Fprintf(&b, "[%s, relevância %.2f]\n", h.Filename, h.Score)
+		for _, c := range h.Content {
+			b.WriteString(c.Text + "\n")
+		}
+		b.WriteString("\n")
+	}
+	return b.String(), nil
+}
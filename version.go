@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	openai "github.com/openai/openai-go/v2"
+)
+
+// ===================== Version =====================
+//
+// `gptcli version` mostra versão, commit e Go; com --check faz uma chamada
+// autenticada barata (lista de modelos) para confirmar que chave, base_url
+// e proxy do profile funcionam.
+
+// version e commit vêm do build: go build -ldflags "-X main.version=v1.2.3 -X main.commit=abc1234".
+var (
+	version = "dev"
+	commit  = ""
+)
+
+// buildVersion completa version/commit com o que o toolchain gravou no binário
+// (go install ...@vX, ou build dentro do repositório git).
+func buildVersion() (ver, rev, when string, dirty bool) {
+	ver, rev = version, commit
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ver, rev, "", false
+	}
+	if ver == "dev" && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		ver = info.Main.Version
+	}
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			if rev == "" {
+				rev = s.Value
+			}
+		case "vcs.time":
+			when = s.Value
+		case "vcs.modified":
+			dirty = s.Value == "true"
+		}
+	}
+	if len(rev) > 12 {
+		rev = rev[:12]
+	}
+	return ver, rev, when, dirty
+}
+
+func versionCmd(args []string) error {
+	fs := flag.NewFlagSet("version", flag.ExitOnError)
+	flags := commonFlags(fs)
+	check := fs.Bool("check", false, "testa chave, base_url e proxy com uma chamada autenticada (lista de modelos)")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "uso: gptcli version [--check] [--profile p] [--base-url u] [--proxy p]")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	ver, rev, when, dirty := buildVersion()
+	fmt.Println("gptcli", ver)
+	if rev != "" {
+		if dirty {
+			rev += " (modificado)"
+		}
+		fmt.Println("commit:", rev)
+	}
+	if when != "" {
+		fmt.Println("data:  ", when)
+	}
+	fmt.Printf("go:     %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	if !*check {
+		return nil
+	}
+
+	fmt.Println()
+	cfg, _ := loadConfig()
+	st, err := resolveSettings(cfg, flags)
+	if err != nil {
+		return err
+	}
+	fmt.Println("profile: ", chooseNonEmpty(st.profName, "(nenhum)"))
+	fmt.Println("endpoint:", chooseNonEmpty(st.baseURL, "https://api.openai.com/v1"))
+	if st.proxy != "" {
+		fmt.Println("proxy:   ", redactURL(st.proxy))
+	}
+	fmt.Println("chave:   ", maskKey(st.apiKey))
+	client, err := buildClient(st)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	start := time.Now()
+	page, err := client.Models.List(ctx)
+	elapsed := time.Since(start).Round(time.Millisecond)
+	if err != nil {
+		fmt.Println("status:   FALHOU -", describeCheckError(err))
+		os.Exit(1)
+	}
+	fmt.Printf("status:   ok (%d modelo(s), %s)\n", len(page.Data), elapsed)
+	found := false
+	for _, m := range page.Data {
+		if m.ID == st.model {
+			found = true
+			break
+		}
+	}
+	if !found && len(page.Data) > 0 {
+		fmt.Printf("aviso:    o modelo %q não aparece na lista do endpoint\n", st.model)
+	}
+	return nil
+}
+
+// describeCheckError traduz as falhas mais comuns em uma dica.
+func describeCheckError(err error) string {
+	var apiErr *openai.Error
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case 401:
+			return "HTTP 401: chave inválida ou revogada"
+		case 403:
+			return "HTTP 403: a chave não tem acesso a este endpoint (projeto/organização?)"
+		case 404:
+			return "HTTP 404: base_url não parece uma API compatível com a OpenAI (falta /v1?)"
+		case 429:
+			return "HTTP 429: limite de uso ou cota esgotada"
+		}
+		return fmt.Sprintf("HTTP %d: %s", apiErr.StatusCode, apiErr.Message)
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return "tempo esgotado (endpoint ou proxy não respondem)"
+	}
+	if isNetworkError(err) {
+		return "sem conexão: " + err.Error()
+	}
+	return err.Error()
+}
+
+// maskKey mostra só o começo e o fim da chave.
+func maskKey(key string) string {
+	if len(key) <= 10 {
+		return "***"
+	}
+	return key[:3] + "…" + key[len(key)-4:]
+}
+
+// redactURL esconde usuário e senha de URLs de proxy.
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.User == nil {
+		return raw
+	}
+	u.User = nil
+	return strings.Replace(u.String(), "://", "://***@", 1)
+}
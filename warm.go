@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/openai/openai-go/v2"
+)
+
+// ===================== Aquecimento =====================
+//
+// --warm (ou warm: true no profile) faz uma chamada mínima em segundo plano ao
+// abrir o REPL ou o gptcli web: DNS, TLS e, com system, o cache de prefixo do
+// provedor ficam prontos antes do primeiro prompt. No daemon, keepalive (raiz
+// do config ou --keepalive) repete o ping para as conexões não esfriarem.
+
+const warmTimeout = 15 * time.Second
+
+// warmUp faz a chamada de aquecimento. Com system, é um chat de 1 token que
+// começa pelo mesmo prefixo dos prompts reais; sem system, basta listar os
+// modelos, o que abre a conexão sem gastar tokens.
+func warmUp(ctx context.Context, client openai.Client, model, system string) error {
+	ctx, cancel := context.WithTimeout(ctx, warmTimeout)
+	defer cancel()
+	if system == "" {
+		_, err := client.Models.List(ctx)
+		return err
+	}
+	_, err := client.Chat.Completions.New(ctx, openai.ChatCompletionNewParams{
+		Model: openai.ChatModel(model),
+		Messages: []openai.ChatCompletionMessageParamUnion{
+			openai.SystemMessage(system),
+			openai.UserMessage("ok"),
+		},
+		MaxTokens: openai.Int(1),
+	})
+	return err
+}
+
+// warmInBackground dispara warmUp sem bloquear. O resultado vai só para o log
+// operacional (mode "warm"): se a API estiver fora, o primeiro prompt dá o
+// mesmo erro com mais contexto.
+func warmInBackground(ctx context.Context, client openai.Client, st *settings) {
+	if !st.warm || daemonTarget != nil { // o daemon já mantém as conexões quentes
+		return
+	}
+	go func() {
+		e := &logEntry{Mode: "warm", Model: st.model, started: time.Now()}
+		appLog.write(e, warmUp(ctx, client, st.model, st.system))
+	}()
+}
+
+// keepaliveInterval lê o keepalive do config; vazio é 0 (sem ping periódico).
+func keepaliveInterval(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("keepalive inválido %q (ex: 30s)", s)
+	}
+	return d, nil
+}
+
+// keepalive repete o ping de conexão em todos os clientes do daemon a cada
+// intervalo, até ctx terminar.
+func (d *daemonServer) keepalive(ctx context.Context, every time.Duration) {
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		d.mu.Lock()
+		clients := make([]openai.Client, 0, len(d.clients))
+		for _, c := range d.clients {
+			clients = append(clients, c)
+		}
+		d.mu.Unlock()
+		for _, c := range clients {
+			if err := warmUp(ctx, c, "", ""); err != nil && ctx.Err() == nil {
+				fmt.Fprintln(os.Stderr, "keepalive:", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	openai "github.com/openai/openai-go/v2"
+)
+
+// ===================== Web UI =====================
+
+//go:embed assets/web.html
+var webPage []byte
+
+// commonFlags registra num FlagSet de subcomando as flags de conexão/modelo
+// que também existem no modo principal.
+func commonFlags(fs *flag.FlagSet) *Flags {
+	f := &Flags{}
+	fs.StringVar(&f.APIKey, "api-key", "", "OpenAI API key (ou use OPENAI_API_KEY)")
+	fs.StringVar(&f.Model, "model", "", "modelo. Default: o do profile/persona ou gpt-5-mini")
+	systemFlags(fs, &f.System)
+	fs.Float64Var(&f.Temp, "temp", -1, "temperature (0-2). Omitido = default do modelo")
+	fs.StringVar(&f.BaseURL, "base-url", "", "Base URL customizada (opcional)")
+	fs.StringVar(&f.Proxy, "proxy", "", "HTTP(S) proxy")
+	fs.StringVar(&f.Provider, "provider", "", "provedor: openrouter, xai, mistral, deepseek, groq ou bedrock")
+	fs.StringVar(&f.Profile, "profile", "", "nome do profile do config.yaml")
+	fs.StringVar(&f.Persona, "persona", "", "nome da persona do config.yaml")
+	fs.StringVar(&f.Persona, "P", "", "atalho para --persona")
+	fs.Int64Var(&f.MaxTokens, "max-tokens", 0, "limite de tokens da resposta (0 = auto)")
+	fs.Var(&f.Tools, "tool", "habilita uma ferramenta para o modelo (repetível)")
+	fs.BoolVar(&f.Yes, "yes", false, "aprova sem perguntar as ferramentas com política confirm")
+	fs.BoolVar(&f.ShowRequestID, "show-request-id", false, "mostra no stderr o x-request-id de cada chamada")
+	fs.BoolVar(&f.ShowUsage, "show-usage", false, "mostra no stderr os tokens e o finish_reason de cada operação")
+	fs.BoolVar(&f.Ephemeral, "ephemeral", false, "não grava nada em disco: sessões, histórico e log (ou GPTCLI_EPHEMERAL=1)")
+	retryFlags(fs, &f.Retry)
+	return f
+}
+
+type webServer struct {
+	st     *settings
+	client openai.Client
+}
+
+type webChatRequest struct {
+	Messages []struct {
+		Role    string `json:"role"`
+		Content string `json:"content"`
+	} `json:"messages"`
+}
+
+func webCmd(args []string) error {
+	fs := flag.NewFlagSet("web", flag.ExitOnError)
+	listen := fs.String("listen", "127.0.0.1:7777", "endereço para escutar")
+	flags := commonFlags(fs)
+	fs.BoolVar(&flags.Warm, "warm", false, "aquece a conexão (e o cache do system) antes da primeira pergunta")
+	_ = fs.Parse(args)
+
+	cfg, _ := loadConfig()
+	st, err := resolveSettings(cfg, flags)
+	if err != nil {
+		return err
+	}
+	initAppLog(st.logLevel, st.profName, st.personaName)
+	configureTools(st)
+	client, err := buildClient(st)
+	if err != nil {
+		return err
+	}
+
+	if host, _, err := net.SplitHostPort(*listen); err == nil {
+		if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+			fmt.Fprintln(os.Stderr, "aviso: escutando fora do loopback; qualquer um na rede poderá usar a sua chave")
+		}
+	}
+
+	warmInBackground(context.Background(), client, st)
+	w := &webServer{st: st, client: client}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = rw.Write(webPage)
+	})
+	mux.HandleFunc("GET /api/info", w.info)
+	mux.HandleFunc("POST /api/chat", w.chat)
+	enableMetrics()
+	mux.HandleFunc("GET /metrics", promStats.handler)
+
+	fmt.Fprintf(os.Stderr, "gptcli web • model=%s • http://%s\n", st.model, *listen)
+	return http.ListenAndServe(*listen, mux)
+}
+
+func (w *webServer) info(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(rw).Encode(map[string]string{
+		"model": w.st.model, "profile": w.st.profName, "persona": w.st.personaName,
+	})
+}
+
+// sameOriginJSON barra pedidos que outro site aberto no navegador pode
+// forjar contra a porta local: um form só manda text/plain, urlencoded ou
+// multipart, e um fetch de outra origem traz o Origin dela.
+func sameOriginJSON(r *http.Request) (int, error) {
+	if mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mt != "application/json" {
+		return http.StatusUnsupportedMediaType, errors.New("Content-Type deve ser application/json")
+	}
+	if origin := r.Header.Get("Origin"); origin != "" {
+		u, err := url.Parse(origin)
+		if err != nil || u.Host != r.Host {
+			return http.StatusForbidden, fmt.Errorf("origem %q não é a desta página", origin)
+		}
+	}
+	return 0, nil
+}
+
+func (w *webServer) chat(rw http.ResponseWriter, r *http.Request) {
+	if code, err := sameOriginJSON(r); err != nil {
+		http.Error(rw, err.Error(), code)
+		return
+	}
+	var req webChatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(rw, "json inválido: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	sess := &Session{Format: w.st.format, Examples: w.st.persona.exampleTurns(), Tools: w.st.tools, Context: w.st.prof.Context}
+	sess.addSystem(w.st.system)
+	for _, m := range req.Messages {
+		if m.Role != "user" && m.Role != "assistant" {
+			http.Error(rw, "role inválido: "+m.Role, http.StatusBadRequest)
+			return
+		}
+		sess.Turns = append(sess.Turns, Turn{Role: m.Role, Content: m.Content})
+	}
+	if len(sess.Turns) == 0 || sess.Turns[len(sess.Turns)-1].Role != "user" {
+		http.Error(rw, "a última mensagem deve ser do usuário", http.StatusBadRequest)
+		return
+	}
+
+	flusher, ok := rw.(http.Flusher)
+	if !ok {
+		http.Error(rw, "streaming não suportado", http.StatusInternalServerError)
+		return
+	}
+	rw.Header().Set("Content-Type", "text/event-stream")
+	rw.Header().Set("Cache-Control", "no-cache")
+	send := func(v any) {
+		b, _ := json.Marshal(v)
+		fmt.Fprintf(rw, "data: %s\n\n", b)
+		flusher.Flush()
+	}
+
+	err := logOp(withRetryConfig(r.Context(), w.st.retry), "web", w.st.model, func(ctx context.Context) error {
+		_, err := streamChat(ctx, w.client, sess, w.st.model, w.st.temp, w.st.maxTokens,
+			func(delta string) { send(map[string]string{"delta": delta}) })
+		return err
+	})
+	flushTelemetry()
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return
+		}
+		send(map[string]string{"error": err.Error()})
+		return
+	}
+	send(map[string]bool{"done": true})
+	last := sess.Turns[len(sess.Turns)-1].Content
+	saveHistory("WEB: " + strings.TrimSpace(last))
+}
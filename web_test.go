@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWebChatRejectsCrossSite(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		origin      string
+		want        int
+	}{
+		{name: "form de outro site", contentType: "application/x-www-form-urlencoded", want: http.StatusUnsupportedMediaType},
+		{name: "text/plain", contentType: "text/plain", origin: "http://127.0.0.1:7777", want: http.StatusUnsupportedMediaType},
+		{name: "sem Content-Type", want: http.StatusUnsupportedMediaType},
+		{name: "fetch de outra origem", contentType: "application/json", origin: "https://evil.example", want: http.StatusForbidden},
+		{name: "outra porta do localhost", contentType: "application/json", origin: "http://127.0.0.1:8080", want: http.StatusForbidden},
+		{name: "origem inválida", contentType: "application/json", origin: "::", want: http.StatusForbidden},
+		{name: "mesma origem", contentType: "application/json; charset=utf-8", origin: "http://127.0.0.1:7777", want: http.StatusBadRequest},
+		{name: "sem Origin", contentType: "application/json", want: http.StatusBadRequest},
+	}
+	w := &webServer{st: &settings{}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// corpo sem mensagens: quem passa pela checagem para no 400
+			r := httptest.NewRequest(http.MethodPost, "http://127.0.0.1:7777/api/chat", strings.NewReader(`{"messages":[]}`))
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			rec := httptest.NewRecorder()
+			w.chat(rec, r)
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, queria %d (%s)", rec.Code, tt.want, rec.Body.String())
+			}
+		})
+	}
+}
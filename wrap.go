@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ===================== Quebra de linha =====================
+//
+// --wrap auto|<colunas>|off quebra o texto do stream na largura indicada,
+// sem mexer em blocos de código. `auto` usa a largura do terminal e não
+// quebra nada quando a saída é redirecionada.
+
+// outputWidth é definido por configureOutput; 0 = sem quebra.
+var outputWidth int
+
+// streamOut recebe os tokens ao vivo. Com --stream-to stderr o progresso vai
+// para o stderr e o stdout fica só com a resposta final (bom para $(...)).
+var streamOut = os.Stdout
+
+// parseWrap resolve a largura; `auto` olha o terminal de destino do stream.
+func parseWrap(v string, toStderr bool) (int, error) {
+	switch strings.ToLower(strings.TrimSpace(v)) {
+	case "", "off":
+		return 0, nil
+	case "auto":
+		out := os.Stdout
+		if toStderr {
+			out = os.Stderr
+		}
+		if st, err := out.Stat(); err != nil || st.Mode()&os.ModeCharDevice == 0 {
+			return 0, nil
+		}
+		return terminalWidth(), nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 20 {
+		return 0, fmt.Errorf("--wrap inválido %q (use auto, off ou um número de colunas >= 20)", v)
+	}
+	return n, nil
+}
+
+// terminalWidth pergunta ao stty; sem resposta, usa $COLUMNS ou 80.
+func terminalWidth() int {
+	if tty, err := os.Open("/dev/tty"); err == nil {
+		defer tty.Close()
+		cmd := exec.Command("stty", "size")
+		cmd.Stdin = tty
+		if out, err := cmd.Output(); err == nil {
+			if f := strings.Fields(string(out)); len(f) == 2 {
+				if n, err := strconv.Atoi(f[1]); err == nil && n > 0 {
+					return n
+				}
+			}
+		}
+	}
+	if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 0 {
+		return n
+	}
+	return 80
+}
+
+func configureOutput(st *settings) {
+	outputWidth = st.wrapWidth
+	citationsMode = st.citations
+	outputChunk = st.chunk
+	if st.chunk > 0 || isChatFormat(st.format) {
+		outputChunkDelim = st.chunkDelim
+	}
+	if st.streamToStderr {
+		streamOut = os.Stderr
+	}
+}
+
+// wrapWriter quebra o texto palavra a palavra conforme ele chega. Cada
+// palavra fica no buffer até o próximo espaço; linhas dentro de ``` passam
+// intactas. A continuação de uma linha quebrada mantém o recuo original.
+type wrapWriter struct {
+	w       io.Writer
+	width   int
+	col     int    // colunas já escritas na linha atual
+	indent  string // recuo da linha atual
+	word    strings.Builder
+	space   string // espaços pendentes entre a última palavra e a próxima
+	atStart bool   // ainda no recuo do início da linha
+	inFence bool
+}
+
+func newWrapWriter(w io.Writer, width int) *wrapWriter {
+	return &wrapWriter{w: w, width: width, atStart: true}
+}
+
+func (ww *wrapWriter) WriteString(s string) {
+	if ww.width <= 0 {
+		fmt.Fprint(ww.w, s)
+		return
+	}
+	for _, r := range s {
+		switch {
+		case r == '\n':
+			ww.flushWord()
+			fmt.Fprint(ww.w, "\n")
+			ww.col, ww.indent, ww.space, ww.atStart = 0, "", "", true
+		case r == ' ' || r == '\t':
+			if ww.atStart {
+				ww.indent += string(r)
+				continue
+			}
+			ww.flushWord()
+			ww.space += string(r)
+		default:
+			if ww.atStart {
+				fmt.Fprint(ww.w, ww.indent)
+				ww.col = len(ww.indent)
+				ww.atStart = false
+			}
+			ww.word.WriteRune(r)
+		}
+	}
+}
+
+func (ww *wrapWriter) flushWord() {
+	if ww.word.Len() == 0 {
+		return
+	}
+	word := ww.word.String()
+	ww.word.Reset()
+	if ww.col == len(ww.indent) && strings.HasPrefix(word, "```") {
+		ww.inFence = !ww.inFence // abre ou fecha um bloco de código
+	}
+	n := utf8.RuneCountInString(word)
+	if !ww.inFence && ww.col > len(ww.indent) && ww.col+len(ww.space)+n > ww.width {
+		fmt.Fprint(ww.w, "\n"+ww.indent)
+		ww.col, ww.space = len(ww.indent), ""
+	}
+	first := ww.col == len(ww.indent)
+	fmt.Fprint(ww.w, ww.space+word)
+	ww.col += len(ww.space) + n
+	ww.space = ""
+	if first && !ww.inFence && listMarkerRe.MatchString(word) {
+		// item de lista: a continuação alinha com o texto, não com o marcador
+		ww.indent += strings.Repeat(" ", n+1)
+	}
+}
+
+var listMarkerRe = regexp.MustCompile(`^([-*+]|\d+[.)])$`)
+
+// Flush escreve o que ainda está no buffer (fim do stream).
+func (ww *wrapWriter) Flush() {
+	if ww.width <= 0 {
+		return
+	}
+	ww.flushWord()
+	if ww.space != "" {
+		fmt.Fprint(ww.w, ww.space)
+		ww.col += len(ww.space)
+		ww.space = ""
+	}
+}